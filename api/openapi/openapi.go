@@ -0,0 +1,58 @@
+// Package openapi embeds the OpenAPI 3.1 document of the HTTP API of auth-service
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v2"
+)
+
+// Yaml is the OpenAPI document as it is written
+//
+//go:embed openapi.yaml
+var Yaml []byte
+
+// Document parses the OpenAPI document into maps keyed by strings, so that it can be walked or encoded to JSON
+func Document() (map[string]interface{}, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(Yaml, &doc); err != nil {
+		return nil, err
+	}
+
+	m, ok := stringKeys(doc).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("openapi document is not an object")
+	}
+
+	return m, nil
+}
+
+// JSON returns the OpenAPI document encoded to JSON
+func JSON() ([]byte, error) {
+	doc, err := Document()
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(doc)
+}
+
+// stringKeys converts the map[interface{}]interface{} values which are decoded by yaml.v2 to map[string]interface{}
+func stringKeys(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, val := range t {
+			m[fmt.Sprint(k)] = stringKeys(val)
+		}
+		return m
+	case []interface{}:
+		for i, val := range t {
+			t[i] = stringKeys(val)
+		}
+		return t
+	default:
+		return v
+	}
+}
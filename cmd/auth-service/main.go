@@ -1,53 +1,321 @@
 package main
 
 import (
+	"auth-service/internal/audit"
+	"auth-service/internal/auth"
+	"auth-service/internal/ca"
 	"auth-service/internal/database"
+	"auth-service/internal/encryption"
+	"auth-service/internal/health"
+	"auth-service/internal/lifecycle"
 	"auth-service/internal/metrics"
 	"auth-service/internal/options"
+	"auth-service/internal/ratelimit"
+	"auth-service/internal/revocation"
+	"auth-service/internal/risk"
+	"auth-service/internal/rpc"
+	"auth-service/internal/tracing"
 	"auth-service/internal/web"
+	"context"
+	"errors"
+	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+	"github.com/prometheus/client_golang/prometheus"
 	commons "github.com/vpnbeast/golang-commons"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 )
 
-var (
-	db     *gorm.DB
-	logger *zap.Logger
-	opts   *options.AuthServiceOptions
-)
+var logger = commons.GetLogger()
+
+func main() {
+	os.Exit(run())
+}
+
+func run() int {
+	// stdout can not be synced on some platforms, so the error is ignored
+	defer func() {
+		_ = logger.Sync()
+	}()
+
+	opts, err := options.NewAuthServiceOptions()
+	if err != nil {
+		logger.Error("fatal error occurred while initializing options", zap.String("error", err.Error()))
+		return 1
+	}
+
+	// closers are registered as soon as their resources are created, so they are released on an initialization error
+	// as well as on shutdown. They are called in reverse order, so the spans are flushed last
+	manager := lifecycle.NewManager(logger)
+	timeout := seconds(opts.ShutdownTimeoutSeconds, 30)
+	fail := func(component string, err error) int {
+		logger.Error(fmt.Sprintf("fatal error occurred while initializing %s", component),
+			zap.String("error", err.Error()))
+		_ = manager.Close(timeout)
+		return 1
+	}
+
+	provider, err := tracing.Setup(context.Background(), opts)
+	if err != nil {
+		return fail("tracing", err)
+	}
+	if provider != nil {
+		manager.OnShutdown("tracer provider", provider.Shutdown)
+	}
+
+	db, err := database.Open(opts)
+	if err != nil {
+		return fail("database", err)
+	}
+	manager.OnShutdown("database", func(context.Context) error {
+		return database.Close(db)
+	})
+
+	authority, err := newCertificateAuthority(opts)
+	if err != nil {
+		return fail("certificate authority", err)
+	}
+
+	auditor, err := newAuditor(opts, db)
+	if err != nil {
+		return fail("audit log", err)
+	}
+	// audit events are written before the database is closed
+	manager.OnShutdown("audit sinks", auditor.Close)
 
-func init() {
-	db = database.InitDatabase()
+	guard, notifier, err := newLoginGuard(opts, db)
+	if err != nil {
+		return fail("login risk evaluation", err)
+	}
+	manager.OnShutdown("risk notifier", notifier.Close)
+
+	limiter, closeLimiter, err := newLimiter(opts)
+	if err != nil {
+		return fail("rate limiter", err)
+	}
+	manager.OnShutdown("rate limiter", closeLimiter)
+
+	if err := addServers(manager, opts, db, authority, auditor, guard, limiter); err != nil {
+		return fail("web server", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+	if err := manager.Run(ctx, timeout); err != nil {
+		logger.Error("auth-service stopped with error", zap.String("error", err.Error()))
+		return 1
+	}
+
+	logger.Info("auth-service stopped")
+	return 0
+}
+
+// addServers adds the api, grpc, metrics and health servers to the manager, the policy watcher of the web server is
+// stopped first on shutdown
+func addServers(manager *lifecycle.Manager, opts *options.AuthServiceOptions, db *gorm.DB,
+	authority *ca.CertificateAuthority, auditor *audit.Auditor, guard *risk.Guard, limiter *ratelimit.Limiter) error {
 	gin.SetMode(gin.ReleaseMode)
 	// gin.DisableConsoleColor()
-	logger = commons.GetLogger()
-	opts = options.GetAuthServiceOptions()
+	router := gin.Default()
+	// forwarded headers are spoofable by the clients, so they are only honored from the configured proxies
+	if err := router.SetTrustedProxies(trustedProxies(opts.TrustedProxies)); err != nil {
+		return fmt.Errorf("an error occurred while parsing TRUSTED_PROXIES: %w", err)
+	}
+	var traceId metrics.TraceIdFunc
+	if opts.MetricsExemplarsEnabled {
+		traceId = tracing.TraceId
+	}
+	// tracing middleware must come first, so the span is in the request context of the others
+	router.Use(tracing.Middleware(), metrics.Middleware(traceId))
+	sessions := auth.NewSessionCollector(db, seconds(opts.HealthCheckTimeoutSeconds, 2))
+	if err := prometheus.Register(sessions); err != nil {
+		logger.Warn("an error occurred while registering session collector", zap.String("error", err.Error()))
+	}
+
+	encryptionClient := encryption.NewClient(opts.EncryptionServiceUrl, encryption.Config{
+		Timeout:          time.Duration(opts.EncryptionServiceTimeoutSeconds) * time.Second,
+		MaxRetries:       opts.EncryptionServiceMaxRetries,
+		BreakerThreshold: opts.EncryptionServiceBreakerThreshold,
+		BreakerTimeout:   time.Duration(opts.EncryptionServiceBreakerTimeoutSeconds) * time.Second,
+	})
+	prober := health.NewProber(seconds(opts.HealthCheckTimeoutSeconds, 2), seconds(opts.HealthCheckCacheSeconds, 5))
+	metricsServer := metrics.NewServer(router, opts)
+	// certificates of the users are revoked together with their sessions
+	var listeners []revocation.Listener
+	if authority != nil {
+		listeners = append(listeners, authority.RevokeUserCertificates)
+	}
+
+	// background work of the web server like the policy watcher is stopped on shutdown
+	background, stopBackground := context.WithCancel(context.Background())
+	server, err := web.InitServer(background, router, web.Dependencies{
+		Options:    opts,
+		Db:         db,
+		Authority:  authority,
+		Encryption: encryptionClient,
+		Prober:     prober,
+		Auditor:    auditor,
+		Guard:      guard,
+		Limiter:    limiter,
+		Revocation: listeners,
+	})
+	if err != nil {
+		stopBackground()
+		return err
+	}
+
+	grpcServer := rpc.NewServer(web.AuthService(), web.Realms(), limiter)
+
+	prober.AddCheck("database", health.DatabaseChecker(db), health.Ready)
+	prober.AddCheck("encryption-service", encryptionClient.Ping, health.Ready)
+	prober.AddCheck("signing-key", health.SigningKeyChecker(web.Realms()), health.Ready, health.Startup)
+	prober.AddCheck("revocation-store", health.RevocationStoreChecker(db), health.Ready)
+	prober.AddCheck("migrations", health.MigrationsChecker(db), health.Startup)
+
+	manager.Drain(seconds(opts.ShutdownDrainSeconds, 0), func() {
+		prober.SetDraining(true)
+	})
+	manager.Add(fmt.Sprintf("web server on port %d", opts.ServerPort), lifecycle.HttpServer(server))
+	manager.Add(fmt.Sprintf("grpc server on port %d", opts.GrpcPort),
+		lifecycle.GrpcServer(grpcServer, fmt.Sprintf(":%d", opts.GrpcPort)))
+	manager.Add(fmt.Sprintf("metrics server on port %d", opts.MetricsPort), lifecycle.HttpServer(metricsServer))
+	manager.Add(fmt.Sprintf("health server on port %d", opts.HealthPort),
+		lifecycle.HttpServer(health.NewServer(opts, prober)))
+	// policies are reloaded from the database, so the watcher is stopped first
+	manager.OnShutdown("policy watcher", func(context.Context) error {
+		stopBackground()
+		return nil
+	})
+	return nil
 }
 
-func main() {
-	defer func() {
-		err := logger.Sync()
-		if err != nil {
-			panic(err)
+// newAuditor creates the auditor which writes to the comma separated AUDIT_SINKS
+func newAuditor(opts *options.AuthServiceOptions, db *gorm.DB) (*audit.Auditor, error) {
+	var sinks []audit.Sink
+	for _, name := range strings.Split(opts.AuditSinks, ",") {
+		switch strings.TrimSpace(name) {
+		case "":
+		case audit.SinkFile:
+			sink, err := audit.NewFileSink(opts.AuditFile)
+			if err != nil {
+				return nil, err
+			}
+			sinks = append(sinks, sink)
+		case audit.SinkDb:
+			sinks = append(sinks, audit.NewDbSink(db))
+		case audit.SinkWebhook:
+			if opts.AuditWebhookUrl == "" {
+				return nil, errors.New("audit webhook url is not configured")
+			}
+			sinks = append(sinks, audit.NewWebhookSink(logger, opts.AuditWebhookUrl,
+				seconds(opts.AuditWebhookTimeoutSeconds, 5), 1000))
+		default:
+			return nil, fmt.Errorf("unknown audit sink %s", name)
 		}
-	}()
+	}
 
-	defer func() {
-		sqlDb, err := db.DB()
+	return audit.NewAuditor(logger, sinks...), nil
+}
+
+// newCertificateAuthority creates the configured certificate authority, returns nil if it is not configured
+func newCertificateAuthority(opts *options.AuthServiceOptions) (*ca.CertificateAuthority, error) {
+	if opts.CaCertificate == "" || opts.CaPrivateKey == "" {
+		logger.Warn("certificate authority is not configured, vpn certificate endpoints are disabled")
+		return nil, nil
+	}
+
+	return ca.NewCertificateAuthority(opts.CaCertificate, opts.CaPrivateKey, opts.CaRoleExtKeyUsages,
+		time.Duration(opts.CaCrlValidityMinutes)*time.Minute)
+}
+
+// newLoginGuard creates the guard which assesses the logins with the configured rules and GeoIP database, returned
+// notifier posts the notifications to RISK_NOTIFY_WEBHOOK_URL and must be closed on shutdown
+func newLoginGuard(opts *options.AuthServiceOptions, db *gorm.DB) (*risk.Guard, *audit.Auditor, error) {
+	config := risk.DefaultConfig()
+	if opts.RiskRulesFile != "" {
+		var err error
+		if config, err = risk.LoadConfig(opts.RiskRulesFile); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	locator := risk.NoLocator
+	if opts.GeoIpDatabaseFile != "" {
+		geoIp, err := risk.NewGeoIpLocator(opts.GeoIpDatabaseFile)
 		if err != nil {
-			panic(err)
+			return nil, nil, err
+		}
+		locator = geoIp
+	} else {
+		logger.Warn("geoip database is not configured, new country and impossible travel detection are disabled")
+	}
+
+	var sinks []audit.Sink
+	if opts.RiskNotifyWebhookUrl != "" {
+		sinks = append(sinks, audit.NewWebhookSink(logger, opts.RiskNotifyWebhookUrl,
+			seconds(opts.AuditWebhookTimeoutSeconds, 5), 1000))
+	}
+	notifier := audit.NewAuditor(logger, sinks...)
+
+	return risk.NewGuard(db, locator, config, notifier), notifier, nil
+}
+
+// newLimiter creates the rate limiter with the configured backend and policies, it is nil if rate limiting is
+// disabled. Returned closer closes the connections of the backend
+func newLimiter(opts *options.AuthServiceOptions) (*ratelimit.Limiter, lifecycle.Closer, error) {
+	noop := func(context.Context) error { return nil }
+	if !opts.RateLimitEnabled {
+		logger.Warn("rate limiting is disabled")
+		return nil, noop, nil
+	}
+
+	policies := ratelimit.DefaultPolicies()
+	if opts.RateLimitPoliciesFile != "" {
+		var err error
+		if policies, err = ratelimit.LoadPolicies(opts.RateLimitPoliciesFile); err != nil {
+			return nil, nil, err
 		}
+	}
 
-		err = sqlDb.Close()
+	switch opts.RateLimitBackend {
+	case "", ratelimit.BackendMemory:
+		return ratelimit.NewLimiter(ratelimit.NewMemoryBackend(nil), policies), noop, nil
+	case ratelimit.BackendRedis:
+		redisOpts, err := redis.ParseURL(opts.RateLimitRedisUrl)
 		if err != nil {
-			panic(err)
+			return nil, nil, err
 		}
-	}()
 
-	router := gin.Default()
-	go metrics.RunMetricsServer(router)
-	server := web.InitServer(router)
-	logger.Info("web server is up and running", zap.Int("serverPort", opts.ServerPort))
-	panic(server.ListenAndServe())
+		backend := ratelimit.NewRedisBackend(redis.NewClient(redisOpts), nil)
+		return ratelimit.NewLimiter(backend, policies), backend.Close, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown rate limit backend %s", opts.RateLimitBackend)
+	}
+}
+
+// trustedProxies splits the comma separated TRUSTED_PROXIES, nil means no proxy is trusted
+func trustedProxies(option string) []string {
+	var proxies []string
+	for _, proxy := range strings.Split(option, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+
+	return proxies
+}
+
+// seconds converts the option to a duration, fallback is used if the option is not set
+func seconds(option, fallback int) time.Duration {
+	if option <= 0 {
+		return time.Duration(fallback) * time.Second
+	}
+
+	return time.Duration(option) * time.Second
 }
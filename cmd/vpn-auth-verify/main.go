@@ -0,0 +1,109 @@
+// vpn-auth-verify implements the OpenVPN auth-user-pass-verify script contract against auth-service. It supports
+// both via-file and via-env methods:
+//
+//	auth-user-pass-verify "/usr/local/bin/vpn-auth-verify -url http://auth-service:5000" via-file
+//	auth-user-pass-verify "/usr/local/bin/vpn-auth-verify -url http://auth-service:5000" via-env
+//
+// When started with -deferred and OpenVPN provides auth_control_file, the verification continues in a detached
+// worker process, the script exits with code 2 and the result is written to auth_control_file.
+package main
+
+import (
+	"auth-service/internal/vpnauth"
+	"context"
+	"flag"
+	commons "github.com/vpnbeast/golang-commons"
+	"go.uber.org/zap"
+	"os"
+	"time"
+)
+
+var logger = commons.GetLogger()
+
+func main() {
+	os.Exit(run())
+}
+
+func run() int {
+	flags := flag.NewFlagSet("vpn-auth-verify", flag.ContinueOnError)
+	url := flags.String("url", getEnv("AUTH_SERVICE_URL", "http://localhost:5000"), "base url of the auth-service")
+	timeout := flags.Duration("timeout", 5*time.Second, "timeout of the requests to the auth-service")
+	cacheDir := flags.String("cache-dir", vpnauth.DefaultCacheDir,
+		"private directory of the local verification cache, must be owned by the user with mode 0700")
+	cacheTtl := flags.Duration("cache-ttl", 5*time.Minute, "ttl of the local verification cache, 0 disables cache")
+	deferred := flags.Bool("deferred", false, "use deferred authentication through auth_control_file")
+	worker := flags.Bool("deferred-worker", false, "internal flag, runs as the deferred authentication worker")
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		return vpnauth.ExitFailure
+	}
+
+	verifier := vpnauth.NewVerifier(vpnauth.NewClient(*url, *timeout), vpnauth.NewCache(*cacheDir, *cacheTtl), logger)
+	controlFile := os.Getenv("auth_control_file")
+
+	if *worker {
+		return runWorker(verifier, controlFile, *timeout)
+	}
+
+	creds, err := readCredentials(flags.Args())
+	if err != nil {
+		logger.Error("an error occurred while reading credentials", zap.String("error", err.Error()))
+		return vpnauth.ExitFailure
+	}
+
+	if *deferred && controlFile != "" {
+		executable, err := os.Executable()
+		if err != nil {
+			logger.Error("an error occurred while resolving executable", zap.String("error", err.Error()))
+			return vpnauth.ExitFailure
+		}
+
+		args := append([]string{"-deferred-worker"}, os.Args[1:]...)
+		if err := vpnauth.SpawnDeferred(executable, args, creds); err != nil {
+			logger.Error("an error occurred while spawning deferred worker", zap.String("error", err.Error()))
+			return vpnauth.ExitFailure
+		}
+
+		return vpnauth.ExitDeferred
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	return verifier.Run(ctx, creds)
+}
+
+func runWorker(verifier *vpnauth.Verifier, controlFile string, timeout time.Duration) int {
+	creds, err := vpnauth.ReadDeferredCredentials(os.Stdin)
+	if err != nil {
+		logger.Error("an error occurred while reading deferred credentials", zap.String("error", err.Error()))
+		_ = vpnauth.WriteAuthControlFile(controlFile, false)
+		return vpnauth.ExitFailure
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	code := verifier.Run(ctx, creds)
+	if err := vpnauth.WriteAuthControlFile(controlFile, code == vpnauth.ExitSuccess); err != nil {
+		logger.Error("an error occurred while writing auth control file", zap.String("error", err.Error()))
+		return vpnauth.ExitFailure
+	}
+
+	return code
+}
+
+// readCredentials reads the credentials from the file given as the last argument in via-file mode, which OpenVPN
+// appends to the command, falls back to the environment variables of via-env mode
+func readCredentials(args []string) (vpnauth.Credentials, error) {
+	if len(args) > 0 {
+		return vpnauth.ReadCredentialsFromFile(args[len(args)-1])
+	}
+
+	return vpnauth.ReadCredentialsFromEnv()
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+
+	return defaultValue
+}
@@ -0,0 +1,117 @@
+package account
+
+import (
+	"auth-service/internal/model"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const maxUserNameLength = 16
+
+var (
+	// ErrRoleNotFound is returned when a role to assign does not exist in the realm
+	ErrRoleNotFound = errors.New("role not found")
+
+	invalidUserNameChars = regexp.MustCompile(`[^a-z0-9._-]`)
+)
+
+// Provision creates the user in the realm with the roles, users created by auth-service have no password so they can
+// only log in through their identity backend
+func Provision(db *gorm.DB, user *model.User, roleNames []string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		roles, err := ExistingRoles(tx, user.RealmId, roleNames)
+		if err != nil {
+			return err
+		}
+
+		if len(roles) != len(roleNames) {
+			return ErrRoleNotFound
+		}
+
+		now := time.Now().Format(time.RFC3339)
+		user.Uuid = uuid.NewString()
+		user.Enabled = true
+		user.CreatedAt = now
+		user.UpdatedAt = now
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+
+		return SetRoles(tx, user, roles)
+	})
+}
+
+// ExistingRoles returns the roles of the realm with the given names, unknown names are skipped
+func ExistingRoles(db *gorm.DB, realmId uint, roleNames []string) ([]model.Role, error) {
+	var roles []model.Role
+	if len(roleNames) == 0 {
+		return roles, nil
+	}
+
+	err := db.Where("realm_id = ? AND name IN ?", realmId, roleNames).Find(&roles).Error
+	return roles, err
+}
+
+// SetRoles replaces the roles of the user, users_roles is written directly since the association is read only
+func SetRoles(tx *gorm.DB, user *model.User, roles []model.Role) error {
+	if err := tx.Exec("DELETE FROM users_roles WHERE user_id = ?", user.Id).Error; err != nil {
+		return err
+	}
+
+	user.Roles = make([]*model.Role, 0, len(roles))
+	for i := range roles {
+		err := tx.Table("users_roles").Create(map[string]interface{}{
+			"user_id": user.Id,
+			"role_id": roles[i].Id,
+		}).Error
+		if err != nil {
+			return err
+		}
+		user.Roles = append(user.Roles, &roles[i])
+	}
+
+	return nil
+}
+
+// UniqueUserName derives a free user name in the realm from the given name, like an email address, by removing the
+// unsupported characters and appending a number if it is taken
+func UniqueUserName(db *gorm.DB, realmId uint, name string) (string, error) {
+	base := strings.ToLower(name)
+	if i := strings.Index(base, "@"); i >= 0 {
+		base = base[:i]
+	}
+
+	base = invalidUserNameChars.ReplaceAllString(base, "")
+	if len(base) < 3 {
+		base = "user" + base
+	}
+
+	if len(base) > maxUserNameLength {
+		base = base[:maxUserNameLength]
+	}
+
+	candidate := base
+	for i := 1; ; i++ {
+		var count int64
+		err := db.Model(&model.User{}).Where("realm_id = ? AND user_name = ?", realmId, candidate).Count(&count).Error
+		if err != nil {
+			return "", err
+		}
+
+		if count == 0 {
+			return candidate, nil
+		}
+
+		suffix := fmt.Sprint(i)
+		if len(base)+len(suffix) > maxUserNameLength {
+			candidate = base[:maxUserNameLength-len(suffix)] + suffix
+		} else {
+			candidate = base + suffix
+		}
+	}
+}
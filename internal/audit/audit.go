@@ -0,0 +1,172 @@
+package audit
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"strings"
+	"time"
+)
+
+// EventType is the type of a security relevant event
+type EventType string
+
+// types of the audit events
+const (
+	EventLoginSucceeded  EventType = "login.succeeded"
+	EventLoginFailed     EventType = "login.failed"
+	EventLoginSuspicious EventType = "login.suspicious"
+	EventTokenRefreshed  EventType = "token.refreshed"
+	EventTokenRevoked    EventType = "token.revoked"
+	EventRoleChanged     EventType = "role.changed"
+	EventPasswordChanged EventType = "password.changed"
+	EventLockout         EventType = "user.locked"
+)
+
+// Outcome is the result of the action of an audit event
+type Outcome string
+
+// outcomes of the audit events
+const (
+	OutcomeSuccess Outcome = "success"
+	OutcomeFailure Outcome = "failure"
+)
+
+// redacted replaces the values of the secret details
+const redacted = "[REDACTED]"
+
+// secretKeys are the suffixes of the detail keys whose values are never written to the sinks, e.g. newPassword or
+// refreshToken
+var secretKeys = []string{"password", "secret", "token", "authorization", "cookie", "credential", "key"}
+
+// Event represents a security relevant action. Actor is the user who made the request and Subject is the user, role
+// or token the action is made on, they are the same for the logins. Ip, UserAgent and RequestId are filled from the
+// request of the context if they are empty
+type Event struct {
+	Type      EventType         `json:"type"`
+	Outcome   Outcome           `json:"outcome"`
+	Reason    string            `json:"reason,omitempty"`
+	RealmId   uint              `json:"realmId"`
+	Actor     string            `json:"actor,omitempty"`
+	Subject   string            `json:"subject,omitempty"`
+	Ip        string            `json:"ip,omitempty"`
+	UserAgent string            `json:"userAgent,omitempty"`
+	RequestId string            `json:"requestId,omitempty"`
+	Details   map[string]string `json:"details,omitempty"`
+	Time      time.Time         `json:"time"`
+}
+
+// Request represents the client of the request which caused an event
+type Request struct {
+	Ip        string
+	UserAgent string
+	RequestId string
+}
+
+type requestKey struct{}
+
+// WithRequest returns a copy of the context which carries the client of the request
+func WithRequest(ctx context.Context, request Request) context.Context {
+	return context.WithValue(ctx, requestKey{}, request)
+}
+
+// RequestFromContext returns the client of the request carried by the context
+func RequestFromContext(ctx context.Context) Request {
+	request, _ := ctx.Value(requestKey{}).(Request)
+	return request
+}
+
+// Sink writes the audit events to a destination like a file, a database table or a webhook
+type Sink interface {
+	Name() string
+	Write(ctx context.Context, event Event) error
+}
+
+// Closer is implemented by the sinks which must be flushed on shutdown
+type Closer interface {
+	Close(ctx context.Context) error
+}
+
+// Recorder records the audit events, *Auditor implements it
+type Recorder interface {
+	Record(ctx context.Context, event Event)
+}
+
+type discard struct{}
+
+func (discard) Record(context.Context, Event) {}
+
+// Discard is the Recorder which drops the events
+var Discard Recorder = discard{}
+
+// Auditor redacts the events and writes them to all the sinks. Failing sinks are logged, they never fail the action
+// which is audited
+type Auditor struct {
+	logger *zap.Logger
+	sinks  []Sink
+}
+
+// NewAuditor creates an Auditor which writes to the sinks
+func NewAuditor(logger *zap.Logger, sinks ...Sink) *Auditor {
+	return &Auditor{logger: logger, sinks: sinks}
+}
+
+// Record fills the client of the request and the time of the event, redacts its secrets and writes it to the sinks
+func (a *Auditor) Record(ctx context.Context, event Event) {
+	request := RequestFromContext(ctx)
+	if event.Ip == "" {
+		event.Ip = request.Ip
+	}
+	if event.UserAgent == "" {
+		event.UserAgent = request.UserAgent
+	}
+	if event.RequestId == "" {
+		event.RequestId = request.RequestId
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	event.Details = Redact(event.Details)
+
+	for _, sink := range a.sinks {
+		if err := sink.Write(ctx, event); err != nil {
+			a.logger.Error("an error occurred while writing audit event", zap.String("sink", sink.Name()),
+				zap.String("type", string(event.Type)), zap.String("error", err.Error()))
+		}
+	}
+}
+
+// Close flushes and closes the sinks which implement Closer
+func (a *Auditor) Close(ctx context.Context) error {
+	var firstErr error
+	for _, sink := range a.sinks {
+		if closer, ok := sink.(Closer); ok {
+			if err := closer.Close(ctx); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	return firstErr
+}
+
+// Redact returns a copy of the details whose secret values are replaced, a detail is secret if its key ends with one of
+// the secretKeys
+func Redact(details map[string]string) map[string]string {
+	if len(details) == 0 {
+		return nil
+	}
+
+	result := make(map[string]string, len(details))
+	for key, value := range details {
+		result[key] = value
+		lower := strings.ToLower(key)
+		for _, secret := range secretKeys {
+			if strings.HasSuffix(lower, secret) {
+				result[key] = redacted
+				break
+			}
+		}
+	}
+
+	return result
+}
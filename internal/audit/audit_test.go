@@ -0,0 +1,162 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go.uber.org/zap"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type memorySink struct {
+	mu     sync.Mutex
+	events []Event
+	err    error
+}
+
+func (s *memorySink) Name() string {
+	return "memory"
+}
+
+func (s *memorySink) Write(_ context.Context, event Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, event)
+	return s.err
+}
+
+func TestRedact(t *testing.T) {
+	details := map[string]string{
+		"newPassword":  "secret123",
+		"refreshToken": "eyJ...",
+		"apiKey":       "abc",
+		"provider":     "google",
+	}
+
+	redactedDetails := Redact(details)
+	for _, key := range []string{"newPassword", "refreshToken", "apiKey"} {
+		if redactedDetails[key] != redacted {
+			t.Errorf("expected %s to be redacted, got %s", key, redactedDetails[key])
+		}
+	}
+
+	if redactedDetails["provider"] != "google" {
+		t.Errorf("expected provider to be kept, got %s", redactedDetails["provider"])
+	}
+
+	if details["newPassword"] != "secret123" {
+		t.Error("expected the details of the caller to be left untouched")
+	}
+}
+
+func TestRecordFillsRequest(t *testing.T) {
+	failing := &memorySink{err: errors.New("sink is down")}
+	sink := &memorySink{}
+	auditor := NewAuditor(zap.NewNop(), failing, sink)
+
+	ctx := WithRequest(context.Background(), Request{Ip: "10.0.0.1", UserAgent: "curl/7.79", RequestId: "req-1"})
+	auditor.Record(ctx, Event{Type: EventLoginFailed, Outcome: OutcomeFailure, Actor: "john", Subject: "john",
+		Details: map[string]string{"password": "123"}})
+
+	if len(sink.events) != 1 {
+		t.Fatalf("expected the event to be written despite the failing sink, got %d events", len(sink.events))
+	}
+
+	event := sink.events[0]
+	if event.Ip != "10.0.0.1" || event.UserAgent != "curl/7.79" || event.RequestId != "req-1" {
+		t.Errorf("expected the request to be filled, got %+v", event)
+	}
+
+	if event.Time.IsZero() {
+		t.Error("expected the time to be filled")
+	}
+
+	if event.Details["password"] != redacted {
+		t.Errorf("expected password to be redacted, got %s", event.Details["password"])
+	}
+}
+
+func TestFileSink(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	sink, err := NewFileSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, eventType := range []EventType{EventLoginSucceeded, EventTokenRevoked} {
+		if err := sink.Write(context.Background(), Event{Type: eventType, Outcome: OutcomeSuccess}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := sink.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d", len(lines))
+	}
+
+	var event Event
+	if err := json.Unmarshal([]byte(lines[1]), &event); err != nil {
+		t.Fatal(err)
+	}
+
+	if event.Type != EventTokenRevoked {
+		t.Errorf("expected %s, got %s", EventTokenRevoked, event.Type)
+	}
+}
+
+func TestWebhookSinkDeliversOnClose(t *testing.T) {
+	var mu sync.Mutex
+	var received []Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event Event
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		mu.Lock()
+		received = append(received, event)
+		mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	sink := NewWebhookSink(zap.NewNop(), server.URL, time.Second, 10)
+	for i := 0; i < 3; i++ {
+		if err := sink.Write(context.Background(), Event{Type: EventTokenRefreshed}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := sink.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 3 {
+		t.Errorf("expected 3 events to be delivered, got %d", len(received))
+	}
+
+	if err := sink.Write(context.Background(), Event{Type: EventTokenRefreshed}); err == nil {
+		t.Error("expected an error after the sink is closed")
+	}
+}
@@ -0,0 +1,54 @@
+package audit
+
+import (
+	"auth-service/internal/model"
+	"gorm.io/gorm"
+	"time"
+)
+
+// MaxLimit is the maximum number of the events returned by a single Query
+const MaxLimit = 1000
+
+// Filter selects the events of a Query, empty fields match everything
+type Filter struct {
+	Type    string
+	Outcome string
+	Actor   string
+	Subject string
+	Since   *time.Time
+	Until   *time.Time
+	Limit   int
+	Offset  int
+}
+
+// Query returns the events of the realm stored by the DbSink, newest first
+func Query(db *gorm.DB, realmId uint, filter Filter) ([]model.AuditEvent, error) {
+	query := db.Where("realm_id = ?", realmId)
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if filter.Outcome != "" {
+		query = query.Where("outcome = ?", filter.Outcome)
+	}
+	if filter.Actor != "" {
+		query = query.Where("actor = ?", filter.Actor)
+	}
+	if filter.Subject != "" {
+		query = query.Where("subject = ?", filter.Subject)
+	}
+	if filter.Since != nil {
+		query = query.Where("created_at >= ?", *filter.Since)
+	}
+	if filter.Until != nil {
+		query = query.Where("created_at < ?", *filter.Until)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 || limit > MaxLimit {
+		limit = MaxLimit
+	}
+
+	events := make([]model.AuditEvent, 0)
+	err := query.Order("created_at DESC, id DESC").Limit(limit).Offset(filter.Offset).Find(&events).Error
+	return events, err
+}
@@ -0,0 +1,199 @@
+package audit
+
+import (
+	"auth-service/internal/model"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// names of the sinks
+const (
+	SinkFile    = "file"
+	SinkDb      = "db"
+	SinkWebhook = "webhook"
+)
+
+// FileSink appends the events to a file as JSON lines
+type FileSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// NewFileSink opens the file in append mode, the file is created if it does not exist
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileSink{file: file}, nil
+}
+
+// Name implements Sink
+func (s *FileSink) Name() string {
+	return SinkFile
+}
+
+// Write implements Sink, every event is written with a single write call so the lines are not interleaved
+func (s *FileSink) Write(_ context.Context, event Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.file.Write(append(line, '\n'))
+	return err
+}
+
+// Close implements Closer
+func (s *FileSink) Close(context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+// DbSink stores the events in the audit_events table, so they can be queried with Query
+type DbSink struct {
+	db *gorm.DB
+}
+
+// NewDbSink creates a DbSink on the database
+func NewDbSink(db *gorm.DB) *DbSink {
+	return &DbSink{db: db}
+}
+
+// Name implements Sink
+func (s *DbSink) Name() string {
+	return SinkDb
+}
+
+// Write implements Sink
+func (s *DbSink) Write(ctx context.Context, event Event) error {
+	var details []byte
+	if len(event.Details) != 0 {
+		var err error
+		if details, err = json.Marshal(event.Details); err != nil {
+			return err
+		}
+	}
+
+	return s.db.WithContext(ctx).Create(&model.AuditEvent{
+		RealmId:   event.RealmId,
+		Type:      string(event.Type),
+		Outcome:   string(event.Outcome),
+		Reason:    event.Reason,
+		Actor:     event.Actor,
+		Subject:   event.Subject,
+		Ip:        event.Ip,
+		UserAgent: event.UserAgent,
+		RequestId: event.RequestId,
+		Details:   string(details),
+		CreatedAt: event.Time,
+	}).Error
+}
+
+// WebhookSink posts the events as JSON to a webhook in the background, so a slow webhook does not slow down the
+// audited actions. Events are dropped and logged if the queue is full
+type WebhookSink struct {
+	url        string
+	httpClient *http.Client
+	logger     *zap.Logger
+	mu         sync.RWMutex
+	closed     bool
+	queue      chan Event
+	done       chan struct{}
+}
+
+// NewWebhookSink creates a WebhookSink and starts its worker, each post times out after the timeout
+func NewWebhookSink(logger *zap.Logger, url string, timeout time.Duration, queueSize int) *WebhookSink {
+	s := &WebhookSink{
+		url:        url,
+		httpClient: &http.Client{Timeout: timeout},
+		logger:     logger,
+		queue:      make(chan Event, queueSize),
+		done:       make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+// Name implements Sink
+func (s *WebhookSink) Name() string {
+	return SinkWebhook
+}
+
+// Write implements Sink, the event is only queued
+func (s *WebhookSink) Write(_ context.Context, event Event) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return errors.New("webhook sink is closed, event is dropped")
+	}
+
+	select {
+	case s.queue <- event:
+		return nil
+	default:
+		return errors.New("webhook queue is full, event is dropped")
+	}
+}
+
+// Close implements Closer, queued events are posted until the context is done
+func (s *WebhookSink) Close(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *WebhookSink) run() {
+	defer close(s.done)
+	for event := range s.queue {
+		if err := s.post(event); err != nil {
+			s.logger.Error("an error occurred while posting audit event to webhook",
+				zap.String("type", string(event.Type)), zap.String("error", err.Error()))
+		}
+	}
+}
+
+func (s *WebhookSink) post(event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.httpClient.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"auth-service/internal/audit"
+	"auth-service/internal/realm"
+	"context"
+	"errors"
+)
+
+// record records an audit event whose actor and subject is the user, event fails with the reason of the error if
+// there is one
+func (s *AuthService) record(ctx context.Context, eventType audit.EventType, r *realm.Realm, username string,
+	err error, details map[string]string) {
+	event := audit.Event{
+		Type:    eventType,
+		Outcome: audit.OutcomeSuccess,
+		RealmId: r.Id,
+		Actor:   username,
+		Subject: username,
+		Details: details,
+	}
+	if err != nil {
+		event.Outcome = audit.OutcomeFailure
+		event.Reason = failureReason(err)
+	}
+
+	s.recorder.Record(ctx, event)
+}
+
+// recordLogin records the audit event of a login, refused logins of the locked users are lockouts
+func (s *AuthService) recordLogin(ctx context.Context, r *realm.Realm, username string, err error,
+	details map[string]string) {
+	switch {
+	case err == nil:
+		s.record(ctx, audit.EventLoginSucceeded, r, username, nil, details)
+	case errors.Is(err, ErrUserLocked):
+		s.record(ctx, audit.EventLockout, r, username, err, details)
+	default:
+		s.record(ctx, audit.EventLoginFailed, r, username, err, details)
+	}
+}
+
+// failureReason maps the error of a failed action to the reason of its audit event
+func failureReason(err error) string {
+	var tokenErr *TokenError
+	switch {
+	case err == ErrTokenRevoked:
+		return "token_revoked"
+	case errors.As(err, &tokenErr):
+		return "invalid_token"
+	default:
+		return authenticationOutcome(err)
+	}
+}
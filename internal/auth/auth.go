@@ -0,0 +1,285 @@
+package auth
+
+import (
+	"auth-service/internal/audit"
+	"auth-service/internal/identity"
+	"auth-service/internal/jwt"
+	"auth-service/internal/model"
+	"auth-service/internal/realm"
+	"auth-service/internal/tracing"
+	"context"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"time"
+)
+
+var (
+	// ErrUserNotFound is returned when the user does not exist in the realm
+	ErrUserNotFound = identity.ErrUserNotFound
+	// ErrInvalidCredentials is returned when the password does not match
+	ErrInvalidCredentials = identity.ErrInvalidCredentials
+	// ErrUserDisabled is returned when the user is disabled
+	ErrUserDisabled = identity.ErrUserDisabled
+	// ErrUserLocked is returned when the identity backend locked the user
+	ErrUserLocked = identity.ErrUserLocked
+	// ErrUserConflict is returned when the identity backend can not take over the existing user with the same name
+	ErrUserConflict = identity.ErrUserConflict
+)
+
+// Store loads and persists the users, their sessions and the data which is embedded into their tokens
+type Store interface {
+	// FindUser returns the user with its roles, ErrUserNotFound if there is no such user in the realm
+	FindUser(ctx context.Context, realmId uint, username string) (*model.User, error)
+	SaveUser(ctx context.Context, user *model.User) error
+	// IsRevoked checks if a token issued to the subject at issuedAt is revoked
+	IsRevoked(ctx context.Context, realmId uint, subject string, issuedAt time.Time) (bool, error)
+	RevokeSessions(ctx context.Context, user *model.User) error
+	// ResolvePersonalAccessToken returns the owner of the token and the roles granted to it, ErrInvalidToken if the
+	// token is unknown or revoked
+	ResolvePersonalAccessToken(ctx context.Context, token string) (*model.User, []string, error)
+	// Enrichers returns the enrichers which add the entitlements and the permissions of the user to the claims
+	Enrichers(ctx context.Context, realmId, userId uint, roles []string) []jwt.ClaimEnricher
+}
+
+// Signer issues and parses the tokens of a realm, *jwt.Signer implements it
+type Signer interface {
+	GenerateToken(username string, roles []string, expiresAtInMinutes int32, enrichers ...jwt.ClaimEnricher) (string,
+		error)
+	ParseToken(signedToken string) (*jwt.VpnbeastClaim, error, int)
+}
+
+// SignerProvider returns the Signer of the realm
+type SignerProvider func(r *realm.Realm) Signer
+
+// PasswordVerifier verifies the password of the user and returns the user with its roles
+type PasswordVerifier interface {
+	Verify(ctx context.Context, r *realm.Realm, username, password string) (*model.User, error)
+}
+
+// Clock returns the current time
+type Clock func() time.Time
+
+// RealmSigner is the SignerProvider which returns the signer of the loaded realm
+func RealmSigner(r *realm.Realm) Signer {
+	return r.Signer()
+}
+
+// Credentials represents the username and the password of a login, DeviceId optionally identifies the device of the
+// client for the risk evaluation
+type Credentials struct {
+	Username string
+	Password string
+	DeviceId string
+}
+
+// AuthService implements the login, refresh, validation and logout flows independent of the transport, so the rest
+// and the gRPC apis share the same behavior
+type AuthService struct {
+	store    Store
+	signers  SignerProvider
+	verifier PasswordVerifier
+	recorder audit.Recorder
+	guard    LoginGuard
+	now      Clock
+}
+
+// NewAuthService creates an AuthService with the dependencies, recorder defaults to audit.Discard, guard defaults to
+// allowing every login without a history and clock defaults to time.Now if they are nil
+func NewAuthService(store Store, signers SignerProvider, verifier PasswordVerifier, recorder audit.Recorder,
+	guard LoginGuard, clock Clock) *AuthService {
+	if recorder == nil {
+		recorder = audit.Discard
+	}
+
+	if guard == nil {
+		guard = allowGuard{}
+	}
+
+	if clock == nil {
+		clock = time.Now
+	}
+
+	return &AuthService{store: store, signers: signers, verifier: verifier, recorder: recorder, guard: guard,
+		now: clock}
+}
+
+// Authenticate verifies the credentials with the identity backend of the user, assesses the risk of the login and
+// issues a new token pair
+func (s *AuthService) Authenticate(ctx context.Context, r *realm.Realm, creds Credentials) (user *model.User,
+	err error) {
+	ctx, span := tracing.Start(ctx, "auth.Authenticate", trace.WithAttributes(attribute.String("realm", r.Name)))
+	defer func(start time.Time) {
+		observeAuthentication(r.Name, start, err)
+		s.recordLogin(ctx, r, creds.Username, err, nil)
+		tracing.End(span, err)
+	}(time.Now())
+	login := s.newLogin(ctx, creds)
+	user, err = s.verifier.Verify(ctx, r, creds.Username, creds.Password)
+	if err != nil {
+		s.recordFailedLogin(ctx, r, creds.Username, login, err)
+		return nil, err
+	}
+
+	if err := s.assessLogin(ctx, r, user.UserName, login); err != nil {
+		return nil, err
+	}
+
+	if err := s.IssueTokens(ctx, r, user, roleNames(user)); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// AuthenticateFederated issues a new token pair for the user whose identity is verified by the provider and linked
+// by the caller. Disabled users are refused, and the login is assessed and recorded like the password logins
+func (s *AuthService) AuthenticateFederated(ctx context.Context, r *realm.Realm, provider string, user *model.User,
+	deviceId string) (err error) {
+	ctx, span := tracing.Start(ctx, "auth.AuthenticateFederated", trace.WithAttributes(
+		attribute.String("realm", r.Name), attribute.String("provider", provider)))
+	defer func(start time.Time) {
+		observeAuthentication(r.Name, start, err)
+		s.recordLogin(ctx, r, user.UserName, err, map[string]string{"provider": provider})
+		tracing.End(span, err)
+	}(time.Now())
+	login := s.newLogin(ctx, Credentials{Username: user.UserName, DeviceId: deviceId})
+	if !user.Enabled {
+		s.recordFailedLogin(ctx, r, user.UserName, login, ErrUserDisabled)
+		return ErrUserDisabled
+	}
+
+	if err := s.assessLogin(ctx, r, user.UserName, login); err != nil {
+		return err
+	}
+
+	return s.IssueTokens(ctx, r, user, roleNames(user))
+}
+
+// Refresh issues a new token pair with the current roles of the user, so the roles removed since the login are not
+// carried over, and refuses the disabled users. Entitlements are loaded again so the expired ones are dropped from
+// the refreshed access token
+func (s *AuthService) Refresh(ctx context.Context, r *realm.Realm, refreshToken string) (user *model.User,
+	err error) {
+	var subject string
+	defer func() {
+		observeTokenOperation(operationRefreshed, err)
+		s.record(ctx, audit.EventTokenRefreshed, r, subject, err, nil)
+	}()
+	claims, err := s.ValidateRefreshToken(ctx, r, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	subject = claims.Subject
+
+	user, err = s.store.FindUser(ctx, r.Id, claims.Subject)
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.Enabled {
+		return nil, ErrUserDisabled
+	}
+
+	if err := s.IssueTokens(ctx, r, user, roleNames(user)); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// Validate validates the session or personal access token, the token is refused if it carries an expired
+// entitlement or its subject does not exist anymore. Claims are returned with these errors for logging
+func (s *AuthService) Validate(ctx context.Context, r *realm.Realm, token string) (claims *jwt.VpnbeastClaim,
+	err error) {
+	defer func() {
+		observeTokenOperation(operationValidated, err)
+	}()
+	claims, err = s.AuthenticateToken(ctx, r, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if expired := claims.ExpiredEntitlements(s.now().Unix()); len(expired) != 0 {
+		return claims, ErrEntitlementExpired
+	}
+
+	if _, err := s.store.FindUser(ctx, r.Id, claims.Subject); err != nil {
+		return claims, err
+	}
+
+	return claims, nil
+}
+
+// WhoAmI returns the user of the session or personal access token
+func (s *AuthService) WhoAmI(ctx context.Context, r *realm.Realm, token string) (*model.User, error) {
+	claims, err := s.AuthenticateToken(ctx, r, token)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.store.FindUser(ctx, r.Id, claims.Subject)
+}
+
+// Logout revokes all the sessions of the subject
+func (s *AuthService) Logout(ctx context.Context, r *realm.Realm, subject string) (*model.User, error) {
+	user, err := s.store.FindUser(ctx, r.Id, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.store.RevokeSessions(ctx, user)
+	observeTokenOperation(operationRevoked, err)
+	s.record(ctx, audit.EventTokenRevoked, r, subject, err, map[string]string{"scope": "sessions"})
+	return user, err
+}
+
+// IssueTokens generates a new token pair in the realm and stores it on the user
+func (s *AuthService) IssueTokens(ctx context.Context, r *realm.Realm, user *model.User, roles []string) (err error) {
+	defer func() {
+		observeTokenOperation(operationIssued, err)
+	}()
+	signer := s.signers(r)
+	accessToken, err := generateToken(ctx, jwt.TokenTypeAccess, signer, user.UserName, roles,
+		r.AccessTokenValidInMinutes(), s.store.Enrichers(ctx, r.Id, user.Id, roles)...)
+	if err != nil {
+		return err
+	}
+
+	refreshToken, err := generateToken(ctx, jwt.TokenTypeRefresh, signer, user.UserName, roles,
+		r.RefreshTokenValidInMinutes())
+	if err != nil {
+		return err
+	}
+
+	now := s.now()
+	user.LastLogin = now.Format(time.RFC3339)
+	user.UpdatedAt = now.Format(time.RFC3339)
+	user.AccessToken = accessToken
+	user.AccessTokenExpiresAt = now.Add(time.Duration(r.AccessTokenValidInMinutes()) * time.Minute).Format(time.RFC3339)
+	user.RefreshToken = refreshToken
+	user.RefreshTokenExpiresAt = now.Add(time.Duration(r.RefreshTokenValidInMinutes()) * time.Minute).
+		Format(time.RFC3339)
+	user.Version = user.Version + 1
+	return s.store.SaveUser(ctx, user)
+}
+
+// generateToken signs the token of the type in a span, so the time spent on signing is visible in the trace of the
+// request
+func generateToken(ctx context.Context, tokenType string, signer Signer, username string, roles []string,
+	expiresAtInMinutes int32, enrichers ...jwt.ClaimEnricher) (string, error) {
+	_, span := tracing.Start(ctx, "jwt.GenerateToken", trace.WithAttributes(attribute.String("token.type", tokenType)))
+	enrichers = append([]jwt.ClaimEnricher{jwt.WithType(tokenType)}, enrichers...)
+	token, err := signer.GenerateToken(username, roles, expiresAtInMinutes, enrichers...)
+	tracing.End(span, err)
+	return token, err
+}
+
+func roleNames(user *model.User) []string {
+	roles := make([]string, 0, len(user.Roles))
+	for _, role := range user.Roles {
+		roles = append(roles, role.Name)
+	}
+
+	return roles
+}
@@ -0,0 +1,391 @@
+package auth
+
+import (
+	"auth-service/internal/audit"
+	"auth-service/internal/jwt"
+	"auth-service/internal/model"
+	"auth-service/internal/realm"
+	"auth-service/internal/risk"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var testNow = time.Date(2022, 2, 1, 10, 0, 0, 0, time.UTC)
+
+type fakeStore struct {
+	users    map[string]*model.User
+	saved    []*model.User
+	revoked  map[string]time.Time
+	enriched []string
+}
+
+func (s *fakeStore) FindUser(_ context.Context, realmId uint, username string) (*model.User, error) {
+	user, ok := s.users[username]
+	if !ok || user.RealmId != realmId {
+		return nil, ErrUserNotFound
+	}
+
+	return user, nil
+}
+
+func (s *fakeStore) SaveUser(_ context.Context, user *model.User) error {
+	s.saved = append(s.saved, user)
+	return nil
+}
+
+func (s *fakeStore) IsRevoked(_ context.Context, _ uint, subject string, issuedAt time.Time) (bool, error) {
+	revokedAt, ok := s.revoked[subject]
+	return ok && !issuedAt.After(revokedAt), nil
+}
+
+func (s *fakeStore) RevokeSessions(_ context.Context, user *model.User) error {
+	s.revoked[user.UserName] = testNow
+	return nil
+}
+
+func (s *fakeStore) ResolvePersonalAccessToken(context.Context, string) (*model.User, []string, error) {
+	return nil, nil, ErrInvalidToken
+}
+
+func (s *fakeStore) Enrichers(context.Context, uint, uint, []string) []jwt.ClaimEnricher {
+	return []jwt.ClaimEnricher{func(claims *jwt.VpnbeastClaim) error {
+		s.enriched = append(s.enriched, claims.Subject)
+		return nil
+	}}
+}
+
+// fakeSigner issues readable tokens and parses the tokens in claims, issued tokens are added to claims and roles of
+// the last issued token are kept
+type fakeSigner struct {
+	claims map[string]*jwt.VpnbeastClaim
+	roles  []string
+}
+
+func (s *fakeSigner) GenerateToken(username string, roles []string, expiresAtInMinutes int32,
+	enrichers ...jwt.ClaimEnricher) (string, error) {
+	s.roles = roles
+	claims := &jwt.VpnbeastClaim{Roles: roles}
+	claims.Subject = username
+	for _, enrich := range enrichers {
+		if err := enrich(claims); err != nil {
+			return "", err
+		}
+	}
+
+	token := fmt.Sprintf("%s-%d", username, expiresAtInMinutes)
+	s.claims[token] = claims
+	return token, nil
+}
+
+func (s *fakeSigner) ParseToken(token string) (*jwt.VpnbeastClaim, error, int) {
+	claims, ok := s.claims[token]
+	if !ok {
+		return nil, errors.New("malformed token"), http.StatusBadRequest
+	}
+
+	return claims, nil, http.StatusOK
+}
+
+type fakeVerifier map[string]string
+
+func (v fakeVerifier) Verify(_ context.Context, _ *realm.Realm, username, password string) (*model.User, error) {
+	expected, ok := v[username]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+
+	if expected != password {
+		return nil, ErrInvalidCredentials
+	}
+
+	return &model.User{RealmId: 1, UserName: username, Roles: []*model.Role{{Name: "ROLE_USER"}}}, nil
+}
+
+type fakeRecorder []audit.Event
+
+func (r *fakeRecorder) Record(_ context.Context, event audit.Event) {
+	*r = append(*r, event)
+}
+
+type fakeGuard struct {
+	action  string
+	results []string
+}
+
+func (g *fakeGuard) Assess(context.Context, uint, string, risk.Login) (risk.Assessment, error) {
+	return risk.Assessment{Signals: []string{risk.SignalNewDevice}, Action: g.action}, nil
+}
+
+func (g *fakeGuard) Record(_ context.Context, _ uint, _ string, _ risk.Login, result string, _ risk.Assessment) error {
+	g.results = append(g.results, result)
+	return nil
+}
+
+func newTestService() (*AuthService, *fakeStore, *fakeSigner, *realm.Realm) {
+	store := &fakeStore{
+		users: map[string]*model.User{
+			"alice": {Id: 1, RealmId: 1, UserName: "alice", Version: 3, Enabled: true,
+				Roles: []*model.Role{{Name: "ROLE_USER"}}},
+		},
+		revoked: map[string]time.Time{},
+	}
+	signer := &fakeSigner{claims: map[string]*jwt.VpnbeastClaim{}}
+	r := &realm.Realm{Realm: model.Realm{Id: 1, Name: "default", AccessTokenValidInMinutes: 60,
+		RefreshTokenValidInMinutes: 600}}
+	service := NewAuthService(store, func(*realm.Realm) Signer { return signer }, fakeVerifier{"alice": "secret"},
+		nil, nil, func() time.Time { return testNow })
+	return service, store, signer, r
+}
+
+func sessionClaims(subject, tokenType string, issuedAt time.Time, roles ...string) *jwt.VpnbeastClaim {
+	claims := &jwt.VpnbeastClaim{Type: tokenType, Roles: roles}
+	claims.Subject = subject
+	claims.IssuedAt = issuedAt.Unix()
+	claims.IssuedAtMillis = issuedAt.UnixMilli()
+	return claims
+}
+
+func TestAuthenticate(t *testing.T) {
+	service, store, _, r := newTestService()
+	user, err := service.Authenticate(context.Background(), r, Credentials{Username: "alice", Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if user.AccessToken != "alice-60" || user.RefreshToken != "alice-600" {
+		t.Errorf("unexpected tokens %s %s", user.AccessToken, user.RefreshToken)
+	}
+
+	if user.AccessTokenExpiresAt != "2022-02-01T11:00:00Z" || user.LastLogin != "2022-02-01T10:00:00Z" {
+		t.Errorf("expected the times to be calculated with the clock, got %s %s", user.AccessTokenExpiresAt,
+			user.LastLogin)
+	}
+
+	if len(store.saved) != 1 || store.saved[0] != user {
+		t.Errorf("expected the user to be saved once, got %v", store.saved)
+	}
+
+	if !reflect.DeepEqual(store.enriched, []string{"alice"}) {
+		t.Errorf("expected only the access token to be enriched, got %v", store.enriched)
+	}
+
+	_, err = service.Authenticate(context.Background(), r, Credentials{Username: "alice", Password: "wrong"})
+	if err != ErrInvalidCredentials {
+		t.Errorf("expected %v, got %v", ErrInvalidCredentials, err)
+	}
+
+	if len(store.saved) != 1 {
+		t.Errorf("expected failed login not to be saved")
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	service, store, signer, r := newTestService()
+	signer.claims["refresh"] = sessionClaims("alice", jwt.TokenTypeRefresh, testNow.Add(-time.Hour), "ROLE_USER",
+		"ROLE_ADMIN")
+	user, err := service.Refresh(context.Background(), r, "refresh")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if user.Version != 4 || user.AccessToken != "alice-60" {
+		t.Errorf("expected a new token pair, got version %d and %s", user.Version, user.AccessToken)
+	}
+
+	if !reflect.DeepEqual(signer.roles, []string{"ROLE_USER"}) {
+		t.Errorf("expected the current roles of the user instead of the roles of the token, got %v", signer.roles)
+	}
+
+	user.Enabled = false
+	if _, err := service.Refresh(context.Background(), r, "refresh"); err != ErrUserDisabled {
+		t.Errorf("expected %v, got %v", ErrUserDisabled, err)
+	}
+	user.Enabled = true
+
+	store.revoked["alice"] = testNow.Add(-time.Minute)
+	if _, err := service.Refresh(context.Background(), r, "refresh"); err != ErrTokenRevoked {
+		t.Errorf("expected %v, got %v", ErrTokenRevoked, err)
+	}
+
+	var tokenErr *TokenError
+	if _, err := service.Refresh(context.Background(), r, "malformed"); !errors.As(err, &tokenErr) ||
+		tokenErr.Code != http.StatusBadRequest {
+		t.Errorf("expected a token error with the code of the signer, got %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	service, _, signer, r := newTestService()
+	claims := sessionClaims("alice", jwt.TokenTypeAccess, testNow)
+	claims.Entitlements = map[string]jwt.EntitlementClaim{
+		"premium": {Value: "true", ExpiresAt: testNow.Add(time.Minute).Unix()},
+	}
+	signer.claims["access"] = claims
+	if _, err := service.Validate(context.Background(), r, "access"); err != nil {
+		t.Fatal(err)
+	}
+
+	claims.Entitlements["trial"] = jwt.EntitlementClaim{Value: "true", ExpiresAt: testNow.Unix()}
+	if _, err := service.Validate(context.Background(), r, "access"); err != ErrEntitlementExpired {
+		t.Errorf("expected %v, got %v", ErrEntitlementExpired, err)
+	}
+
+	signer.claims["orphan"] = sessionClaims("bob", jwt.TokenTypeAccess, testNow)
+	if _, err := service.Validate(context.Background(), r, "orphan"); err != ErrUserNotFound {
+		t.Errorf("expected %v, got %v", ErrUserNotFound, err)
+	}
+}
+
+func TestValidateSessionRevokedInTheSameSecond(t *testing.T) {
+	service, store, signer, r := newTestService()
+	store.revoked["alice"] = testNow.Add(500 * time.Millisecond)
+	signer.claims["before"] = sessionClaims("alice", jwt.TokenTypeAccess, testNow.Add(200*time.Millisecond))
+	signer.claims["after"] = sessionClaims("alice", jwt.TokenTypeAccess, testNow.Add(800*time.Millisecond))
+	if _, err := service.ValidateSession(context.Background(), r, "before"); err != ErrTokenRevoked {
+		t.Errorf("expected %v for the token issued before the logout, got %v", ErrTokenRevoked, err)
+	}
+
+	if _, err := service.ValidateSession(context.Background(), r, "after"); err != nil {
+		t.Errorf("expected the token issued after the logout to be valid, got %v", err)
+	}
+}
+
+func TestTokenTypes(t *testing.T) {
+	service, _, signer, r := newTestService()
+	user, err := service.Authenticate(context.Background(), r, Credentials{Username: "alice", Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	accessToken, refreshToken := user.AccessToken, user.RefreshToken
+
+	if _, err := service.Validate(context.Background(), r, refreshToken); err != ErrWrongTokenType {
+		t.Errorf("expected the refresh token to be refused by validate, got %v", err)
+	}
+
+	if _, err := service.WhoAmI(context.Background(), r, refreshToken); err != ErrWrongTokenType {
+		t.Errorf("expected the refresh token to be refused by whoami, got %v", err)
+	}
+
+	if _, err := service.Refresh(context.Background(), r, accessToken); err != ErrWrongTokenType {
+		t.Errorf("expected the access token to be refused by refresh, got %v", err)
+	}
+
+	if _, err := service.Validate(context.Background(), r, accessToken); err != nil {
+		t.Errorf("expected the access token to be valid, got %v", err)
+	}
+
+	if _, err := service.Refresh(context.Background(), r, refreshToken); err != nil {
+		t.Errorf("expected the refresh token to be refreshed, got %v", err)
+	}
+
+	// tokens issued before the type was introduced can only be refreshed
+	signer.claims["legacy"] = sessionClaims("alice", "", testNow)
+	if _, err := service.Validate(context.Background(), r, "legacy"); err != ErrWrongTokenType {
+		t.Errorf("expected the untyped token to be refused by validate, got %v", err)
+	}
+
+	if _, err := service.Refresh(context.Background(), r, "legacy"); err != nil {
+		t.Errorf("expected the untyped token to be refreshed, got %v", err)
+	}
+}
+
+func TestAuthenticateFederated(t *testing.T) {
+	service, store, _, r := newTestService()
+	guard := &fakeGuard{action: risk.ActionAllow}
+	recorder := &fakeRecorder{}
+	service.guard, service.recorder = guard, recorder
+	user := store.users["alice"]
+	if err := service.AuthenticateFederated(context.Background(), r, "google", user, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if user.AccessToken != "alice-60" || len(store.saved) != 1 {
+		t.Errorf("expected a new token pair, got %s", user.AccessToken)
+	}
+
+	user.Enabled = false
+	if err := service.AuthenticateFederated(context.Background(), r, "google", user, ""); err != ErrUserDisabled {
+		t.Errorf("expected %v, got %v", ErrUserDisabled, err)
+	}
+
+	if !reflect.DeepEqual(guard.results, []string{risk.ResultSuccess, risk.ResultFailure}) {
+		t.Errorf("expected both logins in the login history, got %v", guard.results)
+	}
+
+	// the guard flags the new device of the first login as suspicious
+	events := *recorder
+	if len(events) != 3 || events[0].Type != audit.EventLoginSuspicious || events[1].Type != audit.EventLoginSucceeded ||
+		events[2].Type != audit.EventLoginFailed || events[2].Details["provider"] != "google" {
+		t.Errorf("expected the logins to be assessed and audited with the provider, got %+v", events)
+	}
+}
+
+func TestAuthenticationMetrics(t *testing.T) {
+	service, _, _, r := newTestService()
+	cases := []struct {
+		creds   Credentials
+		outcome string
+	}{
+		{Credentials{Username: "alice", Password: "secret"}, outcomeSuccess},
+		{Credentials{Username: "alice", Password: "wrong"}, outcomeBadPassword},
+		{Credentials{Username: "bob", Password: "secret"}, outcomeUnknownUser},
+	}
+
+	for _, c := range cases {
+		attempts := authenticationAttempts.WithLabelValues(r.Name, c.outcome)
+		before := testutil.ToFloat64(attempts)
+		_, _ = service.Authenticate(context.Background(), r, c.creds)
+		if after := testutil.ToFloat64(attempts); after != before+1 {
+			t.Errorf("expected %s attempts to be incremented, got %v -> %v", c.outcome, before, after)
+		}
+	}
+
+	if outcome := authenticationOutcome(fmt.Errorf("ldap: %w", ErrUserLocked)); outcome != outcomeLocked {
+		t.Errorf("expected %s, got %s", outcomeLocked, outcome)
+	}
+}
+
+func TestAuthenticateRisk(t *testing.T) {
+	cases := []struct {
+		action string
+		err    error
+		result string
+	}{
+		{risk.ActionNotify, nil, risk.ResultSuccess},
+		{risk.ActionStepUp, ErrStepUpRequired, risk.ResultStepUp},
+		{risk.ActionBlock, ErrLoginBlocked, risk.ResultBlocked},
+	}
+
+	for _, c := range cases {
+		service, store, _, r := newTestService()
+		guard := &fakeGuard{action: c.action}
+		service.guard = guard
+		_, err := service.Authenticate(context.Background(), r, Credentials{Username: "alice", Password: "secret"})
+		if err != c.err {
+			t.Errorf("%s: expected %v, got %v", c.action, c.err, err)
+		}
+
+		if !reflect.DeepEqual(guard.results, []string{c.result}) {
+			t.Errorf("%s: expected the login to be recorded as %s, got %v", c.action, c.result, guard.results)
+		}
+
+		if issued := len(store.saved) == 1; issued != (c.err == nil) {
+			t.Errorf("%s: expected tokens to be issued only for the allowed logins", c.action)
+		}
+	}
+
+	service, _, _, r := newTestService()
+	guard := &fakeGuard{action: risk.ActionAllow}
+	service.guard = guard
+	_, _ = service.Authenticate(context.Background(), r, Credentials{Username: "alice", Password: "wrong"})
+	_, _ = service.Authenticate(context.Background(), r, Credentials{Username: "bob", Password: "wrong"})
+	if !reflect.DeepEqual(guard.results, []string{risk.ResultFailure}) {
+		t.Errorf("expected only the failure of the existing user to be recorded, got %v", guard.results)
+	}
+}
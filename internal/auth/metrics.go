@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"auth-service/internal/model"
+	"context"
+	"errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gorm.io/gorm"
+	"time"
+)
+
+// outcomes of an authentication attempt
+const (
+	outcomeSuccess     = "success"
+	outcomeBadPassword = "bad_password"
+	outcomeUnknownUser = "unknown_user"
+	outcomeLocked      = "locked"
+	outcomeDisabled    = "disabled"
+	outcomeBlocked     = "blocked"
+	outcomeStepUp      = "step_up_required"
+	outcomeError       = "error"
+)
+
+// token operations and their results
+const (
+	operationIssued    = "issued"
+	operationRefreshed = "refreshed"
+	operationValidated = "validated"
+	operationRevoked   = "revoked"
+
+	resultSuccess  = "success"
+	resultRejected = "rejected"
+	resultError    = "error"
+)
+
+var (
+	authenticationAttempts = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "auth_service",
+		Name:      "authentication_attempts_total",
+		Help:      "Number of the password authentication attempts by realm and outcome",
+	}, []string{"realm", "outcome"})
+	authenticationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "auth_service",
+		Name:      "authentication_duration_seconds",
+		Help:      "Latency of the password authentications by outcome, including the token issuance",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"outcome"})
+	tokenOperations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "auth_service",
+		Name:      "token_operations_total",
+		Help:      "Number of the issued, refreshed, validated and revoked tokens by result",
+	}, []string{"operation", "result"})
+)
+
+// authenticationOutcome maps the error of an authentication attempt to its outcome label
+func authenticationOutcome(err error) string {
+	switch {
+	case err == nil:
+		return outcomeSuccess
+	case errors.Is(err, ErrInvalidCredentials):
+		return outcomeBadPassword
+	case errors.Is(err, ErrUserNotFound):
+		return outcomeUnknownUser
+	case errors.Is(err, ErrUserLocked):
+		return outcomeLocked
+	case errors.Is(err, ErrUserDisabled):
+		return outcomeDisabled
+	case errors.Is(err, ErrLoginBlocked):
+		return outcomeBlocked
+	case errors.Is(err, ErrStepUpRequired):
+		return outcomeStepUp
+	default:
+		return outcomeError
+	}
+}
+
+// observeAuthentication records the outcome and the latency of an authentication attempt
+func observeAuthentication(realm string, start time.Time, err error) {
+	outcome := authenticationOutcome(err)
+	authenticationAttempts.WithLabelValues(realm, outcome).Inc()
+	authenticationDuration.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+}
+
+// observeTokenOperation records the result of a token operation, refused tokens are counted as rejected
+func observeTokenOperation(operation string, err error) {
+	var tokenErr *TokenError
+	result := resultSuccess
+	switch {
+	case err == nil:
+	case errors.As(err, &tokenErr), errors.Is(err, ErrUserNotFound):
+		result = resultRejected
+	default:
+		result = resultError
+	}
+
+	tokenOperations.WithLabelValues(operation, result).Inc()
+}
+
+// SessionCollector exports the number of the active sessions, which are the users with an unexpired refresh token.
+// Sessions are counted in the database on every scrape, so all the replicas export the same value
+type SessionCollector struct {
+	db      *gorm.DB
+	timeout time.Duration
+	desc    *prometheus.Desc
+}
+
+// NewSessionCollector creates a SessionCollector, count query is cancelled after the timeout
+func NewSessionCollector(db *gorm.DB, timeout time.Duration) *SessionCollector {
+	return &SessionCollector{
+		db:      db,
+		timeout: timeout,
+		desc: prometheus.NewDesc("auth_service_active_sessions", "Number of the users with an unexpired "+
+			"refresh token", nil, nil),
+	}
+}
+
+// Describe implements prometheus.Collector
+func (c *SessionCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+// Collect implements prometheus.Collector, the metric is reported as invalid if the sessions can not be counted
+func (c *SessionCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	// expiry is stored in RFC3339 with the local offset of the service, so it is compared in the same format
+	var count int64
+	err := c.db.WithContext(ctx).Model(&model.User{}).
+		Where("refresh_token <> '' AND refresh_token_expires_at > ?", time.Now().Format(time.RFC3339)).
+		Count(&count).Error
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(count))
+}
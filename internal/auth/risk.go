@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"auth-service/internal/audit"
+	"auth-service/internal/realm"
+	"auth-service/internal/risk"
+	"context"
+	"errors"
+)
+
+var (
+	// ErrLoginBlocked is returned when the risk rules block a suspicious login
+	ErrLoginBlocked = errors.New("login is blocked")
+	// ErrStepUpRequired is returned when the risk rules require a second factor for a suspicious login
+	ErrStepUpRequired = errors.New("login requires a second factor")
+)
+
+// LoginGuard keeps the login history of the users and assesses the risk of their logins, *risk.Guard implements it
+type LoginGuard interface {
+	Assess(ctx context.Context, realmId uint, username string, login risk.Login) (risk.Assessment, error)
+	Record(ctx context.Context, realmId uint, username string, login risk.Login, result string,
+		assessment risk.Assessment) error
+}
+
+type allowGuard struct{}
+
+func (allowGuard) Assess(context.Context, uint, string, risk.Login) (risk.Assessment, error) {
+	return risk.Assessment{Action: risk.ActionAllow}, nil
+}
+
+func (allowGuard) Record(context.Context, uint, string, risk.Login, string, risk.Assessment) error {
+	return nil
+}
+
+// newLogin describes the client of the login from the request of the context
+func (s *AuthService) newLogin(ctx context.Context, creds Credentials) risk.Login {
+	request := audit.RequestFromContext(ctx)
+	return risk.Login{
+		Time:        s.now(),
+		Ip:          request.Ip,
+		UserAgent:   request.UserAgent,
+		Fingerprint: risk.Fingerprint(creds.DeviceId, request.UserAgent),
+	}
+}
+
+// assessLogin evaluates the risk of the verified login and records it in the history of the user, ErrLoginBlocked or
+// ErrStepUpRequired is returned if the rules do not allow the login
+func (s *AuthService) assessLogin(ctx context.Context, r *realm.Realm, username string, login risk.Login) error {
+	assessment, err := s.guard.Assess(ctx, r.Id, username, login)
+	if err != nil {
+		return err
+	}
+
+	if assessment.Flagged() {
+		s.recorder.Record(ctx, risk.SuspiciousLoginEvent(r.Id, username, assessment))
+	}
+
+	result := risk.ResultSuccess
+	switch assessment.Action {
+	case risk.ActionBlock:
+		err, result = ErrLoginBlocked, risk.ResultBlocked
+	case risk.ActionStepUp:
+		err, result = ErrStepUpRequired, risk.ResultStepUp
+	}
+
+	if recordErr := s.guard.Record(ctx, r.Id, username, login, result, assessment); recordErr != nil {
+		return recordErr
+	}
+
+	return err
+}
+
+// recordFailedLogin records the login of an existing user which is refused by the identity backend, failures of the
+// unknown users are not recorded so they can not fill the history
+func (s *AuthService) recordFailedLogin(ctx context.Context, r *realm.Realm, username string, login risk.Login,
+	err error) {
+	if errors.Is(err, ErrInvalidCredentials) || errors.Is(err, ErrUserLocked) || errors.Is(err, ErrUserDisabled) {
+		_ = s.guard.Record(ctx, r.Id, username, login, risk.ResultFailure, risk.Assessment{})
+	}
+}
@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"auth-service/internal/entitlement"
+	"auth-service/internal/jwt"
+	"auth-service/internal/model"
+	"auth-service/internal/pat"
+	"auth-service/internal/rbac"
+	"auth-service/internal/revocation"
+	"context"
+	"errors"
+	"gorm.io/gorm"
+	"time"
+)
+
+// GormStore is the Store on the database of auth-service
+type GormStore struct {
+	db        *gorm.DB
+	listeners []revocation.Listener
+}
+
+// NewGormStore creates a GormStore on the database, listeners are notified whenever the sessions of a user are revoked
+func NewGormStore(db *gorm.DB, listeners ...revocation.Listener) *GormStore {
+	return &GormStore{db: db, listeners: listeners}
+}
+
+// FindUser loads the user of the realm with its roles
+func (s *GormStore) FindUser(ctx context.Context, realmId uint, username string) (*model.User, error) {
+	var user model.User
+	err := s.db.WithContext(ctx).Preload("Roles").Where("realm_id = ? AND user_name = ?", realmId, username).
+		First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUserNotFound
+	}
+
+	return &user, err
+}
+
+// SaveUser saves all the fields of the user
+func (s *GormStore) SaveUser(ctx context.Context, user *model.User) error {
+	return s.db.WithContext(ctx).Save(user).Error
+}
+
+// IsRevoked checks the session revocations, see revocation.IsRevoked
+func (s *GormStore) IsRevoked(ctx context.Context, realmId uint, subject string, issuedAt time.Time) (bool,
+	error) {
+	return revocation.IsRevoked(s.db.WithContext(ctx), realmId, subject, issuedAt)
+}
+
+// RevokeSessions revokes the sessions of the user, see revocation.RevokeSessions
+func (s *GormStore) RevokeSessions(ctx context.Context, user *model.User) error {
+	return revocation.RevokeSessions(s.db.WithContext(ctx), user, s.listeners...)
+}
+
+// ResolvePersonalAccessToken resolves the token, see pat.Resolve
+func (s *GormStore) ResolvePersonalAccessToken(ctx context.Context, token string) (*model.User, []string, error) {
+	user, roles, err := pat.Resolve(s.db.WithContext(ctx), token)
+	if err == pat.ErrInvalidToken {
+		return nil, nil, ErrInvalidToken
+	}
+
+	return user, roles, err
+}
+
+// Enrichers returns the entitlement and the rbac enrichers of the user
+func (s *GormStore) Enrichers(ctx context.Context, realmId, userId uint, roles []string) []jwt.ClaimEnricher {
+	db := s.db.WithContext(ctx)
+	return []jwt.ClaimEnricher{entitlement.Enricher(db, userId), rbac.Enricher(db, realmId, roles)}
+}
@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"auth-service/internal/jwt"
+	"auth-service/internal/pat"
+	"auth-service/internal/realm"
+	"auth-service/internal/tracing"
+	"context"
+	"errors"
+	"net/http"
+)
+
+// TokenTypePat is the id claim of the claims built for a personal access token
+const TokenTypePat = "pat"
+
+var (
+	// ErrTokenRevoked is returned when the sessions of the subject are revoked after the token is issued
+	ErrTokenRevoked = &TokenError{Err: errors.New("Token is revoked!"), Code: http.StatusUnauthorized}
+	// ErrInvalidToken is returned when a personal access token is unknown, revoked or belongs to another realm
+	ErrInvalidToken = &TokenError{Err: errors.New("Invalid token!"), Code: http.StatusUnauthorized}
+	// ErrEntitlementExpired is returned when the token carries an expired entitlement and must be refreshed
+	ErrEntitlementExpired = &TokenError{Err: errors.New("Entitlement expired, token must be refreshed!"),
+		Code: http.StatusUnauthorized}
+	// ErrWrongTokenType is returned when a refresh token is used as an access token or vice versa
+	ErrWrongTokenType = &TokenError{Err: errors.New("Wrong token type!"), Code: http.StatusUnauthorized}
+)
+
+// TokenError represents a refused token, Code is the http status code of the refusal. Other errors of the token
+// validation are internal errors
+type TokenError struct {
+	Err  error
+	Code int
+}
+
+func (e *TokenError) Error() string {
+	return e.Err.Error()
+}
+
+// ValidateSession validates the session token(JWT) of the realm and makes sure that the sessions of the subject are
+// not revoked after the token is issued, both access and refresh tokens are accepted
+func (s *AuthService) ValidateSession(ctx context.Context, r *realm.Realm, token string) (*jwt.VpnbeastClaim,
+	error) {
+	_, span := tracing.Start(ctx, "jwt.ParseToken")
+	claims, err, code := s.signers(r).ParseToken(token)
+	tracing.End(span, err)
+	if err != nil {
+		return nil, &TokenError{Err: err, Code: code}
+	}
+
+	revoked, err := s.store.IsRevoked(ctx, r.Id, claims.Subject, claims.IssuedAtTime())
+	if err != nil {
+		return nil, err
+	}
+
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+
+	return claims, nil
+}
+
+// ValidateAccessToken is like ValidateSession but refuses the refresh tokens, which do not carry the entitlements
+// and live much longer. Tokens without a type are refused too, their holders get a typed pair by refreshing
+func (s *AuthService) ValidateAccessToken(ctx context.Context, r *realm.Realm, token string) (*jwt.VpnbeastClaim,
+	error) {
+	claims, err := s.ValidateSession(ctx, r, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Type != jwt.TokenTypeAccess {
+		return nil, ErrWrongTokenType
+	}
+
+	return claims, nil
+}
+
+// ValidateRefreshToken is like ValidateSession but refuses the access tokens. Tokens without a type are accepted
+// since the refresh tokens issued before the type was introduced do not carry it
+func (s *AuthService) ValidateRefreshToken(ctx context.Context, r *realm.Realm, token string) (*jwt.VpnbeastClaim,
+	error) {
+	claims, err := s.ValidateSession(ctx, r, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if claims.Type == jwt.TokenTypeAccess {
+		return nil, ErrWrongTokenType
+	}
+
+	return claims, nil
+}
+
+// AuthenticateToken validates either a session access token(JWT) or a personal access token of the realm. Claims of
+// a personal access token are built from the user and the scopes of the token, its id claim is set to TokenTypePat
+func (s *AuthService) AuthenticateToken(ctx context.Context, r *realm.Realm, token string) (*jwt.VpnbeastClaim,
+	error) {
+	if !pat.IsPersonalAccessToken(token) {
+		return s.ValidateAccessToken(ctx, r, token)
+	}
+
+	user, roles, err := s.store.ResolvePersonalAccessToken(ctx, token)
+	if err == ErrInvalidToken || (err == nil && user.RealmId != r.Id) {
+		return nil, ErrInvalidToken
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &jwt.VpnbeastClaim{Roles: roles, Realm: r.Name}
+	claims.Id = TokenTypePat
+	claims.Subject = user.UserName
+	claims.Issuer = r.Issuer()
+	for _, enrich := range s.store.Enrichers(ctx, r.Id, user.Id, roles) {
+		if err := enrich(claims); err != nil {
+			return nil, err
+		}
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,31 @@
+package auth
+
+import (
+	"auth-service/internal/identity"
+	"auth-service/internal/model"
+	"auth-service/internal/realm"
+	"context"
+	"gorm.io/gorm"
+)
+
+// BackendVerifier is the PasswordVerifier which verifies the password with the identity backend of the user
+type BackendVerifier struct {
+	db       *gorm.DB
+	backends *identity.Registry
+}
+
+// NewBackendVerifier creates a BackendVerifier which selects the backends of the users from the registry
+func NewBackendVerifier(db *gorm.DB, backends *identity.Registry) *BackendVerifier {
+	return &BackendVerifier{db: db, backends: backends}
+}
+
+// Verify selects the identity backend of the user and authenticates the user with it
+func (v *BackendVerifier) Verify(ctx context.Context, r *realm.Realm, username, password string) (*model.User,
+	error) {
+	backend, err := v.backends.Select(v.db, &r.Realm, username)
+	if err != nil {
+		return nil, err
+	}
+
+	return backend.Authenticate(ctx, v.db, r.Id, username, password)
+}
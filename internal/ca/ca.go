@@ -0,0 +1,303 @@
+package ca
+
+import (
+	"auth-service/internal/model"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	commons "github.com/vpnbeast/golang-commons"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"math/big"
+	"strings"
+	"time"
+)
+
+const (
+	statusGood    = "good"
+	statusRevoked = "revoked"
+	statusUnknown = "unknown"
+)
+
+var (
+	// ErrInvalidCsr is returned when the certificate signing request can not be parsed or its signature is invalid
+	ErrInvalidCsr = errors.New("invalid certificate signing request")
+	// ErrNoEligibleRole is returned when none of the roles of the user is mapped to an extended key usage
+	ErrNoEligibleRole = errors.New("user has no role eligible for a vpn certificate")
+
+	logger = commons.GetLogger()
+)
+
+// CertificateAuthority signs short-lived vpn client certificates and keeps track of them for revocation
+type CertificateAuthority struct {
+	certificate    *x509.Certificate
+	certificatePem []byte
+	signer         crypto.Signer
+	usagesByRole   map[string][]x509.ExtKeyUsage
+	crlValidity    time.Duration
+}
+
+// NewCertificateAuthority creates a CertificateAuthority from PEM encoded certificate and private key. usages maps
+// roles to extended key usages in "ROLE_USER:clientAuth;ROLE_ADMIN:clientAuth,serverAuth" format
+func NewCertificateAuthority(certificatePem, privateKeyPem, usages string,
+	crlValidity time.Duration) (*CertificateAuthority, error) {
+	certBlock, _ := pem.Decode([]byte(certificatePem))
+	if certBlock == nil {
+		return nil, errors.New("can not decode ca certificate")
+	}
+
+	certificate, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := parsePrivateKey(privateKeyPem)
+	if err != nil {
+		return nil, err
+	}
+
+	usagesByRole, err := parseRoleExtKeyUsages(usages)
+	if err != nil {
+		return nil, err
+	}
+
+	if crlValidity <= 0 {
+		crlValidity = 24 * time.Hour
+	}
+
+	return &CertificateAuthority{
+		certificate:    certificate,
+		certificatePem: pem.EncodeToMemory(certBlock),
+		signer:         signer,
+		usagesByRole:   usagesByRole,
+		crlValidity:    crlValidity,
+	}, nil
+}
+
+// CertificatePem returns the PEM encoded certificate of the certificate authority
+func (ca *CertificateAuthority) CertificatePem() []byte {
+	return ca.certificatePem
+}
+
+// Sign signs the PEM encoded certificate signing request for the user and stores the issued certificate, the
+// certificate is valid until notAfter
+func (ca *CertificateAuthority) Sign(db *gorm.DB, user *model.User, roles []string, csrPem string,
+	notAfter time.Time) (*model.VpnCertificate, []byte, error) {
+	issued, certificatePem, err := ca.issue(user, roles, csrPem, notAfter)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	certificate := &model.VpnCertificate{
+		SerialNumber: issued.SerialNumber.Text(16),
+		UserId:       user.Id,
+		UserName:     user.UserName,
+		Subject:      issued.Subject.String(),
+		NotBefore:    issued.NotBefore,
+		NotAfter:     issued.NotAfter,
+		CreatedAt:    time.Now(),
+	}
+
+	if err := db.Create(certificate).Error; err != nil {
+		return nil, nil, err
+	}
+
+	return certificate, certificatePem, nil
+}
+
+func (ca *CertificateAuthority) issue(user *model.User, roles []string, csrPem string,
+	notAfter time.Time) (*x509.Certificate, []byte, error) {
+	block, _ := pem.Decode([]byte(csrPem))
+	if block == nil || block.Type != "CERTIFICATE REQUEST" {
+		return nil, nil, ErrInvalidCsr
+	}
+
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, nil, ErrInvalidCsr
+	}
+
+	if err := csr.CheckSignature(); err != nil {
+		return nil, nil, ErrInvalidCsr
+	}
+
+	extKeyUsages := ca.extKeyUsages(roles)
+	if len(extKeyUsages) == 0 {
+		return nil, nil, ErrNoEligibleRole
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if notAfter.After(ca.certificate.NotAfter) {
+		notAfter = ca.certificate.NotAfter
+	}
+
+	// everything except the public key is taken from the authenticated user, never from the csr
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName:   user.UserName,
+			SerialNumber: user.Uuid,
+		},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:           extKeyUsages,
+		BasicConstraintsValid: true,
+		IsCA:                  false,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.certificate, csr.PublicKey, ca.signer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	issued, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return issued, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), nil
+}
+
+// RevokeUserCertificates revokes all the unexpired certificates of the user, it is meant to be registered as a
+// revocation.Listener
+func (ca *CertificateAuthority) RevokeUserCertificates(tx *gorm.DB, user *model.User) error {
+	now := time.Now()
+	return tx.Model(&model.VpnCertificate{}).
+		Where("user_id = ? AND revoked_at IS NULL AND not_after > ?", user.Id, now).
+		Update("revoked_at", now).Error
+}
+
+// GenerateCrl generates a DER encoded certificate revocation list of the revoked and unexpired certificates
+func (ca *CertificateAuthority) GenerateCrl(db *gorm.DB) ([]byte, error) {
+	var certificates []model.VpnCertificate
+	now := time.Now()
+	err := db.Where("revoked_at IS NOT NULL AND not_after > ?", now).Find(&certificates).Error
+	if err != nil {
+		return nil, err
+	}
+
+	revoked := make([]pkix.RevokedCertificate, 0, len(certificates))
+	for _, c := range certificates {
+		serial, ok := new(big.Int).SetString(c.SerialNumber, 16)
+		if !ok {
+			logger.Warn("skipping certificate with malformed serial number", zap.String("serial", c.SerialNumber))
+			continue
+		}
+
+		revoked = append(revoked, pkix.RevokedCertificate{
+			SerialNumber:   serial,
+			RevocationTime: *c.RevokedAt,
+		})
+	}
+
+	return x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		RevokedCertificates: revoked,
+		// a nanosecond timestamp based crl number is monotonically increasing without keeping extra state, even for
+		// the crls generated in the same second, and stays greater than the second based numbers issued before
+		Number:     big.NewInt(now.UnixNano()),
+		ThisUpdate: now,
+		NextUpdate: now.Add(ca.crlValidity),
+	}, ca.certificate, ca.signer)
+}
+
+// CertificateStatus returns the OCSP like status of the certificate with the given hex encoded serial number
+func (ca *CertificateAuthority) CertificateStatus(db *gorm.DB, serial string) (string, *model.VpnCertificate, error) {
+	var certificate model.VpnCertificate
+	err := db.Where("serial_number = ?", strings.ToLower(serial)).First(&certificate).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return statusUnknown, nil, nil
+	}
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	if certificate.RevokedAt != nil {
+		return statusRevoked, &certificate, nil
+	}
+
+	return statusGood, &certificate, nil
+}
+
+func (ca *CertificateAuthority) extKeyUsages(roles []string) []x509.ExtKeyUsage {
+	seen := make(map[x509.ExtKeyUsage]bool)
+	var usages []x509.ExtKeyUsage
+	for _, role := range roles {
+		for _, usage := range ca.usagesByRole[role] {
+			if !seen[usage] {
+				seen[usage] = true
+				usages = append(usages, usage)
+			}
+		}
+	}
+
+	return usages
+}
+
+func parsePrivateKey(privateKeyPem string) (crypto.Signer, error) {
+	block, _ := pem.Decode([]byte(privateKeyPem))
+	if block == nil {
+		return nil, errors.New("can not decode ca private key")
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		switch k := key.(type) {
+		case *rsa.PrivateKey:
+			return k, nil
+		case *ecdsa.PrivateKey:
+			return k, nil
+		default:
+			return nil, fmt.Errorf("unsupported ca private key type %T", key)
+		}
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	return x509.ParseECPrivateKey(block.Bytes)
+}
+
+var extKeyUsageNames = map[string]x509.ExtKeyUsage{
+	"clientAuth":      x509.ExtKeyUsageClientAuth,
+	"serverAuth":      x509.ExtKeyUsageServerAuth,
+	"codeSigning":     x509.ExtKeyUsageCodeSigning,
+	"emailProtection": x509.ExtKeyUsageEmailProtection,
+}
+
+func parseRoleExtKeyUsages(spec string) (map[string][]x509.ExtKeyUsage, error) {
+	usagesByRole := make(map[string][]x509.ExtKeyUsage)
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("malformed role extended key usage mapping %q", entry)
+		}
+
+		role := strings.TrimSpace(parts[0])
+		for _, name := range strings.Split(parts[1], ",") {
+			usage, ok := extKeyUsageNames[strings.TrimSpace(name)]
+			if !ok {
+				return nil, fmt.Errorf("unknown extended key usage %q", name)
+			}
+			usagesByRole[role] = append(usagesByRole[role], usage)
+		}
+	}
+
+	return usagesByRole, nil
+}
@@ -0,0 +1,106 @@
+package ca
+
+import (
+	"auth-service/internal/model"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func newTestAuthority(t *testing.T) *CertificateAuthority {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "vpnbeast test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDer, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authority, err := NewCertificateAuthority(
+		string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDer})),
+		"ROLE_USER:clientAuth;ROLE_ADMIN:clientAuth,serverAuth", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return authority
+}
+
+func newTestCsr(t *testing.T) string {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// subject of the csr must be ignored in favor of the authenticated user
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: "admin"},
+	}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}))
+}
+
+func TestIssue(t *testing.T) {
+	authority := newTestAuthority(t)
+	user := &model.User{UserName: "foo", Uuid: "2b1b5a4e-6c1e-4f1a-9a8b-9f2f0d2c7b11"}
+	notAfter := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	certificate, _, err := authority.issue(user, []string{"ROLE_USER"}, newTestCsr(t), notAfter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if certificate.Subject.CommonName != user.UserName || certificate.Subject.SerialNumber != user.Uuid {
+		t.Errorf("unexpected subject %s", certificate.Subject)
+	}
+
+	if !certificate.NotAfter.Equal(notAfter) {
+		t.Errorf("expected notAfter %s, got %s", notAfter, certificate.NotAfter)
+	}
+
+	if len(certificate.ExtKeyUsage) != 1 || certificate.ExtKeyUsage[0] != x509.ExtKeyUsageClientAuth {
+		t.Errorf("unexpected extended key usages %v", certificate.ExtKeyUsage)
+	}
+}
+
+func TestIssueNoEligibleRole(t *testing.T) {
+	authority := newTestAuthority(t)
+	_, _, err := authority.issue(&model.User{UserName: "foo"}, []string{"ROLE_GUEST"}, newTestCsr(t), time.Now())
+	if err != ErrNoEligibleRole {
+		t.Errorf("expected %v, got %v", ErrNoEligibleRole, err)
+	}
+}
+
+func TestIssueInvalidCsr(t *testing.T) {
+	authority := newTestAuthority(t)
+	_, _, err := authority.issue(&model.User{UserName: "foo"}, []string{"ROLE_USER"}, "garbage", time.Now())
+	if err != ErrInvalidCsr {
+		t.Errorf("expected %v, got %v", ErrInvalidCsr, err)
+	}
+}
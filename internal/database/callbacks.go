@@ -0,0 +1,29 @@
+package database
+
+import "gorm.io/gorm"
+
+// registerCallbacks registers the callbacks which run before and after the create, query, update, delete, row and raw
+// processors, callbacks are created for the operation name of each processor
+func registerCallbacks(db *gorm.DB, name string, before, after func(operation string) func(*gorm.DB)) error {
+	callbacks := db.Callback()
+	processors := map[string][2]func(name string, fn func(*gorm.DB)) error{
+		"create": {callbacks.Create().Before("*").Register, callbacks.Create().After("*").Register},
+		"query":  {callbacks.Query().Before("*").Register, callbacks.Query().After("*").Register},
+		"update": {callbacks.Update().Before("*").Register, callbacks.Update().After("*").Register},
+		"delete": {callbacks.Delete().Before("*").Register, callbacks.Delete().After("*").Register},
+		"row":    {callbacks.Row().Before("*").Register, callbacks.Row().After("*").Register},
+		"raw":    {callbacks.Raw().Before("*").Register, callbacks.Raw().After("*").Register},
+	}
+
+	for operation, register := range processors {
+		if err := register[0](name+":before_"+operation, before(operation)); err != nil {
+			return err
+		}
+
+		if err := register[1](name+":after_"+operation, after(operation)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
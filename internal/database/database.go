@@ -3,54 +3,53 @@ package database
 import (
 	"auth-service/internal/options"
 	"database/sql"
-	"github.com/gorilla/mux"
 	commons "github.com/vpnbeast/golang-commons"
-	"go.uber.org/zap"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 	"time"
 )
 
-var (
-	logger *zap.Logger
-	router *mux.Router
-	gormdb *gorm.DB
-	sqlDB  *sql.DB
-	opts   *options.AuthServiceOptions
-	err    error
-)
-
-func init() {
-	logger = commons.GetLogger()
-	router = mux.NewRouter()
-	opts = options.GetAuthServiceOptions()
-}
+var logger = commons.GetLogger()
 
-// InitDatabase initializes the database connection
-func InitDatabase() *gorm.DB {
-	gormdb, err = gorm.Open(mysql.Open(opts.DbUrl), &gorm.Config{})
+// Open opens the database connection pool and applies the pending migrations, pool must be closed by the caller
+func Open(opts *options.AuthServiceOptions) (*gorm.DB, error) {
+	db, err := gorm.Open(mysql.Open(opts.DbUrl), &gorm.Config{})
 	if err != nil {
-		logger.Fatal("fatal error occurred while opening database connection", zap.String("error", err.Error()))
-		return nil
+		return nil, err
 	}
 
-	sqlDB, err = gormdb.DB()
+	sqlDb, err := db.DB()
 	if err != nil {
-		logger.Fatal("fatal error occurred while getting sql.DB from gorm.DB", zap.String("error", err.Error()))
-		return nil
+		return nil, err
 	}
 
-	tuneDbPooling(sqlDB, opts.DbMaxOpenConn, opts.DbMaxIdleConn, opts.DbConnMaxLifetimeMin)
-	go func() {
-		RunHealthProbe(router)
-	}()
+	tuneDbPooling(sqlDb, opts.DbMaxOpenConn, opts.DbMaxIdleConn, opts.DbConnMaxLifetimeMin)
+	if err := registerMetrics(db); err != nil {
+		_ = sqlDb.Close()
+		return nil, err
+	}
+
+	if err := db.Use(tracingPlugin{}); err != nil {
+		_ = sqlDb.Close()
+		return nil, err
+	}
 
-	return gormdb
+	if err := runMigrations(db); err != nil {
+		_ = sqlDb.Close()
+		return nil, err
+	}
+
+	return db, nil
 }
 
-// GetDatabase returns the initialized *sql.DB instance
-func GetDatabase() *gorm.DB {
-	return gormdb
+// Close closes the connection pool of the database
+func Close(db *gorm.DB) error {
+	sqlDb, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	return sqlDb.Close()
 }
 
 // Read on https://www.alexedwards.net/blog/configuring-sqldb for detailed explanation
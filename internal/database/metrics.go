@@ -0,0 +1,44 @@
+package database
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gorm.io/gorm"
+	"time"
+)
+
+const startedAtKey = "metrics:started_at"
+
+var queryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "auth_service",
+	Subsystem: "db",
+	Name:      "query_duration_seconds",
+	Help:      "Latency of the database queries by operation and table",
+	Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+}, []string{"operation", "table"})
+
+// registerMetrics observes the latency of every statement executed through gorm
+func registerMetrics(db *gorm.DB) error {
+	return registerCallbacks(db, "metrics", startTimer, observeQuery)
+}
+
+func startTimer(string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		db.InstanceSet(startedAtKey, time.Now())
+	}
+}
+
+func observeQuery(operation string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		startedAt, ok := db.InstanceGet(startedAtKey)
+		if !ok {
+			return
+		}
+
+		table := db.Statement.Table
+		if table == "" {
+			table = "unknown"
+		}
+		queryDuration.WithLabelValues(operation, table).Observe(time.Since(startedAt.(time.Time)).Seconds())
+	}
+}
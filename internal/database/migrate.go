@@ -0,0 +1,235 @@
+package database
+
+import (
+	"auth-service/internal/jwt"
+	"auth-service/internal/model"
+	"fmt"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"time"
+)
+
+// schemaMigration represents an applied migration, users and roles tables are managed outside auth-service so only
+// the tables owned by auth-service are migrated here
+type schemaMigration struct {
+	Id        string `gorm:"primaryKey;size:128"`
+	AppliedAt time.Time
+}
+
+// migration represents a single schema change, id of a migration must never be changed once it is released
+type migration struct {
+	id      string
+	migrate func(tx *gorm.DB) error
+}
+
+var migrations = []migration{
+	{
+		id: "0001_create_session_revocations",
+		migrate: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&model.SessionRevocation{})
+		},
+	},
+	{
+		id: "0002_create_vpn_certificates",
+		migrate: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&model.VpnCertificate{})
+		},
+	},
+	{
+		id: "0003_create_entitlements",
+		migrate: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&model.Entitlement{})
+		},
+	},
+	{
+		id: "0004_create_personal_access_tokens",
+		migrate: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&model.PersonalAccessToken{})
+		},
+	},
+	{
+		id: "0005_create_permissions",
+		migrate: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&model.Permission{}, &model.RolePermission{}, &model.RoleParent{})
+		},
+	},
+	{
+		id: "0006_create_policies",
+		migrate: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&model.Policy{})
+		},
+	},
+	{
+		id:      "0007_create_realms",
+		migrate: migrateRealms,
+	},
+	{
+		id: "0008_create_federated_identities",
+		migrate: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&model.FederatedIdentity{})
+		},
+	},
+	{
+		id: "0009_add_identity_backends",
+		migrate: func(tx *gorm.DB) error {
+			if err := tx.AutoMigrate(&model.Realm{}); err != nil {
+				return err
+			}
+
+			// users table is not owned by auth-service, only the column is added
+			return tx.Migrator().AddColumn(&model.User{}, "IdentityBackend")
+		},
+	},
+	{
+		id: "0010_add_user_external_ids",
+		migrate: func(tx *gorm.DB) error {
+			return tx.Migrator().AddColumn(&model.User{}, "ExternalId")
+		},
+	},
+	{
+		id: "0011_create_audit_events",
+		migrate: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&model.AuditEvent{})
+		},
+	},
+	{
+		id: "0012_create_login_histories",
+		migrate: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&model.LoginHistory{})
+		},
+	},
+	{
+		id:      "0013_default_realm_ids",
+		migrate: migrateDefaultRealmIds,
+	},
+}
+
+// migrateRealms creates the realms and moves the existing users, roles and session revocations into the default
+// realm. users and roles tables are not owned by auth-service, so only the realm_id column is added to them. mysql
+// commits the schema changes implicitly, so every step must be safe to re-run after a failed attempt
+func migrateRealms(tx *gorm.DB) error {
+	if err := tx.AutoMigrate(&model.Realm{}, &model.RealmKey{}); err != nil {
+		return err
+	}
+
+	defaultRealm := model.Realm{
+		Name:      jwt.DefaultRealm,
+		Enabled:   true,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	if err := tx.Where("name = ?", jwt.DefaultRealm).FirstOrCreate(&defaultRealm).Error; err != nil {
+		return err
+	}
+
+	for _, table := range []interface{}{&model.User{}, &model.Role{}, &model.SessionRevocation{}} {
+		if !tx.Migrator().HasColumn(table, "RealmId") {
+			if err := tx.Migrator().AddColumn(table, "RealmId"); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Model(table).Where("1 = 1").Update("realm_id", defaultRealm.Id).Error; err != nil {
+			return err
+		}
+	}
+
+	// user names are unique per realm from now on
+	if tx.Migrator().HasIndex(&model.SessionRevocation{}, "idx_session_revocations_user_name") {
+		err := tx.Migrator().DropIndex(&model.SessionRevocation{}, "idx_session_revocations_user_name")
+		if err != nil {
+			return err
+		}
+	}
+
+	if tx.Migrator().HasIndex(&model.SessionRevocation{}, "idx_session_revocations_realm_user") {
+		return nil
+	}
+
+	return tx.Migrator().CreateIndex(&model.SessionRevocation{}, "idx_session_revocations_realm_user")
+}
+
+// migrateDefaultRealmIds makes the default realm the column default of realm_id in users and roles, since they are
+// created by user-service which does not know about the realms. Rows which were created without a realm are moved
+// into the default realm
+func migrateDefaultRealmIds(tx *gorm.DB) error {
+	var defaultRealm model.Realm
+	if err := tx.Where("name = ?", jwt.DefaultRealm).First(&defaultRealm).Error; err != nil {
+		return err
+	}
+
+	for _, table := range []string{"users", "roles"} {
+		// placeholders are not supported in the column defaults, id is an unsigned integer
+		alter := fmt.Sprintf("ALTER TABLE %s ALTER COLUMN realm_id SET DEFAULT %d", table, defaultRealm.Id)
+		if err := tx.Exec(alter).Error; err != nil {
+			return err
+		}
+
+		err := tx.Table(table).Where("realm_id IS NULL OR realm_id = 0").Update("realm_id", defaultRealm.Id).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PendingMigrations returns the ids of the migrations which are not applied yet
+func PendingMigrations(db *gorm.DB) ([]string, error) {
+	var applied []string
+	if err := db.Model(&schemaMigration{}).Pluck("id", &applied).Error; err != nil {
+		return nil, err
+	}
+
+	appliedIds := make(map[string]bool, len(applied))
+	for _, id := range applied {
+		appliedIds[id] = true
+	}
+
+	var pending []string
+	for _, m := range migrations {
+		if !appliedIds[m.id] {
+			pending = append(pending, m.id)
+		}
+	}
+
+	return pending, nil
+}
+
+// runMigrations applies the pending migrations in order, each migration runs in its own transaction
+func runMigrations(db *gorm.DB) error {
+	if err := db.AutoMigrate(&schemaMigration{}); err != nil {
+		return err
+	}
+
+	var applied []schemaMigration
+	if err := db.Find(&applied).Error; err != nil {
+		return err
+	}
+
+	appliedIds := make(map[string]bool, len(applied))
+	for _, m := range applied {
+		appliedIds[m.Id] = true
+	}
+
+	for _, m := range migrations {
+		if appliedIds[m.id] {
+			continue
+		}
+
+		logger.Info("applying migration", zap.String("migration", m.id))
+		m := m
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.migrate(tx); err != nil {
+				return err
+			}
+
+			return tx.Create(&schemaMigration{Id: m.id, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
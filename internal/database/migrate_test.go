@@ -0,0 +1,116 @@
+package database
+
+import (
+	"auth-service/internal/jwt"
+	"auth-service/internal/model"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"os"
+	"testing"
+)
+
+// userServiceUser is the users table as user-service creates it, without the columns of auth-service
+type userServiceUser struct {
+	Id       uint   `gorm:"primaryKey"`
+	UserName string `gorm:"size:255"`
+}
+
+func (userServiceUser) TableName() string {
+	return "users"
+}
+
+// userServiceRole is the roles table as user-service creates it
+type userServiceRole struct {
+	Id   uint   `gorm:"primaryKey"`
+	Name string `gorm:"size:255"`
+}
+
+func (userServiceRole) TableName() string {
+	return "roles"
+}
+
+// openTestDb connects to the empty mysql database of TEST_DB_URL, tests are skipped if it is not set since the
+// migrations use mysql specific statements. All the tables are dropped and the tables of user-service are created
+func openTestDb(t *testing.T) *gorm.DB {
+	url := os.Getenv("TEST_DB_URL")
+	if url == "" {
+		t.Skip("TEST_DB_URL is not set")
+	}
+
+	db, err := gorm.Open(mysql.Open(url), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sqlDb, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// foreign key checks are disabled per connection
+	sqlDb.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = sqlDb.Close() })
+
+	var tables []string
+	if err := db.Raw("SHOW TABLES").Scan(&tables).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	db.Exec("SET FOREIGN_KEY_CHECKS = 0")
+	for _, table := range tables {
+		if err := db.Migrator().DropTable(table); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Exec("SET FOREIGN_KEY_CHECKS = 1")
+
+	if err := db.AutoMigrate(&userServiceUser{}, &userServiceRole{}); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+func TestMigrationsMoveNewUsersIntoDefaultRealm(t *testing.T) {
+	db := openTestDb(t)
+	if err := runMigrations(db); err != nil {
+		t.Fatal(err)
+	}
+
+	// user-service does not know about the realms
+	if err := db.Create(&userServiceUser{UserName: "john"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Create(&userServiceRole{Name: "ROLE_USER"}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var defaultRealm model.Realm
+	if err := db.Where("name = ?", jwt.DefaultRealm).First(&defaultRealm).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var users, roles int64
+	db.Model(&model.User{}).Where("realm_id = ? AND user_name = ?", defaultRealm.Id, "john").Count(&users)
+	db.Model(&model.Role{}).Where("realm_id = ? AND name = ?", defaultRealm.Id, "ROLE_USER").Count(&roles)
+	if users != 1 || roles != 1 {
+		t.Errorf("expected the user and the role in the default realm, got %d users and %d roles", users, roles)
+	}
+}
+
+func TestMigrateRealmsCanBeRerun(t *testing.T) {
+	db := openTestDb(t)
+	if err := runMigrations(db); err != nil {
+		t.Fatal(err)
+	}
+
+	// a failed attempt leaves the committed schema changes and the default realm behind
+	if err := db.Transaction(migrateRealms); err != nil {
+		t.Fatalf("expected the realms migration to be re-runnable, got %v", err)
+	}
+
+	var realms int64
+	db.Model(&model.Realm{}).Where("name = ?", jwt.DefaultRealm).Count(&realms)
+	if realms != 1 {
+		t.Errorf("expected a single default realm, got %d", realms)
+	}
+}
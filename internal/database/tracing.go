@@ -0,0 +1,54 @@
+package database
+
+import (
+	"auth-service/internal/tracing"
+	"errors"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const spanKey = "tracing:span"
+
+// tracingPlugin is the gorm plugin which creates a client span for every statement as a child of the context of the
+// statement, so the queries and the saves are visible in the trace of the request
+type tracingPlugin struct{}
+
+// Name implements gorm.Plugin
+func (tracingPlugin) Name() string {
+	return "tracing"
+}
+
+// Initialize implements gorm.Plugin
+func (tracingPlugin) Initialize(db *gorm.DB) error {
+	return registerCallbacks(db, "tracing", startSpan, endSpan)
+}
+
+func startSpan(operation string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		_, span := tracing.Start(db.Statement.Context, "gorm."+operation, trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(semconv.DBSystemMySQL))
+		db.InstanceSet(spanKey, span)
+	}
+}
+
+func endSpan(string) func(db *gorm.DB) {
+	return func(db *gorm.DB) {
+		value, ok := db.InstanceGet(spanKey)
+		if !ok {
+			return
+		}
+
+		span := value.(trace.Span)
+		// only the statement with placeholders is recorded, the values may be sensitive
+		span.SetAttributes(semconv.DBSQLTableKey.String(db.Statement.Table),
+			semconv.DBStatementKey.String(db.Statement.SQL.String()),
+			attribute.Int64("db.rows_affected", db.Statement.RowsAffected))
+		err := db.Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			err = nil
+		}
+		tracing.End(span, err)
+	}
+}
@@ -0,0 +1,95 @@
+package encryption
+
+import (
+	"sync"
+	"time"
+)
+
+// breaker states, values are exported as the circuit state gauge
+const (
+	stateClosed = iota
+	stateHalfOpen
+	stateOpen
+)
+
+// breaker is a consecutive failures circuit breaker. It opens after threshold failures in a row, fails fast while
+// open, and lets a single trial call through after openTimeout which closes it again on success
+type breaker struct {
+	mu          sync.Mutex
+	threshold   int
+	openTimeout time.Duration
+	state       int
+	failures    int
+	openedAt    time.Time
+	trial       bool
+	now         func() time.Time
+	onChange    func(state int)
+}
+
+func newBreaker(threshold int, openTimeout time.Duration, onChange func(state int)) *breaker {
+	return &breaker{
+		threshold:   threshold,
+		openTimeout: openTimeout,
+		now:         time.Now,
+		onChange:    onChange,
+	}
+}
+
+// allow reports whether a call can be made, every allowed call must be followed by a done call
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case stateOpen:
+		if b.now().Sub(b.openedAt) < b.openTimeout {
+			return false
+		}
+		b.setState(stateHalfOpen)
+		fallthrough
+	case stateHalfOpen:
+		if b.trial {
+			return false
+		}
+		b.trial = true
+	}
+
+	return true
+}
+
+// done records the result of an allowed call
+func (b *breaker) done(success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+	if success {
+		b.failures = 0
+		b.setState(stateClosed)
+		return
+	}
+
+	b.failures++
+	if b.state == stateHalfOpen || b.failures >= b.threshold {
+		b.openedAt = b.now()
+		b.setState(stateOpen)
+	}
+}
+
+// release ends an allowed call without recording a result
+func (b *breaker) release() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trial = false
+}
+
+func (b *breaker) setState(state int) {
+	if b.state == state {
+		return
+	}
+
+	b.state = state
+	if b.onChange != nil {
+		b.onChange(state)
+	}
+}
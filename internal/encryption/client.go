@@ -0,0 +1,260 @@
+package encryption
+
+import (
+	"auth-service/internal/tracing"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultTimeout          = 5 * time.Second
+	defaultRetryBackoff     = 100 * time.Millisecond
+	defaultBreakerThreshold = 5
+	defaultBreakerTimeout   = 30 * time.Second
+	maxResponseSize         = 1 << 20
+)
+
+var (
+	// ErrUnavailable is returned when the encryption-service can not be reached, responds with a server error after
+	// all the retries or the circuit is open
+	ErrUnavailable = errors.New("encryption-service is unavailable")
+	// ErrCircuitOpen is returned without making a request while the circuit is open, it wraps ErrUnavailable
+	ErrCircuitOpen = fmt.Errorf("%w: circuit is open", ErrUnavailable)
+)
+
+// StatusError is returned when the encryption-service responds with a non-2xx code which is not retried
+type StatusError struct {
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected response code %d from encryption-service", e.Code)
+}
+
+// Config represents the resilience settings of the Client, zero values are replaced with the defaults except
+// MaxRetries
+type Config struct {
+	// Timeout is the timeout of a single attempt
+	Timeout time.Duration
+	// MaxRetries is the number of the retries after the first attempt
+	MaxRetries int
+	// RetryBackoff is the base of the exponential backoff, actual wait is a random duration up to the backoff
+	RetryBackoff time.Duration
+	// BreakerThreshold is the number of the consecutive failures which opens the circuit
+	BreakerThreshold int
+	// BreakerTimeout is the duration the circuit stays open before a trial request is let through
+	BreakerTimeout time.Duration
+}
+
+// Client makes the password check requests to the encryption-service
+type Client struct {
+	url        string
+	config     Config
+	httpClient *http.Client
+	breaker    *breaker
+}
+
+type checkRequest struct {
+	PlainText     string `json:"plainText"`
+	EncryptedText string `json:"encryptedText"`
+}
+
+type checkResponse struct {
+	Status bool `json:"status"`
+}
+
+// NewClient creates a Client for the check endpoint of the encryption-service
+func NewClient(url string, config Config) *Client {
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+
+	if config.MaxRetries < 0 {
+		config.MaxRetries = 0
+	}
+
+	if config.RetryBackoff <= 0 {
+		config.RetryBackoff = defaultRetryBackoff
+	}
+
+	if config.BreakerThreshold <= 0 {
+		config.BreakerThreshold = defaultBreakerThreshold
+	}
+
+	if config.BreakerTimeout <= 0 {
+		config.BreakerTimeout = defaultBreakerTimeout
+	}
+
+	return &Client{
+		url:    url,
+		config: config,
+		// timeouts are applied per attempt through the request context
+		httpClient: &http.Client{},
+		breaker: newBreaker(config.BreakerThreshold, config.BreakerTimeout, func(state int) {
+			circuitState.Set(float64(state))
+		}),
+	}
+}
+
+// Check checks if the plain text matches the encrypted text. Connection errors, timeouts and 429, 502, 503, 504
+// responses are retried with jittered exponential backoff since the check has no side effects
+func (c *Client) Check(ctx context.Context, plainText, encryptedText string) (bool, error) {
+	body, err := json.Marshal(checkRequest{PlainText: plainText, EncryptedText: encryptedText})
+	if err != nil {
+		return false, err
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= c.config.MaxRetries; attempt++ {
+		if attempt > 0 {
+			retries.Inc()
+			if err := c.wait(ctx, attempt); err != nil {
+				return false, err
+			}
+		}
+
+		if !c.breaker.allow() {
+			requestDuration.WithLabelValues(outcomeCircuitOpen).Observe(0)
+			return false, ErrCircuitOpen
+		}
+
+		res, retryable, err := c.attempt(ctx, body)
+		if err == nil {
+			return res.Status, nil
+		}
+
+		if !retryable || ctx.Err() != nil {
+			return false, err
+		}
+		lastErr = err
+	}
+
+	return false, fmt.Errorf("%w: %v", ErrUnavailable, lastErr)
+}
+
+// attempt makes a single request, returns whether the error can be retried
+func (c *Client) attempt(ctx context.Context, body []byte) (checkResponse, bool, error) {
+	attemptCtx, cancel := context.WithTimeout(ctx, c.config.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	res, outcome, err := c.post(attemptCtx, body)
+	requestDuration.WithLabelValues(outcome).Observe(time.Since(start).Seconds())
+	if ctx.Err() != nil {
+		// the caller gave up, which says nothing about the health of the service
+		c.breaker.release()
+		return res, false, err
+	}
+
+	// client errors mean that the service is up, they must not open the circuit
+	c.breaker.done(outcome == outcomeSuccess || outcome == outcomeClientError)
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.Code {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout:
+			return res, true, err
+		}
+		return res, false, err
+	}
+
+	return res, outcome == outcomeNetwork, err
+}
+
+// post makes the request in a client span, trace context is propagated to the encryption-service
+func (c *Client) post(ctx context.Context, body []byte) (res checkResponse, outcome string, err error) {
+	ctx, span := tracing.Start(ctx, "encryption-service check", trace.WithSpanKind(trace.SpanKindClient))
+	defer func() {
+		span.SetAttributes(attribute.String("encryption_service.outcome", outcome))
+		tracing.End(span, err)
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return res, outcomeClientError, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	span.SetAttributes(semconv.HTTPClientAttributesFromHTTPRequest(req)...)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return res, outcomeNetwork, err
+	}
+
+	defer func() {
+		// drain the body so the connection can be reused
+		_, _ = io.Copy(ioutil.Discard, io.LimitReader(resp.Body, maxResponseSize))
+		_ = resp.Body.Close()
+	}()
+
+	span.SetAttributes(semconv.HTTPAttributesFromHTTPStatusCode(resp.StatusCode)...)
+	switch {
+	case resp.StatusCode >= 500:
+		return res, outcomeServerError, &StatusError{Code: resp.StatusCode}
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return res, outcomeServerError, &StatusError{Code: resp.StatusCode}
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return res, outcomeClientError, &StatusError{Code: resp.StatusCode}
+	}
+
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&res); err != nil {
+		return res, outcomeServerError, fmt.Errorf("invalid response from encryption-service: %w", err)
+	}
+
+	return res, outcomeSuccess, nil
+}
+
+// Ping checks if the encryption-service is reachable, any response other than a server error means it is up. Pings
+// are not retried and do not count for the circuit breaker
+func (c *Client) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, c.config.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(resp.Body, maxResponseSize))
+	_ = resp.Body.Close()
+
+	if resp.StatusCode >= 500 {
+		return &StatusError{Code: resp.StatusCode}
+	}
+
+	return nil
+}
+
+// wait sleeps a random duration up to the exponential backoff of the attempt, full jitter spreads the retries of
+// the concurrent logins
+func (c *Client) wait(ctx context.Context, attempt int) error {
+	backoff := c.config.RetryBackoff << uint(attempt-1)
+	timer := time.NewTimer(time.Duration(rand.Int63n(int64(backoff) + 1)))
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,169 @@
+package encryption
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestServer(t *testing.T, handler func(calls int32, w http.ResponseWriter, req checkRequest)) (*httptest.Server,
+	*int32) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req checkRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("invalid request body: %v", err)
+		}
+		handler(atomic.AddInt32(&calls, 1), w, req)
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func TestCheckRetriesServerErrors(t *testing.T) {
+	server, calls := newTestServer(t, func(calls int32, w http.ResponseWriter, req checkRequest) {
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(checkResponse{Status: req.PlainText == "secret"})
+	})
+
+	client := NewClient(server.URL, Config{MaxRetries: 2, RetryBackoff: time.Millisecond})
+	ok, err := client.Check(context.Background(), "secret", "encrypted")
+	if err != nil || !ok {
+		t.Fatalf("expected successful check, got %v %v", ok, err)
+	}
+
+	if *calls != 3 {
+		t.Errorf("expected 3 calls, got %d", *calls)
+	}
+}
+
+func TestCheckDoesNotRetryClientErrors(t *testing.T) {
+	server, calls := newTestServer(t, func(calls int32, w http.ResponseWriter, req checkRequest) {
+		w.WriteHeader(http.StatusBadRequest)
+	})
+
+	client := NewClient(server.URL, Config{MaxRetries: 2, RetryBackoff: time.Millisecond})
+	_, err := client.Check(context.Background(), "secret", "encrypted")
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.Code != http.StatusBadRequest {
+		t.Fatalf("expected status error, got %v", err)
+	}
+
+	if *calls != 1 {
+		t.Errorf("expected a single call, got %d", *calls)
+	}
+}
+
+func TestCheckTimesOut(t *testing.T) {
+	server, _ := newTestServer(t, func(calls int32, w http.ResponseWriter, req checkRequest) {
+		time.Sleep(200 * time.Millisecond)
+	})
+
+	client := NewClient(server.URL, Config{Timeout: 20 * time.Millisecond, RetryBackoff: time.Millisecond})
+	start := time.Now()
+	if _, err := client.Check(context.Background(), "secret", "encrypted"); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("expected %v, got %v", ErrUnavailable, err)
+	}
+
+	if elapsed := time.Since(start); elapsed > 150*time.Millisecond {
+		t.Errorf("expected the attempt to time out, took %s", elapsed)
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	server, calls := newTestServer(t, func(calls int32, w http.ResponseWriter, req checkRequest) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+
+	client := NewClient(server.URL, Config{BreakerThreshold: 2, BreakerTimeout: time.Hour})
+	for i := 0; i < 2; i++ {
+		if _, err := client.Check(context.Background(), "secret", "encrypted"); err == nil {
+			t.Fatal("expected an error")
+		}
+	}
+
+	if _, err := client.Check(context.Background(), "secret", "encrypted"); err != ErrCircuitOpen {
+		t.Fatalf("expected %v, got %v", ErrCircuitOpen, err)
+	}
+
+	if *calls != 2 {
+		t.Errorf("expected open circuit to fail fast, got %d calls", *calls)
+	}
+
+	// a successful trial after the open timeout closes the circuit
+	now := time.Now().Add(2 * time.Hour)
+	client.breaker.now = func() time.Time { return now }
+	if !client.breaker.allow() {
+		t.Fatal("expected a trial call to be allowed")
+	}
+
+	if client.breaker.allow() {
+		t.Error("expected a single trial call while half open")
+	}
+
+	client.breaker.done(true)
+	if client.breaker.state != stateClosed {
+		t.Errorf("expected closed circuit, got %d", client.breaker.state)
+	}
+}
+
+func TestPing(t *testing.T) {
+	status := int32(http.StatusMethodNotAllowed)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, Config{})
+	if err := client.Ping(context.Background()); err != nil {
+		t.Errorf("expected client error responses to mean reachable, got %v", err)
+	}
+
+	atomic.StoreInt32(&status, http.StatusBadGateway)
+	var statusErr *StatusError
+	if err := client.Ping(context.Background()); !errors.As(err, &statusErr) {
+		t.Errorf("expected status error, got %v", err)
+	}
+
+	server.Close()
+	if err := client.Ping(context.Background()); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("expected %v, got %v", ErrUnavailable, err)
+	}
+}
+
+func TestCheckPropagatesTraceContext(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		_ = json.NewEncoder(w).Encode(checkResponse{Status: true})
+	}))
+	t.Cleanup(server.Close)
+
+	traceId, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanId, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithRemoteSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceId,
+		SpanID:     spanId,
+		TraceFlags: trace.FlagsSampled,
+	}))
+	if _, err := NewClient(server.URL, Config{}).Check(ctx, "secret", "encrypted"); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(traceparent, "00-4bf92f3577b34da6a3ce929d0e0e4736-") {
+		t.Errorf("expected the trace context to be propagated, got %q", traceparent)
+	}
+}
@@ -0,0 +1,37 @@
+package encryption
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// outcomes of a request to the encryption-service
+const (
+	outcomeSuccess     = "success"
+	outcomeClientError = "client_error"
+	outcomeServerError = "server_error"
+	outcomeNetwork     = "network_error"
+	outcomeCircuitOpen = "circuit_open"
+)
+
+var (
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "auth_service",
+		Subsystem: "encryption_service",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of the requests to the encryption-service by outcome, retries are observed separately",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"outcome"})
+	retries = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: "auth_service",
+		Subsystem: "encryption_service",
+		Name:      "retries_total",
+		Help:      "Number of the retried requests to the encryption-service",
+	})
+	circuitState = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: "auth_service",
+		Subsystem: "encryption_service",
+		Name:      "circuit_state",
+		Help:      "State of the encryption-service circuit breaker, 0 is closed, 1 is half open and 2 is open",
+	})
+)
@@ -0,0 +1,64 @@
+package entitlement
+
+import (
+	"auth-service/internal/jwt"
+	"auth-service/internal/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// Active returns the unexpired entitlements of the user
+func Active(db *gorm.DB, userId uint) ([]model.Entitlement, error) {
+	var entitlements []model.Entitlement
+	err := db.Where("user_id = ? AND (expires_at IS NULL OR expires_at > ?)", userId, time.Now()).
+		Order("name").Find(&entitlements).Error
+	return entitlements, err
+}
+
+// List returns all the entitlements of the user including the expired ones
+func List(db *gorm.DB, userId uint) ([]model.Entitlement, error) {
+	var entitlements []model.Entitlement
+	err := db.Where("user_id = ?", userId).Order("name").Find(&entitlements).Error
+	return entitlements, err
+}
+
+// Assign creates the entitlement or updates the value and expiry if the user already has an entitlement with the
+// same name
+func Assign(db *gorm.DB, entitlement *model.Entitlement) error {
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"value", "expires_at", "updated_at"}),
+	}).Create(entitlement).Error
+}
+
+// Remove deletes the entitlement of the user with the given name, returns false if there is no such entitlement
+func Remove(db *gorm.DB, userId uint, name string) (bool, error) {
+	result := db.Where("user_id = ? AND name = ?", userId, name).Delete(&model.Entitlement{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// Enricher returns a jwt.ClaimEnricher which embeds the active entitlements of the user into the token
+func Enricher(db *gorm.DB, userId uint) jwt.ClaimEnricher {
+	return func(claims *jwt.VpnbeastClaim) error {
+		entitlements, err := Active(db, userId)
+		if err != nil {
+			return err
+		}
+
+		if len(entitlements) == 0 {
+			return nil
+		}
+
+		claims.Entitlements = make(map[string]jwt.EntitlementClaim, len(entitlements))
+		for _, e := range entitlements {
+			claim := jwt.EntitlementClaim{Value: e.Value}
+			if e.ExpiresAt != nil {
+				claim.ExpiresAt = e.ExpiresAt.Unix()
+			}
+			claims.Entitlements[e.Name] = claim
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,206 @@
+package federation
+
+import (
+	"auth-service/internal/model"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// mockIdp is a minimal OpenID Connect provider which issues an id token for a single authorization code
+type mockIdp struct {
+	server    *httptest.Server
+	key       *rsa.PrivateKey
+	code      string
+	challenge string
+	nonce     string
+	email     string
+}
+
+func newMockIdp(t *testing.T) *mockIdp {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idp := &mockIdp{key: key, code: "code-1", email: "foo@example.com"}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                idp.server.URL,
+			"authorization_endpoint":                idp.server.URL + "/authorize",
+			"token_endpoint":                        idp.server.URL + "/token",
+			"jwks_uri":                              idp.server.URL + "/jwks",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "k1",
+				"alg": "RS256",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		verifier := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if r.PostForm.Get("code") != idp.code ||
+			base64.RawURLEncoding.EncodeToString(verifier[:]) != idp.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss":            idp.server.URL,
+			"sub":            "upstream-1",
+			"aud":            "client-1",
+			"exp":            time.Now().Add(time.Minute).Unix(),
+			"iat":            time.Now().Unix(),
+			"nonce":          idp.nonce,
+			"email":          idp.email,
+			"email_verified": true,
+		})
+		token.Header["kid"] = "k1"
+		idToken, err := token.SignedString(key)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "upstream-access-token",
+			"token_type":   "Bearer",
+			"expires_in":   60,
+			"id_token":     idToken,
+		})
+	})
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	idp := newMockIdp(t)
+	provider := NewProvider(ProviderConfig{
+		Name:        "mock",
+		Realm:       "default",
+		IssuerUrl:   idp.server.URL,
+		ClientId:    "client-1",
+		RedirectUrl: "http://localhost/auth/oidc/mock/callback",
+	})
+
+	state, err := NewFlowState("default", "mock", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authUrl, err := provider.AuthCodeUrl(context.Background(), state)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := url.Parse(authUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := parsed.Query()
+	if query.Get("state") != state.State || query.Get("code_challenge_method") != "S256" {
+		t.Fatalf("unexpected authorization url %s", authUrl)
+	}
+
+	// the provider redirects back with the code after the user logs in
+	idp.challenge = query.Get("code_challenge")
+	idp.nonce = query.Get("nonce")
+	identity, err := provider.Exchange(context.Background(), idp.code, state)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if identity.Subject != "upstream-1" || identity.Email != idp.email || !identity.EmailVerified {
+		t.Errorf("unexpected identity %+v", identity)
+	}
+
+	// replayed id token of another flow is refused due to the nonce
+	other, err := NewFlowState("default", "mock", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.Verifier = state.Verifier
+	if _, err := provider.Exchange(context.Background(), idp.code, other); err != ErrInvalidIdToken {
+		t.Errorf("expected %v, got %v", ErrInvalidIdToken, err)
+	}
+
+	// code can not be redeemed without the code verifier
+	other.Verifier = "wrong"
+	if _, err := provider.Exchange(context.Background(), idp.code, other); err == nil {
+		t.Error("expected exchange without the code verifier to fail")
+	}
+}
+
+func TestFlowState(t *testing.T) {
+	secret := []byte("secret")
+	state, err := NewFlowState("acme", "google", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encoded, err := state.Encode(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := DecodeFlowState(secret, encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !decoded.Matches("acme", "google", state.State) || decoded.Matches("default", "google", state.State) {
+		t.Error("unexpected state match result")
+	}
+
+	if _, err := DecodeFlowState([]byte("other"), encoded); err != ErrInvalidState {
+		t.Errorf("expected tampered state to be refused, got %v", err)
+	}
+
+	state.ExpiresAt = time.Now().Add(-time.Second).Unix()
+	expired, _ := state.Encode(secret)
+	if _, err := DecodeFlowState(secret, expired); err != ErrInvalidState {
+		t.Errorf("expected expired state to be refused, got %v", err)
+	}
+}
+
+func TestLinkable(t *testing.T) {
+	cases := []struct {
+		user     model.User
+		linkable bool
+	}{
+		{model.User{EncryptedPassword: "encrypted"}, true},
+		{model.User{IdentityBackend: "ldap"}, false},
+		{model.User{IdentityBackend: "local", EncryptedPassword: "encrypted"}, false},
+	}
+
+	for _, c := range cases {
+		if linkable(&c.user) != c.linkable {
+			t.Errorf("expected linkable=%t for %+v", c.linkable, c.user)
+		}
+	}
+}
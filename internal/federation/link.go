@@ -0,0 +1,102 @@
+package federation
+
+import (
+	"auth-service/internal/account"
+	"auth-service/internal/model"
+	"errors"
+	"gorm.io/gorm"
+	"time"
+)
+
+var (
+	// ErrNotLinked is returned when the identity can not be linked to a user and auto provisioning is disabled
+	ErrNotLinked = errors.New("identity is not linked to a user")
+	// ErrUserConflict is returned when the user with the same verified email belongs to an identity backend, which
+	// owns its credentials
+	ErrUserConflict = errors.New("user with the same email belongs to an identity backend")
+)
+
+// Link finds the user of the identity in the realm. An unknown identity is linked to the user with the same verified
+// email unless it belongs to an identity backend, or a new user is provisioned with the default roles if the provider
+// allows it. Whether the user can log in is checked by the caller
+func Link(db *gorm.DB, realmId uint, config ProviderConfig, identity *Identity) (*model.User, error) {
+	var user model.User
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var federated model.FederatedIdentity
+		err := tx.Where("realm_id = ? AND provider = ? AND subject = ?", realmId, config.Name, identity.Subject).
+			First(&federated).Error
+		switch {
+		case err == nil:
+			if err := tx.Preload("Roles").Where("id = ?", federated.UserId).First(&user).Error; err != nil {
+				return err
+			}
+
+			return tx.Model(&federated).Updates(map[string]interface{}{
+				"email":         identity.Email,
+				"last_login_at": time.Now(),
+			}).Error
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return err
+		}
+
+		if err := findOrProvision(tx, realmId, config, identity, &user); err != nil {
+			return err
+		}
+
+		return tx.Create(&model.FederatedIdentity{
+			RealmId:     realmId,
+			Provider:    config.Name,
+			Subject:     identity.Subject,
+			UserId:      user.Id,
+			Email:       identity.Email,
+			CreatedAt:   time.Now(),
+			LastLoginAt: time.Now(),
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func findOrProvision(tx *gorm.DB, realmId uint, config ProviderConfig, identity *Identity, user *model.User) error {
+	// unverified emails could be used to take over the accounts of other users
+	if identity.Email != "" && identity.EmailVerified {
+		err := tx.Preload("Roles").Where("realm_id = ? AND email = ?", realmId, identity.Email).First(user).Error
+		switch {
+		case err == nil && !linkable(user):
+			return ErrUserConflict
+		case err == nil || !errors.Is(err, gorm.ErrRecordNotFound):
+			return err
+		}
+	}
+
+	if !config.AutoProvision {
+		return ErrNotLinked
+	}
+
+	name := identity.Email
+	if name == "" {
+		name = identity.Subject
+	}
+
+	userName, err := account.UniqueUserName(tx, realmId, name)
+	if err != nil {
+		return err
+	}
+
+	*user = model.User{
+		RealmId:       realmId,
+		UserName:      userName,
+		Email:         identity.Email,
+		EmailVerified: identity.EmailVerified,
+	}
+	return account.Provision(tx, user, config.DefaultRoles)
+}
+
+// linkable checks if an identity can be linked to the existing user, users of an identity backend like ldap can not
+// be taken over by the identity providers
+func linkable(user *model.User) bool {
+	return user.IdentityBackend == ""
+}
@@ -0,0 +1,200 @@
+package federation
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+var (
+	// ErrProviderNotFound is returned when there is no provider with the given name in the realm
+	ErrProviderNotFound = errors.New("identity provider not found")
+	// ErrInvalidIdToken is returned when the id token is missing, invalid or its nonce does not match
+	ErrInvalidIdToken = errors.New("invalid id token")
+)
+
+// ProviderConfig represents an upstream OpenID Connect identity provider of a realm
+type ProviderConfig struct {
+	Name         string   `yaml:"name"`
+	Realm        string   `yaml:"realm"`
+	IssuerUrl    string   `yaml:"issuerUrl"`
+	ClientId     string   `yaml:"clientId"`
+	ClientSecret string   `yaml:"clientSecret"`
+	RedirectUrl  string   `yaml:"redirectUrl"`
+	Scopes       []string `yaml:"scopes"`
+	// AutoProvision creates a user with DefaultRoles if no user can be linked to the identity
+	AutoProvision bool     `yaml:"autoProvision"`
+	DefaultRoles  []string `yaml:"defaultRoles"`
+}
+
+type providersFile struct {
+	Providers []ProviderConfig `yaml:"providers"`
+}
+
+// LoadProviderConfigs reads the providers from the yaml file, ${VAR} references are expanded from the environment so
+// the client secrets do not have to be stored in the file. Providers without a realm belong to the default realm
+func LoadProviderConfigs(path, defaultRealm string) ([]ProviderConfig, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file providersFile
+	if err := yaml.Unmarshal([]byte(os.ExpandEnv(string(content))), &file); err != nil {
+		return nil, err
+	}
+
+	for i, p := range file.Providers {
+		if p.Name == "" || p.IssuerUrl == "" || p.ClientId == "" || p.RedirectUrl == "" {
+			return nil, fmt.Errorf("provider %d: name, issuerUrl, clientId and redirectUrl are required", i)
+		}
+
+		if p.Realm == "" {
+			file.Providers[i].Realm = defaultRealm
+		}
+	}
+
+	return file.Providers, nil
+}
+
+// Identity represents the verified claims of the id token
+type Identity struct {
+	Subject       string `json:"sub"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	Name          string `json:"name"`
+}
+
+// Provider performs the authorization code flow with PKCE against an upstream provider. Discovery is done on the
+// first use, so an unreachable provider does not prevent the service from starting
+type Provider struct {
+	config   ProviderConfig
+	mu       sync.Mutex
+	oauth2   *oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+// NewProvider creates a new Provider
+func NewProvider(config ProviderConfig) *Provider {
+	return &Provider{config: config}
+}
+
+// Config returns the configuration of the provider
+func (p *Provider) Config() ProviderConfig {
+	return p.config
+}
+
+func (p *Provider) discover(ctx context.Context) (*oauth2.Config, *oidc.IDTokenVerifier, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.oauth2 != nil {
+		return p.oauth2, p.verifier, nil
+	}
+
+	provider, err := oidc.NewProvider(ctx, p.config.IssuerUrl)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	scopes := p.config.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"profile", "email"}
+	}
+
+	p.oauth2 = &oauth2.Config{
+		ClientID:     p.config.ClientId,
+		ClientSecret: p.config.ClientSecret,
+		RedirectURL:  p.config.RedirectUrl,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       append([]string{oidc.ScopeOpenID}, scopes...),
+	}
+	p.verifier = provider.Verifier(&oidc.Config{ClientID: p.config.ClientId})
+	return p.oauth2, p.verifier, nil
+}
+
+// AuthCodeUrl returns the authorization url of the provider which the user is redirected to
+func (p *Provider) AuthCodeUrl(ctx context.Context, state *FlowState) (string, error) {
+	config, _, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	challenge := sha256.Sum256([]byte(state.Verifier))
+	return config.AuthCodeURL(state.State,
+		oidc.Nonce(state.Nonce),
+		oauth2.SetAuthURLParam("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:])),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+	), nil
+}
+
+// Exchange redeems the authorization code and returns the identity from the id token, which is verified against
+// the keys of the provider and the nonce of the flow
+func (p *Provider) Exchange(ctx context.Context, code string, state *FlowState) (*Identity, error) {
+	config, verifier, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := config.Exchange(ctx, code, oauth2.SetAuthURLParam("code_verifier", state.Verifier))
+	if err != nil {
+		return nil, err
+	}
+
+	rawIdToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, ErrInvalidIdToken
+	}
+
+	idToken, err := verifier.Verify(ctx, rawIdToken)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidIdToken, err)
+	}
+
+	if idToken.Nonce != state.Nonce {
+		return nil, ErrInvalidIdToken
+	}
+
+	var identity Identity
+	if err := idToken.Claims(&identity); err != nil {
+		return nil, err
+	}
+
+	return &identity, nil
+}
+
+// Registry holds the providers by realm and name
+type Registry struct {
+	providers map[string]*Provider
+}
+
+// NewRegistry creates a Registry of the configured providers
+func NewRegistry(configs []ProviderConfig) *Registry {
+	registry := &Registry{providers: make(map[string]*Provider, len(configs))}
+	for _, config := range configs {
+		registry.providers[config.Realm+"/"+config.Name] = NewProvider(config)
+	}
+
+	return registry
+}
+
+// Get returns the provider of the realm with the given name
+func (r *Registry) Get(realm, name string) (*Provider, error) {
+	if r == nil {
+		return nil, ErrProviderNotFound
+	}
+
+	provider, ok := r.providers[realm+"/"+name]
+	if !ok {
+		return nil, ErrProviderNotFound
+	}
+
+	return provider, nil
+}
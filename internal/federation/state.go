@@ -0,0 +1,93 @@
+package federation
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrInvalidState is returned when the flow state is tampered, expired or does not match the callback
+var ErrInvalidState = errors.New("invalid login state")
+
+// FlowState represents the login flow which is kept in a signed cookie between the login redirect and the callback
+type FlowState struct {
+	Provider  string `json:"p"`
+	Realm     string `json:"r"`
+	State     string `json:"s"`
+	Nonce     string `json:"n"`
+	Verifier  string `json:"v"`
+	ExpiresAt int64  `json:"e"`
+}
+
+// NewFlowState generates random state, nonce and PKCE code verifier for a new login flow
+func NewFlowState(realm, provider string, ttl time.Duration) (*FlowState, error) {
+	values := make([]string, 3)
+	for i := range values {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		values[i] = base64.RawURLEncoding.EncodeToString(b)
+	}
+
+	return &FlowState{
+		Provider:  provider,
+		Realm:     realm,
+		State:     values[0],
+		Nonce:     values[1],
+		Verifier:  values[2],
+		ExpiresAt: time.Now().Add(ttl).Unix(),
+	}, nil
+}
+
+// Encode serializes and signs the state with HMAC-SHA256
+func (s *FlowState) Encode(secret []byte) (string, error) {
+	payload, err := json.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + sign(secret, encoded), nil
+}
+
+// DecodeFlowState verifies the signature and the expiry of the encoded state
+func DecodeFlowState(secret []byte, value string) (*FlowState, error) {
+	parts := strings.Split(value, ".")
+	if len(parts) != 2 || !hmac.Equal([]byte(sign(secret, parts[0])), []byte(parts[1])) {
+		return nil, ErrInvalidState
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrInvalidState
+	}
+
+	var state FlowState
+	if err := json.Unmarshal(payload, &state); err != nil {
+		return nil, ErrInvalidState
+	}
+
+	if time.Now().Unix() > state.ExpiresAt {
+		return nil, ErrInvalidState
+	}
+
+	return &state, nil
+}
+
+// Matches checks if the callback belongs to the flow
+func (s *FlowState) Matches(realm, provider, state string) bool {
+	return s.Realm == realm && s.Provider == provider &&
+		hmac.Equal([]byte(s.State), []byte(state))
+}
+
+func sign(secret []byte, value string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(value))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,63 @@
+package health
+
+import (
+	"auth-service/internal/database"
+	"auth-service/internal/realm"
+	"auth-service/internal/revocation"
+	"context"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"strings"
+)
+
+// DatabaseChecker pings the database
+func DatabaseChecker(db *gorm.DB) Checker {
+	return func(ctx context.Context) error {
+		sqlDb, err := db.DB()
+		if err != nil {
+			return err
+		}
+
+		return sqlDb.PingContext(ctx)
+	}
+}
+
+// MigrationsChecker fails while there are pending migrations
+func MigrationsChecker(db *gorm.DB) Checker {
+	return func(ctx context.Context) error {
+		pending, err := database.PendingMigrations(db.WithContext(ctx))
+		if err != nil {
+			return err
+		}
+
+		if len(pending) != 0 {
+			return fmt.Errorf("pending migrations: %s", strings.Join(pending, ", "))
+		}
+
+		return nil
+	}
+}
+
+// RevocationStoreChecker checks if the session revocations can be read
+func RevocationStoreChecker(db *gorm.DB) Checker {
+	return func(ctx context.Context) error {
+		return revocation.Ping(db.WithContext(ctx))
+	}
+}
+
+// SigningKeyChecker checks if the default realm is loaded with an active signing key
+func SigningKeyChecker(realms *realm.Registry) Checker {
+	return func(ctx context.Context) error {
+		r, err := realms.Default()
+		if err != nil {
+			return err
+		}
+
+		if r.Signer().Keys().ActiveKeyId() == "" {
+			return errors.New("default realm has no active signing key")
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,172 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Probe is the kind of the Kubernetes probe which a check takes part in
+type Probe int
+
+const (
+	// Live fails when the process must be restarted, dependencies must not take part in it
+	Live Probe = iota
+	// Ready fails when the service can not serve requests or it is draining
+	Ready
+	// Startup fails until the service is initialized
+	Startup
+)
+
+const (
+	statusOk       = "ok"
+	statusFailed   = "failed"
+	statusDraining = "draining"
+)
+
+// Checker checks a single dependency, it must respect the deadline of the context
+type Checker func(ctx context.Context) error
+
+// CheckResult represents the last result of a check
+type CheckResult struct {
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+	Duration  string `json:"duration"`
+	CheckedAt string `json:"checkedAt"`
+}
+
+// Report represents the response of a probe
+type Report struct {
+	Status string        `json:"status"`
+	Checks []CheckResult `json:"checks"`
+}
+
+type check struct {
+	name    string
+	checker Checker
+	probes  map[Probe]bool
+	// mu is held while the check runs, so concurrent probes wait for the same result instead of running it again
+	mu        sync.Mutex
+	result    CheckResult
+	checkedAt time.Time
+	err       error
+}
+
+// Prober runs the checks of the probes, results are cached for the ttl so frequent probes of several kubelets and
+// load balancers do not hit the dependencies each time
+type Prober struct {
+	timeout  time.Duration
+	ttl      time.Duration
+	checks   []*check
+	draining int32
+}
+
+// NewProber creates a Prober which times the checks out after timeout and caches their results for ttl
+func NewProber(timeout, ttl time.Duration) *Prober {
+	return &Prober{timeout: timeout, ttl: ttl}
+}
+
+// AddCheck adds the checker to the probes, checks must be added before the probes are served
+func (p *Prober) AddCheck(name string, checker Checker, probes ...Probe) {
+	c := &check{name: name, checker: checker, probes: make(map[Probe]bool, len(probes))}
+	for _, probe := range probes {
+		c.probes[probe] = true
+	}
+	p.checks = append(p.checks, c)
+}
+
+// SetDraining marks the service as not ready regardless of the checks, it is set when the shutdown starts
+func (p *Prober) SetDraining(draining bool) {
+	var value int32
+	if draining {
+		value = 1
+	}
+	atomic.StoreInt32(&p.draining, value)
+}
+
+// Run runs the checks of the probe concurrently and reports whether all of them passed
+func (p *Prober) Run(ctx context.Context, probe Probe) (Report, bool) {
+	report := Report{Status: statusOk, Checks: []CheckResult{}}
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for _, c := range p.checks {
+		if !c.probes[probe] {
+			continue
+		}
+
+		c := c
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := p.run(ctx, c)
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks = append(report.Checks, result)
+			if err != nil {
+				report.Status = statusFailed
+			}
+		}()
+	}
+	wg.Wait()
+
+	if probe == Ready && atomic.LoadInt32(&p.draining) == 1 {
+		report.Status = statusDraining
+	}
+
+	sort.Slice(report.Checks, func(i, j int) bool {
+		return report.Checks[i].Name < report.Checks[j].Name
+	})
+	return report, report.Status == statusOk
+}
+
+// Handler serves the report of the probe with 200 if it passed and 503 otherwise
+func (p *Prober) Handler(probe Probe) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report, ok := p.Run(r.Context(), probe)
+		code := http.StatusOK
+		if !ok {
+			code = http.StatusServiceUnavailable
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(report)
+	}
+}
+
+// run returns the cached result of the check if it is fresh, or runs the check
+func (p *Prober) run(ctx context.Context, c *check) (CheckResult, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.checkedAt.IsZero() && time.Since(c.checkedAt) < p.ttl {
+		return c.result, c.err
+	}
+
+	checkCtx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	start := time.Now()
+	err := c.checker(checkCtx)
+	result := CheckResult{
+		Name:      c.name,
+		Status:    statusOk,
+		Duration:  time.Since(start).String(),
+		CheckedAt: start.Format(time.RFC3339),
+	}
+	if err != nil {
+		result.Status = statusFailed
+		result.Error = err.Error()
+	}
+
+	// the caller gave up, the result says nothing about the dependency so it is not cached
+	if ctx.Err() == nil {
+		c.result, c.err, c.checkedAt = result, err, time.Now()
+	}
+
+	return result, err
+}
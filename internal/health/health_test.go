@@ -0,0 +1,91 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunCachesResults(t *testing.T) {
+	var calls int32
+	prober := NewProber(time.Second, time.Minute)
+	prober.AddCheck("database", func(ctx context.Context) error {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	}, Ready)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok := prober.Run(context.Background(), Ready); !ok {
+				t.Error("expected probe to pass")
+			}
+		}()
+	}
+	wg.Wait()
+
+	if calls != 1 {
+		t.Errorf("expected concurrent probes to share a single check, got %d calls", calls)
+	}
+}
+
+func TestRunReportsFailedChecks(t *testing.T) {
+	prober := NewProber(20*time.Millisecond, 0)
+	prober.AddCheck("migrations", func(ctx context.Context) error {
+		return errors.New("pending migrations: 0011")
+	}, Startup)
+	prober.AddCheck("encryption-service", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, Ready)
+
+	if _, ok := prober.Run(context.Background(), Live); !ok {
+		t.Error("expected liveness to pass without checks")
+	}
+
+	report, ok := prober.Run(context.Background(), Ready)
+	if ok || len(report.Checks) != 1 || report.Checks[0].Error != context.DeadlineExceeded.Error() {
+		t.Errorf("expected the slow check to time out, got %+v", report)
+	}
+
+	report, ok = prober.Run(context.Background(), Startup)
+	if ok || report.Status != statusFailed || report.Checks[0].Name != "migrations" {
+		t.Errorf("expected startup to fail, got %+v", report)
+	}
+}
+
+func TestHandlerWhileDraining(t *testing.T) {
+	prober := NewProber(time.Second, time.Minute)
+	prober.AddCheck("database", func(ctx context.Context) error { return nil }, Ready)
+	prober.SetDraining(true)
+
+	recorder := httptest.NewRecorder()
+	prober.Handler(Ready)(recorder, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected %d, got %d", http.StatusServiceUnavailable, recorder.Code)
+	}
+
+	var report Report
+	if err := json.NewDecoder(recorder.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+
+	if report.Status != statusDraining || report.Checks[0].Status != statusOk {
+		t.Errorf("expected draining with passing checks, got %+v", report)
+	}
+
+	recorder = httptest.NewRecorder()
+	prober.Handler(Live)(recorder, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	if recorder.Code != http.StatusOK {
+		t.Errorf("expected liveness not to be affected by draining, got %d", recorder.Code)
+	}
+}
@@ -0,0 +1,31 @@
+package health
+
+import (
+	"auth-service/internal/options"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Register registers the /livez, /readyz and /startupz endpoints of the prober on the mux
+func Register(mux *http.ServeMux, prober *Prober) {
+	mux.Handle("/livez", prober.Handler(Live))
+	mux.Handle("/readyz", prober.Handler(Ready))
+	mux.Handle("/startupz", prober.Handler(Startup))
+}
+
+// NewServer creates the server of the probes on the health port, health endpoint is kept as an alias of /readyz
+func NewServer(opts *options.AuthServiceOptions, prober *Prober) *http.Server {
+	mux := http.NewServeMux()
+	Register(mux, prober)
+	if opts.HealthEndpoint != "" && opts.HealthEndpoint != "/readyz" {
+		mux.Handle(opts.HealthEndpoint, prober.Handler(Ready))
+	}
+
+	return &http.Server{
+		Handler:      mux,
+		Addr:         fmt.Sprintf(":%d", opts.HealthPort),
+		WriteTimeout: time.Duration(int32(opts.WriteTimeoutSeconds)) * time.Second,
+		ReadTimeout:  time.Duration(int32(opts.ReadTimeoutSeconds)) * time.Second,
+	}
+}
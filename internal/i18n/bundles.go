@@ -0,0 +1,159 @@
+package i18n
+
+// DefaultBundles returns the translations which are shipped with the service. Validation messages of tr and es are
+// provided by the validator, so only de carries them
+func DefaultBundles() map[string]Bundle {
+	return map[string]Bundle{
+		"tr": {
+			Errors: map[string]string{
+				"internal_error":         "Sunucuda bilinmeyen bir hata oluştu!",
+				"validation_failed":      "İstek doğrulanamadı!",
+				"invalid_json":           "Geçerli bir JSON isteği değil!",
+				"invalid_credentials":    "Geçersiz parola!",
+				"user_not_found":         "Kullanıcı bulunamadı!",
+				"user_not_linked":        "Kullanıcı bulunamadı!",
+				"missing_token":          "Bearer token eksik!",
+				"invalid_token":          "Geçersiz token!",
+				"token_revoked":          "Token iptal edilmiş!",
+				"entitlement_expired":    "Yetkinin süresi dolmuş, token yenilenmeli!",
+				"ca_disabled":            "Sertifika otoritesi yapılandırılmamış!",
+				"invalid_csr":            "Geçersiz sertifika imzalama isteği!",
+				"no_eligible_role":       "Kullanıcının vpn sertifikasına uygun bir rolü yok!",
+				"forbidden":              "Yetersiz yetki!",
+				"entitlement_not_found":  "Yetki bulunamadı!",
+				"invalid_timestamp":      "Zaman damgası RFC3339 biçiminde olmalı!",
+				"invalid_limit":          "Limit 1 ile 1000 arasında olmalı!",
+				"invalid_offset":         "Offset negatif olmayan bir tam sayı olmalı!",
+				"invalid_scope":          "Kapsamlar kullanıcının rollerinin bir alt kümesi olmalı!",
+				"token_not_found":        "Token bulunamadı!",
+				"role_not_found":         "Rol bulunamadı!",
+				"permission_not_found":   "İzin bulunamadı!",
+				"permission_exists":      "İzin zaten mevcut!",
+				"invalid_permission":     "İzin adı resource:action biçiminde olmalı!",
+				"cyclic_inheritance":     "Rol kalıtımı döngüsel olamaz!",
+				"realm_not_found":        "Realm bulunamadı!",
+				"realm_exists":           "Realm zaten mevcut!",
+				"invalid_realm_name":     "Realm adı küçük harf, rakam ve tirelerden oluşmalı!",
+				"default_realm_required": "Varsayılan realm devre dışı bırakılamaz!",
+				"provider_not_found":     "Kimlik sağlayıcı bulunamadı!",
+				"provider_unavailable":   "Kimlik sağlayıcıya ulaşılamıyor!",
+				"invalid_login_state":    "Giriş durumu geçersiz veya süresi dolmuş!",
+				"federated_login_failed": "Federe giriş başarısız!",
+				"user_disabled":          "Kullanıcı devre dışı!",
+				"user_locked":            "Kullanıcı kilitli!",
+				"login_blocked":          "Giriş şüpheli olduğu için engellendi!",
+				"step_up_required":       "Giriş şüpheli, ikinci bir doğrulama gerekli!",
+				"too_many_requests":      "Çok fazla istek, lütfen daha sonra tekrar deneyin!",
+				"backend_not_found":      "Kimlik altyapısı bulunamadı!",
+				"encryption_unavailable": "Şifreleme servisine ulaşılamıyor, lütfen daha sonra tekrar deneyin!",
+				"invalid_csrf_token":     "CSRF token eksik veya geçersiz!",
+			},
+		},
+		"de": {
+			Errors: map[string]string{
+				"internal_error":         "Im Backend ist ein unbekannter Fehler aufgetreten!",
+				"validation_failed":      "Die Validierung der Anfrage ist fehlgeschlagen!",
+				"invalid_json":           "Keine gültige JSON-Anfrage!",
+				"invalid_credentials":    "Ungültiges Passwort!",
+				"user_not_found":         "Benutzer nicht gefunden!",
+				"user_not_linked":        "Benutzer nicht gefunden!",
+				"missing_token":          "Bearer-Token fehlt!",
+				"invalid_token":          "Ungültiges Token!",
+				"token_revoked":          "Token wurde widerrufen!",
+				"entitlement_expired":    "Berechtigung abgelaufen, das Token muss erneuert werden!",
+				"ca_disabled":            "Zertifizierungsstelle ist nicht konfiguriert!",
+				"invalid_csr":            "Ungültige Zertifikatsignierungsanforderung!",
+				"no_eligible_role":       "Der Benutzer hat keine für ein VPN-Zertifikat berechtigte Rolle!",
+				"forbidden":              "Unzureichende Berechtigungen!",
+				"entitlement_not_found":  "Berechtigung nicht gefunden!",
+				"invalid_timestamp":      "Zeitstempel muss im RFC3339-Format sein!",
+				"invalid_limit":          "Limit muss zwischen 1 und 1000 liegen!",
+				"invalid_offset":         "Offset muss eine nicht negative ganze Zahl sein!",
+				"invalid_scope":          "Scopes müssen eine Teilmenge der Rollen des Benutzers sein!",
+				"token_not_found":        "Token nicht gefunden!",
+				"role_not_found":         "Rolle nicht gefunden!",
+				"permission_not_found":   "Recht nicht gefunden!",
+				"permission_exists":      "Recht existiert bereits!",
+				"invalid_permission":     "Der Name des Rechts muss im Format resource:action sein!",
+				"cyclic_inheritance":     "Die Vererbung von Rollen darf nicht zyklisch sein!",
+				"realm_not_found":        "Realm nicht gefunden!",
+				"realm_exists":           "Realm existiert bereits!",
+				"invalid_realm_name":     "Der Realm-Name darf nur Kleinbuchstaben, Ziffern und Bindestriche enthalten!",
+				"default_realm_required": "Der Standard-Realm kann nicht deaktiviert werden!",
+				"provider_not_found":     "Identitätsanbieter nicht gefunden!",
+				"provider_unavailable":   "Identitätsanbieter ist nicht erreichbar!",
+				"invalid_login_state":    "Der Anmeldestatus ist ungültig oder abgelaufen!",
+				"federated_login_failed": "Föderierte Anmeldung fehlgeschlagen!",
+				"user_disabled":          "Benutzer ist deaktiviert!",
+				"user_locked":            "Benutzer ist gesperrt!",
+				"login_blocked":          "Die Anmeldung wurde als verdächtig blockiert!",
+				"step_up_required":       "Die Anmeldung ist verdächtig, ein zweiter Faktor ist erforderlich!",
+				"too_many_requests":      "Zu viele Anfragen, bitte versuchen Sie es später erneut!",
+				"backend_not_found":      "Identitäts-Backend nicht gefunden!",
+				"encryption_unavailable": "Der Verschlüsselungsdienst ist nicht erreichbar, bitte versuchen Sie es " +
+					"später erneut!",
+				"invalid_csrf_token": "CSRF-Token fehlt oder ist ungültig!",
+			},
+			Validation: map[string]string{
+				"required":   "{0} ist ein Pflichtfeld",
+				"min_string": "{0} muss mindestens {1} Zeichen lang sein",
+				"min_number": "{0} muss {1} oder größer sein",
+				"min_items":  "{0} muss mindestens {1} Elemente enthalten",
+				"max_string": "{0} darf maximal {1} Zeichen lang sein",
+				"max_number": "{0} muss {1} oder kleiner sein",
+				"max_items":  "{0} darf maximal {1} Elemente enthalten",
+				"len_string": "{0} muss genau {1} Zeichen lang sein",
+				"len_number": "{0} muss gleich {1} sein",
+				"len_items":  "{0} muss genau {1} Elemente enthalten",
+				"email":      "{0} muss eine gültige E-Mail-Adresse sein",
+				"url":        "{0} muss eine gültige URL sein",
+				"oneof":      "{0} muss einer der folgenden Werte sein: {1}",
+			},
+		},
+		"es": {
+			Errors: map[string]string{
+				"internal_error":         "¡Se produjo un error desconocido en el servidor!",
+				"validation_failed":      "¡La validación de la solicitud falló!",
+				"invalid_json":           "¡No es una solicitud JSON válida!",
+				"invalid_credentials":    "¡Contraseña inválida!",
+				"user_not_found":         "¡Usuario no encontrado!",
+				"user_not_linked":        "¡Usuario no encontrado!",
+				"missing_token":          "¡Falta el token Bearer!",
+				"invalid_token":          "¡Token inválido!",
+				"token_revoked":          "¡El token fue revocado!",
+				"entitlement_expired":    "¡El derecho expiró, el token debe renovarse!",
+				"ca_disabled":            "¡La autoridad de certificación no está configurada!",
+				"invalid_csr":            "¡Solicitud de firma de certificado inválida!",
+				"no_eligible_role":       "¡El usuario no tiene ningún rol apto para un certificado vpn!",
+				"forbidden":              "¡Privilegios insuficientes!",
+				"entitlement_not_found":  "¡Derecho no encontrado!",
+				"invalid_timestamp":      "¡La marca de tiempo debe estar en formato RFC3339!",
+				"invalid_limit":          "¡El límite debe estar entre 1 y 1000!",
+				"invalid_offset":         "¡El desplazamiento debe ser un entero no negativo!",
+				"invalid_scope":          "¡Los alcances deben ser un subconjunto de los roles del usuario!",
+				"token_not_found":        "¡Token no encontrado!",
+				"role_not_found":         "¡Rol no encontrado!",
+				"permission_not_found":   "¡Permiso no encontrado!",
+				"permission_exists":      "¡El permiso ya existe!",
+				"invalid_permission":     "¡El nombre del permiso debe tener el formato resource:action!",
+				"cyclic_inheritance":     "¡La herencia de roles no puede ser cíclica!",
+				"realm_not_found":        "¡Realm no encontrado!",
+				"realm_exists":           "¡El realm ya existe!",
+				"invalid_realm_name":     "¡El nombre del realm debe tener solo minúsculas, dígitos y guiones!",
+				"default_realm_required": "¡El realm predeterminado no se puede deshabilitar!",
+				"provider_not_found":     "¡Proveedor de identidad no encontrado!",
+				"provider_unavailable":   "¡El proveedor de identidad no está disponible!",
+				"invalid_login_state":    "¡El estado de inicio de sesión es inválido o expiró!",
+				"federated_login_failed": "¡El inicio de sesión federado falló!",
+				"user_disabled":          "¡El usuario está deshabilitado!",
+				"user_locked":            "¡El usuario está bloqueado!",
+				"login_blocked":          "¡El inicio de sesión fue bloqueado por sospechoso!",
+				"step_up_required":       "¡El inicio de sesión es sospechoso, se requiere un segundo factor!",
+				"too_many_requests":      "¡Demasiadas solicitudes, inténtelo de nuevo más tarde!",
+				"backend_not_found":      "¡Backend de identidad no encontrado!",
+				"encryption_unavailable": "¡El servicio de cifrado no está disponible, inténtelo de nuevo más tarde!",
+				"invalid_csrf_token":     "¡El token CSRF falta o es inválido!",
+			},
+		},
+	}
+}
@@ -0,0 +1,172 @@
+package i18n
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultLocale is the locale of the messages which are hard-coded in the service, it is used when none of the
+// locales of the client is supported
+const DefaultLocale = "en"
+
+// Bundle holds the messages of a locale. Errors are keyed by the codes of the error catalog, validation messages are
+// keyed by the validation tags like required, or by the tag and the kind of the field like min_string, min_number and
+// min_items. {0} is replaced with the field and {1} with the parameter of the tag in the validation messages
+type Bundle struct {
+	Errors     map[string]string `yaml:"errors"`
+	Validation map[string]string `yaml:"validation"`
+}
+
+// Catalog holds the bundles of the supported locales, locales are lowercase language tags like tr or pt-br
+type Catalog struct {
+	bundles map[string]Bundle
+}
+
+// NewCatalog creates a Catalog with the DefaultBundles
+func NewCatalog() *Catalog {
+	c := &Catalog{bundles: map[string]Bundle{DefaultLocale: {}}}
+	for locale, bundle := range DefaultBundles() {
+		c.Merge(locale, bundle)
+	}
+
+	return c
+}
+
+// Merge adds the messages of the bundle to the locale, messages which already exist are overridden
+func (c *Catalog) Merge(locale string, bundle Bundle) {
+	locale = strings.ToLower(locale)
+	existing := c.bundles[locale]
+	c.bundles[locale] = Bundle{
+		Errors:     merge(existing.Errors, bundle.Errors),
+		Validation: merge(existing.Validation, bundle.Validation),
+	}
+}
+
+func merge(dst, src map[string]string) map[string]string {
+	if dst == nil {
+		dst = make(map[string]string)
+	}
+
+	for k, v := range src {
+		dst[k] = v
+	}
+
+	return dst
+}
+
+// LoadDir merges the yaml files in the directory into the catalog, name of the file without the extension is the
+// locale like de.yml or pt-BR.yaml. Files can both add new locales and override the messages of the existing ones
+func (c *Catalog) LoadDir(dir string) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		ext := filepath.Ext(f.Name())
+		if f.IsDir() || (ext != ".yml" && ext != ".yaml") {
+			continue
+		}
+
+		content, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return err
+		}
+
+		var bundle Bundle
+		if err := yaml.Unmarshal([]byte(os.ExpandEnv(string(content))), &bundle); err != nil {
+			return fmt.Errorf("translation file %s: %v", f.Name(), err)
+		}
+
+		c.Merge(strings.TrimSuffix(f.Name(), ext), bundle)
+	}
+
+	return nil
+}
+
+// Locales returns the supported locales in alphabetical order
+func (c *Catalog) Locales() []string {
+	locales := make([]string, 0, len(c.bundles))
+	for locale := range c.bundles {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Error returns the message of the error code in the locale
+func (c *Catalog) Error(locale, code string) (string, bool) {
+	message, ok := c.bundles[locale].Errors[code]
+	return message, ok
+}
+
+// Validation returns the validation message of the field in the locale, message of the tag and the kind is preferred
+// over the message of the tag
+func (c *Catalog) Validation(locale, tag, kind, field, param string) (string, bool) {
+	messages := c.bundles[locale].Validation
+	message, ok := messages[tag+"_"+kind]
+	if !ok {
+		if message, ok = messages[tag]; !ok {
+			return "", false
+		}
+	}
+
+	return strings.NewReplacer("{0}", field, "{1}", param).Replace(message), true
+}
+
+type languageRange struct {
+	tag     string
+	quality float64
+}
+
+// Negotiate picks the supported locale which the client prefers most in the Accept-Language header. A language tag
+// like de-AT matches de if de-AT is not supported, DefaultLocale is returned if nothing matches
+func (c *Catalog) Negotiate(acceptLanguage string) string {
+	var ranges []languageRange
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		r := languageRange{tag: strings.ToLower(strings.TrimSpace(fields[0])), quality: 1}
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				q, err := strconv.ParseFloat(param[2:], 64)
+				if err != nil {
+					q = 0
+				}
+				r.quality = q
+			}
+		}
+
+		if r.tag != "" && r.quality > 0 {
+			ranges = append(ranges, r)
+		}
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].quality > ranges[j].quality
+	})
+
+	for _, r := range ranges {
+		if r.tag == "*" {
+			return DefaultLocale
+		}
+
+		if _, ok := c.bundles[r.tag]; ok {
+			return r.tag
+		}
+
+		if i := strings.IndexByte(r.tag, '-'); i > 0 {
+			if _, ok := c.bundles[r.tag[:i]]; ok {
+				return r.tag[:i]
+			}
+		}
+	}
+
+	return DefaultLocale
+}
@@ -0,0 +1,77 @@
+package i18n
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	c := NewCatalog()
+	cases := map[string]string{
+		"":                             DefaultLocale,
+		"tr":                           "tr",
+		"de-AT,de;q=0.9":               "de",
+		"fr-FR,fr;q=0.9,es;q=0.8":      "es",
+		"en;q=0.5,tr;q=0.8":            "tr",
+		"es;q=0,de":                    "de",
+		"*":                            DefaultLocale,
+		"ja, zh-CN;q=0.9, invalid;q=x": DefaultLocale,
+	}
+	for header, expected := range cases {
+		if locale := c.Negotiate(header); locale != expected {
+			t.Errorf("%q: expected %s, got %s", header, expected, locale)
+		}
+	}
+}
+
+func TestLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"de.yml":    "errors:\n  user_not_found: Kein Benutzer!\n",
+		"pt-BR.yml": "errors:\n  user_not_found: Usuário não encontrado!\nvalidation:\n  required: '{0} é obrigatório'\n",
+		"notes.txt": "ignored",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := NewCatalog()
+	if err := c.LoadDir(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if message, _ := c.Error("de", "user_not_found"); message != "Kein Benutzer!" {
+		t.Errorf("expected the message to be overridden, got %q", message)
+	}
+
+	if message, _ := c.Error("de", "user_locked"); message != "Benutzer ist gesperrt!" {
+		t.Errorf("expected the other messages to be kept, got %q", message)
+	}
+
+	if locale := c.Negotiate("pt-BR"); locale != "pt-br" {
+		t.Errorf("expected the new locale to be supported, got %s", locale)
+	}
+
+	if message, ok := c.Validation("pt-br", "required", "string", "Name", ""); !ok || message != "Name é obrigatório" {
+		t.Errorf("unexpected validation message %q", message)
+	}
+}
+
+func TestValidation(t *testing.T) {
+	c := NewCatalog()
+	if message, _ := c.Validation("de", "min", "string", "Password", "3"); message !=
+		"Password muss mindestens 3 Zeichen lang sein" {
+		t.Errorf("expected the message of the kind, got %q", message)
+	}
+
+	if message, _ := c.Validation("de", "required", "items", "Scopes", ""); message != "Scopes ist ein Pflichtfeld" {
+		t.Errorf("expected the message of the tag, got %q", message)
+	}
+
+	if _, ok := c.Validation("tr", "required", "string", "Name", ""); ok {
+		t.Error("expected the validator translations to be used for tr")
+	}
+}
@@ -0,0 +1,53 @@
+package identity
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+)
+
+const backendTypeLdap = "ldap"
+
+type backendConfig struct {
+	Name       string `yaml:"name"`
+	Type       string `yaml:"type"`
+	LdapConfig `yaml:",inline"`
+}
+
+type backendsFile struct {
+	Backends []backendConfig `yaml:"backends"`
+}
+
+// LoadBackends reads the backends from the yaml file, ${VAR} references are expanded from the environment so the bind
+// passwords do not have to be stored in the file
+func LoadBackends(path string) ([]Backend, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file backendsFile
+	if err := yaml.Unmarshal([]byte(os.ExpandEnv(string(content))), &file); err != nil {
+		return nil, err
+	}
+
+	backends := make([]Backend, 0, len(file.Backends))
+	for i, config := range file.Backends {
+		if config.Name == "" || config.Name == LocalBackendName {
+			return nil, fmt.Errorf("backend %d: name is required and can not be %s", i, LocalBackendName)
+		}
+
+		switch config.Type {
+		case backendTypeLdap:
+			if config.Url == "" || config.UserBaseDn == "" {
+				return nil, fmt.Errorf("backend %s: url and userBaseDn are required", config.Name)
+			}
+			backends = append(backends, NewLdapBackend(config.Name, config.LdapConfig))
+		default:
+			return nil, fmt.Errorf("backend %s: unsupported type %q", config.Name, config.Type)
+		}
+	}
+
+	return backends, nil
+}
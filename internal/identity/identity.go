@@ -0,0 +1,81 @@
+package identity
+
+import (
+	"auth-service/internal/model"
+	"context"
+	"errors"
+	"gorm.io/gorm"
+)
+
+// LocalBackendName is the name of the backend which verifies the passwords stored in the users table
+const LocalBackendName = "local"
+
+var (
+	// ErrInvalidCredentials is returned when the password does not match
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrUserNotFound is returned when there is no such user in the realm or in the backend
+	ErrUserNotFound = errors.New("user not found")
+	// ErrUserDisabled is returned when the user is disabled
+	ErrUserDisabled = errors.New("user is disabled")
+	// ErrUserLocked is returned when the backend locked the account, e.g. after too many failed logins
+	ErrUserLocked = errors.New("user is locked")
+	// ErrUserConflict is returned when the user exists in the realm but belongs to another backend or has its own
+	// credentials, so the backend can not take it over
+	ErrUserConflict = errors.New("user belongs to another identity backend")
+	// ErrBackendNotFound is returned when the user or the realm refers to a backend which is not configured
+	ErrBackendNotFound = errors.New("identity backend not found")
+)
+
+// Backend verifies the credentials of the users of a realm
+type Backend interface {
+	Name() string
+	// Authenticate verifies the credentials and returns the user of the realm with its roles, backends which own
+	// their users provision or update the user in the users table
+	Authenticate(ctx context.Context, db *gorm.DB, realmId uint, username, password string) (*model.User, error)
+}
+
+// Registry holds the configured backends by name
+type Registry struct {
+	backends map[string]Backend
+}
+
+// NewRegistry creates a Registry, local backend is always available
+func NewRegistry(local Backend, backends ...Backend) *Registry {
+	registry := &Registry{backends: map[string]Backend{LocalBackendName: local}}
+	for _, backend := range backends {
+		registry.backends[backend.Name()] = backend
+	}
+
+	return registry
+}
+
+// Get returns the backend with the given name, empty name means the local backend
+func (r *Registry) Get(name string) (Backend, error) {
+	if name == "" {
+		name = LocalBackendName
+	}
+
+	backend, ok := r.backends[name]
+	if !ok {
+		return nil, ErrBackendNotFound
+	}
+
+	return backend, nil
+}
+
+// Select returns the backend of the user if it is assigned one, otherwise the backend of the realm. Users which do
+// not exist yet are authenticated by the backend of the realm, so it can provision them
+func (r *Registry) Select(db *gorm.DB, realm *model.Realm, username string) (Backend, error) {
+	var users []model.User
+	err := db.Select("identity_backend").Where("realm_id = ? AND user_name = ?", realm.Id, username).Limit(1).
+		Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if len(users) != 0 && users[0].IdentityBackend != "" {
+		return r.Get(users[0].IdentityBackend)
+	}
+
+	return r.Get(realm.IdentityBackend)
+}
@@ -0,0 +1,292 @@
+package identity
+
+import (
+	"auth-service/internal/account"
+	"auth-service/internal/model"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"github.com/go-ldap/ldap/v3"
+	"gorm.io/gorm"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	ldapTimeout = 10 * time.Second
+	// adLockedSubCode is the sub code in the diagnostic message of active directory for the locked accounts
+	adLockedSubCode = "data 775"
+)
+
+// LdapConfig represents an LDAP or Active Directory backend. {username} in UserFilter and {dn} in GroupFilter are
+// replaced with the escaped values
+type LdapConfig struct {
+	Url                string            `yaml:"url"`
+	StartTls           bool              `yaml:"startTls"`
+	InsecureSkipVerify bool              `yaml:"insecureSkipVerify"`
+	BindDn             string            `yaml:"bindDn"`
+	BindPassword       string            `yaml:"bindPassword"`
+	UserBaseDn         string            `yaml:"userBaseDn"`
+	UserFilter         string            `yaml:"userFilter"`
+	EmailAttribute     string            `yaml:"emailAttribute"`
+	GroupBaseDn        string            `yaml:"groupBaseDn"`
+	GroupFilter        string            `yaml:"groupFilter"`
+	GroupAttribute     string            `yaml:"groupAttribute"`
+	GroupRoles         map[string]string `yaml:"groupRoles"`
+	Provision          bool              `yaml:"provision"`
+}
+
+// LdapBackend authenticates the users by binding to the directory as the user, roles of the user are synchronized
+// from its groups on every login
+type LdapBackend struct {
+	name   string
+	config LdapConfig
+}
+
+// NewLdapBackend creates a new LdapBackend, defaults are applied for the empty filters and attributes
+func NewLdapBackend(name string, config LdapConfig) *LdapBackend {
+	if config.UserFilter == "" {
+		config.UserFilter = "(uid={username})"
+	}
+
+	if config.EmailAttribute == "" {
+		config.EmailAttribute = "mail"
+	}
+
+	if config.GroupFilter == "" {
+		config.GroupFilter = "(member={dn})"
+	}
+
+	if config.GroupAttribute == "" {
+		config.GroupAttribute = "cn"
+	}
+
+	return &LdapBackend{name: name, config: config}
+}
+
+// Name returns the configured name of the backend
+func (b *LdapBackend) Name() string {
+	return b.name
+}
+
+// Authenticate binds as the user, resolves its roles from its groups and provisions or updates the user
+func (b *LdapBackend) Authenticate(ctx context.Context, db *gorm.DB, realmId uint, username,
+	password string) (*model.User, error) {
+	// an empty password would be an unauthenticated bind which succeeds on most of the directories
+	if password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	entry, groups, err := b.verify(ctx, username, password)
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := account.ExistingRoles(db, realmId, b.roleNames(groups))
+	if err != nil {
+		return nil, err
+	}
+
+	var user model.User
+	err = db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("realm_id = ? AND user_name = ?", realmId, username).First(&user).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if !b.config.Provision {
+				return ErrUserNotFound
+			}
+
+			user = model.User{
+				RealmId:         realmId,
+				IdentityBackend: b.name,
+				UserName:        username,
+				Email:           entry.GetAttributeValue(b.config.EmailAttribute),
+			}
+			return account.Provision(tx, &user, nil)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		return b.checkAdoptable(tx, &user)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.Enabled {
+		return nil, ErrUserDisabled
+	}
+
+	// directory is the source of truth for the roles of its users
+	if err := db.Transaction(func(tx *gorm.DB) error { return account.SetRoles(tx, &user, roles) }); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// checkAdoptable refuses to take over an existing user unless it is assigned to this backend, or it is assigned to
+// none and has neither a local password nor a federated identity, like the users provisioned by SCIM. The directory
+// replaces the roles of the users it takes over, so a directory user must not be merged into an unrelated account
+// with the same name. Admins can assign the backend to the user explicitly to allow it
+func (b *LdapBackend) checkAdoptable(tx *gorm.DB, user *model.User) error {
+	if !b.adoptable(user) {
+		return ErrUserConflict
+	}
+
+	if user.IdentityBackend == b.name {
+		return nil
+	}
+
+	var federated int64
+	if err := tx.Model(&model.FederatedIdentity{}).Where("user_id = ?", user.Id).Count(&federated).Error; err != nil {
+		return err
+	}
+
+	if federated != 0 {
+		return ErrUserConflict
+	}
+
+	return nil
+}
+
+func (b *LdapBackend) adoptable(user *model.User) bool {
+	return user.IdentityBackend == b.name || (user.IdentityBackend == "" && user.EncryptedPassword == "")
+}
+
+// verify finds the user entry, binds as the user and returns the entry with the names of its groups
+func (b *LdapBackend) verify(ctx context.Context, username, password string) (*ldap.Entry, []string, error) {
+	conn, err := b.dial(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer conn.Close()
+
+	if err := b.serviceBind(conn); err != nil {
+		return nil, nil, err
+	}
+
+	result, err := conn.Search(ldap.NewSearchRequest(b.config.UserBaseDn, ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases, 2, int(ldapTimeout.Seconds()), false,
+		strings.ReplaceAll(b.config.UserFilter, "{username}", ldap.EscapeFilter(username)),
+		[]string{"dn", b.config.EmailAttribute}, nil))
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+		return nil, nil, fmt.Errorf("an error occurred while searching user: %w", err)
+	}
+
+	if result == nil || len(result.Entries) == 0 {
+		return nil, nil, ErrUserNotFound
+	}
+
+	if len(result.Entries) > 1 {
+		return nil, nil, fmt.Errorf("user filter matched %d entries", len(result.Entries))
+	}
+
+	entry := result.Entries[0]
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			// active directory reports the locked accounts as invalid credentials with the 775 sub code
+			if strings.Contains(err.Error(), adLockedSubCode) {
+				return nil, nil, ErrUserLocked
+			}
+
+			return nil, nil, ErrInvalidCredentials
+		}
+
+		return nil, nil, err
+	}
+
+	// groups are searched with the service account, users may not be allowed to read the groups
+	if err := b.serviceBind(conn); err != nil {
+		return nil, nil, err
+	}
+
+	groups, err := b.groups(conn, entry.DN)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return entry, groups, nil
+}
+
+func (b *LdapBackend) groups(conn *ldap.Conn, dn string) ([]string, error) {
+	if b.config.GroupBaseDn == "" {
+		return nil, nil
+	}
+
+	result, err := conn.Search(ldap.NewSearchRequest(b.config.GroupBaseDn, ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases, 0, int(ldapTimeout.Seconds()), false,
+		strings.ReplaceAll(b.config.GroupFilter, "{dn}", ldap.EscapeFilter(dn)),
+		[]string{b.config.GroupAttribute}, nil))
+	if err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) {
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("an error occurred while searching groups: %w", err)
+	}
+
+	groups := make([]string, 0, len(result.Entries))
+	for _, entry := range result.Entries {
+		if name := entry.GetAttributeValue(b.config.GroupAttribute); name != "" {
+			groups = append(groups, name)
+		}
+	}
+
+	return groups, nil
+}
+
+// roleNames maps the groups to the role names, group names are used as is if there is no mapping
+func (b *LdapBackend) roleNames(groups []string) []string {
+	var roles []string
+	for _, group := range groups {
+		if len(b.config.GroupRoles) == 0 {
+			roles = append(roles, group)
+		} else if role, ok := b.config.GroupRoles[group]; ok {
+			roles = append(roles, role)
+		}
+	}
+
+	return roles
+}
+
+// dial connects to the directory, operations time out with the deadline of the context if it is sooner than
+// ldapTimeout
+func (b *LdapBackend) dial(ctx context.Context) (*ldap.Conn, error) {
+	timeout := ldapTimeout
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < timeout {
+		timeout = time.Until(deadline)
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: b.config.InsecureSkipVerify}
+	conn, err := ldap.DialURL(b.config.Url, ldap.DialWithDialer(&net.Dialer{Timeout: timeout}),
+		ldap.DialWithTLSConfig(tlsConfig))
+	if err != nil {
+		return nil, fmt.Errorf("an error occurred while connecting to %s: %w", b.config.Url, err)
+	}
+
+	conn.SetTimeout(timeout)
+	if b.config.StartTls {
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
+
+func (b *LdapBackend) serviceBind(conn *ldap.Conn) error {
+	if b.config.BindDn == "" {
+		return conn.UnauthenticatedBind("")
+	}
+
+	if err := conn.Bind(b.config.BindDn, b.config.BindPassword); err != nil {
+		return fmt.Errorf("an error occurred while binding as service account: %w", err)
+	}
+
+	return nil
+}
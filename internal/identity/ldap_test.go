@@ -0,0 +1,173 @@
+package identity
+
+import (
+	"auth-service/internal/model"
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jimlambrt/gldap"
+)
+
+const (
+	testServiceDn = "cn=vpnbeast,ou=services,dc=example,dc=org"
+	testUserDn    = "uid=alice,ou=people,dc=example,dc=org"
+)
+
+// startTestDirectory starts an in-process directory with a service account, alice and her groups
+func startTestDirectory(t *testing.T) string {
+	passwords := map[string]string{testServiceDn: "service-secret", testUserDn: "alice-secret"}
+	users := map[string]map[string][]string{
+		"(uid=alice)": {"mail": {"alice@example.org"}},
+	}
+	groups := map[string][]string{
+		fmt.Sprintf("(member=%s)", testUserDn): {"vpn-users", "staff"},
+	}
+
+	mux, err := gldap.NewMux()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = mux.Bind(func(w *gldap.ResponseWriter, r *gldap.Request) {
+		res := r.NewBindResponse(gldap.WithResponseCode(gldap.ResultInvalidCredentials))
+		defer func() { _ = w.Write(res) }()
+		m, err := r.GetSimpleBindMessage()
+		if err != nil {
+			return
+		}
+
+		if m.UserName == "" && m.Password == "" {
+			res.SetResultCode(gldap.ResultSuccess)
+			return
+		}
+
+		if expected, ok := passwords[m.UserName]; ok && expected == string(m.Password) {
+			res.SetResultCode(gldap.ResultSuccess)
+		}
+	})
+	_ = mux.Search(func(w *gldap.ResponseWriter, r *gldap.Request) {
+		res := r.NewSearchDoneResponse(gldap.WithResponseCode(gldap.ResultSuccess))
+		defer func() { _ = w.Write(res) }()
+		m, err := r.GetSearchMessage()
+		if err != nil {
+			res.SetResultCode(gldap.ResultOperationsError)
+			return
+		}
+
+		if attributes, ok := users[m.Filter]; ok {
+			entry := r.NewSearchResponseEntry(testUserDn)
+			for name, values := range attributes {
+				entry.AddAttribute(name, values)
+			}
+			_ = w.Write(entry)
+		}
+
+		for _, cn := range groups[m.Filter] {
+			entry := r.NewSearchResponseEntry(fmt.Sprintf("cn=%s,ou=groups,dc=example,dc=org", cn))
+			entry.AddAttribute("cn", []string{cn})
+			_ = w.Write(entry)
+		}
+	})
+
+	server, err := gldap.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = server.Router(mux)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	_ = listener.Close()
+
+	go func() { _ = server.Run(addr) }()
+	t.Cleanup(func() { _ = server.Stop() })
+	for i := 0; !server.Ready(); i++ {
+		if i == 100 {
+			t.Fatal("test directory is not ready")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return "ldap://" + addr
+}
+
+func TestLdapVerify(t *testing.T) {
+	backend := NewLdapBackend("corp", LdapConfig{
+		Url:          startTestDirectory(t),
+		BindDn:       testServiceDn,
+		BindPassword: "service-secret",
+		UserBaseDn:   "ou=people,dc=example,dc=org",
+		GroupBaseDn:  "ou=groups,dc=example,dc=org",
+		GroupRoles:   map[string]string{"vpn-users": "ROLE_USER"},
+	})
+
+	entry, groups, err := backend.verify(context.Background(), "alice", "alice-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if entry.DN != testUserDn || entry.GetAttributeValue("mail") != "alice@example.org" {
+		t.Errorf("unexpected entry %s %v", entry.DN, entry.Attributes)
+	}
+
+	if !reflect.DeepEqual(groups, []string{"vpn-users", "staff"}) {
+		t.Errorf("unexpected groups %v", groups)
+	}
+
+	if roles := backend.roleNames(groups); !reflect.DeepEqual(roles, []string{"ROLE_USER"}) {
+		t.Errorf("expected only the mapped groups to become roles, got %v", roles)
+	}
+
+	if _, _, err := backend.verify(context.Background(), "alice", "wrong"); err != ErrInvalidCredentials {
+		t.Errorf("expected %v, got %v", ErrInvalidCredentials, err)
+	}
+
+	if _, _, err := backend.verify(context.Background(), "bob", "secret"); err != ErrUserNotFound {
+		t.Errorf("expected %v, got %v", ErrUserNotFound, err)
+	}
+
+	if _, err := backend.Authenticate(context.Background(), nil, 1, "alice", ""); err != ErrInvalidCredentials {
+		t.Errorf("expected empty password to be refused before binding, got %v", err)
+	}
+}
+
+func TestLdapVerifyWithWrongServiceAccount(t *testing.T) {
+	backend := NewLdapBackend("corp", LdapConfig{
+		Url:          startTestDirectory(t),
+		BindDn:       testServiceDn,
+		BindPassword: "wrong",
+		UserBaseDn:   "ou=people,dc=example,dc=org",
+	})
+
+	_, _, err := backend.verify(context.Background(), "alice", "alice-secret")
+	if err == nil || err == ErrInvalidCredentials {
+		t.Errorf("expected service bind error, got %v", err)
+	}
+}
+
+func TestLdapAdoptable(t *testing.T) {
+	backend := NewLdapBackend("corp", LdapConfig{})
+	cases := []struct {
+		user      model.User
+		adoptable bool
+	}{
+		{model.User{IdentityBackend: "corp"}, true},
+		{model.User{}, true},
+		{model.User{EncryptedPassword: "encrypted"}, false},
+		{model.User{IdentityBackend: "other"}, false},
+		{model.User{IdentityBackend: "local"}, false},
+	}
+
+	for _, c := range cases {
+		if adoptable := backend.adoptable(&c.user); adoptable != c.adoptable {
+			t.Errorf("expected adoptable %v for %+v, got %v", c.adoptable, c.user, adoptable)
+		}
+	}
+}
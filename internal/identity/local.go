@@ -0,0 +1,60 @@
+package identity
+
+import (
+	"auth-service/internal/model"
+	"context"
+	"errors"
+	"gorm.io/gorm"
+)
+
+// PasswordChecker checks if the plain password matches the encrypted password of the user
+type PasswordChecker func(ctx context.Context, plain, encrypted string) (bool, error)
+
+// LocalBackend verifies the passwords stored in the users table
+type LocalBackend struct {
+	check PasswordChecker
+}
+
+// NewLocalBackend creates a LocalBackend which compares the passwords with the checker
+func NewLocalBackend(check PasswordChecker) *LocalBackend {
+	return &LocalBackend{check: check}
+}
+
+// Name returns LocalBackendName
+func (b *LocalBackend) Name() string {
+	return LocalBackendName
+}
+
+// Authenticate loads the user and checks the password
+func (b *LocalBackend) Authenticate(ctx context.Context, db *gorm.DB, realmId uint, username,
+	password string) (*model.User, error) {
+	var user model.User
+	err := db.Preload("Roles").Where("realm_id = ? AND user_name = ?", realmId, username).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUserNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	// federated and provisioned users have no password
+	if user.EncryptedPassword == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	ok, err := b.check(ctx, password, user.EncryptedPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, ErrInvalidCredentials
+	}
+
+	if !user.Enabled {
+		return nil, ErrUserDisabled
+	}
+
+	return &user, nil
+}
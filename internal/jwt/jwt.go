@@ -1,47 +1,69 @@
 package jwt
 
 import (
-	"auth-service/internal/options"
-	"crypto/rsa"
 	"errors"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
 )
 
-var (
-	privateKey      *rsa.PrivateKey
-	publicKey       *rsa.PublicKey
-	privateKeyBytes []byte
-	publicKeyBytes  []byte
-	err             error
-	opts            *options.AuthServiceOptions
-)
+// DefaultRealm is the name of the realm which the tokens without a realm claim belong to
+const DefaultRealm = "default"
 
-func init() {
-	opts = options.GetAuthServiceOptions()
-	privateKeyBytes = []byte(opts.PrivateKey)
-	privateKey, err = jwt.ParseRSAPrivateKeyFromPEM(privateKeyBytes)
-	publicKeyBytes = []byte(opts.PublicKey)
-	publicKey, err = jwt.ParseRSAPublicKeyFromPEM(publicKeyBytes)
-	if err != nil {
-		panic(err)
+var errCrossRealm = errors.New("token is issued by another realm")
+
+// Signer issues and validates the tokens of a single realm
+type Signer struct {
+	realm  string
+	issuer string
+	keys   *KeyRing
+}
+
+// NewSigner creates a Signer for the realm with the given issuer and key ring
+func NewSigner(realm, issuer string, keys *KeyRing) *Signer {
+	return &Signer{
+		realm:  realm,
+		issuer: issuer,
+		keys:   keys,
 	}
 }
 
-// GenerateToken generates JWT token with username and expiresAtInMinutes in RS256 signing method
-func GenerateToken(username string, roles []string, expiresAtInMinutes int32) (string, error) {
-	t := jwt.New(jwt.GetSigningMethod("RS256"))
-	t.Claims = &VpnbeastClaim{
-		Roles: roles,
+// Keys returns the key ring of the Signer
+func (s *Signer) Keys() *KeyRing {
+	return s.keys
+}
+
+// GenerateToken generates JWT token with username and expiresAtInMinutes in RS256 signing method, claims are passed
+// through the enrichers in order before the token is signed
+func (s *Signer) GenerateToken(username string, roles []string, expiresAtInMinutes int32,
+	enrichers ...ClaimEnricher) (string, error) {
+	now := time.Now()
+	claims := &VpnbeastClaim{
+		Roles:          roles,
+		Realm:          s.realm,
+		IssuedAtMillis: now.UnixMilli(),
 		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: time.Now().Add(time.Duration(expiresAtInMinutes) * time.Minute).Unix(),
-			Issuer:    opts.Issuer,
+			ExpiresAt: now.Add(time.Duration(expiresAtInMinutes) * time.Minute).Unix(),
+			Issuer:    s.issuer,
 			Subject:   username,
-			IssuedAt:  time.Now().Unix(),
+			IssuedAt:  now.Unix(),
 		},
 	}
 
+	for _, enrich := range enrichers {
+		if err := enrich(claims); err != nil {
+			return "", err
+		}
+	}
+
+	kid, privateKey, err := s.keys.activeKey()
+	if err != nil {
+		return "", err
+	}
+
+	t := jwt.New(jwt.GetSigningMethod("RS256"))
+	t.Header["kid"] = kid
+	t.Claims = claims
 	tokenString, err := t.SignedString(privateKey)
 	if err != nil {
 		return "", err
@@ -50,24 +72,44 @@ func GenerateToken(username string, roles []string, expiresAtInMinutes int32) (s
 	return tokenString, nil
 }
 
-// ValidateToken validates JWT token by checking if issuer is registered user, expiration time not passed etc
-func ValidateToken(signedToken string) (string, []string, error, int) {
-	var roles []string
+// ParseToken validates JWT token and returns all the claims of the token. Tokens issued by another realm are
+// refused, even if they are signed by a key of this realm
+func (s *Signer) ParseToken(signedToken string) (*VpnbeastClaim, error, int) {
 	token, err := jwt.ParseWithClaims(
 		signedToken,
 		&VpnbeastClaim{},
 		func(token *jwt.Token) (interface{}, error) {
-			return publicKey, nil
+			if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+				return nil, errors.New("unexpected signing method")
+			}
+
+			// tokens issued before key rotation was introduced have no kid header
+			kid, _ := token.Header["kid"].(string)
+			if kid == "" {
+				kid = DefaultKeyId
+			}
+
+			return s.keys.publicKey(kid)
 		})
 
 	if err != nil {
-		return "", roles, err, 401
+		return nil, err, 401
 	}
 
 	claims, ok := token.Claims.(*VpnbeastClaim)
 	if !ok {
 		err = errors.New("an error occured while parsing token claims")
-		return "", roles, err, 500
+		return nil, err, 500
+	}
+
+	// tokens issued before realms were introduced have no realm claim and belong to the default realm
+	realm := claims.Realm
+	if realm == "" {
+		realm = DefaultRealm
+	}
+
+	if realm != s.realm || claims.Issuer != s.issuer {
+		return nil, errCrossRealm, 401
 	}
 
 	/*if claims.ExpiresAt < time.Now().Local().Unix() {
@@ -75,5 +117,5 @@ func ValidateToken(signedToken string) (string, []string, error, int) {
 		return "", roles, err, 401
 	}*/
 
-	return claims.Subject, claims.Roles, nil, 200
+	return claims, nil, 200
 }
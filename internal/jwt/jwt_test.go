@@ -1 +1,119 @@
 package jwt
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+)
+
+func TestGenerateTokenWithEnricher(t *testing.T) {
+	signer := NewSigner(DefaultRealm, "info@thevpnbeast.com", newTestKeyRing(t, DefaultKeyId))
+	expiresAt := time.Now().Add(time.Hour).Unix()
+	token, err := signer.GenerateToken("foo", []string{"ROLE_USER"}, 5, func(claims *VpnbeastClaim) error {
+		claims.Entitlements = map[string]EntitlementClaim{"plan": {Value: "premium", ExpiresAt: expiresAt}}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err, code := signer.ParseToken(token)
+	if err != nil {
+		t.Fatalf("unexpected error with code %d: %s", code, err)
+	}
+
+	if claims.Subject != "foo" || claims.Entitlements["plan"].Value != "premium" {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+
+	if expired := claims.ExpiredEntitlements(time.Now().Unix()); len(expired) != 0 {
+		t.Errorf("expected no expired entitlements, got %v", expired)
+	}
+
+	if expired := claims.ExpiredEntitlements(expiresAt); len(expired) != 1 || expired[0] != "plan" {
+		t.Errorf("expected plan entitlement to be expired, got %v", expired)
+	}
+}
+
+func newTestKeyRing(t *testing.T, kid string) *KeyRing {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	publicKey, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ring := NewKeyRing()
+	privatePem := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	publicPem := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey})
+	if err := ring.Add(kid, string(privatePem), string(publicPem), true); err != nil {
+		t.Fatal(err)
+	}
+
+	return ring
+}
+
+func TestSignerRefusesCrossRealmTokens(t *testing.T) {
+	ring := newTestKeyRing(t, "k1")
+	acme := NewSigner("acme", "https://acme.example.com", ring)
+	// same keys and issuer, only the realm differs
+	other := NewSigner("other", "https://acme.example.com", ring)
+
+	token, err := acme.GenerateToken("foo", []string{"ROLE_USER"}, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err, _ := acme.ParseToken(token)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if claims.Realm != "acme" {
+		t.Errorf("expected realm claim acme, got %s", claims.Realm)
+	}
+
+	if _, err, code := other.ParseToken(token); err == nil || code != 401 {
+		t.Errorf("expected token of another realm to be refused, got %v with code %d", err, code)
+	}
+
+	defaultSigner := NewSigner(DefaultRealm, "https://acme.example.com", newTestKeyRing(t, DefaultKeyId))
+	if _, err, _ := defaultSigner.ParseToken(token); err == nil {
+		t.Error("expected token signed by an unknown key to be refused by the default realm")
+	}
+}
+
+func TestSignerVerifiesRetiredKeys(t *testing.T) {
+	ring := newTestKeyRing(t, "k1")
+	signer := NewSigner("acme", "https://acme.example.com", ring)
+	token, err := signer.GenerateToken("foo", nil, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated := newTestKeyRing(t, "k2")
+	kids, keys := ring.PublicKeys()
+	publicKey, err := x509.MarshalPKIXPublicKey(keys[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	publicPem := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey})
+	if err := rotated.Add(kids[0], "", string(publicPem), false); err != nil {
+		t.Fatal(err)
+	}
+
+	if rotated.ActiveKeyId() != "k2" {
+		t.Errorf("expected k2 to stay active, got %s", rotated.ActiveKeyId())
+	}
+
+	if _, err, _ := NewSigner("acme", "https://acme.example.com", rotated).ParseToken(token); err != nil {
+		t.Errorf("expected token signed by retired key to be valid, got %s", err)
+	}
+}
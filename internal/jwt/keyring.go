@@ -0,0 +1,112 @@
+package jwt
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// DefaultKeyId is the kid of the configured key pair, tokens without a kid header are verified with this key
+const DefaultKeyId = "default"
+
+var errNoActiveKey = errors.New("there is no active signing key")
+
+// KeyRing holds the signing keys of a realm. Only the active key signs new tokens, retired keys are kept to verify
+// the tokens which are issued before a rotation
+type KeyRing struct {
+	mu          sync.RWMutex
+	activeKid   string
+	privateKeys map[string]*rsa.PrivateKey
+	publicKeys  map[string]*rsa.PublicKey
+}
+
+// NewKeyRing creates an empty KeyRing
+func NewKeyRing() *KeyRing {
+	return &KeyRing{
+		privateKeys: make(map[string]*rsa.PrivateKey),
+		publicKeys:  make(map[string]*rsa.PublicKey),
+	}
+}
+
+// Add parses and adds the PEM encoded key pair, private key can be empty for a retired key
+func (k *KeyRing) Add(kid, privateKeyPem, publicKeyPem string, active bool) error {
+	publicKey, err := jwt.ParseRSAPublicKeyFromPEM([]byte(publicKeyPem))
+	if err != nil {
+		return fmt.Errorf("an error occurred while parsing public key %s: %w", kid, err)
+	}
+
+	var privateKey *rsa.PrivateKey
+	if privateKeyPem != "" {
+		if privateKey, err = jwt.ParseRSAPrivateKeyFromPEM([]byte(privateKeyPem)); err != nil {
+			return fmt.Errorf("an error occurred while parsing private key %s: %w", kid, err)
+		}
+	}
+
+	if active && privateKey == nil {
+		return fmt.Errorf("active key %s has no private key", kid)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.publicKeys[kid] = publicKey
+	if privateKey != nil {
+		k.privateKeys[kid] = privateKey
+	}
+
+	if active {
+		k.activeKid = kid
+	}
+
+	return nil
+}
+
+// ActiveKeyId returns the kid of the key which signs the new tokens
+func (k *KeyRing) ActiveKeyId() string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.activeKid
+}
+
+// PublicKeys returns all the verification keys by their kid, sorted by kid
+func (k *KeyRing) PublicKeys() ([]string, []*rsa.PublicKey) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	kids := make([]string, 0, len(k.publicKeys))
+	for kid := range k.publicKeys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	keys := make([]*rsa.PublicKey, 0, len(kids))
+	for _, kid := range kids {
+		keys = append(keys, k.publicKeys[kid])
+	}
+
+	return kids, keys
+}
+
+func (k *KeyRing) activeKey() (string, *rsa.PrivateKey, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	key, ok := k.privateKeys[k.activeKid]
+	if !ok {
+		return "", nil, errNoActiveKey
+	}
+
+	return k.activeKid, key, nil
+}
+
+func (k *KeyRing) publicKey(kid string) (*rsa.PublicKey, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	key, ok := k.publicKeys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key %s", kid)
+	}
+
+	return key, nil
+}
@@ -1,8 +1,65 @@
 package jwt
 
-import "github.com/dgrijalva/jwt-go"
+import (
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// types of the session tokens
+const (
+	TokenTypeAccess  = "access"
+	TokenTypeRefresh = "refresh"
+)
 
 type VpnbeastClaim struct {
-	Roles []string `json:"roles"`
+	// Type tells the access tokens from the refresh tokens, it is empty for the tokens issued before it was introduced
+	Type         string                      `json:"typ,omitempty"`
+	Roles        []string                    `json:"roles"`
+	Entitlements map[string]EntitlementClaim `json:"entitlements,omitempty"`
+	Scope        string                      `json:"scope,omitempty"`
+	Realm        string                      `json:"realm,omitempty"`
+	// IssuedAtMillis is the issue time in unix milliseconds, iat only has second precision which can not tell a
+	// token issued right after a logout from the ones issued before it
+	IssuedAtMillis int64 `json:"iat_ms,omitempty"`
 	jwt.StandardClaims
 }
+
+// IssuedAtTime returns the issue time of the token in millisecond precision, falls back to iat for the tokens
+// issued before iat_ms was introduced
+func (c *VpnbeastClaim) IssuedAtTime() time.Time {
+	if c.IssuedAtMillis > 0 {
+		return time.UnixMilli(c.IssuedAtMillis)
+	}
+
+	return time.Unix(c.IssuedAt, 0)
+}
+
+// EntitlementClaim represents a single entitlement of the user embedded into the access token
+type EntitlementClaim struct {
+	Value     string `json:"value"`
+	ExpiresAt int64  `json:"exp,omitempty"`
+}
+
+// ClaimEnricher adds extra claims to the token before it is signed
+type ClaimEnricher func(claims *VpnbeastClaim) error
+
+// WithType sets the type of the token, see TokenTypeAccess and TokenTypeRefresh
+func WithType(tokenType string) ClaimEnricher {
+	return func(claims *VpnbeastClaim) error {
+		claims.Type = tokenType
+		return nil
+	}
+}
+
+// ExpiredEntitlements returns the names of the embedded entitlements which are expired at the given unix time
+func (c *VpnbeastClaim) ExpiredEntitlements(now int64) []string {
+	var expired []string
+	for name, entitlement := range c.Entitlements {
+		if entitlement.ExpiresAt != 0 && entitlement.ExpiresAt <= now {
+			expired = append(expired, name)
+		}
+	}
+
+	return expired
+}
@@ -0,0 +1,161 @@
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"sync"
+	"time"
+)
+
+// Component is a long running part of the service like a server. Start blocks until the component is stopped or
+// fails, Stop makes Start return and must give up when the context is done
+type Component interface {
+	Start() error
+	Stop(ctx context.Context) error
+}
+
+// Closer releases a resource after the components are stopped, like the database connection pool
+type Closer func(ctx context.Context) error
+
+type namedComponent struct {
+	name      string
+	component Component
+}
+
+type namedCloser struct {
+	name  string
+	close Closer
+}
+
+// Manager starts the components together and shuts them down when the context is done or any of them fails
+type Manager struct {
+	logger     *zap.Logger
+	components []namedComponent
+	closers    []namedCloser
+	drainHooks []func()
+	drainDelay time.Duration
+}
+
+// NewManager creates an empty Manager
+func NewManager(logger *zap.Logger) *Manager {
+	return &Manager{logger: logger}
+}
+
+// Add adds a component which is started by Run
+func (m *Manager) Add(name string, component Component) {
+	m.components = append(m.components, namedComponent{name: name, component: component})
+}
+
+// OnShutdown adds a closer which is called after all the components are stopped, closers are called in the reverse
+// order of addition
+func (m *Manager) OnShutdown(name string, close Closer) {
+	m.closers = append(m.closers, namedCloser{name: name, close: close})
+}
+
+// Drain adds a hook which is called as soon as the shutdown starts, like marking the service not ready. Components
+// are stopped after the longest delay of the hooks, so the load balancers have time to notice it
+func (m *Manager) Drain(delay time.Duration, hook func()) {
+	m.drainHooks = append(m.drainHooks, hook)
+	if delay > m.drainDelay {
+		m.drainDelay = delay
+	}
+}
+
+// Run starts the components and blocks until the context is done or a component fails. Components are then stopped
+// concurrently and the closers are called, all within the timeout. Returned error is the failure of the component
+// which caused the shutdown, or the first error of the shutdown
+func (m *Manager) Run(ctx context.Context, timeout time.Duration) error {
+	failures := make(chan error, len(m.components))
+	for _, c := range m.components {
+		c := c
+		m.logger.Info("starting component", zap.String("component", c.name))
+		go func() {
+			if err := c.component.Start(); err != nil {
+				failures <- fmt.Errorf("%s: %w", c.name, err)
+			}
+		}()
+	}
+
+	var cause error
+	select {
+	case <-ctx.Done():
+		m.logger.Info("shutting down", zap.Duration("timeout", timeout))
+	case cause = <-failures:
+		m.logger.Error("shutting down due to component failure", zap.String("error", cause.Error()))
+	}
+
+	for _, hook := range m.drainHooks {
+		hook()
+	}
+
+	// the delay is skipped after a failure since the service can not serve anyway
+	if cause == nil && m.drainDelay > 0 {
+		m.logger.Info("draining", zap.Duration("delay", m.drainDelay))
+		time.Sleep(m.drainDelay)
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err := m.shutdown(shutdownCtx)
+	if cause != nil {
+		return cause
+	}
+
+	return err
+}
+
+// Close calls the closers within the timeout without starting the components, so the resources which are created
+// before the service fails to initialize are released. Returned error is the first error of the closers
+func (m *Manager) Close(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	errs := make(chan error, len(m.closers))
+	m.close(ctx, errs)
+	close(errs)
+	return m.firstError(errs)
+}
+
+func (m *Manager) shutdown(ctx context.Context) error {
+	errs := make(chan error, len(m.components)+len(m.closers))
+	var wg sync.WaitGroup
+	for _, c := range m.components {
+		c := c
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.component.Stop(ctx); err != nil {
+				errs <- fmt.Errorf("%s: %w", c.name, err)
+				return
+			}
+
+			m.logger.Info("component stopped", zap.String("component", c.name))
+		}()
+	}
+	wg.Wait()
+	m.close(ctx, errs)
+	close(errs)
+	return m.firstError(errs)
+}
+
+// close calls the closers in the reverse order of addition and sends their errors to errs
+func (m *Manager) close(ctx context.Context, errs chan<- error) {
+	for i := len(m.closers) - 1; i >= 0; i-- {
+		if err := m.closers[i].close(ctx); err != nil {
+			errs <- fmt.Errorf("%s: %w", m.closers[i].name, err)
+		}
+	}
+}
+
+// firstError logs the errors of the closed channel and returns the first of them
+func (m *Manager) firstError(errs <-chan error) error {
+	var first error
+	for err := range errs {
+		m.logger.Error("an error occurred while shutting down", zap.String("error", err.Error()))
+		if first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
@@ -0,0 +1,121 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"go.uber.org/zap"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder records the order of the lifecycle events
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) record(event string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+type fakeComponent struct {
+	name     string
+	recorder *recorder
+	err      error
+	stop     chan struct{}
+}
+
+func newFakeComponent(name string, recorder *recorder, err error) *fakeComponent {
+	return &fakeComponent{name: name, recorder: recorder, err: err, stop: make(chan struct{})}
+}
+
+func (c *fakeComponent) Start() error {
+	if c.err != nil {
+		return c.err
+	}
+
+	<-c.stop
+	return nil
+}
+
+func (c *fakeComponent) Stop(context.Context) error {
+	close(c.stop)
+	c.recorder.record("stop " + c.name)
+	return nil
+}
+
+func (r *recorder) closer(name string) Closer {
+	return func(context.Context) error {
+		r.record("close " + name)
+		return nil
+	}
+}
+
+func TestRunStopsComponentsBeforeClosers(t *testing.T) {
+	recorder := &recorder{}
+	manager := NewManager(zap.NewNop())
+	manager.Add("api", newFakeComponent("api", recorder, nil))
+	manager.OnShutdown("database", recorder.closer("database"))
+	manager.OnShutdown("logger", recorder.closer("logger"))
+	manager.Drain(10*time.Millisecond, func() { recorder.record("drain") })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if err := manager.Run(ctx, time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"drain", "stop api", "close logger", "close database"}
+	if !reflect.DeepEqual(recorder.events, expected) {
+		t.Errorf("expected %v, got %v", expected, recorder.events)
+	}
+}
+
+func TestRunShutsDownOnComponentFailure(t *testing.T) {
+	recorder := &recorder{}
+	failure := errors.New("address already in use")
+	manager := NewManager(zap.NewNop())
+	manager.Add("api", newFakeComponent("api", recorder, nil))
+	manager.Add("metrics", newFakeComponent("metrics", recorder, failure))
+	manager.OnShutdown("database", recorder.closer("database"))
+
+	done := make(chan error, 1)
+	go func() { done <- manager.Run(context.Background(), time.Second) }()
+	select {
+	case err := <-done:
+		if !errors.Is(err, failure) {
+			t.Errorf("expected failure of the component, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("manager did not shut down after the failure")
+	}
+
+	if events := recorder.events; len(events) != 3 || events[2] != "close database" {
+		t.Errorf("expected all components to be stopped before closing, got %v", events)
+	}
+}
+
+func TestCloseCallsOnlyClosers(t *testing.T) {
+	recorder := &recorder{}
+	manager := NewManager(zap.NewNop())
+	manager.Add("api", newFakeComponent("api", recorder, nil))
+	manager.OnShutdown("database", recorder.closer("database"))
+	failure := errors.New("connection reset")
+	manager.OnShutdown("redis", func(context.Context) error {
+		recorder.record("close redis")
+		return failure
+	})
+
+	if err := manager.Close(time.Second); !errors.Is(err, failure) {
+		t.Errorf("expected %v, got %v", failure, err)
+	}
+
+	expected := []string{"close redis", "close database"}
+	if !reflect.DeepEqual(recorder.events, expected) {
+		t.Errorf("expected %v, got %v", expected, recorder.events)
+	}
+}
@@ -0,0 +1,66 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"google.golang.org/grpc"
+	"net"
+	"net/http"
+)
+
+type httpServer struct {
+	server *http.Server
+}
+
+// HttpServer adapts the server to a Component, in-flight requests are drained on stop
+func HttpServer(server *http.Server) Component {
+	return &httpServer{server: server}
+}
+
+func (s *httpServer) Start() error {
+	if err := s.server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	return nil
+}
+
+func (s *httpServer) Stop(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
+
+type grpcServer struct {
+	server *grpc.Server
+	addr   string
+}
+
+// GrpcServer adapts the server which listens on addr to a Component, in-flight calls are drained on stop and the
+// remaining ones are cancelled when the context is done
+func GrpcServer(server *grpc.Server, addr string) Component {
+	return &grpcServer{server: server, addr: addr}
+}
+
+func (s *grpcServer) Start() error {
+	listener, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+
+	return s.server.Serve(listener)
+}
+
+func (s *grpcServer) Stop(ctx context.Context) error {
+	stopped := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		s.server.Stop()
+		return ctx.Err()
+	}
+}
@@ -2,11 +2,15 @@ package metrics
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
+// prometheusHandler exports the metrics of the default registry, OpenMetrics format is negotiated so the exemplars
+// can be scraped
 func prometheusHandler() gin.HandlerFunc {
-	h := promhttp.Handler()
+	h := promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{EnableOpenMetrics: true}))
 
 	return func(c *gin.Context) {
 		h.ServeHTTP(c.Writer, c.Request)
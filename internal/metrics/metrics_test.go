@@ -1,31 +1,27 @@
 package metrics
 
 import (
-	"fmt"
-	"github.com/gorilla/mux"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
-	"go.uber.org/zap"
-	"net/http"
+	"auth-service/internal/options"
+	"github.com/gin-gonic/gin"
 	"testing"
 	"time"
 )
 
 // TODO: different cases(ports, timeouts etc)
 func TestRunMetricsServer(t *testing.T) {
-	errChan := make(chan error, 1)
+	opts, err := options.NewAuthServiceOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
 
+	errChan := make(chan error, 1)
+	metricServer := NewServer(gin.New(), opts)
 	go func() {
-		router := mux.NewRouter()
-		metricServer := &http.Server{
-			Handler:      router,
-			Addr:         fmt.Sprintf(":%d", opts.MetricsPort),
-			WriteTimeout: time.Duration(int32(opts.WriteTimeoutSeconds)) * time.Second,
-			ReadTimeout:  time.Duration(int32(opts.ReadTimeoutSeconds)) * time.Second,
-		}
-		router.Handle("/metrics", promhttp.Handler())
-		logger.Info("metric server is up and running", zap.Int("port", opts.MetricsPort))
 		errChan <- metricServer.ListenAndServe()
 	}()
+	defer func() {
+		_ = metricServer.Close()
+	}()
 
 	for {
 		select {
@@ -0,0 +1,49 @@
+package metrics
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"strconv"
+	"time"
+)
+
+// unmatchedRoute is the route label of the requests which do not match any route, so unknown paths do not create
+// new series
+const unmatchedRoute = "unmatched"
+
+var httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "auth_service",
+	Subsystem: "http",
+	Name:      "request_duration_seconds",
+	Help:      "Latency of the http requests by method, route and status code",
+	Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+}, []string{"method", "route", "status"})
+
+// TraceIdFunc returns the trace id of the request, or an empty string if the request is not traced
+type TraceIdFunc func(c *gin.Context) string
+
+// Middleware observes the latency of every request by its route template. If traceId is not nil, trace id of the
+// request is attached to the observation as an exemplar
+func Middleware(traceId TraceIdFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+
+		observer := httpRequestDuration.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status()))
+		elapsed := time.Since(start).Seconds()
+		if traceId != nil {
+			if id := traceId(c); id != "" {
+				observer.(prometheus.ExemplarObserver).ObserveWithExemplar(elapsed, prometheus.Labels{"trace_id": id})
+				return
+			}
+		}
+
+		observer.Observe(elapsed)
+	}
+}
@@ -0,0 +1,30 @@
+package metrics
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Middleware(func(c *gin.Context) string {
+		return c.GetHeader("X-Trace-Id")
+	}))
+	router.GET("/users/:id", func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	for _, path := range []string{"/users/1", "/users/2", "/unknown"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-Trace-Id", "4bf92f3577b34da6a3ce929d0e0e4736")
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if count := testutil.CollectAndCount(httpRequestDuration); count != 2 {
+		t.Errorf("expected the requests to be observed by route template, got %d series", count)
+	}
+}
@@ -1,7 +1,12 @@
 package model
 
+import "time"
+
 type User struct {
 	Id                     uint `gorm:"primary_key,AUTO_INCREMENT"`
+	RealmId                uint
+	IdentityBackend        string `gorm:"size:64"`
+	ExternalId             string `gorm:"size:255"`
 	Uuid                   string
 	UserName               string
 	EncryptedPassword      string
@@ -27,9 +32,173 @@ type User struct {
 
 type Role struct {
 	Id        uint    `gorm:"primary_key,AUTO_INCREMENT" json:"id"`
+	RealmId   uint    `json:"realmId"`
 	Name      string  `json:"name"`
 	Version   uint    `json:"version"`
 	CreatedAt string  `json:"createdAt"`
 	UpdatedAt string  `json:"updatedAt"`
 	Users     []*User `gorm:"many2many:users_roles" json:"users"`
 }
+
+// SessionRevocation represents the point in time before which all tokens of a user are considered revoked
+type SessionRevocation struct {
+	Id        uint   `gorm:"primaryKey"`
+	RealmId   uint   `gorm:"uniqueIndex:idx_session_revocations_realm_user"`
+	UserName  string `gorm:"uniqueIndex:idx_session_revocations_realm_user;size:255"`
+	RevokedAt time.Time
+}
+
+// VpnCertificate represents a client certificate issued by the embedded certificate authority
+type VpnCertificate struct {
+	Id           uint       `gorm:"primaryKey" json:"id"`
+	SerialNumber string     `gorm:"uniqueIndex;size:64" json:"serialNumber"`
+	UserId       uint       `gorm:"index" json:"userId"`
+	UserName     string     `gorm:"size:255" json:"userName"`
+	Subject      string     `json:"subject"`
+	NotBefore    time.Time  `json:"notBefore"`
+	NotAfter     time.Time  `gorm:"index" json:"notAfter"`
+	RevokedAt    *time.Time `json:"revokedAt"`
+	CreatedAt    time.Time  `json:"createdAt"`
+}
+
+// Entitlement represents a subscription entitlement of a user like plan, max devices or allowed regions
+type Entitlement struct {
+	Id        uint       `gorm:"primaryKey" json:"id"`
+	UserId    uint       `gorm:"uniqueIndex:idx_entitlements_user_name" json:"userId"`
+	Name      string     `gorm:"uniqueIndex:idx_entitlements_user_name;size:64" json:"name"`
+	Value     string     `json:"value"`
+	ExpiresAt *time.Time `gorm:"index" json:"expiresAt"`
+	CreatedAt time.Time  `json:"createdAt"`
+	UpdatedAt time.Time  `json:"updatedAt"`
+}
+
+// PersonalAccessToken represents a long-lived token of a user for automation, only the sha256 digest of the token
+// is stored
+type PersonalAccessToken struct {
+	Id          uint       `gorm:"primaryKey" json:"id"`
+	UserId      uint       `gorm:"index" json:"userId"`
+	Name        string     `gorm:"size:255" json:"name"`
+	TokenPrefix string     `gorm:"size:16" json:"tokenPrefix"`
+	TokenHash   string     `gorm:"uniqueIndex;size:64" json:"-"`
+	Scopes      string     `json:"-"`
+	LastUsedAt  *time.Time `json:"lastUsedAt"`
+	ExpiresAt   *time.Time `json:"expiresAt"`
+	RevokedAt   *time.Time `json:"revokedAt"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+// Permission represents a fine-grained permission like vpn:connect or users:read
+type Permission struct {
+	Id          uint      `gorm:"primaryKey" json:"id"`
+	Name        string    `gorm:"uniqueIndex;size:128" json:"name"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+// RolePermission represents a permission granted to a role
+type RolePermission struct {
+	RoleId       uint `gorm:"primaryKey;autoIncrement:false"`
+	PermissionId uint `gorm:"primaryKey;autoIncrement:false"`
+}
+
+// RoleParent represents a role which inherits the permissions of its parent role
+type RoleParent struct {
+	RoleId       uint `gorm:"primaryKey;autoIncrement:false"`
+	ParentRoleId uint `gorm:"primaryKey;autoIncrement:false"`
+}
+
+// Policy represents a contextual authorization rule evaluated by the policy engine, Actions and ResourceTypes are
+// comma separated lists and an empty list matches everything
+type Policy struct {
+	Id            uint      `gorm:"primaryKey" json:"id"`
+	Name          string    `gorm:"uniqueIndex;size:128" json:"name"`
+	Description   string    `json:"description"`
+	Effect        string    `gorm:"size:8" json:"effect"`
+	Priority      int       `json:"priority"`
+	Actions       string    `json:"actions"`
+	ResourceTypes string    `json:"resourceTypes"`
+	Condition     string    `gorm:"type:text" json:"condition"`
+	Enabled       bool      `json:"enabled"`
+	CreatedAt     time.Time `json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+// Realm represents a tenant with its own issuer, signing keys, token lifetimes, password policy and users. Zero
+// lifetimes and password lengths fall back to the service defaults
+type Realm struct {
+	Id                         uint      `gorm:"primaryKey" json:"id"`
+	Name                       string    `gorm:"uniqueIndex;size:64" json:"name"`
+	IssuerUrl                  string    `json:"issuerUrl"`
+	Hosts                      string    `json:"hosts"`
+	AccessTokenValidInMinutes  int32     `json:"accessTokenValidInMinutes"`
+	RefreshTokenValidInMinutes int32     `json:"refreshTokenValidInMinutes"`
+	PasswordMinLength          int       `json:"passwordMinLength"`
+	PasswordMaxLength          int       `json:"passwordMaxLength"`
+	PasswordRequireDigit       bool      `json:"passwordRequireDigit"`
+	PasswordRequireUpper       bool      `json:"passwordRequireUpper"`
+	PasswordRequireLower       bool      `json:"passwordRequireLower"`
+	PasswordRequireSymbol      bool      `json:"passwordRequireSymbol"`
+	IdentityBackend            string    `gorm:"size:64" json:"identityBackend"`
+	Enabled                    bool      `json:"enabled"`
+	CreatedAt                  time.Time `json:"createdAt"`
+	UpdatedAt                  time.Time `json:"updatedAt"`
+}
+
+// RealmKey represents a signing key pair of a realm, retired keys are only used to verify the tokens issued before
+// a rotation
+type RealmKey struct {
+	Id         uint       `gorm:"primaryKey" json:"id"`
+	RealmId    uint       `gorm:"index" json:"realmId"`
+	Kid        string     `gorm:"uniqueIndex;size:64" json:"kid"`
+	PrivateKey string     `gorm:"type:text" json:"-"`
+	PublicKey  string     `gorm:"type:text" json:"publicKey"`
+	Active     bool       `json:"active"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	RetiredAt  *time.Time `json:"retiredAt"`
+}
+
+// FederatedIdentity links a user to its identity at an upstream OpenID Connect provider
+type FederatedIdentity struct {
+	Id          uint      `gorm:"primaryKey" json:"id"`
+	RealmId     uint      `gorm:"uniqueIndex:idx_federated_identities_subject" json:"realmId"`
+	Provider    string    `gorm:"uniqueIndex:idx_federated_identities_subject;size:64" json:"provider"`
+	Subject     string    `gorm:"uniqueIndex:idx_federated_identities_subject;size:255" json:"subject"`
+	UserId      uint      `gorm:"index" json:"userId"`
+	Email       string    `json:"email"`
+	CreatedAt   time.Time `json:"createdAt"`
+	LastLoginAt time.Time `json:"lastLoginAt"`
+}
+
+// AuditEvent represents a security relevant event like a login or a role change, Details is a JSON object whose
+// secrets are redacted before it is stored
+type AuditEvent struct {
+	Id        uint      `gorm:"primaryKey" json:"id"`
+	RealmId   uint      `gorm:"index:idx_audit_events_realm_time" json:"-"`
+	Type      string    `gorm:"index;size:64" json:"type"`
+	Outcome   string    `gorm:"size:16" json:"outcome"`
+	Reason    string    `gorm:"size:64" json:"reason,omitempty"`
+	Actor     string    `gorm:"index;size:255" json:"actor,omitempty"`
+	Subject   string    `gorm:"index;size:255" json:"subject,omitempty"`
+	Ip        string    `gorm:"size:64" json:"ip,omitempty"`
+	UserAgent string    `gorm:"size:512" json:"userAgent,omitempty"`
+	RequestId string    `gorm:"size:64" json:"requestId,omitempty"`
+	Details   string    `gorm:"type:text" json:"details,omitempty"`
+	CreatedAt time.Time `gorm:"index:idx_audit_events_realm_time" json:"createdAt"`
+}
+
+// LoginHistory represents a password login attempt of a user, Signals is the space separated risk signals of the
+// login. Country and the coordinates are empty if the ip can not be located
+type LoginHistory struct {
+	Id          uint      `gorm:"primaryKey" json:"-"`
+	RealmId     uint      `gorm:"index:idx_login_histories_user" json:"-"`
+	UserName    string    `gorm:"index:idx_login_histories_user;size:255" json:"-"`
+	Ip          string    `gorm:"size:64" json:"ip"`
+	UserAgent   string    `gorm:"size:512" json:"userAgent"`
+	Fingerprint string    `gorm:"size:64" json:"fingerprint"`
+	Country     string    `gorm:"size:2" json:"country,omitempty"`
+	Latitude    *float64  `json:"latitude,omitempty"`
+	Longitude   *float64  `json:"longitude,omitempty"`
+	Result      string    `gorm:"size:16" json:"result"`
+	Signals     string    `gorm:"size:255" json:"-"`
+	CreatedAt   time.Time `gorm:"index:idx_login_histories_user" json:"time"`
+}
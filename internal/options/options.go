@@ -2,45 +2,44 @@ package options
 
 import (
 	commons "github.com/vpnbeast/golang-commons"
-	"go.uber.org/zap"
 	"strings"
 )
 
-var (
-	logger *zap.Logger
-	opts   *AuthServiceOptions
-)
-
-func init() {
-	logger = commons.GetLogger()
-	opts = newAuthServiceOptions()
-	err := commons.InitOptions(opts, "auth-service")
-	if err != nil {
-		logger.Fatal("fatal error occured while initializing options", zap.Error(err))
+// NewAuthServiceOptions loads the AuthServiceOptions from the config store, or from the static file if the active
+// profile is unit-test. Options missing from both keep their envDefault values set here, the config store does not
+// read the tag
+func NewAuthServiceOptions() (*AuthServiceOptions, error) {
+	opts := &AuthServiceOptions{LegacyErrorResponses: true}
+	if err := commons.InitOptions(opts, "auth-service"); err != nil {
+		return nil, err
 	}
+
 	// required logic for auth-service to convert private key and public key to specific format
 	opts.PrivateKey = strings.Replace(opts.PrivateKey, "\\n", "\n", -1)
 	opts.PublicKey = strings.Replace(opts.PublicKey, "\\n", "\n", -1)
-}
-
-// GetAuthServiceOptions returns the initialized AuthServiceOptions
-func GetAuthServiceOptions() *AuthServiceOptions {
-	return opts
-}
-
-// newAuthServiceOptions creates an AuthServiceOptions struct with zero values
-func newAuthServiceOptions() *AuthServiceOptions {
-	return &AuthServiceOptions{}
+	opts.CaCertificate = strings.Replace(opts.CaCertificate, "\\n", "\n", -1)
+	opts.CaPrivateKey = strings.Replace(opts.CaPrivateKey, "\\n", "\n", -1)
+	return opts, nil
 }
 
 // AuthServiceOptions represents auth-service environment variables
 type AuthServiceOptions struct {
 	// web server related config
 	ServerPort          int    `env:"SERVER_PORT"`
+	GrpcPort            int    `env:"GRPC_PORT"`
 	MetricsPort         int    `env:"METRICS_PORT"`
 	MetricsEndpoint     string `env:"METRICS_ENDPOINT"`
 	WriteTimeoutSeconds int    `env:"WRITE_TIMEOUT_SECONDS"`
 	ReadTimeoutSeconds  int    `env:"READ_TIMEOUT_SECONDS"`
+	// TrustedProxies is the comma separated ips or cidrs of the proxies whose X-Forwarded-For and X-Real-Ip headers
+	// are honored, client ip is the remote address of the connection if none is configured
+	TrustedProxies string `env:"TRUSTED_PROXIES"`
+	// MetricsExemplarsEnabled attaches the trace ids of the requests to the latency histograms as exemplars
+	MetricsExemplarsEnabled bool `env:"METRICS_EXEMPLARS_ENABLED"`
+	// ShutdownTimeoutSeconds is the deadline of draining the servers and closing the resources on SIGTERM,
+	// ShutdownDrainSeconds is the time the service reports not ready before the servers stop accepting connections
+	ShutdownTimeoutSeconds int `env:"SHUTDOWN_TIMEOUT_SECONDS"`
+	ShutdownDrainSeconds   int `env:"SHUTDOWN_DRAIN_SECONDS"`
 	// jwt related config
 	Issuer                     string `env:"ISSUER"`
 	PrivateKey                 string `env:"PRIVATE_KEY"`
@@ -48,13 +47,66 @@ type AuthServiceOptions struct {
 	AccessTokenValidInMinutes  int    `env:"ACCESS_TOKEN_VALID_IN_MINUTES"`
 	RefreshTokenValidInMinutes int    `env:"REFRESH_TOKEN_VALID_IN_MINUTES"`
 	EncryptionServiceUrl       string `env:"ENCRYPTION_SERVICE_URL"`
+	AdminRole                  string `env:"ADMIN_ROLE"`
+	ScimRole                   string `env:"SCIM_ROLE"`
+	RealmCacheTtlSeconds       int    `env:"REALM_CACHE_TTL_SECONDS"`
+	// encryption-service client related config
+	EncryptionServiceTimeoutSeconds        int `env:"ENCRYPTION_SERVICE_TIMEOUT_SECONDS"`
+	EncryptionServiceMaxRetries            int `env:"ENCRYPTION_SERVICE_MAX_RETRIES"`
+	EncryptionServiceBreakerThreshold      int `env:"ENCRYPTION_SERVICE_BREAKER_THRESHOLD"`
+	EncryptionServiceBreakerTimeoutSeconds int `env:"ENCRYPTION_SERVICE_BREAKER_TIMEOUT_SECONDS"`
 	// database related config
-	DbUrl                    string `env:"DB_URL"`
-	DbDriver                 string `env:"DB_DRIVER"`
-	HealthPort               int    `env:"HEALTH_PORT"`
-	HealthEndpoint           string `env:"HEALTH_ENDPOINT"`
-	DbMaxOpenConn            int    `env:"DB_MAX_OPEN_CONN"`
-	DbMaxIdleConn            int    `env:"DB_MAX_IDLE_CONN"`
-	DbConnMaxLifetimeMin     int    `env:"DB_CONN_MAX_LIFETIME_MIN"`
-	HealthCheckMaxTimeoutMin int    `env:"HEALTHCHECK_MAX_TIMEOUT_MIN"`
+	DbUrl                string `env:"DB_URL"`
+	DbDriver             string `env:"DB_DRIVER"`
+	HealthPort           int    `env:"HEALTH_PORT"`
+	HealthEndpoint       string `env:"HEALTH_ENDPOINT"`
+	DbMaxOpenConn        int    `env:"DB_MAX_OPEN_CONN"`
+	DbMaxIdleConn        int    `env:"DB_MAX_IDLE_CONN"`
+	DbConnMaxLifetimeMin int    `env:"DB_CONN_MAX_LIFETIME_MIN"`
+	// tracing related config, TracingSampleRatio is the ratio of the sampled new traces between 0 and 1
+	TracingEnabled      bool    `env:"TRACING_ENABLED"`
+	TracingOtlpEndpoint string  `env:"TRACING_OTLP_ENDPOINT"`
+	TracingOtlpInsecure bool    `env:"TRACING_OTLP_INSECURE"`
+	TracingSampleRatio  float64 `env:"TRACING_SAMPLE_RATIO"`
+	// health probe related config
+	HealthCheckTimeoutSeconds int `env:"HEALTH_CHECK_TIMEOUT_SECONDS"`
+	HealthCheckCacheSeconds   int `env:"HEALTH_CHECK_CACHE_SECONDS"`
+	// audit log related config, AuditSinks is the comma separated list of file, db and webhook
+	AuditSinks                 string `env:"AUDIT_SINKS"`
+	AuditFile                  string `env:"AUDIT_FILE"`
+	AuditWebhookUrl            string `env:"AUDIT_WEBHOOK_URL"`
+	AuditWebhookTimeoutSeconds int    `env:"AUDIT_WEBHOOK_TIMEOUT_SECONDS"`
+	// suspicious login detection related config
+	GeoIpDatabaseFile    string `env:"GEOIP_DATABASE_FILE"`
+	RiskRulesFile        string `env:"RISK_RULES_FILE"`
+	RiskNotifyWebhookUrl string `env:"RISK_NOTIFY_WEBHOOK_URL"`
+	// rate limiting related config, RateLimitBackend is memory or redis
+	RateLimitEnabled      bool   `env:"RATE_LIMIT_ENABLED"`
+	RateLimitBackend      string `env:"RATE_LIMIT_BACKEND"`
+	RateLimitRedisUrl     string `env:"RATE_LIMIT_REDIS_URL"`
+	RateLimitPoliciesFile string `env:"RATE_LIMIT_POLICIES_FILE"`
+	// error response related config, legacy response shapes are kept by default during the migration to the problems
+	LegacyErrorResponses  bool   `env:"LEGACY_ERROR_RESPONSES" envDefault:"true"`
+	TranslationsDirectory string `env:"TRANSLATIONS_DIRECTORY"`
+	// api versioning related config, AuthV1SunsetDate is the date in 2006-01-02 format on which the deprecated /auth
+	// endpoints are going to be removed
+	AuthV1SunsetDate string `env:"AUTH_V1_SUNSET_DATE"`
+	// cookie session related config, SessionCookiePath defaults to the path of the auth routes of the api version
+	// like /v2/auth and must cover both refresh and logout
+	SessionCookieEnabled bool   `env:"SESSION_COOKIE_ENABLED"`
+	SessionCookieDomain  string `env:"SESSION_COOKIE_DOMAIN"`
+	SessionCookiePath    string `env:"SESSION_COOKIE_PATH"`
+	// policy engine related config
+	PolicyDirectory             string `env:"POLICY_DIRECTORY"`
+	PolicyReloadIntervalSeconds int    `env:"POLICY_RELOAD_INTERVAL_SECONDS"`
+	// identity federation related config
+	OidcProvidersFile string `env:"OIDC_PROVIDERS_FILE"`
+	OidcStateSecret   string `env:"OIDC_STATE_SECRET"`
+	// identity backend related config
+	IdentityBackendsFile string `env:"IDENTITY_BACKENDS_FILE"`
+	// certificate authority related config
+	CaCertificate        string `env:"CA_CERTIFICATE"`
+	CaPrivateKey         string `env:"CA_PRIVATE_KEY"`
+	CaRoleExtKeyUsages   string `env:"CA_ROLE_EXT_KEY_USAGES"`
+	CaCrlValidityMinutes int    `env:"CA_CRL_VALIDITY_MINUTES"`
 }
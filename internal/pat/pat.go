@@ -0,0 +1,206 @@
+package pat
+
+import (
+	"auth-service/internal/model"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"gorm.io/gorm"
+	"hash/crc32"
+	"math/big"
+	"strings"
+	"time"
+)
+
+const (
+	// Prefix is the fixed prefix of the personal access tokens, secret scanners can detect leaked tokens with the
+	// vbpat_[A-Za-z0-9]{36} pattern
+	Prefix = "vbpat_"
+
+	base62         = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	secretLength   = 30
+	checksumLength = 6
+	displayLength  = len(Prefix) + 4
+)
+
+var (
+	// ErrInvalidToken is returned when the token does not exist, is revoked or expired
+	ErrInvalidToken = errors.New("invalid personal access token")
+	// ErrInvalidScope is returned when a requested scope is not one of the roles of the user
+	ErrInvalidScope = errors.New("scope is not granted to the user")
+)
+
+// IsPersonalAccessToken checks if the token is well-formed personal access token with a valid checksum, it does not
+// check the database
+func IsPersonalAccessToken(token string) bool {
+	if !strings.HasPrefix(token, Prefix) || len(token) != len(Prefix)+secretLength+checksumLength {
+		return false
+	}
+
+	body := token[len(Prefix):]
+	for _, c := range body {
+		if !strings.ContainsRune(base62, c) {
+			return false
+		}
+	}
+
+	return checksum(body[:secretLength]) == body[secretLength:]
+}
+
+// Hash returns the hex encoded sha256 digest of the token which is stored in the database
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Scopes returns the scopes of the personal access token
+func Scopes(token *model.PersonalAccessToken) []string {
+	return strings.Fields(token.Scopes)
+}
+
+// Create generates a new personal access token for the user, the plain token is only returned here and can not be
+// recovered later. Every scope must be one of the roles of the user
+func Create(db *gorm.DB, user *model.User, name string, scopes []string, expiresAt *time.Time) (string,
+	*model.PersonalAccessToken, error) {
+	roles := roleNames(user)
+	for _, scope := range scopes {
+		if !contains(roles, scope) {
+			return "", nil, ErrInvalidScope
+		}
+	}
+
+	token, err := generate()
+	if err != nil {
+		return "", nil, err
+	}
+
+	personalAccessToken := &model.PersonalAccessToken{
+		UserId:      user.Id,
+		Name:        name,
+		TokenPrefix: token[:displayLength],
+		TokenHash:   Hash(token),
+		Scopes:      strings.Join(scopes, " "),
+		ExpiresAt:   expiresAt,
+		CreatedAt:   time.Now(),
+	}
+
+	if err := db.Create(personalAccessToken).Error; err != nil {
+		return "", nil, err
+	}
+
+	return token, personalAccessToken, nil
+}
+
+// List returns all the personal access tokens of the user
+func List(db *gorm.DB, userId uint) ([]model.PersonalAccessToken, error) {
+	var tokens []model.PersonalAccessToken
+	err := db.Where("user_id = ?", userId).Order("created_at DESC").Find(&tokens).Error
+	return tokens, err
+}
+
+// Revoke revokes the personal access token of the user, returns false if there is no such active token
+func Revoke(db *gorm.DB, userId, id uint) (bool, error) {
+	result := db.Model(&model.PersonalAccessToken{}).
+		Where("id = ? AND user_id = ? AND revoked_at IS NULL", id, userId).
+		Update("revoked_at", time.Now())
+	return result.RowsAffected > 0, result.Error
+}
+
+// Resolve finds the user of the personal access token and returns the roles granted to the token, which are the
+// roles of the user limited by the scopes of the token. Last used time of the token is updated
+func Resolve(db *gorm.DB, token string) (*model.User, []string, error) {
+	if !IsPersonalAccessToken(token) {
+		return nil, nil, ErrInvalidToken
+	}
+
+	var personalAccessToken model.PersonalAccessToken
+	err := db.Where("token_hash = ? AND revoked_at IS NULL", Hash(token)).First(&personalAccessToken).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, ErrInvalidToken
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	if personalAccessToken.ExpiresAt != nil && !personalAccessToken.ExpiresAt.After(now) {
+		return nil, nil, ErrInvalidToken
+	}
+
+	var user model.User
+	err = db.Preload("Roles").Where("id = ?", personalAccessToken.UserId).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, ErrInvalidToken
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !user.Enabled {
+		return nil, nil, ErrInvalidToken
+	}
+
+	err = db.Model(&personalAccessToken).Update("last_used_at", now).Error
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var roles []string
+	scopes := Scopes(&personalAccessToken)
+	for _, role := range roleNames(&user) {
+		if contains(scopes, role) {
+			roles = append(roles, role)
+		}
+	}
+
+	return &user, roles, nil
+}
+
+// generate generates a new token in vbpat_<30 chars secret><6 chars crc32 checksum> format
+func generate() (string, error) {
+	max := big.NewInt(int64(len(base62)))
+	secret := make([]byte, secretLength)
+	for i := range secret {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		secret[i] = base62[n.Int64()]
+	}
+
+	return Prefix + string(secret) + checksum(string(secret)), nil
+}
+
+// checksum returns the crc32 checksum of the secret as a zero padded base62 string
+func checksum(secret string) string {
+	n := crc32.ChecksumIEEE([]byte(secret))
+	encoded := make([]byte, checksumLength)
+	for i := checksumLength - 1; i >= 0; i-- {
+		encoded[i] = base62[n%62]
+		n /= 62
+	}
+
+	return string(encoded)
+}
+
+func roleNames(user *model.User) []string {
+	names := make([]string, 0, len(user.Roles))
+	for _, role := range user.Roles {
+		names = append(names, role.Name)
+	}
+
+	return names
+}
+
+func contains(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+
+	return false
+}
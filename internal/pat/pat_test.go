@@ -0,0 +1,44 @@
+package pat
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	token, err := generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !regexp.MustCompile(`^vbpat_[A-Za-z0-9]{36}$`).MatchString(token) {
+		t.Errorf("token %s does not match the secret scanning pattern", token)
+	}
+
+	if !IsPersonalAccessToken(token) {
+		t.Errorf("expected %s to be a valid personal access token", token)
+	}
+}
+
+func TestIsPersonalAccessTokenChecksum(t *testing.T) {
+	token, err := generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// flipping a single character of the secret must invalidate the checksum
+	tampered := []byte(token)
+	if tampered[len(Prefix)] == 'a' {
+		tampered[len(Prefix)] = 'b'
+	} else {
+		tampered[len(Prefix)] = 'a'
+	}
+
+	if IsPersonalAccessToken(string(tampered)) {
+		t.Errorf("expected tampered token %s to be rejected", tampered)
+	}
+
+	if IsPersonalAccessToken("eyJhbGciOiJSUzI1NiJ9.eyJzdWIiOiJmb28ifQ.c2ln") {
+		t.Error("expected jwt to be rejected")
+	}
+}
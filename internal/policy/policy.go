@@ -0,0 +1,259 @@
+package policy
+
+import (
+	"fmt"
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+	"sort"
+	"sync"
+)
+
+const (
+	EffectAllow = "allow"
+	EffectDeny  = "deny"
+)
+
+// Policy represents a contextual authorization rule. Condition is a CEL expression which evaluates to bool over the
+// subject, resource, action and request variables. Empty Actions and ResourceTypes match everything
+type Policy struct {
+	Name          string   `yaml:"name" json:"name"`
+	Description   string   `yaml:"description" json:"description"`
+	Effect        string   `yaml:"effect" json:"effect"`
+	Priority      int      `yaml:"priority" json:"priority"`
+	Actions       []string `yaml:"actions" json:"actions"`
+	ResourceTypes []string `yaml:"resourceTypes" json:"resourceTypes"`
+	Condition     string   `yaml:"condition" json:"condition"`
+	Source        string   `yaml:"-" json:"source"`
+}
+
+// Input represents the variables which the conditions are evaluated over
+type Input struct {
+	Subject  map[string]interface{}
+	Action   string
+	Resource map[string]interface{}
+	Request  map[string]interface{}
+}
+
+// Evaluation represents the result of a single applicable policy
+type Evaluation struct {
+	Policy  string `json:"policy"`
+	Source  string `json:"source"`
+	Effect  string `json:"effect"`
+	Matched bool   `json:"matched"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Decision represents the authorization decision and explains which policy caused it
+type Decision struct {
+	Allowed       bool         `json:"allowed"`
+	MatchedPolicy string       `json:"matchedPolicy,omitempty"`
+	Reason        string       `json:"reason"`
+	Evaluations   []Evaluation `json:"evaluations"`
+}
+
+type compiledPolicy struct {
+	Policy
+	program cel.Program
+}
+
+// Engine evaluates the policies loaded from the sources. Deny policies override allow policies and a request is
+// denied if no allow policy matches. A deny policy which fails to evaluate is treated as matched to fail closed
+type Engine struct {
+	env      *cel.Env
+	sources  []Source
+	logger   *zap.Logger
+	mu       sync.RWMutex
+	policies []*compiledPolicy
+}
+
+// NewEngine creates a new Engine and loads the policies from the sources
+func NewEngine(logger *zap.Logger, sources ...Source) (*Engine, error) {
+	env, err := cel.NewEnv(cel.Declarations(
+		decls.NewVar("subject", decls.NewMapType(decls.String, decls.Dyn)),
+		decls.NewVar("resource", decls.NewMapType(decls.String, decls.Dyn)),
+		decls.NewVar("action", decls.String),
+		decls.NewVar("request", decls.NewMapType(decls.String, decls.Dyn)),
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	engine := &Engine{
+		env:     env,
+		sources: sources,
+		logger:  logger,
+	}
+
+	return engine, engine.Reload()
+}
+
+// Reload loads the policies from all the sources again. An error is returned and the current policies are kept if a
+// source fails to load or a policy fails to compile, skipping a broken deny policy would turn its denials into allows
+func (e *Engine) Reload() error {
+	var loaded []Policy
+	for _, source := range e.sources {
+		policies, err := source.Load()
+		if err != nil {
+			return fmt.Errorf("an error occurred while loading policies from %s: %w", source, err)
+		}
+		loaded = append(loaded, policies...)
+	}
+
+	compiled := make([]*compiledPolicy, 0, len(loaded))
+	for _, p := range loaded {
+		c, err := e.compile(p)
+		if err != nil {
+			return fmt.Errorf("an error occurred while compiling policy %s from %s: %w", p.Name, p.Source, err)
+		}
+		compiled = append(compiled, c)
+	}
+
+	sort.SliceStable(compiled, func(i, j int) bool {
+		if compiled[i].Priority != compiled[j].Priority {
+			return compiled[i].Priority > compiled[j].Priority
+		}
+		return compiled[i].Name < compiled[j].Name
+	})
+
+	e.mu.Lock()
+	e.policies = compiled
+	e.mu.Unlock()
+	e.logger.Info("policies loaded", zap.Int("count", len(compiled)))
+	return nil
+}
+
+// Policies returns the currently loaded policies
+func (e *Engine) Policies() []Policy {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	policies := make([]Policy, 0, len(e.policies))
+	for _, p := range e.policies {
+		policies = append(policies, p.Policy)
+	}
+
+	return policies
+}
+
+// Evaluate evaluates the applicable policies in priority order and returns the decision
+func (e *Engine) Evaluate(input Input) Decision {
+	e.mu.RLock()
+	policies := e.policies
+	e.mu.RUnlock()
+
+	vars := map[string]interface{}{
+		"subject":  emptyIfNil(input.Subject),
+		"resource": emptyIfNil(input.Resource),
+		"action":   input.Action,
+		"request":  emptyIfNil(input.Request),
+	}
+	resourceType, _ := input.Resource["type"].(string)
+
+	decision := Decision{Evaluations: make([]Evaluation, 0)}
+	var allowedBy string
+	for _, p := range policies {
+		if !matchesAny(p.Actions, input.Action) || !matchesAny(p.ResourceTypes, resourceType) {
+			continue
+		}
+
+		evaluation := Evaluation{Policy: p.Name, Source: p.Source, Effect: p.Effect}
+		matched, err := p.evaluate(vars)
+		if err != nil {
+			evaluation.Error = err.Error()
+			matched = p.Effect == EffectDeny
+		}
+		evaluation.Matched = matched
+		decision.Evaluations = append(decision.Evaluations, evaluation)
+
+		if !matched {
+			continue
+		}
+
+		if p.Effect == EffectDeny {
+			decision.Allowed = false
+			decision.MatchedPolicy = p.Name
+			decision.Reason = fmt.Sprintf("denied by policy %s", p.Name)
+			if err != nil {
+				decision.Reason = fmt.Sprintf("denied by policy %s which failed to evaluate", p.Name)
+			}
+			return decision
+		}
+
+		if allowedBy == "" {
+			allowedBy = p.Name
+		}
+	}
+
+	if allowedBy == "" {
+		decision.Reason = "no allow policy matched"
+		return decision
+	}
+
+	decision.Allowed = true
+	decision.MatchedPolicy = allowedBy
+	decision.Reason = fmt.Sprintf("allowed by policy %s", allowedBy)
+	return decision
+}
+
+func (e *Engine) compile(p Policy) (*compiledPolicy, error) {
+	if p.Name == "" {
+		return nil, fmt.Errorf("policy name is empty")
+	}
+
+	if p.Effect != EffectAllow && p.Effect != EffectDeny {
+		return nil, fmt.Errorf("effect must be %s or %s", EffectAllow, EffectDeny)
+	}
+
+	ast, issues := e.env.Compile(p.Condition)
+	if issues != nil && issues.Err() != nil {
+		return nil, issues.Err()
+	}
+
+	if !proto.Equal(ast.ResultType(), decls.Bool) && !proto.Equal(ast.ResultType(), decls.Dyn) {
+		return nil, fmt.Errorf("condition must evaluate to bool")
+	}
+
+	program, err := e.env.Program(ast)
+	if err != nil {
+		return nil, err
+	}
+
+	return &compiledPolicy{Policy: p, program: program}, nil
+}
+
+func (p *compiledPolicy) evaluate(vars map[string]interface{}) (bool, error) {
+	out, _, err := p.program.Eval(vars)
+	if err != nil {
+		return false, err
+	}
+
+	matched, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("condition evaluated to %v instead of bool", out.Value())
+	}
+
+	return matched, nil
+}
+
+func matchesAny(items []string, item string) bool {
+	if len(items) == 0 {
+		return true
+	}
+
+	for _, i := range items {
+		if i == item || i == "*" {
+			return true
+		}
+	}
+
+	return false
+}
+
+func emptyIfNil(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return map[string]interface{}{}
+	}
+
+	return m
+}
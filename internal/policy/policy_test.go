@@ -0,0 +1,138 @@
+package policy
+
+import (
+	"go.uber.org/zap"
+	"testing"
+)
+
+type staticSource []Policy
+
+func (s staticSource) Load() ([]Policy, error) {
+	return s, nil
+}
+
+func (s staticSource) String() string {
+	return "static"
+}
+
+func newTestEngine(t *testing.T) *Engine {
+	engine, err := NewEngine(zap.NewNop(), staticSource{
+		{
+			Name:      "users-can-connect",
+			Effect:    EffectAllow,
+			Actions:   []string{"vpn:connect"},
+			Condition: `"ROLE_USER" in subject.roles`,
+		},
+		{
+			Name:      "free-plan-eu-only",
+			Effect:    EffectDeny,
+			Priority:  10,
+			Actions:   []string{"vpn:connect"},
+			Condition: `subject.entitlements.plan == "free" && resource.region != "eu"`,
+		},
+		{
+			Name:          "support-business-hours",
+			Effect:        EffectAllow,
+			ResourceTypes: []string{"user"},
+			Actions:       []string{"users:read"},
+			Condition:     `"ROLE_SUPPORT" in subject.roles && request.hour >= 9 && request.hour < 18`,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return engine
+}
+
+// mutableSource returns the policies it currently holds, so the tests can change the policies between the reloads
+type mutableSource struct {
+	policies []Policy
+}
+
+func (s *mutableSource) Load() ([]Policy, error) {
+	return s.policies, nil
+}
+
+func (s *mutableSource) String() string {
+	return "mutable"
+}
+
+func TestEngineRefusesInvalidPolicies(t *testing.T) {
+	_, err := NewEngine(zap.NewNop(), staticSource{{Name: "invalid", Effect: EffectAllow, Condition: `subject.roles +`}})
+	if err == nil {
+		t.Error("expected the engine to refuse the invalid policy")
+	}
+}
+
+func TestEngineKeepsPoliciesOnInvalidReload(t *testing.T) {
+	allow := Policy{Name: "users-can-connect", Effect: EffectAllow, Condition: `"ROLE_USER" in subject.roles`}
+	deny := Policy{Name: "banned", Effect: EffectDeny, Priority: 10, Condition: `subject.banned == true`}
+	source := &mutableSource{policies: []Policy{allow, deny}}
+	engine, err := NewEngine(zap.NewNop(), source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a typo in the deny policy must not drop it and allow the banned users
+	deny.Condition = `subject.banned ==`
+	source.policies = []Policy{allow, deny}
+	if err := engine.Reload(); err == nil {
+		t.Error("expected the reload to fail")
+	}
+
+	input := Input{Subject: map[string]interface{}{"roles": []string{"ROLE_USER"}, "banned": true}}
+	if decision := engine.Evaluate(input); decision.Allowed || decision.MatchedPolicy != "banned" {
+		t.Errorf("expected the previous deny policy to be kept, got %+v", decision)
+	}
+
+	if policies := engine.Policies(); len(policies) != 2 {
+		t.Errorf("expected the 2 previous policies, got %d", len(policies))
+	}
+}
+
+func TestEngineEvaluate(t *testing.T) {
+	engine := newTestEngine(t)
+	free := map[string]interface{}{
+		"roles":        []string{"ROLE_USER"},
+		"entitlements": map[string]interface{}{"plan": "free"},
+	}
+	premium := map[string]interface{}{
+		"roles":        []string{"ROLE_USER"},
+		"entitlements": map[string]interface{}{"plan": "premium"},
+	}
+	support := map[string]interface{}{
+		"roles":        []string{"ROLE_SUPPORT"},
+		"entitlements": map[string]interface{}{},
+	}
+
+	cases := []struct {
+		name          string
+		input         Input
+		allowed       bool
+		matchedPolicy string
+	}{
+		{"free plan in eu", Input{Subject: free, Action: "vpn:connect",
+			Resource: map[string]interface{}{"type": "server", "region": "eu"}}, true, "users-can-connect"},
+		{"free plan in us", Input{Subject: free, Action: "vpn:connect",
+			Resource: map[string]interface{}{"type": "server", "region": "us"}}, false, "free-plan-eu-only"},
+		{"premium plan in us", Input{Subject: premium, Action: "vpn:connect",
+			Resource: map[string]interface{}{"type": "server", "region": "us"}}, true, "users-can-connect"},
+		{"support in business hours", Input{Subject: support, Action: "users:read",
+			Resource: map[string]interface{}{"type": "user"}, Request: map[string]interface{}{"hour": 10}}, true,
+			"support-business-hours"},
+		{"support out of business hours", Input{Subject: support, Action: "users:read",
+			Resource: map[string]interface{}{"type": "user"}, Request: map[string]interface{}{"hour": 22}}, false, ""},
+		// deny policy can not be evaluated without the entitlements, it must fail closed
+		{"deny policy error", Input{Subject: map[string]interface{}{"roles": []string{"ROLE_USER"}},
+			Action: "vpn:connect", Resource: map[string]interface{}{"region": "us"}}, false, "free-plan-eu-only"},
+	}
+
+	for _, c := range cases {
+		decision := engine.Evaluate(c.input)
+		if decision.Allowed != c.allowed || decision.MatchedPolicy != c.matchedPolicy {
+			t.Errorf("%s: expected allowed=%t matchedPolicy=%q, got %+v", c.name, c.allowed, c.matchedPolicy,
+				decision)
+		}
+	}
+}
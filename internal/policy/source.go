@@ -0,0 +1,113 @@
+package policy
+
+import (
+	"auth-service/internal/model"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"gorm.io/gorm"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Source loads policies from a backing store
+type Source interface {
+	Load() ([]Policy, error)
+	String() string
+}
+
+// FileSource loads the policies from the yaml or json files in a directory, each file contains a policies list
+type FileSource struct {
+	Dir string
+}
+
+type policyFile struct {
+	Policies []Policy `yaml:"policies"`
+}
+
+// Load reads all the *.yaml, *.yml and *.json files in the directory in lexical order
+func (s FileSource) Load() ([]Policy, error) {
+	files, err := ioutil.ReadDir(s.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, f := range files {
+		ext := strings.ToLower(filepath.Ext(f.Name()))
+		if !f.IsDir() && (ext == ".yaml" || ext == ".yml" || ext == ".json") {
+			names = append(names, f.Name())
+		}
+	}
+	sort.Strings(names)
+
+	var policies []Policy
+	for _, name := range names {
+		content, err := ioutil.ReadFile(filepath.Join(s.Dir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		// json is a subset of yaml, so a single parser handles both formats
+		var file policyFile
+		if err := yaml.Unmarshal(content, &file); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+
+		for _, p := range file.Policies {
+			p.Source = "file:" + name
+			policies = append(policies, p)
+		}
+	}
+
+	return policies, nil
+}
+
+func (s FileSource) String() string {
+	return "directory " + s.Dir
+}
+
+// DbSource loads the enabled policies from the policies table
+type DbSource struct {
+	Db *gorm.DB
+}
+
+// Load queries the enabled policies
+func (s DbSource) Load() ([]Policy, error) {
+	var rows []model.Policy
+	if err := s.Db.Where("enabled = ?", true).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	policies := make([]Policy, 0, len(rows))
+	for _, row := range rows {
+		policies = append(policies, Policy{
+			Name:          row.Name,
+			Description:   row.Description,
+			Effect:        row.Effect,
+			Priority:      row.Priority,
+			Actions:       splitList(row.Actions),
+			ResourceTypes: splitList(row.ResourceTypes),
+			Condition:     row.Condition,
+			Source:        "database",
+		})
+	}
+
+	return policies, nil
+}
+
+func (s DbSource) String() string {
+	return "database"
+}
+
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
@@ -0,0 +1,61 @@
+package policy
+
+import (
+	"context"
+	"github.com/fsnotify/fsnotify"
+	"go.uber.org/zap"
+	"time"
+)
+
+// Watch reloads the policies periodically and whenever a file in one of the watched directories changes, until the
+// context is done. Database policies are only picked up by the periodic reload
+func (e *Engine) Watch(ctx context.Context, interval time.Duration, dirs ...string) {
+	var events <-chan fsnotify.Event
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		e.logger.Warn("an error occurred while creating file watcher, falling back to periodic reload",
+			zap.String("error", err.Error()))
+	} else {
+		defer func() {
+			_ = watcher.Close()
+		}()
+
+		for _, dir := range dirs {
+			if err := watcher.Add(dir); err != nil {
+				e.logger.Warn("an error occurred while watching policy directory", zap.String("dir", dir),
+					zap.String("error", err.Error()))
+			}
+		}
+		events = watcher.Events
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// editors emit bursts of events for a single save, reloads are debounced
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			e.logger.Debug("policy file changed", zap.String("file", event.Name))
+			debounce = time.After(500 * time.Millisecond)
+		case <-debounce:
+			debounce = nil
+			e.reload()
+		case <-ticker.C:
+			e.reload()
+		}
+	}
+}
+
+func (e *Engine) reload() {
+	if err := e.Reload(); err != nil {
+		e.logger.Error("an error occurred while reloading policies", zap.String("error", err.Error()))
+	}
+}
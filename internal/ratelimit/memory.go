@@ -0,0 +1,91 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepInterval is the interval of dropping the expired counters
+const sweepInterval = time.Minute
+
+type counter struct {
+	tokens   float64
+	updated  time.Time
+	index    int64
+	current  int64
+	previous int64
+	expires  time.Time
+}
+
+// MemoryBackend keeps the counters in the memory of the replica, so the limits are per replica
+type MemoryBackend struct {
+	mu        sync.Mutex
+	now       func() time.Time
+	counters  map[string]*counter
+	lastSweep time.Time
+}
+
+// NewMemoryBackend creates a MemoryBackend, clock defaults to time.Now if it is nil
+func NewMemoryBackend(clock func() time.Time) *MemoryBackend {
+	if clock == nil {
+		clock = time.Now
+	}
+
+	return &MemoryBackend{now: clock, counters: make(map[string]*counter), lastSweep: clock()}
+}
+
+// Allow implements Backend
+func (b *MemoryBackend) Allow(_ context.Context, key string, policy Policy) (Result, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	b.sweep(now)
+	c, ok := b.counters[key]
+	if !ok || now.After(c.expires) {
+		c = &counter{tokens: float64(policy.Limit), updated: now, index: now.UnixNano() / int64(policy.Window())}
+		b.counters[key] = c
+	}
+
+	var result Result
+	switch policy.Algorithm {
+	case AlgorithmSlidingWindow:
+		window := int64(policy.Window())
+		index := now.UnixNano() / window
+		c.current, c.previous = shiftWindow(c.index, index, c.current, c.previous)
+		c.index = index
+		elapsed := time.Duration(now.UnixNano() - index*window)
+		allowed := slidingWindowEstimate(policy, c.current, c.previous, elapsed)+1 <= float64(policy.Limit)
+		if allowed {
+			c.current++
+		}
+		result = slidingWindowResult(policy, c.current, c.previous, elapsed, allowed)
+		c.expires = now.Add(2 * policy.Window())
+	default:
+		c.tokens = refill(policy, c.tokens, now.Sub(c.updated))
+		c.updated = now
+		allowed := c.tokens >= 1
+		if allowed {
+			c.tokens--
+		}
+		result = tokenBucketResult(policy, c.tokens, allowed)
+		c.expires = now.Add(policy.Window())
+	}
+
+	return result, nil
+}
+
+// sweep drops the expired counters, so the keys which are not seen anymore do not fill the memory
+func (b *MemoryBackend) sweep(now time.Time) {
+	if now.Sub(b.lastSweep) < sweepInterval {
+		return
+	}
+
+	for key, c := range b.counters {
+		if now.After(c.expires) {
+			delete(b.counters, key)
+		}
+	}
+	b.lastSweep = now
+}
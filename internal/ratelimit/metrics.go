@@ -0,0 +1,12 @@
+package ratelimit
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var rejectedRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "auth_service",
+	Name:      "rate_limited_requests_total",
+	Help:      "Number of the requests refused by the rate limiter by route and key",
+}, []string{"route", "key"})
@@ -0,0 +1,220 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"math"
+	"os"
+	"time"
+)
+
+// names of the backends
+const (
+	BackendMemory = "memory"
+	BackendRedis  = "redis"
+)
+
+// algorithms of the policies
+const (
+	AlgorithmTokenBucket   = "token_bucket"
+	AlgorithmSlidingWindow = "sliding_window"
+)
+
+// keys which the requests are counted by
+const (
+	KeyIp       = "ip"
+	KeyUsername = "username"
+	KeyClientId = "client_id"
+	KeySubject  = "subject"
+)
+
+// Policy limits the requests to a route which have the same key. Token bucket allows bursts of Limit requests and
+// refills Limit tokens per window, sliding window allows Limit requests in any window
+type Policy struct {
+	Route         string `yaml:"route"`
+	Key           string `yaml:"key"`
+	Algorithm     string `yaml:"algorithm"`
+	Limit         int    `yaml:"limit"`
+	WindowSeconds int    `yaml:"windowSeconds"`
+}
+
+// Window is the duration of the window of the policy
+func (p Policy) Window() time.Duration {
+	return time.Duration(p.WindowSeconds) * time.Second
+}
+
+// Result is the decision of a request, Reset is the time until the quota is fully restored and RetryAfter is the
+// time until the next request is allowed if the request is refused
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// Backend counts the requests of the keys, counters of a key are shared by all the replicas using the same backend
+type Backend interface {
+	Allow(ctx context.Context, key string, policy Policy) (Result, error)
+}
+
+// DefaultPolicies limits the password logins both by ip and username, so neither a single client nor a distributed
+// attack on a single user can try many passwords
+func DefaultPolicies() []Policy {
+	return []Policy{
+		{Route: "/auth/authenticate", Key: KeyIp, Algorithm: AlgorithmTokenBucket, Limit: 20, WindowSeconds: 60},
+		{Route: "/auth/authenticate", Key: KeyUsername, Algorithm: AlgorithmSlidingWindow, Limit: 10,
+			WindowSeconds: 300},
+		{Route: "/auth/refresh", Key: KeyIp, Algorithm: AlgorithmTokenBucket, Limit: 60, WindowSeconds: 60},
+		{Route: "/auth/validate", Key: KeyIp, Algorithm: AlgorithmTokenBucket, Limit: 1200, WindowSeconds: 60},
+	}
+}
+
+type policiesFile struct {
+	Policies []Policy `yaml:"policies"`
+}
+
+// LoadPolicies reads the policies from the yaml file, they replace the DefaultPolicies
+func LoadPolicies(path string) ([]Policy, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file policiesFile
+	if err := yaml.Unmarshal([]byte(os.ExpandEnv(string(content))), &file); err != nil {
+		return nil, err
+	}
+
+	for i, p := range file.Policies {
+		if p.Route == "" || p.Limit <= 0 || p.WindowSeconds <= 0 {
+			return nil, fmt.Errorf("policy %d: route, limit and windowSeconds are required", i)
+		}
+
+		switch p.Key {
+		case KeyIp, KeyUsername, KeyClientId, KeySubject:
+		default:
+			return nil, fmt.Errorf("policy %d: unsupported key %q", i, p.Key)
+		}
+
+		switch p.Algorithm {
+		case AlgorithmTokenBucket, AlgorithmSlidingWindow:
+		default:
+			return nil, fmt.Errorf("policy %d: unsupported algorithm %q", i, p.Algorithm)
+		}
+	}
+
+	return file.Policies, nil
+}
+
+// Limiter checks the requests against the policies of their routes
+type Limiter struct {
+	backend  Backend
+	policies map[string][]Policy
+}
+
+// NewLimiter creates a Limiter which counts the requests on the backend
+func NewLimiter(backend Backend, policies []Policy) *Limiter {
+	byRoute := make(map[string][]Policy)
+	for _, p := range policies {
+		byRoute[p.Route] = append(byRoute[p.Route], p)
+	}
+
+	return &Limiter{backend: backend, policies: byRoute}
+}
+
+// Policies returns the policies of the route
+func (l *Limiter) Policies(route string) []Policy {
+	return l.policies[route]
+}
+
+// Allow counts the request of the key against the policy
+func (l *Limiter) Allow(ctx context.Context, policy Policy, key string) (Result, error) {
+	result, err := l.backend.Allow(ctx, fmt.Sprintf("ratelimit:%s:%s:%s", policy.Route, policy.Key, key), policy)
+	if err == nil && !result.Allowed {
+		rejectedRequests.WithLabelValues(policy.Route, policy.Key).Inc()
+	}
+
+	return result, err
+}
+
+// refill adds the tokens earned since the last request to the bucket, the bucket never holds more than Limit tokens
+func refill(policy Policy, tokens float64, elapsed time.Duration) float64 {
+	if elapsed < 0 {
+		elapsed = 0
+	}
+
+	rate := float64(policy.Limit) / policy.Window().Seconds()
+	return math.Min(float64(policy.Limit), tokens+elapsed.Seconds()*rate)
+}
+
+// tokenBucketResult builds the result from the tokens left in the bucket after the request
+func tokenBucketResult(policy Policy, tokens float64, allowed bool) Result {
+	perToken := policy.Window().Seconds() / float64(policy.Limit)
+	result := Result{
+		Allowed:   allowed,
+		Limit:     policy.Limit,
+		Remaining: int(math.Floor(tokens)),
+		Reset:     seconds((float64(policy.Limit) - tokens) * perToken),
+	}
+	if !allowed {
+		result.RetryAfter = seconds((1 - tokens) * perToken)
+	}
+
+	return result
+}
+
+// shiftWindow moves the counters of the stored window to the current window, counters of the windows older than
+// the previous one are dropped
+func shiftWindow(stored, index, current, previous int64) (int64, int64) {
+	switch index {
+	case stored:
+		return current, previous
+	case stored + 1:
+		return 0, current
+	default:
+		return 0, 0
+	}
+}
+
+// slidingWindowEstimate weights the previous window by its part which overlaps with the sliding window
+func slidingWindowEstimate(policy Policy, current, previous int64, elapsed time.Duration) float64 {
+	weight := 1 - elapsed.Seconds()/policy.Window().Seconds()
+	return float64(previous)*weight + float64(current)
+}
+
+// slidingWindowResult builds the result from the counters after the request
+func slidingWindowResult(policy Policy, current, previous int64, elapsed time.Duration, allowed bool) Result {
+	window := policy.Window()
+	estimate := slidingWindowEstimate(policy, current, previous, elapsed)
+	result := Result{
+		Allowed:   allowed,
+		Limit:     policy.Limit,
+		Remaining: int(math.Max(0, float64(policy.Limit)-math.Ceil(estimate))),
+		Reset:     window - elapsed,
+	}
+	if allowed {
+		return result
+	}
+
+	limit := int64(policy.Limit)
+	if current+1 > limit || previous == 0 {
+		result.RetryAfter = window - elapsed
+		return result
+	}
+
+	// the request is allowed once the weight of the previous window drops enough
+	weight := float64(limit-1-current) / float64(previous)
+	result.RetryAfter = seconds((1-weight)*window.Seconds()) - elapsed
+	if result.RetryAfter <= 0 {
+		result.RetryAfter = time.Second
+	}
+
+	return result
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func newBackends(t *testing.T, clock *fakeClock) map[string]Backend {
+	server, err := miniredis.Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	return map[string]Backend{
+		"memory": NewMemoryBackend(clock.Now),
+		"redis":  NewRedisBackend(client, clock.Now),
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2022, 2, 1, 10, 0, 0, 0, time.UTC)}
+	policy := Policy{Route: "/auth/authenticate", Key: KeyIp, Algorithm: AlgorithmTokenBucket, Limit: 3,
+		WindowSeconds: 60}
+	for name, backend := range newBackends(t, clock) {
+		limiter := NewLimiter(backend, []Policy{policy})
+		for i := 0; i < 3; i++ {
+			result, err := limiter.Allow(context.Background(), policy, name)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !result.Allowed || result.Remaining != 2-i {
+				t.Errorf("%s: expected request %d to be allowed with %d remaining, got %+v", name, i, 2-i, result)
+			}
+		}
+
+		result, _ := limiter.Allow(context.Background(), policy, name)
+		if result.Allowed || result.RetryAfter != 20*time.Second {
+			t.Errorf("%s: expected the burst to be refused until a token is refilled, got %+v", name, result)
+		}
+
+		clock.now = clock.now.Add(20 * time.Second)
+		if result, _ := limiter.Allow(context.Background(), policy, name); !result.Allowed {
+			t.Errorf("%s: expected the refilled token to be used, got %+v", name, result)
+		}
+
+		if result, _ := limiter.Allow(context.Background(), policy, "other"+name); !result.Allowed {
+			t.Errorf("%s: expected the keys to have separate buckets, got %+v", name, result)
+		}
+	}
+}
+
+func TestSlidingWindow(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2022, 2, 1, 10, 0, 0, 0, time.UTC)}
+	policy := Policy{Route: "/auth/authenticate", Key: KeyUsername, Algorithm: AlgorithmSlidingWindow, Limit: 4,
+		WindowSeconds: 60}
+	for name, backend := range newBackends(t, clock) {
+		start := clock.now
+		for i := 0; i < 4; i++ {
+			if result, err := backend.Allow(context.Background(), name, policy); err != nil || !result.Allowed {
+				t.Fatalf("%s: expected request %d to be allowed, got %+v %v", name, i, result, err)
+			}
+		}
+
+		result, _ := backend.Allow(context.Background(), name, policy)
+		if result.Allowed || result.Remaining != 0 || result.RetryAfter != time.Minute {
+			t.Errorf("%s: expected the request over the limit to wait for the next window, got %+v", name, result)
+		}
+
+		// 4 requests of the previous window weigh 3 at the first quarter of the next one
+		clock.now = start.Add(75 * time.Second)
+		if result, _ := backend.Allow(context.Background(), name, policy); !result.Allowed || result.Remaining != 0 {
+			t.Errorf("%s: expected a single request to be allowed, got %+v", name, result)
+		}
+
+		result, _ = backend.Allow(context.Background(), name, policy)
+		if result.Allowed || result.RetryAfter != 15*time.Second {
+			t.Errorf("%s: expected to wait for the previous window to slide out, got %+v", name, result)
+		}
+
+		clock.now = start
+	}
+}
@@ -0,0 +1,121 @@
+package ratelimit
+
+import (
+	"context"
+	"github.com/go-redis/redis/v8"
+	"strconv"
+	"time"
+)
+
+// tokenBucketScript refills the bucket, takes a token if there is one and returns whether the request is allowed
+// with the tokens left. Tokens are returned as a string since the integer replies of redis drop the fractions
+var tokenBucketScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'updated')
+local tokens = tonumber(state[1])
+local updated = tonumber(state[2])
+if tokens == nil or updated == nil then
+	tokens = limit
+	updated = now
+end
+tokens = math.min(limit, tokens + math.max(0, now - updated) * limit / window)
+local allowed = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+end
+redis.call('HMSET', KEYS[1], 'tokens', tostring(tokens), 'updated', tostring(now))
+redis.call('PEXPIRE', KEYS[1], window)
+return {allowed, tostring(tokens)}
+`)
+
+// slidingWindowScript shifts the counters to the current window, counts the request if the estimate is below the
+// limit and returns whether the request is allowed with the counters
+var slidingWindowScript = redis.NewScript(`
+local limit = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local index = math.floor(now / window)
+local state = redis.call('HMGET', KEYS[1], 'index', 'current', 'previous')
+local stored = tonumber(state[1])
+local current = tonumber(state[2]) or 0
+local previous = tonumber(state[3]) or 0
+if stored == nil then
+	current = 0
+	previous = 0
+elseif index == stored + 1 then
+	previous = current
+	current = 0
+elseif index ~= stored then
+	current = 0
+	previous = 0
+end
+local elapsed = now - index * window
+local allowed = 0
+if previous * (1 - elapsed / window) + current + 1 <= limit then
+	current = current + 1
+	allowed = 1
+end
+redis.call('HMSET', KEYS[1], 'index', index, 'current', current, 'previous', previous)
+redis.call('PEXPIRE', KEYS[1], 2 * window)
+return {allowed, current, previous}
+`)
+
+// RedisBackend keeps the counters in redis, so the limits are shared by all the replicas. Counters are updated with
+// lua scripts, so the concurrent requests of the replicas can not exceed the limits
+type RedisBackend struct {
+	client redis.UniversalClient
+	now    func() time.Time
+}
+
+// NewRedisBackend creates a RedisBackend, clock defaults to time.Now if it is nil. Clocks of the replicas are used for
+// the windows, so they must be synchronized
+func NewRedisBackend(client redis.UniversalClient, clock func() time.Time) *RedisBackend {
+	if clock == nil {
+		clock = time.Now
+	}
+
+	return &RedisBackend{client: client, now: clock}
+}
+
+// Allow implements Backend
+func (b *RedisBackend) Allow(ctx context.Context, key string, policy Policy) (Result, error) {
+	now := b.now()
+	nowMs := now.UnixNano() / int64(time.Millisecond)
+	windowMs := policy.Window().Milliseconds()
+	args := []interface{}{policy.Limit, windowMs, nowMs}
+	if policy.Algorithm == AlgorithmSlidingWindow {
+		reply, err := slidingWindowScript.Run(ctx, b.client, []string{key}, args...).Int64Slice()
+		if err != nil {
+			return Result{}, err
+		}
+
+		elapsed := time.Duration(nowMs-(nowMs/windowMs)*windowMs) * time.Millisecond
+		return slidingWindowResult(policy, reply[1], reply[2], elapsed, reply[0] == 1), nil
+	}
+
+	reply, err := tokenBucketScript.Run(ctx, b.client, []string{key}, args...).Slice()
+	if err != nil {
+		return Result{}, err
+	}
+
+	allowed, _ := reply[0].(int64)
+	tokens, err := strconv.ParseFloat(reply[1].(string), 64)
+	if err != nil {
+		return Result{}, err
+	}
+
+	return tokenBucketResult(policy, tokens, allowed == 1), nil
+}
+
+// Ping checks the connection to redis
+func (b *RedisBackend) Ping(ctx context.Context) error {
+	return b.client.Ping(ctx).Err()
+}
+
+// Close closes the connections to redis
+func (b *RedisBackend) Close(context.Context) error {
+	return b.client.Close()
+}
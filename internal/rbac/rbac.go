@@ -0,0 +1,349 @@
+package rbac
+
+import (
+	"auth-service/internal/jwt"
+	"auth-service/internal/model"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrRoleNotFound is returned when there is no role with the given name
+	ErrRoleNotFound = errors.New("role not found")
+	// ErrPermissionNotFound is returned when there is no permission with the given name
+	ErrPermissionNotFound = errors.New("permission not found")
+	// ErrPermissionExists is returned when a permission with the same name already exists
+	ErrPermissionExists = errors.New("permission already exists")
+	// ErrInvalidPermissionName is returned when the permission name is not in resource:action format
+	ErrInvalidPermissionName = errors.New("permission name must be in resource:action format")
+	// ErrCyclicInheritance is returned when a role would inherit from itself through its parents
+	ErrCyclicInheritance = errors.New("role inheritance can not be cyclic")
+
+	queryRealm            = "realm_id = ?"
+	permissionNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]*:[a-z][a-z0-9_-]*$`)
+)
+
+// RoleDetails represents a role with its direct permissions, parents and the effective permissions
+type RoleDetails struct {
+	Name                 string   `json:"name"`
+	Parents              []string `json:"parents"`
+	Permissions          []string `json:"permissions"`
+	EffectivePermissions []string `json:"effectivePermissions"`
+}
+
+// graph is the in-memory representation of role inheritance and role permissions
+type graph struct {
+	roleIds     map[string]uint
+	roleNames   map[uint]string
+	parents     map[uint][]uint
+	permissions map[uint][]string
+}
+
+// loadGraph loads the role graph of the realm, roles and permissions are expected to be small sets. Inheritance and
+// permissions of the roles in other realms are not loaded
+func loadGraph(db *gorm.DB, realmId uint) (*graph, error) {
+	var roles []model.Role
+	if err := db.Select("id", "name").Where(queryRealm, realmId).Find(&roles).Error; err != nil {
+		return nil, err
+	}
+
+	realmRoles := db.Model(&model.Role{}).Select("id").Where(queryRealm, realmId)
+	var parents []model.RoleParent
+	if err := db.Where("role_id IN (?)", realmRoles).Find(&parents).Error; err != nil {
+		return nil, err
+	}
+
+	var rolePermissions []struct {
+		RoleId uint
+		Name   string
+	}
+	err := db.Table("role_permissions").
+		Select("role_permissions.role_id, permissions.name").
+		Joins("JOIN permissions ON permissions.id = role_permissions.permission_id").
+		Where("role_permissions.role_id IN (?)", realmRoles).
+		Scan(&rolePermissions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	g := &graph{
+		roleIds:     make(map[string]uint, len(roles)),
+		roleNames:   make(map[uint]string, len(roles)),
+		parents:     make(map[uint][]uint),
+		permissions: make(map[uint][]string),
+	}
+	for _, r := range roles {
+		g.roleIds[r.Name] = r.Id
+		g.roleNames[r.Id] = r.Name
+	}
+	for _, p := range parents {
+		g.parents[p.RoleId] = append(g.parents[p.RoleId], p.ParentRoleId)
+	}
+	for _, rp := range rolePermissions {
+		g.permissions[rp.RoleId] = append(g.permissions[rp.RoleId], rp.Name)
+	}
+
+	return g, nil
+}
+
+// ancestors returns the role itself and all the roles it inherits from, cycles are tolerated
+func (g *graph) ancestors(roleIds ...uint) []uint {
+	visited := make(map[uint]bool)
+	queue := append([]uint(nil), roleIds...)
+	var result []uint
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if visited[id] {
+			continue
+		}
+		visited[id] = true
+		result = append(result, id)
+		queue = append(queue, g.parents[id]...)
+	}
+
+	return result
+}
+
+// effectivePermissions returns the sorted unique permissions of the roles, including the inherited ones
+func (g *graph) effectivePermissions(roleNames []string) []string {
+	var roleIds []uint
+	for _, name := range roleNames {
+		if id, ok := g.roleIds[name]; ok {
+			roleIds = append(roleIds, id)
+		}
+	}
+
+	seen := make(map[string]bool)
+	permissions := make([]string, 0)
+	for _, id := range g.ancestors(roleIds...) {
+		for _, permission := range g.permissions[id] {
+			if !seen[permission] {
+				seen[permission] = true
+				permissions = append(permissions, permission)
+			}
+		}
+	}
+
+	sort.Strings(permissions)
+	return permissions
+}
+
+// EffectivePermissions resolves the permissions granted to the roles of the realm directly or through inheritance
+func EffectivePermissions(db *gorm.DB, realmId uint, roleNames []string) ([]string, error) {
+	g, err := loadGraph(db, realmId)
+	if err != nil {
+		return nil, err
+	}
+
+	return g.effectivePermissions(roleNames), nil
+}
+
+// Missing returns the required permissions which are not granted
+func Missing(granted, required []string) []string {
+	grantedSet := make(map[string]bool, len(granted))
+	for _, p := range granted {
+		grantedSet[p] = true
+	}
+
+	var missing []string
+	for _, p := range required {
+		if !grantedSet[p] {
+			missing = append(missing, p)
+		}
+	}
+
+	return missing
+}
+
+// Enricher returns a jwt.ClaimEnricher which embeds the effective permissions of the roles into the scope claim
+func Enricher(db *gorm.DB, realmId uint, roleNames []string) jwt.ClaimEnricher {
+	return func(claims *jwt.VpnbeastClaim) error {
+		permissions, err := EffectivePermissions(db, realmId, roleNames)
+		if err != nil {
+			return err
+		}
+
+		claims.Scope = strings.Join(permissions, " ")
+		return nil
+	}
+}
+
+// ListRoles returns all the roles of the realm with their direct and effective permissions
+func ListRoles(db *gorm.DB, realmId uint) ([]RoleDetails, error) {
+	g, err := loadGraph(db, realmId)
+	if err != nil {
+		return nil, err
+	}
+
+	details := make([]RoleDetails, 0, len(g.roleIds))
+	for name, id := range g.roleIds {
+		parents := make([]string, 0, len(g.parents[id]))
+		for _, parentId := range g.parents[id] {
+			parents = append(parents, g.roleNames[parentId])
+		}
+		sort.Strings(parents)
+
+		permissions := append([]string{}, g.permissions[id]...)
+		sort.Strings(permissions)
+
+		details = append(details, RoleDetails{
+			Name:                 name,
+			Parents:              parents,
+			Permissions:          permissions,
+			EffectivePermissions: g.effectivePermissions([]string{name}),
+		})
+	}
+
+	sort.Slice(details, func(i, j int) bool {
+		return details[i].Name < details[j].Name
+	})
+	return details, nil
+}
+
+// ListPermissions returns all the permissions
+func ListPermissions(db *gorm.DB) ([]model.Permission, error) {
+	var permissions []model.Permission
+	err := db.Order("name").Find(&permissions).Error
+	return permissions, err
+}
+
+// CreatePermission creates a new permission
+func CreatePermission(db *gorm.DB, name, description string) (*model.Permission, error) {
+	if !permissionNamePattern.MatchString(name) {
+		return nil, ErrInvalidPermissionName
+	}
+
+	var count int64
+	if err := db.Model(&model.Permission{}).Where("name = ?", name).Count(&count).Error; err != nil {
+		return nil, err
+	}
+
+	if count != 0 {
+		return nil, ErrPermissionExists
+	}
+
+	permission := &model.Permission{
+		Name:        name,
+		Description: description,
+		CreatedAt:   time.Now(),
+	}
+	return permission, db.Create(permission).Error
+}
+
+// DeletePermission deletes the permission and revokes it from all the roles
+func DeletePermission(db *gorm.DB, name string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		permission, err := findPermission(tx, name)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Where("permission_id = ?", permission.Id).Delete(&model.RolePermission{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(permission).Error
+	})
+}
+
+// GrantPermission grants the permission to the role
+func GrantPermission(db *gorm.DB, realmId uint, roleName, permissionName string) error {
+	role, err := findRole(db, realmId, roleName)
+	if err != nil {
+		return err
+	}
+
+	permission, err := findPermission(db, permissionName)
+	if err != nil {
+		return err
+	}
+
+	return db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&model.RolePermission{RoleId: role.Id, PermissionId: permission.Id}).Error
+}
+
+// RevokePermission revokes the permission from the role
+func RevokePermission(db *gorm.DB, realmId uint, roleName, permissionName string) error {
+	role, err := findRole(db, realmId, roleName)
+	if err != nil {
+		return err
+	}
+
+	permission, err := findPermission(db, permissionName)
+	if err != nil {
+		return err
+	}
+
+	return db.Where("role_id = ? AND permission_id = ?", role.Id, permission.Id).
+		Delete(&model.RolePermission{}).Error
+}
+
+// AddParent makes the role inherit the permissions of the parent role
+func AddParent(db *gorm.DB, realmId uint, roleName, parentName string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		role, err := findRole(tx, realmId, roleName)
+		if err != nil {
+			return err
+		}
+
+		parent, err := findRole(tx, realmId, parentName)
+		if err != nil {
+			return err
+		}
+
+		g, err := loadGraph(tx, realmId)
+		if err != nil {
+			return err
+		}
+
+		for _, id := range g.ancestors(parent.Id) {
+			if id == role.Id {
+				return ErrCyclicInheritance
+			}
+		}
+
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&model.RoleParent{RoleId: role.Id, ParentRoleId: parent.Id}).Error
+	})
+}
+
+// RemoveParent removes the inheritance between the role and the parent role
+func RemoveParent(db *gorm.DB, realmId uint, roleName, parentName string) error {
+	role, err := findRole(db, realmId, roleName)
+	if err != nil {
+		return err
+	}
+
+	parent, err := findRole(db, realmId, parentName)
+	if err != nil {
+		return err
+	}
+
+	return db.Where("role_id = ? AND parent_role_id = ?", role.Id, parent.Id).Delete(&model.RoleParent{}).Error
+}
+
+func findRole(db *gorm.DB, realmId uint, name string) (*model.Role, error) {
+	var role model.Role
+	err := db.Where("realm_id = ? AND name = ?", realmId, name).First(&role).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrRoleNotFound
+	}
+
+	return &role, err
+}
+
+func findPermission(db *gorm.DB, name string) (*model.Permission, error) {
+	var permission model.Permission
+	err := db.Where("name = ?", name).First(&permission).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPermissionNotFound
+	}
+
+	return &permission, err
+}
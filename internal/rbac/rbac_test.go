@@ -0,0 +1,49 @@
+package rbac
+
+import (
+	"reflect"
+	"testing"
+)
+
+func newTestGraph() *graph {
+	// ROLE_ADMIN -> ROLE_SUPPORT -> ROLE_USER, with a cycle between ROLE_A and ROLE_B
+	return &graph{
+		roleIds:   map[string]uint{"ROLE_USER": 1, "ROLE_SUPPORT": 2, "ROLE_ADMIN": 3, "ROLE_A": 4, "ROLE_B": 5},
+		roleNames: map[uint]string{1: "ROLE_USER", 2: "ROLE_SUPPORT", 3: "ROLE_ADMIN", 4: "ROLE_A", 5: "ROLE_B"},
+		parents:   map[uint][]uint{2: {1}, 3: {2}, 4: {5}, 5: {4}},
+		permissions: map[uint][]string{
+			1: {"vpn:connect"},
+			2: {"users:read"},
+			3: {"servers:write", "users:read"},
+			4: {"a:read"},
+			5: {"b:read"},
+		},
+	}
+}
+
+func TestEffectivePermissions(t *testing.T) {
+	g := newTestGraph()
+	cases := []struct {
+		roles    []string
+		expected []string
+	}{
+		{[]string{"ROLE_USER"}, []string{"vpn:connect"}},
+		{[]string{"ROLE_SUPPORT"}, []string{"users:read", "vpn:connect"}},
+		{[]string{"ROLE_ADMIN"}, []string{"servers:write", "users:read", "vpn:connect"}},
+		{[]string{"ROLE_A"}, []string{"a:read", "b:read"}},
+		{[]string{"ROLE_UNKNOWN"}, []string{}},
+	}
+
+	for _, c := range cases {
+		if permissions := g.effectivePermissions(c.roles); !reflect.DeepEqual(permissions, c.expected) {
+			t.Errorf("expected %v for roles %v, got %v", c.expected, c.roles, permissions)
+		}
+	}
+}
+
+func TestMissing(t *testing.T) {
+	missing := Missing([]string{"vpn:connect", "users:read"}, []string{"users:read", "servers:write"})
+	if !reflect.DeepEqual(missing, []string{"servers:write"}) {
+		t.Errorf("unexpected missing permissions %v", missing)
+	}
+}
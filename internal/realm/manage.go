@@ -0,0 +1,146 @@
+package realm
+
+import (
+	"auth-service/internal/jwt"
+	"auth-service/internal/model"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"gorm.io/gorm"
+	"time"
+)
+
+const keyBits = 2048
+
+// List returns all the realms including the disabled ones
+func List(db *gorm.DB) ([]model.Realm, error) {
+	var realms []model.Realm
+	err := db.Order("name").Find(&realms).Error
+	return realms, err
+}
+
+// Create creates a new realm with a freshly generated signing key
+func Create(db *gorm.DB, realm *model.Realm) error {
+	if !realmNamePattern.MatchString(realm.Name) {
+		return ErrInvalidRealmName
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&model.Realm{}).Where("name = ?", realm.Name).Count(&count).Error; err != nil {
+			return err
+		}
+
+		if count != 0 {
+			return ErrRealmExists
+		}
+
+		realm.CreatedAt = time.Now()
+		realm.UpdatedAt = time.Now()
+		if err := tx.Create(realm).Error; err != nil {
+			return err
+		}
+
+		_, err := rotateKey(tx, realm.Id)
+		return err
+	})
+}
+
+// Update updates the settings of the realm with the given name, name of a realm can not be changed
+func Update(db *gorm.DB, name string, realm *model.Realm) error {
+	existing, err := find(db, name)
+	if err != nil {
+		return err
+	}
+
+	if existing.Name == jwt.DefaultRealm && !realm.Enabled {
+		return ErrDisableDefaultRealm
+	}
+
+	return db.Model(existing).Select("issuer_url", "hosts", "access_token_valid_in_minutes",
+		"refresh_token_valid_in_minutes", "password_min_length", "password_max_length", "password_require_digit",
+		"password_require_upper", "password_require_lower", "password_require_symbol", "identity_backend", "enabled",
+		"updated_at").
+		Updates(&model.Realm{
+			IssuerUrl:                  realm.IssuerUrl,
+			Hosts:                      realm.Hosts,
+			AccessTokenValidInMinutes:  realm.AccessTokenValidInMinutes,
+			RefreshTokenValidInMinutes: realm.RefreshTokenValidInMinutes,
+			PasswordMinLength:          realm.PasswordMinLength,
+			PasswordMaxLength:          realm.PasswordMaxLength,
+			PasswordRequireDigit:       realm.PasswordRequireDigit,
+			PasswordRequireUpper:       realm.PasswordRequireUpper,
+			PasswordRequireLower:       realm.PasswordRequireLower,
+			PasswordRequireSymbol:      realm.PasswordRequireSymbol,
+			IdentityBackend:            realm.IdentityBackend,
+			Enabled:                    realm.Enabled,
+			UpdatedAt:                  time.Now(),
+		}).Error
+}
+
+// RotateKey generates a new active signing key for the realm and retires the previous ones, retired keys keep
+// verifying the tokens issued with them
+func RotateKey(db *gorm.DB, name string) (*model.RealmKey, error) {
+	realm, err := find(db, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var key *model.RealmKey
+	err = db.Transaction(func(tx *gorm.DB) error {
+		key, err = rotateKey(tx, realm.Id)
+		return err
+	})
+
+	return key, err
+}
+
+func rotateKey(tx *gorm.DB, realmId uint) (*model.RealmKey, error) {
+	privateKey, err := rsa.GenerateKey(rand.Reader, keyBits)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKeyBytes, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	kid := make([]byte, 8)
+	if _, err := rand.Read(kid); err != nil {
+		return nil, err
+	}
+
+	err = tx.Model(&model.RealmKey{}).Where("realm_id = ? AND active = ?", realmId, true).
+		Updates(map[string]interface{}{"active": false, "retired_at": time.Now()}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	key := &model.RealmKey{
+		RealmId: realmId,
+		Kid:     hex.EncodeToString(kid),
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(privateKey),
+		})),
+		PublicKey: string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKeyBytes})),
+		Active:    true,
+		CreatedAt: time.Now(),
+	}
+
+	return key, tx.Create(key).Error
+}
+
+func find(db *gorm.DB, name string) (*model.Realm, error) {
+	var realm model.Realm
+	err := db.Where("name = ?", name).First(&realm).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrRealmNotFound
+	}
+
+	return &realm, err
+}
@@ -0,0 +1,130 @@
+package realm
+
+import (
+	"auth-service/internal/jwt"
+	"auth-service/internal/model"
+	"errors"
+	"regexp"
+	"strings"
+)
+
+const defaultPasswordMinLength = 8
+
+var (
+	// ErrRealmNotFound is returned when there is no enabled realm with the given name or host
+	ErrRealmNotFound = errors.New("realm not found")
+	// ErrRealmExists is returned when a realm with the same name already exists
+	ErrRealmExists = errors.New("realm already exists")
+	// ErrInvalidRealmName is returned when the realm name is not a lowercase slug
+	ErrInvalidRealmName = errors.New("realm name must consist of lowercase letters, digits and dashes")
+	// ErrDisableDefaultRealm is returned when the default realm is tried to be disabled
+	ErrDisableDefaultRealm = errors.New("default realm can not be disabled")
+
+	realmNamePattern = regexp.MustCompile(`^[a-z][a-z0-9-]{0,63}$`)
+)
+
+// Defaults represents the service wide settings, the configured key pair belongs to the default realm and the
+// other settings are used by the realms which do not override them
+type Defaults struct {
+	Issuer                     string
+	PrivateKey                 string
+	PublicKey                  string
+	AccessTokenValidInMinutes  int32
+	RefreshTokenValidInMinutes int32
+}
+
+// PasswordPolicy represents the password requirements of a realm. auth-service does not set passwords, the policy is
+// published for user-service which enforces it when the passwords are set
+type PasswordPolicy struct {
+	MinLength     int  `json:"minLength"`
+	MaxLength     int  `json:"maxLength,omitempty"`
+	RequireDigit  bool `json:"requireDigit"`
+	RequireUpper  bool `json:"requireUpper"`
+	RequireLower  bool `json:"requireLower"`
+	RequireSymbol bool `json:"requireSymbol"`
+}
+
+// Realm is a loaded realm with its signer, it is immutable and safe to share between requests
+type Realm struct {
+	model.Realm
+	signer   *jwt.Signer
+	defaults Defaults
+}
+
+// Signer returns the Signer which issues and validates the tokens of the realm
+func (r *Realm) Signer() *jwt.Signer {
+	return r.signer
+}
+
+// IsDefault checks if the realm is the default realm which the data before realms belongs to
+func (r *Realm) IsDefault() bool {
+	return r.Name == jwt.DefaultRealm
+}
+
+// Issuer returns the issuer claim of the tokens of the realm
+func (r *Realm) Issuer() string {
+	return issuer(&r.Realm, r.defaults.Issuer)
+}
+
+// AccessTokenValidInMinutes returns the access token lifetime of the realm, falls back to the service default
+func (r *Realm) AccessTokenValidInMinutes() int32 {
+	if r.Realm.AccessTokenValidInMinutes > 0 {
+		return r.Realm.AccessTokenValidInMinutes
+	}
+
+	return r.defaults.AccessTokenValidInMinutes
+}
+
+// RefreshTokenValidInMinutes returns the refresh token lifetime of the realm, falls back to the service default
+func (r *Realm) RefreshTokenValidInMinutes() int32 {
+	if r.Realm.RefreshTokenValidInMinutes > 0 {
+		return r.Realm.RefreshTokenValidInMinutes
+	}
+
+	return r.defaults.RefreshTokenValidInMinutes
+}
+
+// PasswordPolicy returns the password requirements of the realm
+func (r *Realm) PasswordPolicy() PasswordPolicy {
+	policy := PasswordPolicy{
+		MinLength:     r.PasswordMinLength,
+		MaxLength:     r.PasswordMaxLength,
+		RequireDigit:  r.PasswordRequireDigit,
+		RequireUpper:  r.PasswordRequireUpper,
+		RequireLower:  r.PasswordRequireLower,
+		RequireSymbol: r.PasswordRequireSymbol,
+	}
+	if policy.MinLength <= 0 {
+		policy.MinLength = defaultPasswordMinLength
+	}
+
+	return policy
+}
+
+// HostList returns the host names which select the realm
+func (r *Realm) HostList() []string {
+	return splitHosts(r.Hosts)
+}
+
+func issuer(realm *model.Realm, defaultIssuer string) string {
+	if realm.IssuerUrl != "" {
+		return realm.IssuerUrl
+	}
+
+	if realm.Name == jwt.DefaultRealm {
+		return defaultIssuer
+	}
+
+	return strings.TrimSuffix(defaultIssuer, "/") + "/realms/" + realm.Name
+}
+
+func splitHosts(hosts string) []string {
+	var items []string
+	for _, host := range strings.Split(hosts, ",") {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			items = append(items, host)
+		}
+	}
+
+	return items
+}
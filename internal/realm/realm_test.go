@@ -0,0 +1,39 @@
+package realm
+
+import (
+	"auth-service/internal/model"
+	"testing"
+)
+
+func TestPasswordPolicy(t *testing.T) {
+	r := &Realm{Realm: model.Realm{
+		Name:                 "acme",
+		PasswordMaxLength:    16,
+		PasswordRequireDigit: true,
+		PasswordRequireUpper: true,
+	}}
+	policy := r.PasswordPolicy()
+	if policy.MinLength != defaultPasswordMinLength {
+		t.Errorf("expected default min length %d, got %d", defaultPasswordMinLength, policy.MinLength)
+	}
+
+	if policy.MaxLength != 16 || !policy.RequireDigit || !policy.RequireUpper || policy.RequireLower {
+		t.Errorf("unexpected policy %+v", policy)
+	}
+}
+
+func TestIssuer(t *testing.T) {
+	const defaultIssuer = "info@thevpnbeast.com"
+	if issuer(&model.Realm{Name: "default"}, defaultIssuer) != defaultIssuer {
+		t.Error("expected default realm to use the configured issuer")
+	}
+
+	got := issuer(&model.Realm{Name: "acme", IssuerUrl: "https://auth.acme.com"}, defaultIssuer)
+	if got != "https://auth.acme.com" {
+		t.Errorf("expected issuer url of the realm, got %s", got)
+	}
+
+	if got := issuer(&model.Realm{Name: "acme"}, defaultIssuer); got != defaultIssuer+"/realms/acme" {
+		t.Errorf("expected realm specific issuer, got %s", got)
+	}
+}
@@ -0,0 +1,159 @@
+package realm
+
+import (
+	"auth-service/internal/jwt"
+	"auth-service/internal/model"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Registry loads the enabled realms and their keys from the database and caches them for the ttl
+type Registry struct {
+	db       *gorm.DB
+	ttl      time.Duration
+	defaults Defaults
+	mu       sync.RWMutex
+	loadedAt time.Time
+	byName   map[string]*Realm
+	byHost   map[string]*Realm
+}
+
+// NewRegistry creates a new Registry, realms are loaded lazily on the first lookup
+func NewRegistry(db *gorm.DB, ttl time.Duration, defaults Defaults) *Registry {
+	return &Registry{
+		db:       db,
+		ttl:      ttl,
+		defaults: defaults,
+	}
+}
+
+// ByName returns the enabled realm with the given name
+func (r *Registry) ByName(name string) (*Realm, error) {
+	if err := r.refresh(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	realm, ok := r.byName[name]
+	if !ok {
+		return nil, ErrRealmNotFound
+	}
+
+	return realm, nil
+}
+
+// ByHost returns the enabled realm which the host is assigned to, port of the host is ignored
+func (r *Registry) ByHost(host string) (*Realm, error) {
+	if err := r.refresh(); err != nil {
+		return nil, err
+	}
+
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	realm, ok := r.byHost[strings.ToLower(host)]
+	if !ok {
+		return nil, ErrRealmNotFound
+	}
+
+	return realm, nil
+}
+
+// Default returns the default realm
+func (r *Registry) Default() (*Realm, error) {
+	return r.ByName(jwt.DefaultRealm)
+}
+
+// Invalidate drops the cached realms, next lookup loads them again
+func (r *Registry) Invalidate() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.loadedAt = time.Time{}
+}
+
+func (r *Registry) refresh() error {
+	r.mu.RLock()
+	fresh := !r.loadedAt.IsZero() && time.Since(r.loadedAt) < r.ttl
+	r.mu.RUnlock()
+	if fresh {
+		return nil
+	}
+
+	byName, byHost, err := load(r.db, r.defaults)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.byName = byName
+	r.byHost = byHost
+	r.loadedAt = time.Now()
+	return nil
+}
+
+// load builds the enabled realms with their key rings. Configured key pair is always a key of the default realm, it
+// stays active until a key of the default realm is rotated
+func load(db *gorm.DB, defaults Defaults) (map[string]*Realm, map[string]*Realm, error) {
+	var realms []model.Realm
+	if err := db.Where("enabled = ?", true).Find(&realms).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var keys []model.RealmKey
+	if err := db.Order("id").Find(&keys).Error; err != nil {
+		return nil, nil, err
+	}
+
+	keysByRealm := make(map[uint][]model.RealmKey)
+	for _, key := range keys {
+		keysByRealm[key.RealmId] = append(keysByRealm[key.RealmId], key)
+	}
+
+	byName := make(map[string]*Realm, len(realms))
+	byHost := make(map[string]*Realm)
+	for _, realm := range realms {
+		ring := jwt.NewKeyRing()
+		rotated := false
+		for _, key := range keysByRealm[realm.Id] {
+			rotated = rotated || key.Active
+		}
+
+		if realm.Name == jwt.DefaultRealm {
+			if err := ring.Add(jwt.DefaultKeyId, defaults.PrivateKey, defaults.PublicKey, !rotated); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		for _, key := range keysByRealm[realm.Id] {
+			if err := ring.Add(key.Kid, key.PrivateKey, key.PublicKey, key.Active); err != nil {
+				return nil, nil, fmt.Errorf("realm %s: %w", realm.Name, err)
+			}
+		}
+
+		loaded := &Realm{
+			Realm:    realm,
+			signer:   jwt.NewSigner(realm.Name, issuer(&realm, defaults.Issuer), ring),
+			defaults: defaults,
+		}
+		byName[realm.Name] = loaded
+		for _, host := range loaded.HostList() {
+			byHost[host] = loaded
+		}
+	}
+
+	if _, ok := byName[jwt.DefaultRealm]; !ok {
+		return nil, nil, errors.New("default realm is missing or disabled")
+	}
+
+	return byName, byHost, nil
+}
@@ -0,0 +1,73 @@
+package revocation
+
+import (
+	"auth-service/internal/model"
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"time"
+)
+
+// Listener is called in the same transaction when the sessions of a user are revoked, returning an error rolls
+// back the whole revocation
+type Listener func(tx *gorm.DB, user *model.User) error
+
+// RevokeSessions revokes all the tokens issued to the user so far, clears the stored tokens of the user and notifies
+// the given listeners
+func RevokeSessions(db *gorm.DB, user *model.User, listeners ...Listener) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		// tokens carry the issue time in milliseconds, see IsRevoked
+		revocation := model.SessionRevocation{
+			RealmId:   user.RealmId,
+			UserName:  user.UserName,
+			RevokedAt: time.Now().Truncate(time.Millisecond),
+		}
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "realm_id"}, {Name: "user_name"}},
+			DoUpdates: clause.AssignmentColumns([]string{"revoked_at"}),
+		}).Create(&revocation).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Model(user).Updates(map[string]interface{}{
+			"access_token":  "",
+			"refresh_token": "",
+			"updated_at":    time.Now().Format(time.RFC3339),
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		for _, listener := range listeners {
+			if err := listener(tx, user); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// IsRevoked checks if a token issued to the subject of the realm at issuedAt is revoked. Both times have millisecond
+// precision, a token issued in the same millisecond as the revocation is considered revoked since it can not be told
+// apart from the ones issued before
+func IsRevoked(db *gorm.DB, realmId uint, subject string, issuedAt time.Time) (bool, error) {
+	var revocation model.SessionRevocation
+	err := db.Where("realm_id = ? AND user_name = ?", realmId, subject).First(&revocation).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return !issuedAt.After(revocation.RevokedAt), nil
+}
+
+// Ping checks if the session revocations can be read, tokens can not be validated without them
+func Ping(db *gorm.DB) error {
+	var ids []uint
+	return db.Model(&model.SessionRevocation{}).Limit(1).Pluck("id", &ids).Error
+}
@@ -0,0 +1,80 @@
+package risk
+
+import (
+	"github.com/oschwald/maxminddb-golang"
+	"io/ioutil"
+	"net"
+)
+
+// Locator returns the location of an ip, nil is returned if the ip can not be located
+type Locator interface {
+	Locate(ip string) (*Location, error)
+}
+
+type noLocator struct{}
+
+func (noLocator) Locate(string) (*Location, error) {
+	return nil, nil
+}
+
+// NoLocator is the Locator used when there is no GeoIP database, new country and impossible travel signals are never
+// raised with it
+var NoLocator Locator = noLocator{}
+
+// geoIpRecord is the part of the GeoLite2 City and Country records which is used
+type geoIpRecord struct {
+	Country struct {
+		IsoCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	Location struct {
+		Latitude  *float64 `maxminddb:"latitude"`
+		Longitude *float64 `maxminddb:"longitude"`
+	} `maxminddb:"location"`
+}
+
+// GeoIpLocator locates the ips with an offline MaxMind DB file like GeoLite2-City.mmdb, coordinates are only
+// available with the city databases
+type GeoIpLocator struct {
+	reader *maxminddb.Reader
+}
+
+// NewGeoIpLocator reads the database file into memory, so the file can be replaced while the service is running
+func NewGeoIpLocator(path string) (*GeoIpLocator, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := maxminddb.FromBytes(content)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GeoIpLocator{reader: reader}, nil
+}
+
+// Locate implements Locator, private and invalid ips can not be located
+func (l *GeoIpLocator) Locate(ip string) (*Location, error) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return nil, nil
+	}
+
+	var record geoIpRecord
+	if err := l.reader.Lookup(parsed, &record); err != nil {
+		return nil, err
+	}
+
+	if record.Country.IsoCode == "" {
+		return nil, nil
+	}
+
+	location := &Location{Country: record.Country.IsoCode}
+	if record.Location.Latitude != nil && record.Location.Longitude != nil {
+		location.Latitude = *record.Location.Latitude
+		location.Longitude = *record.Location.Longitude
+		location.HasCoordinates = true
+	}
+
+	return location, nil
+}
@@ -0,0 +1,119 @@
+package risk
+
+import (
+	"auth-service/internal/audit"
+	"auth-service/internal/model"
+	"context"
+	commons "github.com/vpnbeast/golang-commons"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"strings"
+)
+
+var logger = commons.GetLogger()
+
+// Guard keeps the login history of the users in the database and assesses their logins. Notifier receives a
+// login.suspicious event for the flagged logins whose action is not allow
+type Guard struct {
+	db       *gorm.DB
+	locator  Locator
+	config   Config
+	notifier audit.Recorder
+}
+
+// NewGuard creates a Guard, locator defaults to NoLocator and notifier defaults to audit.Discard if they are nil
+func NewGuard(db *gorm.DB, locator Locator, config Config, notifier audit.Recorder) *Guard {
+	if locator == nil {
+		locator = NoLocator
+	}
+
+	if notifier == nil {
+		notifier = audit.Discard
+	}
+
+	return &Guard{db: db, locator: locator, config: config, notifier: notifier}
+}
+
+// Assess locates the login and evaluates it against the last successful logins of the user. Login is not located if
+// the GeoIP lookup fails, so a broken database does not prevent the logins
+func (g *Guard) Assess(ctx context.Context, realmId uint, username string, login Login) (Assessment, error) {
+	location, err := g.locator.Locate(login.Ip)
+	if err != nil {
+		logger.Warn("an error occurred while locating login", zap.String("ip", login.Ip),
+			zap.String("error", err.Error()))
+		location = nil
+	}
+
+	history, err := History(g.db.WithContext(ctx), realmId, username, ResultSuccess, g.config.HistorySize, 0)
+	if err != nil {
+		return Assessment{}, err
+	}
+
+	return Evaluate(g.config, login, location, history), nil
+}
+
+// Record appends the login to the history of the user and notifies about it if the assessment requires
+func (g *Guard) Record(ctx context.Context, realmId uint, username string, login Login, result string,
+	assessment Assessment) error {
+	entry := &model.LoginHistory{
+		RealmId:     realmId,
+		UserName:    username,
+		Ip:          login.Ip,
+		UserAgent:   login.UserAgent,
+		Fingerprint: login.Fingerprint,
+		Result:      result,
+		Signals:     strings.Join(assessment.Signals, " "),
+		CreatedAt:   login.Time,
+	}
+	if location := assessment.Location; location != nil {
+		entry.Country = location.Country
+		if location.HasCoordinates {
+			entry.Latitude, entry.Longitude = &location.Latitude, &location.Longitude
+		}
+	}
+
+	if assessment.Flagged() && assessment.Action != ActionAllow {
+		g.notifier.Record(ctx, SuspiciousLoginEvent(realmId, username, assessment))
+	}
+
+	return g.db.WithContext(ctx).Create(entry).Error
+}
+
+// SuspiciousLoginEvent creates the audit event of a flagged login, it fails if the login is not allowed
+func SuspiciousLoginEvent(realmId uint, username string, assessment Assessment) audit.Event {
+	event := audit.Event{
+		Type:    audit.EventLoginSuspicious,
+		Outcome: audit.OutcomeSuccess,
+		RealmId: realmId,
+		Actor:   username,
+		Subject: username,
+		Details: map[string]string{
+			"signals": strings.Join(assessment.Signals, " "),
+			"action":  assessment.Action,
+			"rule":    assessment.Rule,
+		},
+	}
+	if assessment.Location != nil {
+		event.Details["country"] = assessment.Location.Country
+	}
+
+	if assessment.Action == ActionStepUp || assessment.Action == ActionBlock {
+		event.Outcome = audit.OutcomeFailure
+		event.Reason = assessment.Action
+	}
+
+	return event
+}
+
+// History returns the logins of the user, newest first. Only the logins with the result are returned if it is not
+// empty
+func History(db *gorm.DB, realmId uint, username, result string, limit, offset int) ([]model.LoginHistory, error) {
+	query := db.Where("realm_id = ? AND user_name = ?", realmId, username)
+	if result != "" {
+		query = query.Where("result = ?", result)
+	}
+
+	history := make([]model.LoginHistory, 0)
+	err := query.Order("created_at DESC, id DESC").Limit(limit).Offset(offset).Find(&history).Error
+	return history, err
+}
@@ -0,0 +1,284 @@
+package risk
+
+import (
+	"auth-service/internal/model"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"math"
+	"os"
+	"time"
+)
+
+// signals which flag a login as suspicious
+const (
+	SignalNewDevice        = "new_device"
+	SignalNewCountry       = "new_country"
+	SignalImpossibleTravel = "impossible_travel"
+	SignalUnusualHour      = "unusual_hour"
+)
+
+// actions which are taken on the flagged logins, from the least to the most severe
+const (
+	ActionAllow  = "allow"
+	ActionNotify = "notify"
+	ActionStepUp = "step_up"
+	ActionBlock  = "block"
+)
+
+// results of the logins in the history
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+	ResultStepUp  = "step_up"
+	ResultBlocked = "blocked"
+)
+
+const (
+	earthRadiusKm = 6371
+	// minTravelDistanceKm ignores the jumps between the nearby locations, since GeoIP is not accurate enough for them
+	minTravelDistanceKm = 100
+	// minUsualHourLogins is the number of the successful logins needed before the login hours of a user are known
+	minUsualHourLogins = 5
+)
+
+var severities = map[string]int{ActionAllow: 0, ActionNotify: 1, ActionStepUp: 2, ActionBlock: 3}
+
+// Location is the approximate location of an ip
+type Location struct {
+	Country   string
+	Latitude  float64
+	Longitude float64
+	// HasCoordinates is false for the databases which only contain the countries
+	HasCoordinates bool
+}
+
+// Login represents the client of a password login
+type Login struct {
+	Time        time.Time
+	Ip          string
+	UserAgent   string
+	Fingerprint string
+}
+
+// Assessment is the result of the evaluation of a login, Rule is the name of the rule which decided the action
+type Assessment struct {
+	Signals  []string
+	Action   string
+	Rule     string
+	Location *Location
+}
+
+// Flagged checks if the login has any signal
+func (a Assessment) Flagged() bool {
+	return len(a.Signals) != 0
+}
+
+// Rule takes the action on the logins which have all the signals of the rule
+type Rule struct {
+	Name    string   `yaml:"name"`
+	Signals []string `yaml:"signals"`
+	Action  string   `yaml:"action"`
+}
+
+// Config represents the rules of the risk evaluation, HistorySize is the number of the last successful logins which
+// a login is compared with
+type Config struct {
+	MaxTravelSpeedKmh float64 `yaml:"maxTravelSpeedKmh"`
+	HistorySize       int     `yaml:"historySize"`
+	Rules             []Rule  `yaml:"rules"`
+}
+
+// DefaultConfig only notifies about the flagged logins
+func DefaultConfig() Config {
+	return Config{
+		MaxTravelSpeedKmh: 900,
+		HistorySize:       50,
+		Rules:             []Rule{{Name: "notify-suspicious-login", Action: ActionNotify}},
+	}
+}
+
+type configFile struct {
+	Risk Config `yaml:"risk"`
+}
+
+// LoadConfig reads the rules from the yaml file, missing values are taken from DefaultConfig. A rule without signals
+// matches every flagged login
+func LoadConfig(path string) (Config, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+
+	file := configFile{Risk: DefaultConfig()}
+	if err := yaml.Unmarshal([]byte(os.ExpandEnv(string(content))), &file); err != nil {
+		return Config{}, err
+	}
+
+	for i, rule := range file.Risk.Rules {
+		if rule.Name == "" {
+			return Config{}, fmt.Errorf("rule %d: name is required", i)
+		}
+
+		if _, ok := severities[rule.Action]; !ok {
+			return Config{}, fmt.Errorf("rule %s: unsupported action %q", rule.Name, rule.Action)
+		}
+
+		for _, signal := range rule.Signals {
+			switch signal {
+			case SignalNewDevice, SignalNewCountry, SignalImpossibleTravel, SignalUnusualHour:
+			default:
+				return Config{}, fmt.Errorf("rule %s: unsupported signal %q", rule.Name, signal)
+			}
+		}
+	}
+
+	return file.Risk, nil
+}
+
+// Fingerprint identifies the device of a login by the device id sent by the client, or by its user agent if the
+// client does not send one
+func Fingerprint(deviceId, userAgent string) string {
+	source := "ua:" + userAgent
+	if deviceId != "" {
+		source = "device:" + deviceId
+	}
+
+	sum := sha256.Sum256([]byte(source))
+	return hex.EncodeToString(sum[:16])
+}
+
+// Evaluate flags the login by comparing it with the previous successful logins of the user, newest first, and takes
+// the most severe action of the matching rules. The first login of a user is never flagged
+func Evaluate(config Config, login Login, location *Location, history []model.LoginHistory) Assessment {
+	assessment := Assessment{Action: ActionAllow, Location: location}
+	if len(history) == 0 {
+		return assessment
+	}
+
+	if !seenDevice(login.Fingerprint, history) {
+		assessment.Signals = append(assessment.Signals, SignalNewDevice)
+	}
+
+	if location != nil && location.Country != "" && !seenCountry(location.Country, history) {
+		assessment.Signals = append(assessment.Signals, SignalNewCountry)
+	}
+
+	if location != nil && location.HasCoordinates && impossibleTravel(config, login, location, history) {
+		assessment.Signals = append(assessment.Signals, SignalImpossibleTravel)
+	}
+
+	if unusualHour(login.Time, history) {
+		assessment.Signals = append(assessment.Signals, SignalUnusualHour)
+	}
+
+	if !assessment.Flagged() {
+		return assessment
+	}
+
+	for _, rule := range config.Rules {
+		if matches(rule, assessment.Signals) && severities[rule.Action] > severities[assessment.Action] {
+			assessment.Action = rule.Action
+			assessment.Rule = rule.Name
+		}
+	}
+
+	return assessment
+}
+
+func seenDevice(fingerprint string, history []model.LoginHistory) bool {
+	for _, h := range history {
+		if h.Fingerprint == fingerprint {
+			return true
+		}
+	}
+
+	return false
+}
+
+// seenCountry also returns true if none of the previous logins could be located, so enabling GeoIP does not flag all
+// the users at once
+func seenCountry(country string, history []model.LoginHistory) bool {
+	located := false
+	for _, h := range history {
+		if h.Country == country {
+			return true
+		}
+		located = located || h.Country != ""
+	}
+
+	return !located
+}
+
+// impossibleTravel checks if the user must have traveled faster than MaxTravelSpeedKmh since the last located login
+func impossibleTravel(config Config, login Login, location *Location, history []model.LoginHistory) bool {
+	for _, h := range history {
+		if h.Latitude == nil || h.Longitude == nil {
+			continue
+		}
+
+		distance := distanceKm(*h.Latitude, *h.Longitude, location.Latitude, location.Longitude)
+		if distance < minTravelDistanceKm {
+			return false
+		}
+
+		hours := login.Time.Sub(h.CreatedAt).Hours()
+		return hours <= 0 || distance/hours > config.MaxTravelSpeedKmh
+	}
+
+	return false
+}
+
+// unusualHour checks if none of the previous logins were within an hour of the login, hours are compared in UTC
+func unusualHour(t time.Time, history []model.LoginHistory) bool {
+	if len(history) < minUsualHourLogins {
+		return false
+	}
+
+	hour := t.UTC().Hour()
+	for _, h := range history {
+		diff := hour - h.CreatedAt.UTC().Hour()
+		if diff < 0 {
+			diff = -diff
+		}
+
+		if diff <= 1 || diff >= 23 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// distanceKm is the great circle distance between the coordinates
+func distanceKm(lat1, lon1, lat2, lon2 float64) float64 {
+	toRadians := func(degrees float64) float64 {
+		return degrees * math.Pi / 180
+	}
+
+	dLat := toRadians(lat2 - lat1)
+	dLon := toRadians(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
+
+func matches(rule Rule, signals []string) bool {
+	for _, required := range rule.Signals {
+		found := false
+		for _, signal := range signals {
+			if signal == required {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
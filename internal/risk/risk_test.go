@@ -0,0 +1,104 @@
+package risk
+
+import (
+	"auth-service/internal/model"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+var testNow = time.Date(2022, 2, 1, 10, 0, 0, 0, time.UTC)
+
+func coordinate(value float64) *float64 {
+	return &value
+}
+
+// istanbulHistory is a user who always logs in from Istanbul with the same browser around 10:00
+func istanbulHistory() []model.LoginHistory {
+	history := make([]model.LoginHistory, 0, 5)
+	for i := 1; i <= 5; i++ {
+		history = append(history, model.LoginHistory{
+			Fingerprint: Fingerprint("", "firefox"),
+			Country:     "TR",
+			Latitude:    coordinate(41.01),
+			Longitude:   coordinate(28.97),
+			Result:      ResultSuccess,
+			CreatedAt:   testNow.AddDate(0, 0, -i),
+		})
+	}
+
+	return history
+}
+
+func TestEvaluate(t *testing.T) {
+	istanbul := &Location{Country: "TR", Latitude: 41.01, Longitude: 28.97, HasCoordinates: true}
+	newYork := &Location{Country: "US", Latitude: 40.71, Longitude: -74.00, HasCoordinates: true}
+	firefox := Fingerprint("", "firefox")
+	config := Config{
+		MaxTravelSpeedKmh: 900,
+		Rules: []Rule{
+			{Name: "notify", Action: ActionNotify},
+			{Name: "block-travel", Signals: []string{SignalImpossibleTravel}, Action: ActionBlock},
+			{Name: "step-up-device", Signals: []string{SignalNewDevice, SignalNewCountry}, Action: ActionStepUp},
+		},
+	}
+
+	cases := []struct {
+		name     string
+		login    Login
+		location *Location
+		history  []model.LoginHistory
+		signals  []string
+		action   string
+	}{
+		{"first login", Login{Time: testNow, Fingerprint: "new"}, newYork, nil, nil, ActionAllow},
+		{"usual login", Login{Time: testNow, Fingerprint: firefox}, istanbul, istanbulHistory(), nil, ActionAllow},
+		{"new device", Login{Time: testNow, Fingerprint: Fingerprint("phone", "firefox")}, istanbul,
+			istanbulHistory(), []string{SignalNewDevice}, ActionNotify},
+		{"unusual hour", Login{Time: testNow.Add(-6 * time.Hour), Fingerprint: firefox}, nil, istanbulHistory(),
+			[]string{SignalUnusualHour}, ActionNotify},
+		{"impossible travel", Login{Time: testNow.AddDate(0, 0, -1).Add(time.Hour), Fingerprint: firefox}, newYork,
+			istanbulHistory(), []string{SignalNewCountry, SignalImpossibleTravel}, ActionBlock},
+		{"new device from new country", Login{Time: testNow.AddDate(0, 0, 1), Fingerprint: "new"}, newYork,
+			istanbulHistory(), []string{SignalNewDevice, SignalNewCountry}, ActionStepUp},
+	}
+
+	for _, c := range cases {
+		assessment := Evaluate(config, c.login, c.location, c.history)
+		if !reflect.DeepEqual(assessment.Signals, c.signals) {
+			t.Errorf("%s: expected signals %v, got %v", c.name, c.signals, assessment.Signals)
+		}
+
+		if assessment.Action != c.action {
+			t.Errorf("%s: expected action %s, got %s", c.name, c.action, assessment.Action)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "risk.yaml")
+	content := "risk:\n  rules:\n    - name: block-travel\n      signals: [impossible_travel]\n      action: block\n"
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.MaxTravelSpeedKmh != 900 || len(config.Rules) != 1 || config.Rules[0].Action != ActionBlock {
+		t.Errorf("expected the rules to be loaded over the defaults, got %+v", config)
+	}
+
+	content = "risk:\n  rules:\n    - name: unknown\n      signals: [tor_exit_node]\n      action: block\n"
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadConfig(path); err == nil {
+		t.Error("expected unknown signal to be rejected")
+	}
+}
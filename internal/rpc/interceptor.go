@@ -0,0 +1,204 @@
+package rpc
+
+import (
+	authv1 "auth-service/api/proto/auth/v1"
+	"auth-service/internal/audit"
+	"auth-service/internal/auth"
+	"auth-service/internal/jwt"
+	"auth-service/internal/realm"
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	// realmMetadataKey selects the realm of the call, the default realm is used if it is missing
+	realmMetadataKey = "x-realm"
+	// authorizationMetadataKey carries the bearer token of the calls which require a session
+	authorizationMetadataKey = "authorization"
+	bearerPrefix             = "Bearer "
+	userAgentMetadataKey     = "user-agent"
+	requestIdMetadataKey     = "x-request-id"
+	deviceIdMetadataKey      = "x-device-id"
+
+	methodAuthenticate = "/vpnbeast.auth.v1.AuthService/Authenticate"
+	methodRefresh      = "/vpnbeast.auth.v1.AuthService/Refresh"
+	methodValidate     = "/vpnbeast.auth.v1.AuthService/Validate"
+	methodWhoAmI       = "/vpnbeast.auth.v1.AuthService/WhoAmI"
+	methodLogout       = "/vpnbeast.auth.v1.AuthService/Logout"
+)
+
+type (
+	realmKey  struct{}
+	tokenKey  struct{}
+	claimsKey struct{}
+)
+
+var requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name: "auth_service_grpc_request_duration_seconds",
+	Help: "Duration of the gRPC calls by method and status code",
+}, []string{"method", "code"})
+
+// tokenAuthenticators maps the methods which require a bearer token to the way the token is checked. Refresh only
+// accepts a refresh token, Logout accepts both tokens of a session and WhoAmI accepts an access token or a personal
+// access token
+var tokenAuthenticators = map[string]func(s *auth.AuthService, ctx context.Context, r *realm.Realm,
+	token string) (*jwt.VpnbeastClaim, error){
+	methodRefresh: (*auth.AuthService).ValidateRefreshToken,
+	methodLogout:  (*auth.AuthService).ValidateSession,
+	methodWhoAmI:  (*auth.AuthService).AuthenticateToken,
+}
+
+// loggingInterceptor logs the method, status code and duration of every call
+func loggingInterceptor(logger *zap.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		logger.Info("grpc call", zap.String("method", info.FullMethod),
+			zap.String("code", status.Code(err).String()), zap.Duration("duration", time.Since(start)))
+		return resp, err
+	}
+}
+
+// metricsInterceptor observes the duration of every call
+func metricsInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		requestDuration.WithLabelValues(info.FullMethod, status.Code(err).String()).
+			Observe(time.Since(start).Seconds())
+		return resp, err
+	}
+}
+
+// auditInterceptor stores the client of the call in the context, so the audit events of the call carry its peer
+// address, user agent and request id
+func auditInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		request := audit.Request{
+			Ip:        peerIp(ctx),
+			UserAgent: firstValue(md, userAgentMetadataKey),
+			RequestId: firstValue(md, requestIdMetadataKey),
+		}
+
+		return handler(audit.WithRequest(ctx, request), req)
+	}
+}
+
+// realmInterceptor resolves the realm of the auth service calls, health and reflection calls are passed through
+func realmInterceptor(realms *realm.Registry) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		if !strings.HasPrefix(info.FullMethod, "/"+authv1.AuthService_ServiceDesc.ServiceName+"/") {
+			return handler(ctx, req)
+		}
+
+		md, _ := metadata.FromIncomingContext(ctx)
+		r, err := resolveRealm(realms, md)
+		if err != nil {
+			return nil, err
+		}
+
+		return handler(context.WithValue(ctx, realmKey{}, r), req)
+	}
+}
+
+// authInterceptor checks the bearer token of the methods in tokenAuthenticators, other calls are passed through
+func authInterceptor(service *auth.AuthService) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		authenticate, ok := tokenAuthenticators[info.FullMethod]
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		md, _ := metadata.FromIncomingContext(ctx)
+		token := firstValue(md, authorizationMetadataKey)
+		if !strings.HasPrefix(token, bearerPrefix) {
+			return nil, status.Error(codes.Unauthenticated, "bearer token is required")
+		}
+		token = strings.TrimPrefix(token, bearerPrefix)
+
+		claims, err := authenticate(service, ctx, currentRealm(ctx), token)
+		if err != nil {
+			return nil, toStatus(err)
+		}
+
+		ctx = context.WithValue(ctx, tokenKey{}, token)
+		ctx = context.WithValue(ctx, claimsKey{}, claims)
+		return handler(ctx, req)
+	}
+}
+
+func resolveRealm(realms *realm.Registry, md metadata.MD) (*realm.Realm, error) {
+	name := firstValue(md, realmMetadataKey)
+	if name == "" {
+		r, err := realms.Default()
+		if err != nil {
+			return nil, toStatus(err)
+		}
+
+		return r, nil
+	}
+
+	r, err := realms.ByName(name)
+	if err == realm.ErrRealmNotFound {
+		return nil, status.Errorf(codes.NotFound, "realm %s not found", name)
+	}
+
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return r, nil
+}
+
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) != 0 {
+		return values[0]
+	}
+
+	return ""
+}
+
+func currentRealm(ctx context.Context) *realm.Realm {
+	r, _ := ctx.Value(realmKey{}).(*realm.Realm)
+	return r
+}
+
+func bearerToken(ctx context.Context) string {
+	token, _ := ctx.Value(tokenKey{}).(string)
+	return token
+}
+
+// peerIp returns the ip of the peer of the call without the port
+func peerIp(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+
+	if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+		return host
+	}
+
+	return p.Addr.String()
+}
+
+func deviceId(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	return firstValue(md, deviceIdMetadataKey)
+}
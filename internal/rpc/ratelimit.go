@@ -0,0 +1,87 @@
+package rpc
+
+import (
+	authv1 "auth-service/api/proto/auth/v1"
+	"auth-service/internal/ratelimit"
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"math"
+	"strconv"
+)
+
+const (
+	// clientIdMetadataKey identifies the client application for the client id keyed rate limits
+	clientIdMetadataKey   = "x-client-id"
+	retryAfterMetadataKey = "retry-after"
+)
+
+// rateLimitRoutes maps the rate limited methods to the routes of their policies, so the calls share the counters of
+// the rest endpoints
+var rateLimitRoutes = map[string]string{
+	methodAuthenticate: "/auth/authenticate",
+	methodRefresh:      "/auth/refresh",
+	methodValidate:     "/auth/validate",
+}
+
+// rateLimitKey returns the key of the call for the policy, an empty key means the policy does not apply to the call.
+// Keys are scoped by the realm, subject keyed policies do not apply since the limits are checked before the token
+func rateLimitKey(ctx context.Context, req interface{}, policy ratelimit.Policy) string {
+	var key string
+	switch policy.Key {
+	case ratelimit.KeyIp:
+		key = peerIp(ctx)
+	case ratelimit.KeyUsername:
+		if authReq, ok := req.(*authv1.AuthenticateRequest); ok {
+			key = authReq.GetUsername()
+		}
+	case ratelimit.KeyClientId:
+		md, _ := metadata.FromIncomingContext(ctx)
+		key = firstValue(md, clientIdMetadataKey)
+	}
+
+	if key == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("%d:%s", currentRealm(ctx).Id, key)
+}
+
+// rateLimitInterceptor checks the calls of rateLimitRoutes against the policies of their routes and refuses them
+// with ResourceExhausted if any of them is exceeded, calls are allowed if the limiter is nil or the backend fails
+func rateLimitInterceptor(limiter *ratelimit.Limiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo,
+		handler grpc.UnaryHandler) (interface{}, error) {
+		route, ok := rateLimitRoutes[info.FullMethod]
+		if limiter == nil || !ok {
+			return handler(ctx, req)
+		}
+
+		for _, policy := range limiter.Policies(route) {
+			key := rateLimitKey(ctx, req, policy)
+			if key == "" {
+				continue
+			}
+
+			result, err := limiter.Allow(ctx, policy, key)
+			if err != nil {
+				logger.Warn("an error occurred while checking rate limit", zap.String("route", route),
+					zap.String("error", err.Error()))
+				continue
+			}
+
+			if !result.Allowed {
+				retryAfter := strconv.Itoa(int(math.Ceil(result.RetryAfter.Seconds())))
+				_ = grpc.SetHeader(ctx, metadata.Pairs(retryAfterMetadataKey, retryAfter))
+				return nil, status.Errorf(codes.ResourceExhausted, "too many requests, retry after %s seconds",
+					retryAfter)
+			}
+		}
+
+		return handler(ctx, req)
+	}
+}
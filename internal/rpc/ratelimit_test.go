@@ -0,0 +1,57 @@
+package rpc
+
+import (
+	authv1 "auth-service/api/proto/auth/v1"
+	"auth-service/internal/model"
+	"auth-service/internal/ratelimit"
+	"auth-service/internal/realm"
+	"context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"net"
+	"testing"
+)
+
+func TestRateLimitInterceptor(t *testing.T) {
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryBackend(nil), []ratelimit.Policy{
+		{Route: "/auth/authenticate", Key: ratelimit.KeyUsername, Algorithm: ratelimit.AlgorithmSlidingWindow,
+			Limit: 1, WindowSeconds: 60},
+		{Route: "/auth/validate", Key: ratelimit.KeyIp, Algorithm: ratelimit.AlgorithmTokenBucket, Limit: 1,
+			WindowSeconds: 60},
+	})
+	interceptor := rateLimitInterceptor(limiter)
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return "ok", nil }
+
+	call := func(ip, method string, req interface{}) codes.Code {
+		ctx := context.WithValue(context.Background(), realmKey{}, &realm.Realm{Realm: model.Realm{Id: 1}})
+		ctx = peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 40000}})
+		_, err := interceptor(ctx, req, &grpc.UnaryServerInfo{FullMethod: method}, handler)
+		return status.Code(err)
+	}
+
+	cases := []struct {
+		name, ip, method string
+		req              interface{}
+		code             codes.Code
+	}{
+		{"first login", "10.0.0.1", methodAuthenticate, &authv1.AuthenticateRequest{Username: "john"},
+			codes.OK},
+		{"same username from another ip", "10.0.0.2", methodAuthenticate,
+			&authv1.AuthenticateRequest{Username: "john"}, codes.ResourceExhausted},
+		{"another username", "10.0.0.2", methodAuthenticate, &authv1.AuthenticateRequest{Username: "jane"},
+			codes.OK},
+		{"first validation", "10.0.0.1", methodValidate, &authv1.ValidateRequest{}, codes.OK},
+		{"validation from the same ip", "10.0.0.1", methodValidate, &authv1.ValidateRequest{},
+			codes.ResourceExhausted},
+		{"validation from another ip", "10.0.0.2", methodValidate, &authv1.ValidateRequest{}, codes.OK},
+		{"method without policies", "10.0.0.1", methodWhoAmI, &authv1.WhoAmIRequest{}, codes.OK},
+	}
+
+	for _, c := range cases {
+		if code := call(c.ip, c.method, c.req); code != c.code {
+			t.Errorf("%s: expected %v, got %v", c.name, c.code, code)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package rpc
+
+import (
+	authv1 "auth-service/api/proto/auth/v1"
+	"auth-service/internal/auth"
+	"auth-service/internal/ratelimit"
+	"auth-service/internal/realm"
+	commons "github.com/vpnbeast/golang-commons"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+)
+
+var logger = commons.GetLogger()
+
+// NewServer creates the gRPC server with the auth service, health checking and reflection registered. Calls are
+// logged, measured, rate limited and authenticated by the interceptors in this order, the limiter is nil if rate
+// limiting is disabled
+func NewServer(service *auth.AuthService, realms *realm.Registry, limiter *ratelimit.Limiter) *grpc.Server {
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		loggingInterceptor(logger),
+		metricsInterceptor(),
+		auditInterceptor(),
+		realmInterceptor(realms),
+		rateLimitInterceptor(limiter),
+		authInterceptor(service),
+	))
+
+	authv1.RegisterAuthServiceServer(server, &authServer{service: service})
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus(authv1.AuthService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(server, healthServer)
+
+	reflection.Register(server)
+	return server
+}
@@ -0,0 +1,64 @@
+package rpc
+
+import (
+	"auth-service/internal/auth"
+	"auth-service/internal/encryption"
+	"context"
+	"errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"net"
+	"testing"
+)
+
+func TestHealthCheckSkipsAuthentication(t *testing.T) {
+	listener := bufconn.Listen(1024 * 1024)
+	server := NewServer(nil, nil, nil)
+	go func() { _ = server.Serve(listener) }()
+	defer server.Stop()
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}), grpc.WithInsecure())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	res, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{
+		Service: "vpnbeast.auth.v1.AuthService",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Status != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("expected %v, got %v", healthpb.HealthCheckResponse_SERVING, res.Status)
+	}
+}
+
+func TestToStatus(t *testing.T) {
+	cases := []struct {
+		err  error
+		code codes.Code
+	}{
+		{auth.ErrInvalidToken, codes.Unauthenticated},
+		{auth.ErrTokenRevoked, codes.Unauthenticated},
+		{auth.ErrUserNotFound, codes.NotFound},
+		{auth.ErrInvalidCredentials, codes.Unauthenticated},
+		{auth.ErrUserDisabled, codes.PermissionDenied},
+		{auth.ErrUserLocked, codes.PermissionDenied},
+		{encryption.ErrCircuitOpen, codes.Unavailable},
+		{errors.New("connection refused"), codes.Internal},
+	}
+
+	for _, c := range cases {
+		if code := status.Code(toStatus(c.err)); code != c.code {
+			t.Errorf("expected %v for %v, got %v", c.code, c.err, code)
+		}
+	}
+}
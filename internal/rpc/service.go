@@ -0,0 +1,155 @@
+package rpc
+
+import (
+	authv1 "auth-service/api/proto/auth/v1"
+	"auth-service/internal/auth"
+	"auth-service/internal/encryption"
+	"auth-service/internal/jwt"
+	"auth-service/internal/model"
+	"context"
+	"errors"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// authServer implements authv1.AuthServiceServer on top of the auth.AuthService, which is shared with the REST
+// handlers
+type authServer struct {
+	authv1.UnimplementedAuthServiceServer
+	service *auth.AuthService
+}
+
+func (s *authServer) Authenticate(ctx context.Context, req *authv1.AuthenticateRequest) (*authv1.AuthenticateResponse,
+	error) {
+	if req.GetUsername() == "" || req.GetPassword() == "" {
+		return nil, status.Error(codes.InvalidArgument, "username and password are required")
+	}
+
+	user, err := s.service.Authenticate(ctx, currentRealm(ctx), auth.Credentials{
+		Username: req.GetUsername(),
+		Password: req.GetPassword(),
+		DeviceId: deviceId(ctx),
+	})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return toAuthenticateResponse(user), nil
+}
+
+func (s *authServer) Refresh(ctx context.Context, _ *authv1.RefreshRequest) (*authv1.AuthenticateResponse, error) {
+	user, err := s.service.Refresh(ctx, currentRealm(ctx), bearerToken(ctx))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return toAuthenticateResponse(user), nil
+}
+
+func (s *authServer) Validate(ctx context.Context, req *authv1.ValidateRequest) (*authv1.ValidateResponse, error) {
+	if req.GetToken() == "" {
+		return nil, status.Error(codes.InvalidArgument, "token is required")
+	}
+
+	claims, err := s.service.Validate(ctx, currentRealm(ctx), req.GetToken())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	entitlements := make(map[string]*authv1.Entitlement, len(claims.Entitlements))
+	for name, e := range claims.Entitlements {
+		entitlements[name] = &authv1.Entitlement{Value: e.Value, ExpiresAt: e.ExpiresAt}
+	}
+
+	return &authv1.ValidateResponse{
+		Username:     claims.Subject,
+		Roles:        claims.Roles,
+		Entitlements: entitlements,
+		Scope:        claims.Scope,
+	}, nil
+}
+
+func (s *authServer) WhoAmI(ctx context.Context, _ *authv1.WhoAmIRequest) (*authv1.User, error) {
+	user, err := s.service.WhoAmI(ctx, currentRealm(ctx), bearerToken(ctx))
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return toUser(user), nil
+}
+
+func (s *authServer) Logout(ctx context.Context, _ *authv1.LogoutRequest) (*authv1.LogoutResponse, error) {
+	user, err := s.service.Logout(ctx, currentRealm(ctx), currentClaims(ctx).Subject)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	logger.Info("sessions revoked", zap.String("user", user.UserName))
+	return &authv1.LogoutResponse{Username: user.UserName}, nil
+}
+
+// toStatus maps the errors of the auth package to the status codes, internal errors are logged and hidden
+func toStatus(err error) error {
+	var tokenErr *auth.TokenError
+	switch {
+	case errors.As(err, &tokenErr):
+		return status.Error(codes.Unauthenticated, tokenErr.Error())
+	case err == auth.ErrUserNotFound:
+		return status.Error(codes.NotFound, "user not found")
+	case err == auth.ErrInvalidCredentials, err == auth.ErrUserConflict:
+		return status.Error(codes.Unauthenticated, "invalid credentials")
+	case err == auth.ErrUserDisabled:
+		return status.Error(codes.PermissionDenied, "user is disabled")
+	case err == auth.ErrUserLocked:
+		return status.Error(codes.PermissionDenied, "user is locked")
+	case err == auth.ErrLoginBlocked:
+		return status.Error(codes.PermissionDenied, "login is blocked")
+	case err == auth.ErrStepUpRequired:
+		return status.Error(codes.Unauthenticated, "login requires a second factor")
+	case errors.Is(err, encryption.ErrUnavailable):
+		logger.Error("an error occurred while checking password", zap.String("error", err.Error()))
+		return status.Error(codes.Unavailable, "encryption service is unavailable")
+	default:
+		logger.Error("an error occurred while handling grpc call", zap.String("error", err.Error()))
+		return status.Error(codes.Internal, "unknown error occurred at the backend")
+	}
+}
+
+func toAuthenticateResponse(user *model.User) *authv1.AuthenticateResponse {
+	return &authv1.AuthenticateResponse{
+		User: toUser(user),
+		Tokens: &authv1.TokenPair{
+			AccessToken:           user.AccessToken,
+			AccessTokenExpiresAt:  user.AccessTokenExpiresAt,
+			RefreshToken:          user.RefreshToken,
+			RefreshTokenExpiresAt: user.RefreshTokenExpiresAt,
+		},
+	}
+}
+
+func toUser(user *model.User) *authv1.User {
+	roles := make([]string, 0, len(user.Roles))
+	for _, role := range user.Roles {
+		roles = append(roles, role.Name)
+	}
+
+	return &authv1.User{
+		Id:            uint64(user.Id),
+		Uuid:          user.Uuid,
+		Username:      user.UserName,
+		Email:         user.Email,
+		Enabled:       user.Enabled,
+		EmailVerified: user.EmailVerified,
+		LastLogin:     user.LastLogin,
+		Roles:         roles,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Version:       uint64(user.Version),
+	}
+}
+
+func currentClaims(ctx context.Context) *jwt.VpnbeastClaim {
+	claims, _ := ctx.Value(claimsKey{}).(*jwt.VpnbeastClaim)
+	return claims
+}
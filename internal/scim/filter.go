@@ -0,0 +1,338 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// attribute maps a filterable attribute of a resource onto a column
+type attribute struct {
+	column string
+	// wrap is a format string with a single %s which the condition on the column is placed in, used for the
+	// attributes which are stored in the related tables
+	wrap    string
+	boolean bool
+}
+
+var (
+	userAttributes = map[string]attribute{
+		"id":                {column: "uuid"},
+		"username":          {column: "user_name"},
+		"externalid":        {column: "external_id"},
+		"emails":            {column: "email"},
+		"emails.value":      {column: "email"},
+		"active":            {column: "enabled", boolean: true},
+		"meta.created":      {column: "created_at"},
+		"meta.lastmodified": {column: "updated_at"},
+	}
+	groupMembers = attribute{
+		column: "users.uuid",
+		wrap:   "id IN (SELECT users_roles.role_id FROM users_roles JOIN users ON users.id = users_roles.user_id WHERE %s)",
+	}
+	groupAttributes = map[string]attribute{
+		"id":                {column: "id"},
+		"displayname":       {column: "name"},
+		"members":           groupMembers,
+		"members.value":     groupMembers,
+		"meta.created":      {column: "created_at"},
+		"meta.lastmodified": {column: "updated_at"},
+	}
+	comparisons = map[string]string{
+		"eq": "=",
+		"ne": "<>",
+		"gt": ">",
+		"ge": ">=",
+		"lt": "<",
+		"le": "<=",
+	}
+	likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+)
+
+// compileFilter compiles the filter expression of RFC 7644 section 3.4.2.2 into a sql condition with its arguments.
+// Complex attribute filters like emails[type eq "work"] are not supported
+func compileFilter(filter string, attributes map[string]attribute) (string, []interface{}, error) {
+	tokens, err := tokenize(filter)
+	if err != nil {
+		return "", nil, err
+	}
+
+	p := &filterParser{tokens: tokens, attributes: attributes}
+	condition, err := p.parseOr()
+	if err != nil {
+		return "", nil, err
+	}
+
+	if p.pos != len(p.tokens) {
+		return "", nil, invalidFilter("unexpected %q", p.tokens[p.pos])
+	}
+
+	return condition, p.args, nil
+}
+
+func invalidFilter(format string, args ...interface{}) *Error {
+	return newError(http.StatusBadRequest, scimTypeInvalidFilter, format, args...)
+}
+
+// tokenize splits the filter into parentheses, quoted strings and words
+func tokenize(filter string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(filter); {
+		switch c := filter[i]; {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, string(c))
+			i++
+		case c == '"':
+			j := i + 1
+			for ; j < len(filter) && filter[j] != '"'; j++ {
+				if filter[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(filter) {
+				return nil, invalidFilter("unterminated string")
+			}
+			tokens = append(tokens, filter[i:j+1])
+			i = j + 1
+		default:
+			j := i
+			for j < len(filter) && !strings.ContainsRune(" \t()\"", rune(filter[j])) {
+				j++
+			}
+			tokens = append(tokens, filter[i:j])
+			i = j
+		}
+	}
+
+	return tokens, nil
+}
+
+type filterParser struct {
+	tokens     []string
+	pos        int
+	attributes map[string]attribute
+	args       []interface{}
+}
+
+func (p *filterParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+
+	return ""
+}
+
+func (p *filterParser) next() (string, error) {
+	if p.pos >= len(p.tokens) {
+		return "", invalidFilter("unexpected end of filter")
+	}
+
+	p.pos++
+	return p.tokens[p.pos-1], nil
+}
+
+func (p *filterParser) expect(token string) error {
+	t, err := p.next()
+	if err != nil {
+		return err
+	}
+
+	if t != token {
+		return invalidFilter("expected %q, found %q", token, t)
+	}
+
+	return nil
+}
+
+func (p *filterParser) parseOr() (string, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return "", err
+	}
+
+	for strings.EqualFold(p.peek(), "or") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return "", err
+		}
+		left = fmt.Sprintf("(%s OR %s)", left, right)
+	}
+
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (string, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return "", err
+	}
+
+	for strings.EqualFold(p.peek(), "and") {
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return "", err
+		}
+		left = fmt.Sprintf("(%s AND %s)", left, right)
+	}
+
+	return left, nil
+}
+
+func (p *filterParser) parseUnary() (string, error) {
+	negate := strings.EqualFold(p.peek(), "not")
+	if negate {
+		p.pos++
+		if p.peek() != "(" {
+			return "", invalidFilter("expected \"(\" after not")
+		}
+	}
+
+	if p.peek() != "(" {
+		return p.parseComparison()
+	}
+
+	p.pos++
+	condition, err := p.parseOr()
+	if err != nil {
+		return "", err
+	}
+
+	if err := p.expect(")"); err != nil {
+		return "", err
+	}
+
+	if negate {
+		return fmt.Sprintf("NOT (%s)", condition), nil
+	}
+
+	return condition, nil
+}
+
+func (p *filterParser) parseComparison() (string, error) {
+	path, err := p.next()
+	if err != nil {
+		return "", err
+	}
+
+	attr, ok := p.attributes[attributeName(path)]
+	if !ok {
+		return "", invalidFilter("unsupported attribute %q", path)
+	}
+
+	op, err := p.next()
+	if err != nil {
+		return "", err
+	}
+
+	var condition string
+	switch op = strings.ToLower(op); op {
+	case "pr":
+		condition = attr.column + " IS NOT NULL"
+		if !attr.boolean {
+			condition = fmt.Sprintf("(%s AND %s <> '')", condition, attr.column)
+		}
+	case "co", "sw", "ew":
+		value, err := p.parseValue()
+		if err != nil {
+			return "", err
+		}
+
+		s, ok := value.(string)
+		if !ok || attr.boolean {
+			return "", invalidFilter("%s requires a string value", op)
+		}
+
+		pattern := likeEscaper.Replace(s)
+		switch op {
+		case "co":
+			pattern = "%" + pattern + "%"
+		case "sw":
+			pattern = pattern + "%"
+		case "ew":
+			pattern = "%" + pattern
+		}
+		condition = attr.column + " LIKE ?"
+		p.args = append(p.args, pattern)
+	default:
+		comparison, ok := comparisons[op]
+		if !ok {
+			return "", invalidFilter("unsupported operator %q", op)
+		}
+
+		value, err := p.parseValue()
+		if err != nil {
+			return "", err
+		}
+
+		if _, isBool := value.(bool); attr.boolean != isBool && value != nil {
+			return "", invalidFilter("invalid value for %q", path)
+		}
+
+		switch {
+		case value == nil && op == "eq":
+			condition = attr.column + " IS NULL"
+		case value == nil && op == "ne":
+			condition = attr.column + " IS NOT NULL"
+		case value == nil:
+			return "", invalidFilter("%s requires a value", op)
+		default:
+			condition = fmt.Sprintf("%s %s ?", attr.column, comparison)
+			p.args = append(p.args, value)
+		}
+	}
+
+	if attr.wrap != "" {
+		condition = fmt.Sprintf(attr.wrap, condition)
+	}
+
+	return condition, nil
+}
+
+// parseValue parses a compValue, which is a json string, number, boolean or null
+func (p *filterParser) parseValue() (interface{}, error) {
+	token, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+
+	if strings.HasPrefix(token, `"`) {
+		var s string
+		if err := json.Unmarshal([]byte(token), &s); err != nil {
+			return nil, invalidFilter("invalid string %s", token)
+		}
+		return s, nil
+	}
+
+	if n, err := strconv.ParseFloat(token, 64); err == nil {
+		return n, nil
+	}
+
+	return nil, invalidFilter("invalid value %q", token)
+}
+
+// attributeName lowercases the attribute path and removes the schema urn prefix of the core schemas
+func attributeName(path string) string {
+	for _, schema := range []string{SchemaUser, SchemaGroup} {
+		if len(path) > len(schema) && strings.EqualFold(path[:len(schema)+1], schema+":") {
+			path = path[len(schema)+1:]
+			break
+		}
+	}
+
+	return strings.ToLower(path)
+}
@@ -0,0 +1,280 @@
+package scim
+
+import (
+	"auth-service/internal/model"
+	"errors"
+	"gorm.io/gorm"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ListGroups returns a page of the roles of the realm which match the filter, members are not loaded if excluded
+// since groups can be large
+func ListGroups(db *gorm.DB, realmId uint, query Query, excludeMembers bool) (*ListResponse, error) {
+	tx := db.Model(&model.Role{})
+	if !excludeMembers {
+		tx = tx.Preload("Users")
+	}
+
+	var roles []model.Role
+	total, err := list(tx, realmId, query, groupAttributes, &roles)
+	if err != nil {
+		return nil, err
+	}
+
+	query = query.normalize()
+	resources := make([]interface{}, 0, len(roles))
+	for i := range roles {
+		resources = append(resources, toGroupResource(&roles[i]))
+	}
+
+	return newListResponse(total, query, resources), nil
+}
+
+// GetGroup returns the role of the realm with the given id
+func GetGroup(db *gorm.DB, realmId uint, id string) (*Group, error) {
+	role, err := findGroup(db, realmId, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return toGroupResource(role), nil
+}
+
+// CreateGroup creates a role in the realm with the members
+func CreateGroup(db *gorm.DB, realmId uint, resource *Group) (*Group, error) {
+	if resource.DisplayName == "" {
+		return nil, invalidValue("displayName is required")
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	role := &model.Role{
+		RealmId:   realmId,
+		Name:      resource.DisplayName,
+		Version:   1,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := checkDisplayName(tx, realmId, resource.DisplayName); err != nil {
+			return err
+		}
+
+		if err := tx.Omit("Users").Create(role).Error; err != nil {
+			return err
+		}
+
+		return setMembers(tx, role, resource.Members)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toGroupResource(role), nil
+}
+
+// ReplaceGroup replaces the name and the members of the role
+func ReplaceGroup(db *gorm.DB, realmId uint, id, ifMatch string, resource *Group) (*Group, error) {
+	return modifyGroup(db, realmId, id, ifMatch, func(*Group) (*Group, error) {
+		return resource, nil
+	})
+}
+
+// PatchGroup applies the operations to the role
+func PatchGroup(db *gorm.DB, realmId uint, id, ifMatch string, patch PatchRequest) (*Group, error) {
+	if err := patch.validate(); err != nil {
+		return nil, err
+	}
+
+	return modifyGroup(db, realmId, id, ifMatch, func(current *Group) (*Group, error) {
+		for _, op := range patch.Operations {
+			if err := current.applyPatch(op); err != nil {
+				return nil, err
+			}
+		}
+
+		return current, nil
+	})
+}
+
+// DeleteGroup deletes the role with its memberships, permissions and inheritance
+func DeleteGroup(db *gorm.DB, realmId uint, id, ifMatch string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		role, err := findGroup(tx, realmId, id)
+		if err != nil {
+			return err
+		}
+
+		if !matchesVersion(ifMatch, role.Version) {
+			return ErrPreconditionFailed
+		}
+
+		if err := tx.Exec("DELETE FROM users_roles WHERE role_id = ?", role.Id).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("role_id = ?", role.Id).Delete(&model.RolePermission{}).Error; err != nil {
+			return err
+		}
+
+		err = tx.Where("role_id = ? OR parent_role_id = ?", role.Id, role.Id).Delete(&model.RoleParent{}).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Delete(&model.Role{}, role.Id).Error
+	})
+}
+
+// modifyGroup loads the role, builds the new state of it and stores it in a single transaction
+func modifyGroup(db *gorm.DB, realmId uint, id, ifMatch string, modify func(current *Group) (*Group, error)) (*Group,
+	error) {
+	var result *Group
+	err := db.Transaction(func(tx *gorm.DB) error {
+		role, err := findGroup(tx, realmId, id)
+		if err != nil {
+			return err
+		}
+
+		if !matchesVersion(ifMatch, role.Version) {
+			return ErrPreconditionFailed
+		}
+
+		resource, err := modify(toGroupResource(role))
+		if err != nil {
+			return err
+		}
+
+		if resource.DisplayName == "" {
+			return invalidValue("displayName is required")
+		}
+
+		if resource.DisplayName != role.Name {
+			if err := checkDisplayName(tx, realmId, resource.DisplayName); err != nil {
+				return err
+			}
+		}
+
+		updatedAt := time.Now().Format(time.RFC3339)
+		update := tx.Model(&model.Role{}).Where("id = ? AND version = ?", role.Id, role.Version).
+			Updates(map[string]interface{}{
+				"name":       resource.DisplayName,
+				"version":    role.Version + 1,
+				"updated_at": updatedAt,
+			})
+		if update.Error != nil {
+			return update.Error
+		}
+
+		if update.RowsAffected == 0 {
+			return ErrPreconditionFailed
+		}
+
+		role.Name = resource.DisplayName
+		role.Version++
+		role.UpdatedAt = updatedAt
+		if err := setMembers(tx, role, resource.Members); err != nil {
+			return err
+		}
+
+		result = toGroupResource(role)
+		return nil
+	})
+
+	return result, err
+}
+
+// setMembers replaces the users of the role, members must be the users of the same realm
+func setMembers(tx *gorm.DB, role *model.Role, members []Reference) error {
+	ids := make([]string, 0, len(members))
+	for _, member := range members {
+		ids = append(ids, member.Value)
+	}
+
+	var users []*model.User
+	if len(ids) != 0 {
+		if err := tx.Where("realm_id = ? AND uuid IN ?", role.RealmId, ids).Find(&users).Error; err != nil {
+			return err
+		}
+	}
+
+	if len(users) != uniqueCount(ids) {
+		return invalidValue("members must be the users of the realm")
+	}
+
+	if err := tx.Exec("DELETE FROM users_roles WHERE role_id = ?", role.Id).Error; err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		err := tx.Table("users_roles").Create(map[string]interface{}{
+			"user_id": user.Id,
+			"role_id": role.Id,
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	role.Users = users
+	return nil
+}
+
+func uniqueCount(items []string) int {
+	unique := make(map[string]bool, len(items))
+	for _, item := range items {
+		unique[item] = true
+	}
+
+	return len(unique)
+}
+
+func checkDisplayName(db *gorm.DB, realmId uint, name string) error {
+	var count int64
+	err := db.Model(&model.Role{}).Where("realm_id = ? AND name = ?", realmId, name).Count(&count).Error
+	if err != nil {
+		return err
+	}
+
+	if count != 0 {
+		return newError(http.StatusConflict, scimTypeUniqueness, "displayName %q is already taken", name)
+	}
+
+	return nil
+}
+
+func findGroup(db *gorm.DB, realmId uint, id string) (*model.Role, error) {
+	roleId, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+
+	var role model.Role
+	err = db.Preload("Users").Where("realm_id = ? AND id = ?", realmId, roleId).First(&role).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+
+	return &role, err
+}
+
+func toGroupResource(role *model.Role) *Group {
+	resource := &Group{
+		Schemas:     []string{SchemaGroup},
+		Id:          strconv.FormatUint(uint64(role.Id), 10),
+		DisplayName: role.Name,
+		Meta: &Meta{
+			ResourceType: ResourceTypeGroup,
+			Created:      role.CreatedAt,
+			LastModified: role.UpdatedAt,
+			Version:      ETag(role.Version),
+		},
+	}
+
+	for _, user := range role.Users {
+		resource.Members = append(resource.Members, Reference{Value: user.Uuid, Display: user.UserName})
+	}
+
+	return resource
+}
@@ -0,0 +1,298 @@
+package scim
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	opAdd     = "add"
+	opReplace = "replace"
+	opRemove  = "remove"
+)
+
+// PatchRequest represents the body of a PATCH request
+type PatchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+// PatchOperation represents a single operation of a PATCH request, op is case insensitive since some identity
+// providers send Replace instead of replace
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+func invalidValue(format string, args ...interface{}) *Error {
+	return newError(http.StatusBadRequest, scimTypeInvalidValue, format, args...)
+}
+
+func invalidPath(path string) *Error {
+	return newError(http.StatusBadRequest, scimTypeInvalidPath, "unsupported path %q", path)
+}
+
+// validate checks the schema and the operation names of the request
+func (r PatchRequest) validate() error {
+	if !contains(r.Schemas, SchemaPatchOp) {
+		return newError(http.StatusBadRequest, scimTypeInvalidSyntax, "schemas must contain %s", SchemaPatchOp)
+	}
+
+	for _, op := range r.Operations {
+		switch strings.ToLower(op.Op) {
+		case opAdd, opReplace, opRemove:
+		default:
+			return newError(http.StatusBadRequest, scimTypeInvalidSyntax, "unsupported operation %q", op.Op)
+		}
+	}
+
+	return nil
+}
+
+// splitPath splits a path like members[value eq "x"] or emails[type eq "work"].value into the lowercased attribute,
+// the value filter and the lowercased sub attribute
+func splitPath(path string) (string, string, string) {
+	i := strings.Index(path, "[")
+	if i < 0 {
+		return attributeName(path), "", ""
+	}
+
+	j := strings.LastIndex(path, "]")
+	if j < i {
+		return attributeName(path), "", ""
+	}
+
+	return attributeName(path[:i]), path[i+1 : j], strings.ToLower(strings.TrimPrefix(path[j+1:], "."))
+}
+
+// eachValue applies the operation to the attributes of the value object if the operation has no path
+func eachValue(op PatchOperation, apply func(attr string, value json.RawMessage) error) error {
+	if op.Path != "" {
+		return apply("", nil)
+	}
+
+	if strings.ToLower(op.Op) == opRemove {
+		return newError(http.StatusBadRequest, scimTypeNoTarget, "remove requires a path")
+	}
+
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(op.Value, &values); err != nil {
+		return invalidValue("value must be an object when path is empty")
+	}
+
+	for name, value := range values {
+		if err := apply(attributeName(name), value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// applyPatch applies the operation to the user, attributes which are not stored are ignored
+func (u *User) applyPatch(op PatchOperation) error {
+	return eachValue(op, func(attr string, value json.RawMessage) error {
+		if attr == "" {
+			name, filter, sub := splitPath(op.Path)
+			if filter != "" && name != "emails" {
+				return invalidPath(op.Path)
+			}
+
+			attr, value = name, op.Value
+			if sub != "" {
+				attr += "." + sub
+			}
+		}
+
+		if strings.ToLower(op.Op) == opRemove {
+			return u.remove(attr)
+		}
+
+		return u.set(attr, value)
+	})
+}
+
+func (u *User) set(attr string, value json.RawMessage) error {
+	switch attr {
+	case "username":
+		return decodeString(value, &u.UserName)
+	case "externalid":
+		return decodeString(value, &u.ExternalId)
+	case "active":
+		active, err := decodeBool(value)
+		if err != nil {
+			return err
+		}
+		u.Active = &active
+	case "emails":
+		var emails []Email
+		if err := json.Unmarshal(value, &emails); err != nil {
+			return invalidValue("emails must be a list of emails")
+		}
+		u.Emails = emails
+	case "emails.value":
+		var email string
+		if err := decodeString(value, &email); err != nil {
+			return err
+		}
+		u.Emails = []Email{{Value: email, Primary: true}}
+	case "id", "groups", "meta":
+		return newError(http.StatusBadRequest, scimTypeMutability, "%s is read only", attr)
+	}
+
+	return nil
+}
+
+func (u *User) remove(attr string) error {
+	switch attr {
+	case "externalid":
+		u.ExternalId = ""
+	case "emails", "emails.value":
+		u.Emails = nil
+	case "username", "active", "id", "groups", "meta":
+		return newError(http.StatusBadRequest, scimTypeMutability, "%s can not be removed", attr)
+	}
+
+	return nil
+}
+
+// applyPatch applies the operation to the group
+func (g *Group) applyPatch(op PatchOperation) error {
+	return eachValue(op, func(attr string, value json.RawMessage) error {
+		filter := ""
+		if attr == "" {
+			var sub string
+			attr, filter, sub = splitPath(op.Path)
+			value = op.Value
+			if sub != "" {
+				return invalidPath(op.Path)
+			}
+		}
+
+		switch attr {
+		case "displayname":
+			if strings.ToLower(op.Op) == opRemove {
+				return newError(http.StatusBadRequest, scimTypeMutability, "displayName can not be removed")
+			}
+			return decodeString(value, &g.DisplayName)
+		case "members":
+			return g.patchMembers(strings.ToLower(op.Op), filter, value)
+		case "id", "meta":
+			return newError(http.StatusBadRequest, scimTypeMutability, "%s is read only", attr)
+		}
+
+		return nil
+	})
+}
+
+func (g *Group) patchMembers(op, filter string, value json.RawMessage) error {
+	if filter != "" {
+		if op != opRemove {
+			return invalidPath("members[" + filter + "]")
+		}
+
+		id, err := memberFilter(filter)
+		if err != nil {
+			return err
+		}
+		g.Members = withoutMembers(g.Members, []Reference{{Value: id}})
+		return nil
+	}
+
+	var members []Reference
+	if len(value) != 0 && string(value) != "null" {
+		if err := json.Unmarshal(value, &members); err != nil {
+			return invalidValue("members must be a list of references")
+		}
+	}
+
+	switch op {
+	case opAdd:
+		g.Members = append(withoutMembers(g.Members, members), members...)
+	case opReplace:
+		g.Members = members
+	case opRemove:
+		if len(members) == 0 {
+			g.Members = nil
+		} else {
+			g.Members = withoutMembers(g.Members, members)
+		}
+	}
+
+	return nil
+}
+
+// memberFilter parses the value eq "id" filter of a member path
+func memberFilter(filter string) (string, error) {
+	tokens, err := tokenize(filter)
+	if err != nil {
+		return "", err
+	}
+
+	if len(tokens) != 3 || !strings.EqualFold(tokens[0], "value") || !strings.EqualFold(tokens[1], "eq") {
+		return "", invalidPath("members[" + filter + "]")
+	}
+
+	var id string
+	if err := json.Unmarshal([]byte(tokens[2]), &id); err != nil {
+		return "", invalidPath("members[" + filter + "]")
+	}
+
+	return id, nil
+}
+
+func withoutMembers(members, removed []Reference) []Reference {
+	result := make([]Reference, 0, len(members))
+	for _, member := range members {
+		found := false
+		for _, r := range removed {
+			if r.Value == member.Value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			result = append(result, member)
+		}
+	}
+
+	return result
+}
+
+func decodeString(value json.RawMessage, target *string) error {
+	if err := json.Unmarshal(value, target); err != nil {
+		return invalidValue("expected a string")
+	}
+
+	return nil
+}
+
+// decodeBool decodes a boolean, "True" and "False" strings are accepted since Azure AD sends them
+func decodeBool(value json.RawMessage) (bool, error) {
+	var b bool
+	if err := json.Unmarshal(value, &b); err == nil {
+		return b, nil
+	}
+
+	var s string
+	if err := json.Unmarshal(value, &s); err == nil {
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b, nil
+		}
+	}
+
+	return false, invalidValue("expected a boolean")
+}
+
+func contains(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+
+	return false
+}
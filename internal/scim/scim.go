@@ -0,0 +1,173 @@
+package scim
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// schema urns of RFC 7643 and RFC 7644
+const (
+	SchemaUser            = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SchemaGroup           = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SchemaListResponse    = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SchemaPatchOp         = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SchemaError           = "urn:ietf:params:scim:api:messages:2.0:Error"
+	SchemaServiceProvider = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+)
+
+const (
+	ResourceTypeUser  = "User"
+	ResourceTypeGroup = "Group"
+	// DefaultCount is the page size if the count parameter is missing, MaxCount is the upper limit of it
+	DefaultCount = 100
+	MaxCount     = 1000
+
+	scimTypeInvalidFilter = "invalidFilter"
+	scimTypeInvalidPath   = "invalidPath"
+	scimTypeInvalidValue  = "invalidValue"
+	scimTypeUniqueness    = "uniqueness"
+	scimTypeInvalidSyntax = "invalidSyntax"
+	scimTypeNoTarget      = "noTarget"
+	scimTypeMutability    = "mutability"
+)
+
+// Error represents a SCIM error response, errors of the package which are caused by the request are of this type
+type Error struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	ScimType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+	code     int
+}
+
+func newError(code int, scimType, format string, args ...interface{}) *Error {
+	return &Error{
+		Schemas:  []string{SchemaError},
+		Status:   strconv.Itoa(code),
+		ScimType: scimType,
+		Detail:   fmt.Sprintf(format, args...),
+		code:     code,
+	}
+}
+
+// NewError creates an Error which is not specific to a resource, like an authentication or a parsing error
+func NewError(code int, detail string) *Error {
+	return newError(code, "", "%s", detail)
+}
+
+func (e *Error) Error() string {
+	return e.Detail
+}
+
+// Code returns the http status code of the error
+func (e *Error) Code() int {
+	return e.code
+}
+
+var (
+	// ErrNotFound is returned when the resource does not exist in the realm
+	ErrNotFound = newError(http.StatusNotFound, "", "resource not found")
+	// ErrPreconditionFailed is returned when the If-Match header does not match the current version of the resource
+	ErrPreconditionFailed = newError(http.StatusPreconditionFailed, "", "resource has been modified")
+)
+
+// Meta represents the metadata of a resource, Location is set by the caller since it depends on the request
+type Meta struct {
+	ResourceType string `json:"resourceType"`
+	Created      string `json:"created,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	Location     string `json:"location,omitempty"`
+	Version      string `json:"version"`
+}
+
+// Email represents an email address of a user, users have a single email so the first primary one is kept
+type Email struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+// Reference represents a member of a group or a group of a user
+type Reference struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+}
+
+// User represents the SCIM user resource, name and the other attributes which are not stored are ignored
+type User struct {
+	Schemas    []string    `json:"schemas"`
+	Id         string      `json:"id,omitempty"`
+	ExternalId string      `json:"externalId,omitempty"`
+	UserName   string      `json:"userName"`
+	Active     *bool       `json:"active,omitempty"`
+	Emails     []Email     `json:"emails,omitempty"`
+	Groups     []Reference `json:"groups,omitempty"`
+	Meta       *Meta       `json:"meta,omitempty"`
+}
+
+// Group represents the SCIM group resource, groups are the roles of the realm and members are its users
+type Group struct {
+	Schemas     []string    `json:"schemas"`
+	Id          string      `json:"id,omitempty"`
+	DisplayName string      `json:"displayName"`
+	Members     []Reference `json:"members,omitempty"`
+	Meta        *Meta       `json:"meta,omitempty"`
+}
+
+// ListResponse represents a page of the query results
+type ListResponse struct {
+	Schemas      []string      `json:"schemas"`
+	TotalResults int64         `json:"totalResults"`
+	StartIndex   int           `json:"startIndex"`
+	ItemsPerPage int           `json:"itemsPerPage"`
+	Resources    []interface{} `json:"Resources"`
+}
+
+// Query represents the filter and the pagination parameters of a list request, StartIndex is 1-based
+type Query struct {
+	Filter     string
+	StartIndex int
+	Count      int
+}
+
+// normalize applies the defaults and the limits of RFC 7644 section 3.4.2.4
+func (q Query) normalize() Query {
+	if q.StartIndex < 1 {
+		q.StartIndex = 1
+	}
+
+	if q.Count < 0 {
+		q.Count = 0
+	}
+
+	if q.Count > MaxCount {
+		q.Count = MaxCount
+	}
+
+	return q
+}
+
+// ETag returns the weak entity tag of the resource version
+func ETag(version uint) string {
+	return fmt.Sprintf(`W/"%d"`, version)
+}
+
+// matchesVersion checks the If-Match header against the version, empty header and * match every version
+func matchesVersion(ifMatch string, version uint) bool {
+	ifMatch = strings.TrimSpace(ifMatch)
+	if ifMatch == "" || ifMatch == "*" {
+		return true
+	}
+
+	for _, tag := range strings.Split(ifMatch, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == fmt.Sprintf(`"%d"`, version) {
+			return true
+		}
+	}
+
+	return false
+}
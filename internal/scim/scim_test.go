@@ -0,0 +1,122 @@
+package scim
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestCompileFilter(t *testing.T) {
+	cases := []struct {
+		filter    string
+		condition string
+		args      []interface{}
+	}{
+		{`userName eq "alice"`, "user_name = ?", []interface{}{"alice"}},
+		{`urn:ietf:params:scim:schemas:core:2.0:User:userName Eq "alice"`, "user_name = ?", []interface{}{"alice"}},
+		{`active eq false and emails.value co "50%"`, "(enabled = ? AND email LIKE ?)",
+			[]interface{}{false, `%50\%%`}},
+		{`externalId pr or not (userName sw "a")`,
+			"((external_id IS NOT NULL AND external_id <> '') OR NOT (user_name LIKE ?))", []interface{}{"a%"}},
+		{`meta.lastModified gt "2022-01-01T00:00:00Z"`, "updated_at > ?", []interface{}{"2022-01-01T00:00:00Z"}},
+	}
+
+	for _, c := range cases {
+		condition, args, err := compileFilter(c.filter, userAttributes)
+		if err != nil {
+			t.Errorf("%s: %v", c.filter, err)
+			continue
+		}
+
+		if condition != c.condition || !reflect.DeepEqual(args, c.args) {
+			t.Errorf("%s: expected %s %v, got %s %v", c.filter, c.condition, c.args, condition, args)
+		}
+	}
+
+	condition, args, err := compileFilter(`members eq "u-1"`, groupAttributes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "id IN (SELECT users_roles.role_id FROM users_roles JOIN users ON users.id = users_roles.user_id " +
+		"WHERE users.uuid = ?)"
+	if condition != expected || !reflect.DeepEqual(args, []interface{}{"u-1"}) {
+		t.Errorf("unexpected members condition %s %v", condition, args)
+	}
+
+	for _, filter := range []string{`password eq "x"`, `userName eq`, `userName xx "a"`, `(userName eq "a"`,
+		`userName eq "a" extra`, `active eq "true"`, `userName eq "unterminated`} {
+		if _, _, err := compileFilter(filter, userAttributes); err == nil {
+			t.Errorf("%s: expected an error", filter)
+		} else if e, ok := err.(*Error); !ok || e.ScimType != scimTypeInvalidFilter {
+			t.Errorf("%s: expected invalidFilter, got %v", filter, err)
+		}
+	}
+}
+
+func TestUserPatch(t *testing.T) {
+	var patch PatchRequest
+	err := json.Unmarshal([]byte(`{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [
+			{"op": "Replace", "path": "active", "value": "False"},
+			{"op": "replace", "path": "emails[type eq \"work\"].value", "value": "alice@example.org"},
+			{"op": "add", "value": {"externalId": "00u1", "name": {"givenName": "Alice"}}}
+		]
+	}`), &patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := patch.validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	user := &User{UserName: "alice"}
+	for _, op := range patch.Operations {
+		if err := user.applyPatch(op); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if user.Active == nil || *user.Active || user.ExternalId != "00u1" ||
+		primaryEmail(user.Emails) != "alice@example.org" {
+		t.Errorf("unexpected user after patch %+v", user)
+	}
+
+	if err := user.applyPatch(PatchOperation{Op: "remove", Path: "userName"}); err == nil {
+		t.Error("expected userName removal to be refused")
+	}
+}
+
+func TestGroupPatch(t *testing.T) {
+	group := &Group{DisplayName: "vpn-users", Members: []Reference{{Value: "a"}, {Value: "b"}}}
+	ops := []PatchOperation{
+		{Op: "add", Path: "members", Value: json.RawMessage(`[{"value": "b"}, {"value": "c"}]`)},
+		{Op: "remove", Path: `members[value eq "a"]`},
+		{Op: "replace", Value: json.RawMessage(`{"displayName": "vpn-admins"}`)},
+	}
+	for _, op := range ops {
+		if err := group.applyPatch(op); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if group.DisplayName != "vpn-admins" ||
+		!reflect.DeepEqual(group.Members, []Reference{{Value: "b"}, {Value: "c"}}) {
+		t.Errorf("unexpected group after patch %+v", group)
+	}
+
+	if err := group.applyPatch(PatchOperation{Op: "remove", Path: "members"}); err != nil || len(group.Members) != 0 {
+		t.Errorf("expected all members to be removed, got %v %v", group.Members, err)
+	}
+}
+
+func TestMatchesVersion(t *testing.T) {
+	for ifMatch, expected := range map[string]bool{"": true, "*": true, `W/"3"`: true, `"3"`: true, `W/"1", W/"3"`: true,
+		`W/"2"`: false} {
+		if matchesVersion(ifMatch, 3) != expected {
+			t.Errorf("%q: expected %v", ifMatch, expected)
+		}
+	}
+}
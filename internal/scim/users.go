@@ -0,0 +1,310 @@
+package scim
+
+import (
+	"auth-service/internal/account"
+	"auth-service/internal/model"
+	"auth-service/internal/revocation"
+	"errors"
+	"gorm.io/gorm"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ListUsers returns a page of the users of the realm which match the filter
+func ListUsers(db *gorm.DB, realmId uint, query Query) (*ListResponse, error) {
+	var users []model.User
+	total, err := list(db.Model(&model.User{}).Preload("Roles"), realmId, query, userAttributes, &users)
+	if err != nil {
+		return nil, err
+	}
+
+	query = query.normalize()
+	resources := make([]interface{}, 0, len(users))
+	for i := range users {
+		resources = append(resources, toUserResource(&users[i]))
+	}
+
+	return newListResponse(total, query, resources), nil
+}
+
+// GetUser returns the user of the realm with the given id
+func GetUser(db *gorm.DB, realmId uint, id string) (*User, error) {
+	user, err := findUser(db, realmId, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return toUserResource(user), nil
+}
+
+// CreateUser provisions the user into the realm, users created through SCIM have no password and log in through
+// their identity backend or a federated identity provider
+func CreateUser(db *gorm.DB, realmId uint, resource *User) (*User, error) {
+	if resource.UserName == "" {
+		return nil, invalidValue("userName is required")
+	}
+
+	user := &model.User{
+		RealmId:    realmId,
+		UserName:   resource.UserName,
+		Email:      primaryEmail(resource.Emails),
+		ExternalId: resource.ExternalId,
+		Version:    1,
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := checkUserName(tx, realmId, resource.UserName); err != nil {
+			return err
+		}
+
+		if err := account.Provision(tx, user, nil); err != nil {
+			return err
+		}
+
+		if resource.Active != nil && !*resource.Active {
+			user.Enabled = false
+			return tx.Model(user).Update("enabled", false).Error
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toUserResource(user), nil
+}
+
+// ReplaceUser replaces the stored attributes of the user, deactivating the user revokes its sessions
+func ReplaceUser(db *gorm.DB, realmId uint, id, ifMatch string, resource *User,
+	listeners ...revocation.Listener) (*User, error) {
+	return modifyUser(db, realmId, id, ifMatch, listeners, func(*User) (*User, error) {
+		return resource, nil
+	})
+}
+
+// PatchUser applies the operations to the user, deactivating the user revokes its sessions
+func PatchUser(db *gorm.DB, realmId uint, id, ifMatch string, patch PatchRequest,
+	listeners ...revocation.Listener) (*User, error) {
+	if err := patch.validate(); err != nil {
+		return nil, err
+	}
+
+	return modifyUser(db, realmId, id, ifMatch, listeners, func(current *User) (*User, error) {
+		for _, op := range patch.Operations {
+			if err := current.applyPatch(op); err != nil {
+				return nil, err
+			}
+		}
+
+		return current, nil
+	})
+}
+
+// DeleteUser deprovisions the user, the user is disabled instead of deleted so its audit trail is kept, and all of
+// its sessions are revoked
+func DeleteUser(db *gorm.DB, realmId uint, id, ifMatch string, listeners ...revocation.Listener) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		user, err := findUser(tx, realmId, id)
+		if err != nil {
+			return err
+		}
+
+		if !matchesVersion(ifMatch, user.Version) {
+			return ErrPreconditionFailed
+		}
+
+		err = updateVersion(tx, user, map[string]interface{}{"enabled": false})
+		if err != nil {
+			return err
+		}
+
+		user.Enabled = false
+		return revocation.RevokeSessions(tx, user, listeners...)
+	})
+}
+
+// modifyUser loads the user, builds the new state of it and stores it in a single transaction, listeners are notified
+// if the user is deactivated
+func modifyUser(db *gorm.DB, realmId uint, id, ifMatch string, listeners []revocation.Listener,
+	modify func(current *User) (*User, error)) (*User, error) {
+	var result *User
+	err := db.Transaction(func(tx *gorm.DB) error {
+		user, err := findUser(tx, realmId, id)
+		if err != nil {
+			return err
+		}
+
+		if !matchesVersion(ifMatch, user.Version) {
+			return ErrPreconditionFailed
+		}
+
+		resource, err := modify(toUserResource(user))
+		if err != nil {
+			return err
+		}
+
+		if resource.UserName == "" {
+			return invalidValue("userName is required")
+		}
+
+		if resource.UserName != user.UserName {
+			if err := checkUserName(tx, realmId, resource.UserName); err != nil {
+				return err
+			}
+		}
+
+		enabled := user.Enabled
+		if resource.Active != nil {
+			enabled = *resource.Active
+		}
+
+		deactivated := user.Enabled && !enabled
+		err = updateVersion(tx, user, map[string]interface{}{
+			"user_name":   resource.UserName,
+			"email":       primaryEmail(resource.Emails),
+			"external_id": resource.ExternalId,
+			"enabled":     enabled,
+		})
+		if err != nil {
+			return err
+		}
+
+		user.UserName = resource.UserName
+		user.Email = primaryEmail(resource.Emails)
+		user.ExternalId = resource.ExternalId
+		user.Enabled = enabled
+		if deactivated {
+			if err := revocation.RevokeSessions(tx, user, listeners...); err != nil {
+				return err
+			}
+		}
+
+		result = toUserResource(user)
+		return nil
+	})
+
+	return result, err
+}
+
+// updateVersion updates the columns of the user if it is not modified concurrently and increments its version
+func updateVersion(tx *gorm.DB, user *model.User, columns map[string]interface{}) error {
+	columns["version"] = user.Version + 1
+	columns["updated_at"] = time.Now().Format(time.RFC3339)
+	result := tx.Model(&model.User{}).Where("id = ? AND version = ?", user.Id, user.Version).Updates(columns)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return ErrPreconditionFailed
+	}
+
+	user.Version++
+	user.UpdatedAt = columns["updated_at"].(string)
+	return nil
+}
+
+func checkUserName(db *gorm.DB, realmId uint, userName string) error {
+	var count int64
+	err := db.Model(&model.User{}).Where("realm_id = ? AND user_name = ?", realmId, userName).Count(&count).Error
+	if err != nil {
+		return err
+	}
+
+	if count != 0 {
+		return newError(http.StatusConflict, scimTypeUniqueness, "userName %q is already taken", userName)
+	}
+
+	return nil
+}
+
+func findUser(db *gorm.DB, realmId uint, id string) (*model.User, error) {
+	var user model.User
+	err := db.Preload("Roles").Where("realm_id = ? AND uuid = ?", realmId, id).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+
+	return &user, err
+}
+
+func toUserResource(user *model.User) *User {
+	active := user.Enabled
+	resource := &User{
+		Schemas:    []string{SchemaUser},
+		Id:         user.Uuid,
+		ExternalId: user.ExternalId,
+		UserName:   user.UserName,
+		Active:     &active,
+		Meta: &Meta{
+			ResourceType: ResourceTypeUser,
+			Created:      user.CreatedAt,
+			LastModified: user.UpdatedAt,
+			Version:      ETag(user.Version),
+		},
+	}
+
+	if user.Email != "" {
+		resource.Emails = []Email{{Value: user.Email, Primary: true}}
+	}
+
+	for _, role := range user.Roles {
+		resource.Groups = append(resource.Groups, Reference{
+			Value:   strconv.FormatUint(uint64(role.Id), 10),
+			Display: role.Name,
+		})
+	}
+
+	return resource
+}
+
+// primaryEmail returns the primary email, or the first one if none of them is primary
+func primaryEmail(emails []Email) string {
+	for _, email := range emails {
+		if email.Primary {
+			return email.Value
+		}
+	}
+
+	if len(emails) != 0 {
+		return emails[0].Value
+	}
+
+	return ""
+}
+
+// list counts and loads a page of the resources of the realm which match the filter of the query
+func list(db *gorm.DB, realmId uint, query Query, attributes map[string]attribute, dest interface{}) (int64, error) {
+	query = query.normalize()
+	db = db.Where("realm_id = ?", realmId)
+	if query.Filter != "" {
+		condition, args, err := compileFilter(query.Filter, attributes)
+		if err != nil {
+			return 0, err
+		}
+		db = db.Where(condition, args...)
+	}
+
+	var total int64
+	if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return 0, err
+	}
+
+	if query.Count == 0 {
+		return total, nil
+	}
+
+	err := db.Order("id").Offset(query.StartIndex - 1).Limit(query.Count).Find(dest).Error
+	return total, err
+}
+
+func newListResponse(total int64, query Query, resources []interface{}) *ListResponse {
+	return &ListResponse{
+		Schemas:      []string{SchemaListResponse},
+		TotalResults: total,
+		StartIndex:   query.StartIndex,
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}
+}
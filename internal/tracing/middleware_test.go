@@ -0,0 +1,84 @@
+package tracing
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const testTraceId = "4bf92f3577b34da6a3ce929d0e0e4736"
+
+// setupTestProvider installs a tracer provider which samples everything into an in-memory exporter
+func setupTestProvider(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := NewTracerProvider(sdktrace.NewSimpleSpanProcessor(exporter), 1)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		_ = provider.Shutdown(context.Background())
+	})
+	return exporter
+}
+
+func TestMiddleware(t *testing.T) {
+	exporter := setupTestProvider(t)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(Middleware())
+	var traceId string
+	router.GET("/users/:id", func(c *gin.Context) {
+		traceId = TraceId(c)
+		_, span := Start(c.Request.Context(), "child")
+		span.End()
+		c.Status(http.StatusInternalServerError)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	req.Header.Set("traceparent", "00-"+testTraceId+"-00f067aa0ba902b7-01")
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	if traceId != testTraceId {
+		t.Errorf("expected the propagated trace id, got %q", traceId)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+
+	child, server := spans[0], spans[1]
+	if server.Name != "GET /users/:id" || server.SpanKind != trace.SpanKindServer {
+		t.Errorf("expected a server span named by the route, got %s %s", server.Name, server.SpanKind)
+	}
+
+	if server.Parent.SpanID().String() != "00f067aa0ba902b7" || server.SpanContext.TraceID().String() != testTraceId {
+		t.Errorf("expected the server span to continue the propagated trace, got parent %s",
+			server.Parent.SpanID())
+	}
+
+	if child.Parent.SpanID() != server.SpanContext.SpanID() {
+		t.Errorf("expected the span of the handler to be a child of the server span")
+	}
+
+	if server.Status.Code.String() != "Error" {
+		t.Errorf("expected the server error to set the span status, got %s", server.Status.Code)
+	}
+}
+
+func TestTraceIdNotSampled(t *testing.T) {
+	setupTestProvider(t)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+	c.Request.Header.Set("traceparent", "00-"+testTraceId+"-00f067aa0ba902b7-00")
+	Middleware()(c)
+	if traceId := TraceId(c); traceId != "" {
+		t.Errorf("expected no trace id for the unsampled trace, got %s", traceId)
+	}
+}
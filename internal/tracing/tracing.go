@@ -0,0 +1,68 @@
+package tracing
+
+import (
+	"auth-service/internal/options"
+	"context"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	serviceName = "auth-service"
+	// instrumentationName is the name of the tracer of all the spans created by auth-service
+	instrumentationName = "auth-service"
+)
+
+// Setup installs the W3C trace context propagator and, if tracing is enabled, a tracer provider which exports the
+// spans to the OTLP gRPC endpoint. Returned provider must be shut down by the caller to flush the spans, it is nil if
+// tracing is disabled. Incoming trace context is still propagated to the outbound calls when tracing is disabled
+func Setup(ctx context.Context, opts *options.AuthServiceOptions) (*sdktrace.TracerProvider, error) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	if !opts.TracingEnabled {
+		return nil, nil
+	}
+
+	clientOpts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(opts.TracingOtlpEndpoint)}
+	if opts.TracingOtlpInsecure {
+		clientOpts = append(clientOpts, otlptracegrpc.WithInsecure())
+	}
+
+	exporter, err := otlptracegrpc.New(ctx, clientOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := NewTracerProvider(sdktrace.NewBatchSpanProcessor(exporter), opts.TracingSampleRatio)
+	otel.SetTracerProvider(provider)
+	return provider, nil
+}
+
+// NewTracerProvider creates a tracer provider which samples the given ratio of the new traces, sampling decision of
+// the parent is respected for the propagated traces
+func NewTracerProvider(processor sdktrace.SpanProcessor, sampleRatio float64) *sdktrace.TracerProvider {
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(processor),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(serviceName))),
+	)
+}
+
+// Start starts a span with the global tracer provider, so the spans are dropped until a provider is installed
+func Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, opts...)
+}
+
+// End records the error on the span if there is one and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,195 @@
+package vpnauth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultCacheDir is the default directory of the cache, it must be owned by the user OpenVPN runs the script as
+	DefaultCacheDir = "/var/lib/vpn-auth-verify"
+	secretFileName  = ".secret"
+	secretLength    = 32
+)
+
+// Cache is a local file based cache of successful authentications. OpenVPN spawns a new process for every
+// verification, so the cache is kept on disk instead of memory. Entries are named by the HMAC of the credentials
+// under a random secret of the host which is kept in the cache directory, so an entry can only be planted by a user
+// who can read the directory. The directory must be owned by the effective user and not be accessible by the others
+type Cache struct {
+	dir string
+	ttl time.Duration
+	now func() time.Time
+}
+
+// NewCache creates a new Cache which stores entries in dir for ttl. A zero ttl disables the cache
+func NewCache(dir string, ttl time.Duration) *Cache {
+	return &Cache{
+		dir: dir,
+		ttl: ttl,
+		now: time.Now,
+	}
+}
+
+// Lookup returns true if the credentials were successfully verified within the ttl, a directory which is not
+// private is treated as an empty cache
+func (c *Cache) Lookup(creds Credentials) bool {
+	if c.ttl <= 0 {
+		return false
+	}
+
+	if err := c.checkDir(); err != nil {
+		return false
+	}
+
+	secret, err := c.secret(false)
+	if err != nil {
+		return false
+	}
+
+	path := c.path(secret, creds)
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false
+	}
+
+	expiresAt, err := strconv.ParseInt(strings.TrimSpace(string(content)), 10, 64)
+	if err != nil || c.now().Unix() >= expiresAt {
+		_ = os.Remove(path)
+		return false
+	}
+
+	return true
+}
+
+// Store marks the credentials as successfully verified for the ttl, directory is created if it does not exist
+func (c *Cache) Store(creds Credentials) error {
+	if c.ttl <= 0 {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.dir), 0755); err != nil {
+		return err
+	}
+
+	if err := os.Mkdir(c.dir, 0700); err != nil && !os.IsExist(err) {
+		return err
+	}
+
+	if err := c.checkDir(); err != nil {
+		return err
+	}
+
+	secret, err := c.secret(true)
+	if err != nil {
+		return err
+	}
+
+	expiresAt := c.now().Add(c.ttl).Unix()
+	tmp, err := c.writeTemp([]byte(strconv.FormatInt(expiresAt, 10)))
+	if err != nil {
+		return err
+	}
+
+	// rename is atomic, concurrent verifications never read a partially written entry
+	return os.Rename(tmp, c.path(secret, creds))
+}
+
+// checkDir refuses a cache directory which could be written by the other users, like the one which is created in
+// advance in a shared directory
+func (c *Cache) checkDir() error {
+	info, err := os.Lstat(c.dir)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		return fmt.Errorf("cache directory %s is not a directory", c.dir)
+	}
+
+	if info.Mode().Perm() != 0700 {
+		return fmt.Errorf("cache directory %s must have mode 0700, has %04o", c.dir, info.Mode().Perm())
+	}
+
+	return checkOwner(info)
+}
+
+// secret reads the secret of the host from the cache directory, and creates it if create is set. Secret is linked
+// into place so that the concurrent verifications agree on a single fully written secret
+func (c *Cache) secret(create bool) ([]byte, error) {
+	path := filepath.Join(c.dir, secretFileName)
+	secret, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) && create {
+		if err := c.createSecret(path); err != nil {
+			return nil, err
+		}
+		secret, err = ioutil.ReadFile(path)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(secret) != secretLength {
+		return nil, errors.New("cache secret is corrupted")
+	}
+
+	return secret, nil
+}
+
+func (c *Cache) createSecret(path string) error {
+	secret := make([]byte, secretLength)
+	if _, err := rand.Read(secret); err != nil {
+		return err
+	}
+
+	tmp, err := c.writeTemp(secret)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	// link fails if another verification created the secret in the meantime, its secret is used then
+	if err := os.Link(tmp, path); err != nil && !os.IsExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// writeTemp writes the content to a new temporary file in the cache directory and returns its path
+func (c *Cache) writeTemp(content []byte) (string, error) {
+	tmp, err := ioutil.TempFile(c.dir, ".entry-")
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := tmp.Write(content); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return "", err
+	}
+
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return "", err
+	}
+
+	return tmp.Name(), nil
+}
+
+func (c *Cache) path(secret []byte, creds Credentials) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(creds.Username + "\x00" + creds.Password))
+	return filepath.Join(c.dir, hex.EncodeToString(mac.Sum(nil)))
+}
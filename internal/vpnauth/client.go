@@ -0,0 +1,131 @@
+package vpnauth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrRejected is returned when auth-service explicitly rejects the credentials
+	ErrRejected = errors.New("credentials rejected by auth-service")
+	// ErrSubjectMismatch is returned when the bearer token belongs to a different user than the one logging in
+	ErrSubjectMismatch = errors.New("token subject does not match the username")
+)
+
+// Client makes authentication requests to the auth-service
+type Client struct {
+	baseUrl    string
+	httpClient *http.Client
+}
+
+// NewClient creates a new Client with the given auth-service base url and request timeout
+func NewClient(baseUrl string, timeout time.Duration) *Client {
+	return &Client{
+		baseUrl:    strings.TrimRight(baseUrl, "/"),
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+type authenticateRequest struct {
+	Username string `json:"userName"`
+	Password string `json:"password"`
+}
+
+type validateRequest struct {
+	Token string `json:"token"`
+}
+
+type validateResponse struct {
+	Status   bool   `json:"status"`
+	Username string `json:"username"`
+}
+
+// Authenticate verifies the username and password against the /auth/authenticate endpoint
+func (c *Client) Authenticate(ctx context.Context, username, password string) error {
+	code, _, err := c.post(ctx, "/auth/authenticate", authenticateRequest{
+		Username: username,
+		Password: password,
+	})
+	if err != nil {
+		return err
+	}
+
+	return checkStatus(code)
+}
+
+// Validate verifies the bearer token against the /auth/validate endpoint and makes sure that it belongs to the
+// given username
+func (c *Client) Validate(ctx context.Context, username, token string) error {
+	code, body, err := c.post(ctx, "/auth/validate", validateRequest{Token: token})
+	if err != nil {
+		return err
+	}
+
+	if err := checkStatus(code); err != nil {
+		return err
+	}
+
+	var res validateResponse
+	if err := json.Unmarshal(body, &res); err != nil {
+		return err
+	}
+
+	if !res.Status {
+		return ErrRejected
+	}
+
+	if res.Username != username {
+		return ErrSubjectMismatch
+	}
+
+	return nil
+}
+
+func (c *Client) post(ctx context.Context, path string, payload interface{}) (int, []byte, error) {
+	reqBody, err := json.Marshal(payload)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseUrl+path, bytes.NewReader(reqBody))
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return resp.StatusCode, body, nil
+}
+
+// checkStatus maps the auth-service response code to an error. 4xx responses are treated as rejected credentials,
+// everything else which is not 2xx is treated as an unavailable service
+func checkStatus(code int) error {
+	switch {
+	case code >= 200 && code < 300:
+		return nil
+	case code >= 400 && code < 500:
+		return ErrRejected
+	default:
+		return fmt.Errorf("unexpected response code %d from auth-service", code)
+	}
+}
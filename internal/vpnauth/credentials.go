@@ -0,0 +1,84 @@
+package vpnauth
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"strings"
+)
+
+const (
+	bearerPrefix      = "Bearer "
+	bearerPrefixShort = "bearer:"
+)
+
+// Credentials represents the username and password passed by OpenVPN to the auth-user-pass-verify script
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// IsBearer returns true if the password field carries a bearer token instead of a plain password. Tokens can be
+// passed as "Bearer <token>", "bearer:<token>" or as a raw JWT
+func (c Credentials) IsBearer() bool {
+	return c.Token() != ""
+}
+
+// Token returns the bearer token carried in the password field, returns empty string if there is none
+func (c Credentials) Token() string {
+	switch {
+	case strings.HasPrefix(c.Password, bearerPrefix):
+		return strings.TrimSpace(strings.TrimPrefix(c.Password, bearerPrefix))
+	case strings.HasPrefix(c.Password, bearerPrefixShort):
+		return strings.TrimSpace(strings.TrimPrefix(c.Password, bearerPrefixShort))
+	case isJwt(c.Password):
+		return c.Password
+	}
+
+	return ""
+}
+
+// isJwt checks if the given string looks like a compact serialized JWT
+func isJwt(s string) bool {
+	parts := strings.Split(s, ".")
+	return len(parts) == 3 && strings.HasPrefix(parts[0], "eyJ") && parts[1] != "" && parts[2] != ""
+}
+
+// ReadCredentialsFromFile reads the credentials from the temporary file passed by OpenVPN in via-file mode, first
+// line of the file is the username and the second line is the password
+func ReadCredentialsFromFile(path string) (Credentials, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return Credentials{}, err
+	}
+
+	defer func() {
+		_ = file.Close()
+	}()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() && len(lines) < 2 {
+		lines = append(lines, strings.TrimRight(scanner.Text(), "\r"))
+	}
+
+	if err := scanner.Err(); err != nil {
+		return Credentials{}, err
+	}
+
+	if len(lines) < 2 {
+		return Credentials{}, errors.New("credentials file must contain username and password lines")
+	}
+
+	return Credentials{Username: lines[0], Password: lines[1]}, nil
+}
+
+// ReadCredentialsFromEnv reads the credentials from the environment variables set by OpenVPN in via-env mode
+func ReadCredentialsFromEnv() (Credentials, error) {
+	username, password := os.Getenv("username"), os.Getenv("password")
+	if username == "" && password == "" {
+		return Credentials{}, errors.New("username and password environment variables are not set")
+	}
+
+	return Credentials{Username: username, Password: password}, nil
+}
@@ -0,0 +1,47 @@
+package vpnauth
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+)
+
+// SpawnDeferred starts a detached worker process with the given arguments and hands the credentials over on its
+// stdin. The credentials are not passed as arguments or environment variables to keep them out of the process list.
+// OpenVPN removes the via-file credentials file as soon as the script exits, so the caller must read the
+// credentials before spawning
+func SpawnDeferred(executable string, args []string, creds Credentials) error {
+	cmd := exec.Command(executable, args...)
+	cmd.Env = os.Environ()
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	if err := json.NewEncoder(stdin).Encode(creds); err != nil {
+		_ = cmd.Process.Kill()
+		return err
+	}
+
+	if err := stdin.Close(); err != nil {
+		return err
+	}
+
+	// the worker outlives this process and reports the result through auth_control_file
+	return cmd.Process.Release()
+}
+
+// ReadDeferredCredentials reads the credentials handed over by SpawnDeferred
+func ReadDeferredCredentials(r io.Reader) (Credentials, error) {
+	var creds Credentials
+	err := json.NewDecoder(r).Decode(&creds)
+	return creds, err
+}
@@ -0,0 +1,25 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package vpnauth
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// checkOwner checks if the file is owned by the effective user
+func checkOwner(info os.FileInfo) error {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fmt.Errorf("owner of %s is unknown", info.Name())
+	}
+
+	if int(stat.Uid) != os.Geteuid() {
+		return fmt.Errorf("%s is owned by uid %d instead of the effective uid %d", info.Name(), stat.Uid,
+			os.Geteuid())
+	}
+
+	return nil
+}
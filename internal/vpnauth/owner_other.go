@@ -0,0 +1,14 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package vpnauth
+
+import (
+	"errors"
+	"os"
+)
+
+// checkOwner can not check the owner on this platform, so the cache is refused
+func checkOwner(os.FileInfo) error {
+	return errors.New("cache is not supported on this platform")
+}
@@ -0,0 +1,104 @@
+package vpnauth
+
+import (
+	"context"
+	"errors"
+	"go.uber.org/zap"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// exit codes expected by OpenVPN from an auth-user-pass-verify script
+const (
+	ExitSuccess  = 0
+	ExitFailure  = 1
+	ExitDeferred = 2
+)
+
+// Verifier verifies the OpenVPN credentials against auth-service, successful password verifications are cached
+// locally
+type Verifier struct {
+	client *Client
+	cache  *Cache
+	logger *zap.Logger
+}
+
+// NewVerifier creates a new Verifier
+func NewVerifier(client *Client, cache *Cache, logger *zap.Logger) *Verifier {
+	return &Verifier{
+		client: client,
+		cache:  cache,
+		logger: logger,
+	}
+}
+
+// Verify verifies the credentials either as username/password or as a bearer token carried in the password field,
+// the subject of a bearer token must be the username. Bearer tokens are not cached, a cached result would outlive the
+// expiry of the token and the logout of the user
+func (v *Verifier) Verify(ctx context.Context, creds Credentials) error {
+	if creds.Username == "" {
+		return errors.New("username is empty")
+	}
+
+	if creds.IsBearer() {
+		return v.client.Validate(ctx, creds.Username, creds.Token())
+	}
+
+	if v.cache.Lookup(creds) {
+		v.logger.Info("credentials verified from local cache", zap.String("user", creds.Username))
+		return nil
+	}
+
+	if err := v.client.Authenticate(ctx, creds.Username, creds.Password); err != nil {
+		return err
+	}
+
+	if err := v.cache.Store(creds); err != nil {
+		v.logger.Warn("an error occurred while caching verification result", zap.String("error", err.Error()))
+	}
+
+	return nil
+}
+
+// Run verifies the credentials and returns the exit code OpenVPN expects
+func (v *Verifier) Run(ctx context.Context, creds Credentials) int {
+	if err := v.Verify(ctx, creds); err != nil {
+		v.logger.Warn("credential verification failed", zap.String("user", creds.Username),
+			zap.String("commonName", os.Getenv("common_name")), zap.String("untrustedIp", os.Getenv("untrusted_ip")),
+			zap.String("error", err.Error()))
+		return ExitFailure
+	}
+
+	v.logger.Info("credential verification succeeded", zap.String("user", creds.Username),
+		zap.String("commonName", os.Getenv("common_name")), zap.String("untrustedIp", os.Getenv("untrusted_ip")))
+	return ExitSuccess
+}
+
+// WriteAuthControlFile writes the deferred authentication result to the auth_control_file, "1" means success and
+// "0" means failure
+func WriteAuthControlFile(path string, success bool) error {
+	result := "0"
+	if success {
+		result = "1"
+	}
+
+	// OpenVPN polls the control file, so the result is written to a temp file first and renamed to be atomic
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".acf-")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.WriteString(result); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,161 @@
+package vpnauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"go.uber.org/zap"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCredentialsToken(t *testing.T) {
+	cases := []struct {
+		password string
+		token    string
+	}{
+		{"secret", ""},
+		{"Bearer abc.def.ghi", "abc.def.ghi"},
+		{"bearer:abc.def.ghi", "abc.def.ghi"},
+		{"eyJhbGciOiJSUzI1NiJ9.eyJzdWIiOiJmb28ifQ.c2ln", "eyJhbGciOiJSUzI1NiJ9.eyJzdWIiOiJmb28ifQ.c2ln"},
+		{"not.a.jwt", ""},
+	}
+
+	for _, c := range cases {
+		if token := (Credentials{Username: "foo", Password: c.password}).Token(); token != c.token {
+			t.Errorf("expected token %q for password %q, got %q", c.token, c.password, token)
+		}
+	}
+}
+
+func TestReadCredentialsFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "creds")
+	if err := ioutil.WriteFile(path, []byte("foo\nbar\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	creds, err := ReadCredentialsFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if creds.Username != "foo" || creds.Password != "bar" {
+		t.Errorf("unexpected credentials %+v", creds)
+	}
+}
+
+func TestVerifierCachesSuccess(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		var req authenticateRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.Password != "bar" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cache := NewCache(filepath.Join(t.TempDir(), "cache"), time.Minute)
+	verifier := NewVerifier(NewClient(server.URL, time.Second), cache, zap.NewNop())
+	creds := Credentials{Username: "foo", Password: "bar"}
+	for i := 0; i < 2; i++ {
+		if code := verifier.Run(context.Background(), creds); code != ExitSuccess {
+			t.Fatalf("expected exit code %d, got %d", ExitSuccess, code)
+		}
+	}
+
+	if calls != 1 {
+		t.Errorf("expected a single call to auth-service, got %d", calls)
+	}
+
+	if code := verifier.Run(context.Background(), Credentials{Username: "foo", Password: "baz"}); code != ExitFailure {
+		t.Errorf("expected exit code %d, got %d", ExitFailure, code)
+	}
+}
+
+func TestVerifierBearerSubjectMismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(validateResponse{Status: true, Username: "someone-else"})
+	}))
+	defer server.Close()
+
+	verifier := NewVerifier(NewClient(server.URL, time.Second), NewCache(t.TempDir(), 0), zap.NewNop())
+	creds := Credentials{Username: "foo", Password: "Bearer abc.def.ghi"}
+	if err := verifier.Verify(context.Background(), creds); err != ErrSubjectMismatch {
+		t.Errorf("expected %v, got %v", ErrSubjectMismatch, err)
+	}
+}
+
+func TestVerifierDoesNotCacheBearer(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		_ = json.NewEncoder(w).Encode(validateResponse{Status: true, Username: "foo"})
+	}))
+	defer server.Close()
+
+	cache := NewCache(filepath.Join(t.TempDir(), "cache"), time.Minute)
+	verifier := NewVerifier(NewClient(server.URL, time.Second), cache, zap.NewNop())
+	creds := Credentials{Username: "foo", Password: "Bearer abc.def.ghi"}
+	for i := 0; i < 2; i++ {
+		if err := verifier.Verify(context.Background(), creds); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if calls != 2 {
+		t.Errorf("expected every bearer token to be validated by auth-service, got %d calls", calls)
+	}
+
+	if err := verifier.Verify(context.Background(), Credentials{Password: "Bearer abc.def.ghi"}); err == nil {
+		t.Error("expected the bearer token without a username to be refused")
+	}
+}
+
+func TestCacheRefusesSharedDirectory(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cache")
+	if err := os.Mkdir(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	cache := NewCache(dir, time.Minute)
+	creds := Credentials{Username: "foo", Password: "bar"}
+	if err := cache.Store(creds); err == nil {
+		t.Error("expected the shared directory to be refused")
+	}
+
+	// an entry planted under the digest of the credentials is not accepted
+	sum := sha256.Sum256([]byte("foo\x00bar"))
+	planted := filepath.Join(dir, hex.EncodeToString(sum[:]))
+	if err := ioutil.WriteFile(planted, []byte("9999999999"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if cache.Lookup(creds) {
+		t.Error("expected the planted entry to be ignored")
+	}
+
+	if err := os.Chmod(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	if cache.Lookup(creds) {
+		t.Error("expected the planted entry to be ignored in a private directory")
+	}
+
+	if err := cache.Store(creds); err != nil || !cache.Lookup(creds) {
+		t.Errorf("expected the entry to be cached in a private directory, got %v", err)
+	}
+}
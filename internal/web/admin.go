@@ -0,0 +1,112 @@
+package web
+
+import (
+	"auth-service/internal/entitlement"
+	"auth-service/internal/model"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"net/http"
+	"time"
+)
+
+// userByUuid finds the user with the uuid path parameter, writes the error response and returns false if it can not
+func userByUuid(context *gin.Context) (*model.User, bool) {
+	var user model.User
+	uuid := context.Param("uuid")
+	switch err := db.Where(queryUuid, currentRealm(context).Id, uuid).First(&user).Error; err {
+	case nil:
+		return &user, true
+	case gorm.ErrRecordNotFound:
+		logger.Warn(errNoRowsReturned, zap.String("uuid", uuid))
+		errorResponse(context, errUserNotFound)
+	default:
+		logger.Error("an error occurred while querying user", zap.String("error", err.Error()))
+		errorResponse(context, errUnknown)
+	}
+
+	context.Abort()
+	return nil, false
+}
+
+func listEntitlementsHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		user, ok := userByUuid(context)
+		if !ok {
+			return
+		}
+
+		entitlements, err := entitlement.List(db, user.Id)
+		if err != nil {
+			logger.Error("an error occurred while querying entitlements", zap.String("error", err.Error()))
+			errorResponse(context, errUnknown)
+			context.Abort()
+			return
+		}
+
+		context.JSON(http.StatusOK, entitlements)
+	}
+}
+
+func assignEntitlementHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		req, _ := context.Get("data")
+		entitlementReq := req.(entitlementRequest)
+		user, ok := userByUuid(context)
+		if !ok {
+			return
+		}
+
+		e := model.Entitlement{
+			UserId: user.Id,
+			Name:   context.Param("name"),
+			Value:  entitlementReq.Value,
+		}
+		if entitlementReq.ExpiresAt != "" {
+			expiresAt, err := time.Parse(time.RFC3339, entitlementReq.ExpiresAt)
+			if err != nil {
+				validationResponse(context, []fieldError{invalidField("expiresAt", errInvalidTimestamp)})
+				context.Abort()
+				return
+			}
+			e.ExpiresAt = &expiresAt
+		}
+
+		if err := entitlement.Assign(db, &e); err != nil {
+			logger.Error("an error occurred while assigning entitlement", zap.String("error", err.Error()))
+			errorResponse(context, errUnknown)
+			context.Abort()
+			return
+		}
+
+		logger.Info("entitlement assigned", zap.String("user", user.UserName), zap.String("entitlement", e.Name))
+		context.JSON(http.StatusOK, e)
+	}
+}
+
+func removeEntitlementHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		user, ok := userByUuid(context)
+		if !ok {
+			return
+		}
+
+		name := context.Param("name")
+		removed, err := entitlement.Remove(db, user.Id, name)
+		if err != nil {
+			logger.Error("an error occurred while removing entitlement", zap.String("error", err.Error()))
+			errorResponse(context, errUnknown)
+			context.Abort()
+			return
+		}
+
+		if !removed {
+			errorResponse(context, errEntitlementNotFound)
+			context.Abort()
+			return
+		}
+
+		logger.Info("entitlement removed", zap.String("user", user.UserName), zap.String("entitlement", name))
+		context.Status(http.StatusNoContent)
+	}
+}
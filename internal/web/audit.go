@@ -0,0 +1,122 @@
+package web
+
+import (
+	"auth-service/internal/audit"
+	"auth-service/internal/jwt"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// requestIdHeader carries the id of the request which is set by the gateway
+const requestIdHeader = "X-Request-Id"
+
+var auditor audit.Recorder = audit.Discard
+
+// auditRequest stores the client of the request in the request context, so the audit events of the request carry
+// its ip, user agent and request id
+func auditRequest() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(audit.WithRequest(c.Request.Context(), audit.Request{
+			Ip:        c.ClientIP(),
+			UserAgent: c.Request.UserAgent(),
+			RequestId: c.GetHeader(requestIdHeader),
+		}))
+		c.Next()
+	}
+}
+
+// recordEvent records the audit event in the realm of the request, actor defaults to the subject of the validated
+// token
+func recordEvent(context *gin.Context, event audit.Event) {
+	event.RealmId = currentRealm(context).Id
+	if claims, ok := context.Get("claims"); ok && event.Actor == "" {
+		event.Actor = claims.(*jwt.VpnbeastClaim).Subject
+	}
+	if event.Outcome == "" {
+		event.Outcome = audit.OutcomeSuccess
+	}
+
+	auditor.Record(context.Request.Context(), event)
+}
+
+// recordRoleChange records the change of a role made by an admin
+func recordRoleChange(context *gin.Context, role, action string, details map[string]string) {
+	if details == nil {
+		details = map[string]string{}
+	}
+	details["action"] = action
+	recordEvent(context, audit.Event{Type: audit.EventRoleChanged, Subject: role, Details: details})
+}
+
+// recordFederatedLogin records the failed login through an identity provider whose identity can not be linked to a
+// user, logins of the linked users are recorded by auth.AuthService.AuthenticateFederated
+func recordFederatedLogin(context *gin.Context, provider, subject, reason string) {
+	recordEvent(context, audit.Event{Type: audit.EventLoginFailed, Outcome: audit.OutcomeFailure, Actor: subject,
+		Subject: subject, Reason: reason, Details: map[string]string{"provider": provider}})
+}
+
+// pagination parses the limit and offset query parameters, limit is 100 by default. Validation errors are appended
+// to errs
+func pagination(context *gin.Context, errs []fieldError) (int, int, []fieldError) {
+	limit, err := strconv.Atoi(context.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 || limit > audit.MaxLimit {
+		errs = append(errs, invalidField("limit", errInvalidLimit))
+	}
+
+	offset, err := strconv.Atoi(context.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		errs = append(errs, invalidField("offset", errInvalidOffset))
+	}
+
+	return limit, offset, errs
+}
+
+// optionalTime parses the RFC3339 query parameter, nil is returned if it is missing
+func optionalTime(context *gin.Context, key string) (*time.Time, error) {
+	value := context.Query(key)
+	if value == "" {
+		return nil, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
+	return &t, err
+}
+
+func listAuditEventsHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		filter := audit.Filter{
+			Type:    context.Query("type"),
+			Outcome: context.Query("outcome"),
+			Actor:   context.Query("actor"),
+			Subject: context.Query("subject"),
+		}
+
+		var errs []fieldError
+		var err error
+		if filter.Since, err = optionalTime(context, "since"); err != nil {
+			errs = append(errs, invalidField("since", errInvalidTimestamp))
+		}
+		if filter.Until, err = optionalTime(context, "until"); err != nil {
+			errs = append(errs, invalidField("until", errInvalidTimestamp))
+		}
+		filter.Limit, filter.Offset, errs = pagination(context, errs)
+		if len(errs) != 0 {
+			validationResponse(context, errs)
+			context.Abort()
+			return
+		}
+
+		events, err := audit.Query(db, currentRealm(context).Id, filter)
+		if err != nil {
+			logger.Error("an error occurred while querying audit events", zap.String("error", err.Error()))
+			errorResponse(context, errUnknown)
+			context.Abort()
+			return
+		}
+
+		context.JSON(http.StatusOK, events)
+	}
+}
@@ -0,0 +1,149 @@
+package web
+
+import (
+	"auth-service/internal/ca"
+	"auth-service/internal/jwt"
+	"auth-service/internal/model"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"net/http"
+	"time"
+)
+
+// certificateAuthorityEnabled aborts the request if the certificate authority is not configured
+func certificateAuthorityEnabled() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if authority == nil {
+			errorResponse(c, errCaDisabled)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func signCertificateHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		claims := context.MustGet("claims").(*jwt.VpnbeastClaim)
+		req, _ := context.Get("data")
+		certificateReq := req.(certificateRequest)
+
+		var user model.User
+		r := currentRealm(context)
+		switch err := db.Preload("Roles").Where(queryUsername, r.Id, claims.Subject).First(&user).Error; err {
+		case gorm.ErrRecordNotFound:
+			logger.Warn(errNoRowsReturned, zap.String("user", claims.Subject))
+			errorResponse(context, errUserNotFound)
+			context.Abort()
+			return
+		case nil:
+			if !user.Enabled {
+				logger.Warn("disabled user requested a vpn certificate", zap.String("user", user.UserName))
+				errorResponse(context, errUserDisabled)
+				context.Abort()
+				return
+			}
+
+			// certificate can not outlive the access token, even if a longer lived token is presented
+			notAfter := time.Unix(claims.ExpiresAt, 0)
+			maxNotAfter := time.Now().Add(time.Duration(r.AccessTokenValidInMinutes()) * time.Minute)
+			if claims.ExpiresAt == 0 || notAfter.After(maxNotAfter) {
+				notAfter = maxNotAfter
+			}
+
+			certificate, certificatePem, err := authority.Sign(db, &user, currentRoles(&user, claims),
+				certificateReq.Csr, notAfter)
+			switch err {
+			case nil:
+				logger.Info("vpn certificate issued", zap.String("user", user.UserName),
+					zap.String("serial", certificate.SerialNumber))
+				context.JSON(http.StatusOK, certificateResponse{
+					SerialNumber:  certificate.SerialNumber,
+					Certificate:   string(certificatePem),
+					CaCertificate: string(authority.CertificatePem()),
+					NotBefore:     certificate.NotBefore.Format(time.RFC3339),
+					NotAfter:      certificate.NotAfter.Format(time.RFC3339),
+				})
+			case ca.ErrInvalidCsr:
+				errorResponse(context, errInvalidCsr)
+			case ca.ErrNoEligibleRole:
+				errorResponse(context, errNoEligibleRole)
+			default:
+				logger.Error("an error occurred while signing certificate", zap.String("error", err.Error()))
+				errorResponse(context, errUnknown)
+			}
+			context.Abort()
+			return
+		default:
+			logger.Error("an error occurred while querying user", zap.String("error", err.Error()))
+			errorResponse(context, errUnknown)
+			context.Abort()
+			return
+		}
+	}
+}
+
+// currentRoles returns the roles of the token which the user still has, roles removed after the token is issued do
+// not end up in the certificate and the scopes of a personal access token are kept
+func currentRoles(user *model.User, claims *jwt.VpnbeastClaim) []string {
+	var roles []string
+	for _, role := range user.Roles {
+		for _, granted := range claims.Roles {
+			if role.Name == granted {
+				roles = append(roles, role.Name)
+				break
+			}
+		}
+	}
+
+	return roles
+}
+
+func caCertificateHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		context.Data(http.StatusOK, "application/x-pem-file", authority.CertificatePem())
+	}
+}
+
+func crlHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		crl, err := authority.GenerateCrl(db)
+		if err != nil {
+			logger.Error("an error occurred while generating crl", zap.String("error", err.Error()))
+			errorResponse(context, errUnknown)
+			context.Abort()
+			return
+		}
+
+		context.Data(http.StatusOK, "application/pkix-crl", crl)
+	}
+}
+
+func certificateStatusHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		serial := context.Param("serial")
+		status, certificate, err := authority.CertificateStatus(db, serial)
+		if err != nil {
+			logger.Error("an error occurred while querying certificate status", zap.String("error", err.Error()))
+			errorResponse(context, errUnknown)
+			context.Abort()
+			return
+		}
+
+		res := certificateStatusResponse{
+			SerialNumber: serial,
+			Status:       status,
+			Timestamp:    time.Now().Format(time.RFC3339),
+		}
+		if certificate != nil {
+			res.NotAfter = certificate.NotAfter.Format(time.RFC3339)
+			if certificate.RevokedAt != nil {
+				res.RevokedAt = certificate.RevokedAt.Format(time.RFC3339)
+			}
+		}
+
+		context.JSON(http.StatusOK, res)
+	}
+}
@@ -0,0 +1,31 @@
+package web
+
+import (
+	"auth-service/internal/jwt"
+	"auth-service/internal/model"
+	"reflect"
+	"testing"
+)
+
+func TestCurrentRoles(t *testing.T) {
+	user := &model.User{Roles: []*model.Role{{Name: "user"}, {Name: "vpn-admin"}}}
+	cases := []struct {
+		name   string
+		claims []string
+		want   []string
+	}{
+		{"all roles", []string{"user", "vpn-admin"}, []string{"user", "vpn-admin"}},
+		{"removed role", []string{"user", "vpn-admin", "site-admin"}, []string{"user", "vpn-admin"}},
+		{"scoped token", []string{"user"}, []string{"user"}},
+		{"no roles", nil, nil},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := currentRoles(user, &jwt.VpnbeastClaim{Roles: c.claims})
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("currentRoles() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
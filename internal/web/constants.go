@@ -1,10 +1,62 @@
 package web
 
+// apiError is an entry of the error catalog. Code is the stable machine-readable identifier which the clients should
+// rely on, Title is the human-readable summary which is also the message of the legacy responses
+type apiError struct {
+	Code   string
+	Status int
+	Title  string
+}
+
+var (
+	errUnknown             = apiError{"internal_error", 500, "Unknown error occurred at the backend!"}
+	errValidationFailed    = apiError{"validation_failed", 400, "Request validation failed!"}
+	errInvalidJson         = apiError{"invalid_json", 400, "not a valid JSON request!"}
+	errInvalidPass         = apiError{"invalid_credentials", 400, "Invalid password!"}
+	errUserNotFound        = apiError{"user_not_found", 404, "User not found!"}
+	errUserNotLinked       = apiError{"user_not_linked", 403, "User not found!"}
+	errMissingToken        = apiError{"missing_token", 401, "Bearer token is missing!"}
+	errInvalidToken        = apiError{"invalid_token", 401, "Invalid token!"}
+	errTokenRevoked        = apiError{"token_revoked", 401, "Token is revoked!"}
+	errEntitlementExpired  = apiError{"entitlement_expired", 401, "Entitlement expired, token must be refreshed!"}
+	errCaDisabled          = apiError{"ca_disabled", 503, "Certificate authority is not configured!"}
+	errInvalidCsr          = apiError{"invalid_csr", 400, "Invalid certificate signing request!"}
+	errNoEligibleRole      = apiError{"no_eligible_role", 403, "User has no role eligible for a vpn certificate!"}
+	errForbidden           = apiError{"forbidden", 403, "Insufficient privileges!"}
+	errEntitlementNotFound = apiError{"entitlement_not_found", 404, "Entitlement not found!"}
+	errInvalidTimestamp    = apiError{"invalid_timestamp", 400, "Timestamp must be in RFC3339 format!"}
+	errInvalidLimit        = apiError{"invalid_limit", 400, "Limit must be between 1 and 1000!"}
+	errInvalidOffset       = apiError{"invalid_offset", 400, "Offset must be a non-negative integer!"}
+	errInvalidScope        = apiError{"invalid_scope", 400, "Scopes must be a subset of the roles of the user!"}
+	errTokenNotFound       = apiError{"token_not_found", 404, "Token not found!"}
+	errRoleNotFound        = apiError{"role_not_found", 404, "Role not found!"}
+	errPermissionNotFound  = apiError{"permission_not_found", 404, "Permission not found!"}
+	errPermissionExists    = apiError{"permission_exists", 409, "Permission already exists!"}
+	errInvalidPermission   = apiError{"invalid_permission", 400, "Permission name must be in resource:action format!"}
+	errCyclicInheritance   = apiError{"cyclic_inheritance", 409, "Role inheritance can not be cyclic!"}
+	errRealmNotFound       = apiError{"realm_not_found", 404, "Realm not found!"}
+	errRealmExists         = apiError{"realm_exists", 409, "Realm already exists!"}
+	errInvalidRealmName    = apiError{"invalid_realm_name", 400,
+		"Realm name must consist of lowercase letters, digits and dashes!"}
+	errDisableDefaultRealm   = apiError{"default_realm_required", 409, "Default realm can not be disabled!"}
+	errProviderNotFound      = apiError{"provider_not_found", 404, "Identity provider not found!"}
+	errProviderUnavailable   = apiError{"provider_unavailable", 502, "Identity provider is unavailable!"}
+	errInvalidLoginState     = apiError{"invalid_login_state", 400, "Login state is invalid or expired!"}
+	errFederatedLoginFailed  = apiError{"federated_login_failed", 401, "Federated login failed!"}
+	errUserDisabled          = apiError{"user_disabled", 403, "User is disabled!"}
+	errUserLocked            = apiError{"user_locked", 403, "User is locked!"}
+	errLoginBlocked          = apiError{"login_blocked", 403, "Login is blocked as suspicious!"}
+	errStepUpRequired        = apiError{"step_up_required", 401, "Login is suspicious, a second factor is required!"}
+	errTooManyRequests       = apiError{"too_many_requests", 429, "Too many requests, please try again later!"}
+	errBackendNotFound       = apiError{"backend_not_found", 400, "Identity backend not found!"}
+	errEncryptionUnavailable = apiError{"encryption_unavailable", 503,
+		"Encryption service is unavailable, please try again later!"}
+	errInvalidCsrfToken = apiError{"invalid_csrf_token", 403, "CSRF token is missing or invalid!"}
+)
+
 const (
-	errUnknown        = "Unknown error occurred at the backend!"
-	errInvalidPass    = "Invalid password!"
-	errUserNotFound   = "User not found!"
 	errNoRowsReturned = "no rows were returned!"
 
-	queryUsername = "user_name = ?"
+	queryUsername = "realm_id = ? AND user_name = ?"
+	queryUuid     = "realm_id = ? AND uuid = ?"
 )
@@ -0,0 +1,133 @@
+package web
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+const (
+	sessionCookieName = "vpnbeast_session"
+	csrfCookieName    = "vpnbeast_csrf"
+	csrfHeader        = "X-CSRF-Token"
+	// sessionModeHeader opts in to the cookie mode on login with the value cookie
+	sessionModeHeader = "X-Session-Mode"
+	// sessionCookieKey holds the refresh token of the session cookie in the context
+	sessionCookieKey = "sessionCookie"
+)
+
+// sessionCookieAuth reads the refresh token from the session cookie if SESSION_COOKIE_ENABLED is set, cookie takes
+// precedence over the Authorization header and the body. Cookie authenticated requests must echo the csrf cookie in
+// the X-CSRF-Token header, which a cross site request can not read
+func sessionCookieAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !opts.SessionCookieEnabled {
+			c.Next()
+			return
+		}
+
+		token, err := c.Cookie(sessionCookieName)
+		if err != nil || token == "" {
+			c.Next()
+			return
+		}
+
+		if !validCsrfToken(c, token) {
+			logger.Warn("request rejected due to invalid csrf token")
+			errorResponse(c, errInvalidCsrfToken)
+			c.Abort()
+			return
+		}
+
+		c.Set(sessionCookieKey, token)
+		c.Next()
+	}
+}
+
+// cookieMode checks if the tokens of a login or a refresh should be stored in the cookies
+func cookieMode(c *gin.Context) bool {
+	return opts.SessionCookieEnabled && (c.GetHeader(sessionModeHeader) == "cookie" ||
+		c.GetString(sessionCookieKey) != "")
+}
+
+// csrfToken derives the csrf token from the refresh token, so that a csrf cookie planted by a sibling domain can
+// not be used with a session it does not belong to
+func csrfToken(refreshToken string) string {
+	sum := sha256.Sum256([]byte("csrf:" + refreshToken))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// validCsrfToken checks the double submitted csrf token of the request against the session cookie
+func validCsrfToken(c *gin.Context, refreshToken string) bool {
+	header := c.GetHeader(csrfHeader)
+	cookie, err := c.Cookie(csrfCookieName)
+	if err != nil || header == "" {
+		return false
+	}
+
+	expected := []byte(csrfToken(refreshToken))
+	return subtle.ConstantTimeCompare([]byte(header), []byte(cookie)) == 1 &&
+		subtle.ConstantTimeCompare([]byte(header), expected) == 1
+}
+
+// sessionCookiePath returns SESSION_COOKIE_PATH, or the path of the auth routes of the request like /v2/auth so that
+// the cookie is sent to refresh and logout of the same api version and realm
+func sessionCookiePath(c *gin.Context) string {
+	if opts.SessionCookiePath != "" {
+		return opts.SessionCookiePath
+	}
+
+	if path, ok := authPath(c.FullPath(), c.Request.URL.Path); ok {
+		return path
+	}
+
+	return "/"
+}
+
+// setSessionCookies stores the refresh token in an HttpOnly cookie and its csrf token in a cookie which is readable
+// by the scripts of the dashboard, both live as long as the refresh token
+func setSessionCookies(c *gin.Context, refreshToken string) {
+	maxAge := int(currentRealm(c).RefreshTokenValidInMinutes()) * 60
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    refreshToken,
+		Path:     sessionCookiePath(c),
+		Domain:   opts.SessionCookieDomain,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     csrfCookieName,
+		Value:    csrfToken(refreshToken),
+		Path:     "/",
+		Domain:   opts.SessionCookieDomain,
+		MaxAge:   maxAge,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// clearSessionCookies expires the cookies of setSessionCookies
+func clearSessionCookies(c *gin.Context) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     sessionCookieName,
+		Path:     sessionCookiePath(c),
+		Domain:   opts.SessionCookieDomain,
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     csrfCookieName,
+		Path:     "/",
+		Domain:   opts.SessionCookieDomain,
+		MaxAge:   -1,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
@@ -0,0 +1,96 @@
+package web
+
+import (
+	"auth-service/internal/model"
+	"auth-service/internal/options"
+	"auth-service/internal/realm"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newCookieRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	opts = &options.AuthServiceOptions{SessionCookieEnabled: true}
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("realm", &realm.Realm{Realm: model.Realm{Name: "acme", RefreshTokenValidInMinutes: 60}})
+	})
+	router.POST("/realms/:realm/v2/auth/authenticate", apiV2(), func(c *gin.Context) {
+		loginResponse(c, &model.User{UserName: "john", AccessToken: "access", RefreshToken: "refresh"})
+	})
+	router.POST("/realms/:realm/v2/auth/refresh", apiV2(), sessionCookieAuth(), func(c *gin.Context) {
+		c.String(http.StatusOK, c.GetString(sessionCookieKey))
+	})
+	return router
+}
+
+func responseCookie(rec *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+
+	return nil
+}
+
+func TestLoginResponseCookieMode(t *testing.T) {
+	router := newCookieRouter()
+	req := httptest.NewRequest(http.MethodPost, "/realms/acme/v2/auth/authenticate", nil)
+	req.Header.Set(sessionModeHeader, "cookie")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	session := responseCookie(rec, sessionCookieName)
+	if session == nil || session.Value != "refresh" || session.Path != "/realms/acme/v2/auth" || !session.HttpOnly ||
+		!session.Secure || session.SameSite != http.SameSiteStrictMode || session.MaxAge != 3600 {
+		t.Errorf("expected the session cookie scoped to the auth routes, got %+v", session)
+	}
+
+	csrf := responseCookie(rec, csrfCookieName)
+	if csrf == nil || csrf.Value != csrfToken("refresh") || csrf.HttpOnly {
+		t.Errorf("expected a readable csrf cookie, got %+v", csrf)
+	}
+
+	if strings.Contains(rec.Body.String(), "refreshToken") || !strings.Contains(rec.Body.String(), "access") {
+		t.Errorf("expected only the access token in the body, got %s", rec.Body.String())
+	}
+}
+
+func TestSessionCookieAuth(t *testing.T) {
+	router := newCookieRouter()
+	cases := []struct {
+		name, csrfCookie, csrfHeader string
+		code                         int
+	}{
+		{"missing header", csrfToken("refresh"), "", http.StatusForbidden},
+		{"header not matching the cookie", csrfToken("refresh"), "forged", http.StatusForbidden},
+		{"token of another session", csrfToken("other"), csrfToken("other"), http.StatusForbidden},
+		{"valid", csrfToken("refresh"), csrfToken("refresh"), http.StatusOK},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodPost, "/realms/acme/v2/auth/refresh", nil)
+		req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: "refresh"})
+		req.AddCookie(&http.Cookie{Name: csrfCookieName, Value: c.csrfCookie})
+		if c.csrfHeader != "" {
+			req.Header.Set(csrfHeader, c.csrfHeader)
+		}
+
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != c.code {
+			t.Errorf("%s: expected %d, got %d %s", c.name, c.code, rec.Code, rec.Body.String())
+		}
+	}
+
+	// requests without the cookie are left to the other token sources
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/realms/acme/v2/auth/refresh", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "" {
+		t.Errorf("expected the request without the cookie to pass, got %d %s", rec.Code, rec.Body.String())
+	}
+}
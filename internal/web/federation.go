@@ -0,0 +1,189 @@
+package web
+
+import (
+	"auth-service/internal/federation"
+	"auth-service/internal/jwt"
+	"crypto/rand"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+	"time"
+)
+
+const (
+	oidcStateCookie = "vpnbeast_oidc_state"
+	oidcStateTtl    = 10 * time.Minute
+)
+
+var (
+	identityProviders *federation.Registry
+	oidcStateSecret   []byte
+)
+
+// initFederation loads the upstream identity providers if configured. State cookies are signed with a random key if
+// no secret is configured, which only works with a single replica
+func initFederation() error {
+	oidcStateSecret = []byte(opts.OidcStateSecret)
+	if len(oidcStateSecret) == 0 {
+		oidcStateSecret = make([]byte, 32)
+		if _, err := rand.Read(oidcStateSecret); err != nil {
+			return fmt.Errorf("an error occurred while generating oidc state secret: %w", err)
+		}
+	}
+
+	if opts.OidcProvidersFile == "" {
+		return nil
+	}
+
+	if opts.OidcStateSecret == "" {
+		logger.Warn("OIDC_STATE_SECRET is not configured, federated logins will fail across replicas")
+	}
+
+	configs, err := federation.LoadProviderConfigs(opts.OidcProvidersFile, jwt.DefaultRealm)
+	if err != nil {
+		return fmt.Errorf("an error occurred while loading identity providers: %w", err)
+	}
+
+	identityProviders = federation.NewRegistry(configs)
+	logger.Info("identity providers loaded", zap.Int("count", len(configs)))
+	return nil
+}
+
+// identityProvider finds the provider path parameter in the realm of the request, writes the error response and
+// returns false if it can not
+func identityProvider(context *gin.Context) (*federation.Provider, bool) {
+	provider, err := identityProviders.Get(currentRealm(context).Name, context.Param("provider"))
+	if err != nil {
+		errorResponse(context, errProviderNotFound)
+		context.Abort()
+		return nil, false
+	}
+
+	return provider, true
+}
+
+func oidcLoginHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		provider, ok := identityProvider(context)
+		if !ok {
+			return
+		}
+
+		state, err := federation.NewFlowState(currentRealm(context).Name, provider.Config().Name, oidcStateTtl)
+		if err != nil {
+			logger.Error("an error occurred while generating login state", zap.String("error", err.Error()))
+			errorResponse(context, errUnknown)
+			context.Abort()
+			return
+		}
+
+		url, err := provider.AuthCodeUrl(context.Request.Context(), state)
+		if err != nil {
+			logger.Error("an error occurred while discovering identity provider",
+				zap.String("provider", provider.Config().Name), zap.String("error", err.Error()))
+			errorResponse(context, errProviderUnavailable)
+			context.Abort()
+			return
+		}
+
+		cookie, err := state.Encode(oidcStateSecret)
+		if err != nil {
+			logger.Error("an error occurred while encoding login state", zap.String("error", err.Error()))
+			errorResponse(context, errUnknown)
+			context.Abort()
+			return
+		}
+
+		http.SetCookie(context.Writer, &http.Cookie{
+			Name:     oidcStateCookie,
+			Value:    cookie,
+			Path:     "/",
+			MaxAge:   int(oidcStateTtl.Seconds()),
+			HttpOnly: true,
+			Secure:   context.Request.TLS != nil || context.GetHeader("X-Forwarded-Proto") == "https",
+			// the callback is a top level navigation from the provider, so Lax is enough
+			SameSite: http.SameSiteLaxMode,
+		})
+		context.Redirect(http.StatusFound, url)
+	}
+}
+
+func oidcCallbackHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		provider, ok := identityProvider(context)
+		if !ok {
+			return
+		}
+
+		// the state cookie is single use
+		http.SetCookie(context.Writer, &http.Cookie{Name: oidcStateCookie, Path: "/", MaxAge: -1})
+		r := currentRealm(context)
+		if errParam := context.Query("error"); errParam != "" {
+			logger.Warn("identity provider returned an error", zap.String("provider", provider.Config().Name),
+				zap.String("error", errParam))
+			errorResponse(context, errFederatedLoginFailed)
+			context.Abort()
+			return
+		}
+
+		cookie, err := context.Cookie(oidcStateCookie)
+		if err != nil {
+			errorResponse(context, errInvalidLoginState)
+			context.Abort()
+			return
+		}
+
+		state, err := federation.DecodeFlowState(oidcStateSecret, cookie)
+		if err != nil || !state.Matches(r.Name, provider.Config().Name, context.Query("state")) {
+			errorResponse(context, errInvalidLoginState)
+			context.Abort()
+			return
+		}
+
+		identity, err := provider.Exchange(context.Request.Context(), context.Query("code"), state)
+		if err != nil {
+			logger.Warn("an error occurred while exchanging authorization code",
+				zap.String("provider", provider.Config().Name), zap.String("error", err.Error()))
+			errorResponse(context, errFederatedLoginFailed)
+			context.Abort()
+			return
+		}
+
+		user, err := federation.Link(db, r.Id, provider.Config(), identity)
+		switch err {
+		case nil:
+		case federation.ErrNotLinked:
+			logger.Warn("federated identity is not linked", zap.String("provider", provider.Config().Name),
+				zap.String("subject", identity.Subject))
+			recordFederatedLogin(context, provider.Config().Name, identity.Subject, "not_linked")
+			errorResponse(context, errUserNotLinked)
+			context.Abort()
+			return
+		case federation.ErrUserConflict:
+			// existence of the conflicting account is not revealed to the client
+			logger.Warn("user with the same email belongs to an identity backend",
+				zap.String("provider", provider.Config().Name), zap.String("subject", identity.Subject))
+			recordFederatedLogin(context, provider.Config().Name, identity.Subject, "conflict")
+			errorResponse(context, errUserNotLinked)
+			context.Abort()
+			return
+		default:
+			logger.Error("an error occurred while linking federated identity", zap.String("error", err.Error()))
+			errorResponse(context, errUnknown)
+			context.Abort()
+			return
+		}
+
+		err = authService.AuthenticateFederated(context.Request.Context(), r, provider.Config().Name, user,
+			context.GetHeader(deviceIdHeader))
+		if err != nil {
+			authErrorResponse(context, err)
+			return
+		}
+
+		logger.Info("federated login succeeded", zap.String("user", user.UserName),
+			zap.String("provider", provider.Config().Name))
+		loginResponse(context, user)
+	}
+}
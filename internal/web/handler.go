@@ -1,16 +1,18 @@
 package web
 
 import (
-	"auth-service/internal/database"
-	"auth-service/internal/jwt"
+	"auth-service/internal/auth"
+	"auth-service/internal/encryption"
 	"auth-service/internal/model"
+	"errors"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
-	"gorm.io/gorm"
 	"net/http"
 	"time"
 )
 
+var authService *auth.AuthService
+
 func pingHandler() gin.HandlerFunc {
 	return func(context *gin.Context) {
 		context.JSON(http.StatusOK, gin.H{
@@ -23,137 +25,53 @@ func pingHandler() gin.HandlerFunc {
 
 func whoamiHandler() gin.HandlerFunc {
 	return func(context *gin.Context) {
-		token := context.Request.Header.Get("Authorization")[7:]
-		subject, _, err, code := jwt.ValidateToken(token)
-		if err != nil {
-			validateRes := validateResponse{
-				Status:       false,
-				ErrorMessage: err.Error(),
-				HttpCode:     code,
-				Timestamp:    time.Now().Format(time.RFC3339),
-			}
-			context.JSON(code, validateRes)
-			context.Abort()
+		token, ok := bearerToken(context)
+		if !ok {
+			tokenFailResponse(context, errMissingToken, errMissingToken.Title)
 			return
 		}
-		var user model.User
-		db := database.GetDatabase()
-		switch err := db.Preload("Roles").Where(queryUsername, subject).First(&user).Error; err {
-		case gorm.ErrRecordNotFound:
-			logger.Warn(errNoRowsReturned, zap.String("user", subject))
-			errorResponse(context, http.StatusNotFound, errUserNotFound)
-			context.Abort()
-			return
-		case nil:
-			authRes := authSuccessResponse{
-				Uuid:                       user.Uuid,
-				Id:                         user.Id,
-				CreatedAt:                  user.CreatedAt,
-				UpdatedAt:                  user.UpdatedAt,
-				Version:                    user.Version,
-				Username:                   user.UserName,
-				Email:                      user.Email,
-				LastLogin:                  user.LastLogin,
-				Enabled:                    user.Enabled,
-				EmailVerified:              user.EmailVerified,
-				AccessToken:                user.AccessToken,
-				AccessTokenExpiresAt:       user.AccessTokenExpiresAt,
-				RefreshToken:               user.RefreshToken,
-				RefreshTokenExpiresAt:      user.RefreshTokenExpiresAt,
-				VerificationCodeCreatedAt:  user.VerificationCodeCreatedAt,
-				VerificationCodeVerifiedAt: user.VerificationCodeVerifiedAt,
-				Roles:                      user.Roles,
-			}
-			context.JSON(http.StatusOK, authRes)
-			context.Abort()
+
+		user, err := authService.WhoAmI(context.Request.Context(), currentRealm(context), token)
+		if err != nil {
+			authErrorResponse(context, err)
 			return
 		}
+
+		if isV2(context) {
+			context.JSON(http.StatusOK, toUserResponse(user))
+		} else {
+			context.JSON(http.StatusOK, toAuthSuccessResponse(user))
+		}
+		context.Abort()
 	}
 }
 
+// refreshHandler issues new tokens for the refresh token, which is in the body on /v2 and in the Authorization
+// header on /auth unless it is in the session cookie
 func refreshHandler() gin.HandlerFunc {
 	return func(context *gin.Context) {
-		token := context.Request.Header.Get("Authorization")[7:]
-		subject, roles, err, code := jwt.ValidateToken(token)
-		if err != nil {
-			validateRes := validateResponse{
-				Status:       false,
-				ErrorMessage: err.Error(),
-				HttpCode:     code,
-				Timestamp:    time.Now().Format(time.RFC3339),
-			}
-			context.JSON(code, validateRes)
-			context.Abort()
-			return
+		token, ok := bearerToken(context)
+		if isV2(context) {
+			req, _ := context.Get("data")
+			token, ok = req.(refreshRequest).RefreshToken, true
 		}
 
-		var user model.User
-		db := database.GetDatabase()
-		switch err := db.Preload("Roles").Where(queryUsername, subject).First(&user).Error; err {
-		case gorm.ErrRecordNotFound:
-			logger.Warn(errNoRowsReturned, zap.String("user", subject))
-			errorResponse(context, http.StatusNotFound, errUserNotFound)
-			context.Abort()
-			return
-		case nil:
-			accessToken, err := jwt.GenerateToken(subject, roles, int32(opts.AccessTokenValidInMinutes))
-			if err != nil {
-				logger.Error("an error occurred generating access token",
-					zap.String("error", err.Error()))
-				errorResponse(context, http.StatusInternalServerError, errUnknown)
-				context.Abort()
-				return
-			}
-
-			refreshToken, err := jwt.GenerateToken(subject, roles, int32(opts.RefreshTokenValidInMinutes))
-			if err != nil {
-				logger.Error("an error occurred generating refresh token",
-					zap.String("error", err.Error()))
-				errorResponse(context, http.StatusInternalServerError, errUnknown)
-				context.Abort()
-				return
-			}
+		if cookie := context.GetString(sessionCookieKey); cookie != "" {
+			token, ok = cookie, true
+		}
 
-			now := time.Now().Format(time.RFC3339)
-			user.LastLogin = now
-			user.UpdatedAt = now
-			user.AccessToken = accessToken
-			user.AccessTokenExpiresAt = time.Now().Add(time.Duration(opts.AccessTokenValidInMinutes) * time.Minute).Format(time.RFC3339)
-			user.RefreshToken = refreshToken
-			user.RefreshTokenExpiresAt = time.Now().Add(time.Duration(opts.RefreshTokenValidInMinutes) * time.Minute).Format(time.RFC3339)
-			user.Version = user.Version + 1
+		if !ok {
+			tokenFailResponse(context, errMissingToken, errMissingToken.Title)
+			return
+		}
 
-			switch err := db.Save(&user).Error; err {
-			case nil:
-				authRes := authSuccessResponse{
-					Uuid:                       user.Uuid,
-					Id:                         user.Id,
-					CreatedAt:                  user.CreatedAt,
-					UpdatedAt:                  user.UpdatedAt,
-					Version:                    user.Version,
-					Username:                   user.UserName,
-					Email:                      user.Email,
-					LastLogin:                  user.LastLogin,
-					Enabled:                    user.Enabled,
-					EmailVerified:              user.EmailVerified,
-					AccessToken:                user.AccessToken,
-					AccessTokenExpiresAt:       user.AccessTokenExpiresAt,
-					RefreshToken:               user.RefreshToken,
-					RefreshTokenExpiresAt:      user.RefreshTokenExpiresAt,
-					VerificationCodeCreatedAt:  user.VerificationCodeCreatedAt,
-					VerificationCodeVerifiedAt: user.VerificationCodeVerifiedAt,
-					Roles:                      user.Roles,
-				}
-				context.JSON(http.StatusOK, authRes)
-				context.Abort()
-				return
-			default:
-				logger.Warn("an error  occurred while updating db", zap.String("error", err.Error()))
-				errorResponse(context, http.StatusInternalServerError, errUnknown)
-				context.Abort()
-				return
-			}
+		user, err := authService.Refresh(context.Request.Context(), currentRealm(context), token)
+		if err != nil {
+			authErrorResponse(context, err)
+			return
 		}
+
+		loginResponse(context, user)
 	}
 }
 
@@ -161,44 +79,43 @@ func validateHandler() gin.HandlerFunc {
 	return func(context *gin.Context) {
 		req, _ := context.Get("data")
 		validateReq := req.(validateRequest)
-		subject, roles, err, code := jwt.ValidateToken(validateReq.Token)
-		if err != nil {
-			validateRes := validateResponse{
-				Status:       false,
-				ErrorMessage: err.Error(),
-				HttpCode:     code,
-				Timestamp:    time.Now().Format(time.RFC3339),
-			}
-			context.JSON(code, validateRes)
-			context.Abort()
+		claims, err := authService.Validate(context.Request.Context(), currentRealm(context), validateReq.Token)
+		switch err {
+		case auth.ErrEntitlementExpired:
+			logger.Info("token carries expired entitlements", zap.String("user", claims.Subject),
+				zap.Strings("entitlements", claims.ExpiredEntitlements(time.Now().Unix())))
+			tokenFailResponse(context, errEntitlementExpired, err.Error())
 			return
-		}
-
-		var user model.User
-		db := database.GetDatabase()
-		switch err := db.Where(queryUsername, subject).First(&user).Error; err {
-		case gorm.ErrRecordNotFound:
-			logger.Warn(errNoRowsReturned, zap.String("user", subject))
-			validateRes := validateResponse{
-				Status:       false,
-				ErrorMessage: "no such user",
-				HttpCode:     404,
-				Timestamp:    time.Now().Format(time.RFC3339),
-			}
-			context.JSON(http.StatusNotFound, validateRes)
-			context.Abort()
+		case auth.ErrUserNotFound:
+			logger.Warn(errNoRowsReturned, zap.String("user", claims.Subject))
+			tokenFailResponse(context, errUserNotFound, "no such user")
 			return
 		case nil:
+			if isV2(context) {
+				context.JSON(http.StatusOK, tokenInfoResponse{
+					Username:     claims.Subject,
+					Roles:        claims.Roles,
+					Entitlements: claims.Entitlements,
+					Scope:        claims.Scope,
+				})
+				context.Abort()
+				return
+			}
+
 			validateRes := validateResponse{
-				Status:    true,
-				Username:  subject,
-				Roles:     roles,
-				HttpCode:  200,
-				Timestamp: time.Now().Format(time.RFC3339),
+				Status:       true,
+				Username:     claims.Subject,
+				Roles:        claims.Roles,
+				Entitlements: claims.Entitlements,
+				HttpCode:     200,
+				Timestamp:    time.Now().Format(time.RFC3339),
 			}
 			context.JSON(http.StatusOK, validateRes)
 			context.Abort()
 			return
+		default:
+			authErrorResponse(context, err)
+			return
 		}
 	}
 }
@@ -206,101 +123,125 @@ func validateHandler() gin.HandlerFunc {
 func authenticateHandler() gin.HandlerFunc {
 	return func(context *gin.Context) {
 		req, _ := context.Get("data")
-		logger.Info("", zap.Any("req", req))
 		authReq := req.(authRequest)
-		logger.Info("", zap.Any("authReq", authReq.Username))
-		var user model.User
-		db := database.GetDatabase()
-		switch err := db.Preload("Roles").Where(queryUsername, authReq.Username).First(&user).Error; err {
-		case gorm.ErrRecordNotFound:
-			logger.Warn(errNoRowsReturned, zap.String("user", authReq.Username))
-			errorResponse(context, http.StatusNotFound, errUserNotFound)
-			context.Abort()
+		user, err := authService.Authenticate(context.Request.Context(), currentRealm(context), auth.Credentials{
+			Username: authReq.Username,
+			Password: authReq.Password,
+			DeviceId: context.GetHeader(deviceIdHeader),
+		})
+		if err != nil {
+			authErrorResponse(context, err)
 			return
-		case nil:
-			encryptReq := encryptRequest{
-				PlainText:     authReq.Password,
-				EncryptedText: user.EncryptedPassword,
-			}
+		}
 
-			encryptRes, err := encryptReq.encrypt(authReq.Password, user.EncryptedPassword)
-			if err != nil {
-				logger.Error("an error occurred while making encryption request", zap.String("error", err.Error()))
-				errorResponse(context, http.StatusInternalServerError, errUnknown)
-				context.Abort()
-				return
-			}
+		loginResponse(context, user)
+	}
+}
 
-			var roles []string
-			for _, v := range user.Roles {
-				logger.Info("appending role to roles", zap.String("role", v.Name))
-				roles = append(roles, v.Name)
-			}
+// loginResponse writes the user and the tokens of a login or a refresh in the response model of the api version. In
+// the cookie mode the refresh token is only set in the session cookie
+func loginResponse(context *gin.Context, user *model.User) {
+	if cookieMode(context) {
+		setSessionCookies(context, user.RefreshToken)
+		copied := *user
+		copied.RefreshToken, copied.RefreshTokenExpiresAt = "", ""
+		user = &copied
+	}
 
-			if encryptRes.Status {
-				accessToken, err := jwt.GenerateToken(authReq.Username, roles, int32(opts.AccessTokenValidInMinutes))
-				if err != nil {
-					logger.Error("an error occurred generating access token",
-						zap.String("error", err.Error()))
-					errorResponse(context, http.StatusInternalServerError, errUnknown)
-					context.Abort()
-					return
-				}
+	if isV2(context) {
+		context.JSON(http.StatusOK, sessionResponse{
+			User: toUserResponse(user),
+			Tokens: tokenPair{
+				AccessToken:           user.AccessToken,
+				AccessTokenExpiresAt:  user.AccessTokenExpiresAt,
+				RefreshToken:          user.RefreshToken,
+				RefreshTokenExpiresAt: user.RefreshTokenExpiresAt,
+			},
+		})
+	} else {
+		context.JSON(http.StatusOK, toAuthSuccessResponse(user))
+	}
+	context.Abort()
+}
 
-				refreshToken, err := jwt.GenerateToken(authReq.Username, roles, int32(opts.RefreshTokenValidInMinutes))
-				if err != nil {
-					logger.Error("an error occurred generating refresh token",
-						zap.String("error", err.Error()))
-					errorResponse(context, http.StatusInternalServerError, errUnknown)
-					context.Abort()
-					return
-				}
+// authErrorResponse maps the errors of the auth package to the responses
+func authErrorResponse(context *gin.Context, err error) {
+	var tokenErr *auth.TokenError
+	switch {
+	case errors.As(err, &tokenErr):
+		tokenErrorResponse(context, tokenErr.Code, tokenErr)
+		return
+	case err == auth.ErrUserNotFound:
+		logger.Warn(errNoRowsReturned, zap.String("error", err.Error()))
+		errorResponse(context, errUserNotFound)
+	case err == auth.ErrInvalidCredentials:
+		logger.Error("password validation failed")
+		errorResponse(context, errInvalidPass)
+	case err == auth.ErrUserConflict:
+		// existence of the conflicting account is not revealed to the client
+		logger.Warn("user belongs to another identity backend")
+		errorResponse(context, errInvalidPass)
+	case err == auth.ErrUserDisabled:
+		logger.Warn("disabled user attempted to log in")
+		errorResponse(context, errUserDisabled)
+	case err == auth.ErrUserLocked:
+		logger.Warn("locked user attempted to log in")
+		errorResponse(context, errUserLocked)
+	case err == auth.ErrLoginBlocked:
+		logger.Warn("suspicious login is blocked")
+		errorResponse(context, errLoginBlocked)
+	case err == auth.ErrStepUpRequired:
+		logger.Warn("suspicious login requires a second factor")
+		errorResponse(context, errStepUpRequired)
+	case errors.Is(err, encryption.ErrUnavailable):
+		logger.Error("an error occurred while checking password", zap.String("error", err.Error()))
+		errorResponse(context, errEncryptionUnavailable)
+	default:
+		logger.Error("an error occurred while authenticating user", zap.String("error", err.Error()))
+		errorResponse(context, errUnknown)
+	}
+	context.Abort()
+}
 
-				now := time.Now().Format(time.RFC3339)
-				user.LastLogin = now
-				user.UpdatedAt = now
-				user.AccessToken = accessToken
-				user.AccessTokenExpiresAt = time.Now().Add(time.Duration(opts.AccessTokenValidInMinutes) * time.Minute).Format(time.RFC3339)
-				user.RefreshToken = refreshToken
-				user.RefreshTokenExpiresAt = time.Now().Add(time.Duration(opts.RefreshTokenValidInMinutes) * time.Minute).Format(time.RFC3339)
-				user.Version = user.Version + 1
+func toAuthSuccessResponse(user *model.User) authSuccessResponse {
+	return authSuccessResponse{
+		Uuid:                       user.Uuid,
+		Id:                         user.Id,
+		CreatedAt:                  user.CreatedAt,
+		UpdatedAt:                  user.UpdatedAt,
+		Version:                    user.Version,
+		Username:                   user.UserName,
+		Email:                      user.Email,
+		LastLogin:                  user.LastLogin,
+		Enabled:                    user.Enabled,
+		EmailVerified:              user.EmailVerified,
+		AccessToken:                user.AccessToken,
+		AccessTokenExpiresAt:       user.AccessTokenExpiresAt,
+		RefreshToken:               user.RefreshToken,
+		RefreshTokenExpiresAt:      user.RefreshTokenExpiresAt,
+		VerificationCodeCreatedAt:  user.VerificationCodeCreatedAt,
+		VerificationCodeVerifiedAt: user.VerificationCodeVerifiedAt,
+		Roles:                      user.Roles,
+	}
+}
 
-				switch err := db.Save(&user).Error; err {
-				case nil:
-					authRes := authSuccessResponse{
-						Uuid:                       user.Uuid,
-						Id:                         user.Id,
-						CreatedAt:                  user.CreatedAt,
-						UpdatedAt:                  user.UpdatedAt,
-						Version:                    user.Version,
-						Username:                   user.UserName,
-						Email:                      user.Email,
-						LastLogin:                  user.LastLogin,
-						Enabled:                    user.Enabled,
-						EmailVerified:              user.EmailVerified,
-						AccessToken:                user.AccessToken,
-						AccessTokenExpiresAt:       user.AccessTokenExpiresAt,
-						RefreshToken:               user.RefreshToken,
-						RefreshTokenExpiresAt:      user.RefreshTokenExpiresAt,
-						VerificationCodeCreatedAt:  user.VerificationCodeCreatedAt,
-						VerificationCodeVerifiedAt: user.VerificationCodeVerifiedAt,
-						Roles:                      user.Roles,
-					}
-					context.JSON(http.StatusOK, authRes)
-					context.Abort()
-					return
-				default:
-					logger.Warn("an error  occurred while updating db", zap.String("error", err.Error()))
-					errorResponse(context, http.StatusInternalServerError, errUnknown)
-					context.Abort()
-					return
-				}
-			} else {
-				logger.Error("password validation failed")
-				errorResponse(context, http.StatusBadRequest, errInvalidPass)
-				context.Abort()
-				return
-			}
-		}
+func toUserResponse(user *model.User) userResponse {
+	roles := make([]string, 0, len(user.Roles))
+	for _, role := range user.Roles {
+		roles = append(roles, role.Name)
+	}
+
+	return userResponse{
+		Id:            user.Id,
+		Uuid:          user.Uuid,
+		Username:      user.UserName,
+		Email:         user.Email,
+		Enabled:       user.Enabled,
+		EmailVerified: user.EmailVerified,
+		LastLogin:     user.LastLogin,
+		Roles:         roles,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Version:       user.Version,
 	}
 }
@@ -0,0 +1,42 @@
+package web
+
+import (
+	"auth-service/internal/jwt"
+	"auth-service/internal/risk"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+	"strings"
+)
+
+// deviceIdHeader optionally identifies the device of the client, user agent is used for the new device detection if
+// it is missing
+const deviceIdHeader = "X-Device-Id"
+
+func loginHistoryHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		limit, offset, errs := pagination(context, nil)
+		if len(errs) != 0 {
+			validationResponse(context, errs)
+			context.Abort()
+			return
+		}
+
+		claims := context.MustGet("claims").(*jwt.VpnbeastClaim)
+		history, err := risk.History(db, currentRealm(context).Id, claims.Subject, context.Query("result"), limit,
+			offset)
+		if err != nil {
+			logger.Error("an error occurred while querying login history", zap.String("error", err.Error()))
+			errorResponse(context, errUnknown)
+			context.Abort()
+			return
+		}
+
+		res := make([]loginHistoryResponse, 0, len(history))
+		for _, h := range history {
+			res = append(res, loginHistoryResponse{LoginHistory: h, Signals: strings.Fields(h.Signals)})
+		}
+
+		context.JSON(http.StatusOK, res)
+	}
+}
@@ -0,0 +1,71 @@
+package web
+
+import (
+	"auth-service/internal/encryption"
+	"auth-service/internal/identity"
+	"auth-service/internal/model"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"net/http"
+	"time"
+)
+
+var identityBackends *identity.Registry
+
+// initIdentityBackends registers the local backend which verifies the passwords with the encryption-service, and the
+// backends in the identity backends file if configured
+func initIdentityBackends(client *encryption.Client) error {
+	local := identity.NewLocalBackend(client.Check)
+
+	var backends []identity.Backend
+	if opts.IdentityBackendsFile != "" {
+		var err error
+		if backends, err = identity.LoadBackends(opts.IdentityBackendsFile); err != nil {
+			return fmt.Errorf("an error occurred while loading identity backends: %w", err)
+		}
+	}
+
+	identityBackends = identity.NewRegistry(local, backends...)
+	logger.Info("identity backends loaded", zap.Int("count", len(backends)))
+	return nil
+}
+
+func assignIdentityBackendHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		req, _ := context.Get("data")
+		backendReq := req.(identityBackendRequest)
+
+		var user model.User
+		switch err := db.Where(queryUuid, currentRealm(context).Id, context.Param("uuid")).First(&user).Error; err {
+		case gorm.ErrRecordNotFound:
+			logger.Warn(errNoRowsReturned, zap.String("uuid", context.Param("uuid")))
+			errorResponse(context, errUserNotFound)
+			context.Abort()
+			return
+		case nil:
+			err := db.Model(&user).Updates(map[string]interface{}{
+				"identity_backend": backendReq.Backend,
+				"updated_at":       time.Now().Format(time.RFC3339),
+			}).Error
+			if err != nil {
+				logger.Error("an error occurred while updating user", zap.String("error", err.Error()))
+				errorResponse(context, errUnknown)
+				context.Abort()
+				return
+			}
+
+			logger.Info("identity backend assigned", zap.String("user", user.UserName),
+				zap.String("backend", backendReq.Backend))
+			context.Status(http.StatusNoContent)
+			context.Abort()
+			return
+		default:
+			logger.Error("an error occurred while querying user", zap.String("error", err.Error()))
+			errorResponse(context, errUnknown)
+			context.Abort()
+			return
+		}
+	}
+}
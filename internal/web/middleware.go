@@ -7,9 +7,9 @@ import (
 func authRequestValidator() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var authReq authRequest
-		_, errSlice := isValidRequest(c, &authReq)
-		if len(errSlice) != 0 {
-			validationResponse(c, errSlice)
+		_, errs := isValidRequest(c, &authReq)
+		if len(errs) != 0 {
+			validationResponse(c, errs)
 			c.Abort()
 			return
 		}
@@ -22,9 +22,9 @@ func authRequestValidator() gin.HandlerFunc {
 func validateRequestValidator() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var validateReq validateRequest
-		_, errSlice := isValidRequest(c, &validateReq)
-		if len(errSlice) != 0 {
-			validationResponse(c, errSlice)
+		_, errs := isValidRequest(c, &validateReq)
+		if len(errs) != 0 {
+			validationResponse(c, errs)
 			c.Abort()
 			return
 		}
@@ -33,3 +33,161 @@ func validateRequestValidator() gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+func certificateRequestValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var certificateReq certificateRequest
+		_, errs := isValidRequest(c, &certificateReq)
+		if len(errs) != 0 {
+			validationResponse(c, errs)
+			c.Abort()
+			return
+		}
+
+		c.Set("data", certificateReq)
+		c.Next()
+	}
+}
+
+func entitlementRequestValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var entitlementReq entitlementRequest
+		_, errs := isValidRequest(c, &entitlementReq)
+		if len(errs) != 0 {
+			validationResponse(c, errs)
+			c.Abort()
+			return
+		}
+
+		c.Set("data", entitlementReq)
+		c.Next()
+	}
+}
+
+func identityBackendRequestValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var backendReq identityBackendRequest
+		_, errs := isValidRequest(c, &backendReq)
+		if len(errs) != 0 {
+			validationResponse(c, errs)
+			c.Abort()
+			return
+		}
+
+		if backendReq.Backend != "" {
+			if _, err := identityBackends.Get(backendReq.Backend); err != nil {
+				validationResponse(c, []fieldError{invalidField("backend", errBackendNotFound)})
+				c.Abort()
+				return
+			}
+		}
+
+		c.Set("data", backendReq)
+		c.Next()
+	}
+}
+
+func personalAccessTokenRequestValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var tokenReq personalAccessTokenRequest
+		_, errs := isValidRequest(c, &tokenReq)
+		if len(errs) != 0 {
+			validationResponse(c, errs)
+			c.Abort()
+			return
+		}
+
+		c.Set("data", tokenReq)
+		c.Next()
+	}
+}
+
+func authorizeRequestValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var authorizeReq authorizeRequest
+		_, errs := isValidRequest(c, &authorizeReq)
+		if len(errs) != 0 {
+			validationResponse(c, errs)
+			c.Abort()
+			return
+		}
+
+		c.Set("data", authorizeReq)
+		c.Next()
+	}
+}
+
+func permissionRequestValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var permissionReq permissionRequest
+		_, errs := isValidRequest(c, &permissionReq)
+		if len(errs) != 0 {
+			validationResponse(c, errs)
+			c.Abort()
+			return
+		}
+
+		c.Set("data", permissionReq)
+		c.Next()
+	}
+}
+
+func decisionRequestValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var decisionReq decisionRequest
+		_, errs := isValidRequest(c, &decisionReq)
+		if len(errs) != 0 {
+			validationResponse(c, errs)
+			c.Abort()
+			return
+		}
+
+		c.Set("data", decisionReq)
+		c.Next()
+	}
+}
+
+func realmRequestValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var realmReq realmRequest
+		_, errs := isValidRequest(c, &realmReq)
+		if len(errs) != 0 {
+			validationResponse(c, errs)
+			c.Abort()
+			return
+		}
+
+		if realmReq.IdentityBackend != "" {
+			if _, err := identityBackends.Get(realmReq.IdentityBackend); err != nil {
+				validationResponse(c, []fieldError{invalidField("identityBackend", errBackendNotFound)})
+				c.Abort()
+				return
+			}
+		}
+
+		c.Set("data", realmReq)
+		c.Next()
+	}
+}
+
+func refreshRequestValidator() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var refreshReq refreshRequest
+		// the refresh token is in the session cookie in the cookie mode
+		if c.GetString(sessionCookieKey) != "" {
+			c.Set("data", refreshReq)
+			c.Next()
+			return
+		}
+
+		_, errs := isValidRequest(c, &refreshReq)
+		if len(errs) != 0 {
+			validationResponse(c, errs)
+			c.Abort()
+			return
+		}
+
+		c.Set("data", refreshReq)
+		c.Next()
+	}
+}
@@ -0,0 +1,68 @@
+package web
+
+import (
+	"auth-service/api/openapi"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	swaggerFiles "github.com/swaggo/files/v2"
+	"net/http"
+	"strings"
+)
+
+// swaggerInitializer replaces the initializer of the Swagger UI distribution which points to the petstore example,
+// url is relative so that the UI keeps working behind a reverse proxy which adds a path prefix
+var swaggerInitializer = []byte(`window.onload = function() {
+  window.ui = SwaggerUIBundle({
+    url: "../openapi.json",
+    dom_id: '#swagger-ui',
+    deepLinking: true,
+    presets: [
+      SwaggerUIBundle.presets.apis,
+      SwaggerUIStandalonePreset
+    ],
+    plugins: [
+      SwaggerUIBundle.plugins.DownloadUrl
+    ],
+    layout: "StandaloneLayout"
+  });
+};
+`)
+
+// openApiSpec is the OpenAPI document encoded as JSON
+var openApiSpec []byte
+
+// initDocs encodes the OpenAPI document once, it is served as is
+func initDocs() error {
+	spec, err := openapi.JSON()
+	if err != nil {
+		return fmt.Errorf("an error occurred while encoding openapi document: %w", err)
+	}
+
+	openApiSpec = spec
+	return nil
+}
+
+// registerDocsHandlers serves the OpenAPI document at /openapi.json and the Swagger UI at /docs/, initDocs must be
+// called before
+func registerDocsHandlers(router *gin.Engine) {
+	router.GET("/openapi.json", openApiHandler(openApiSpec))
+	router.GET("/docs/*filepath", swaggerUiHandler())
+}
+
+func openApiHandler(spec []byte) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		context.Data(http.StatusOK, "application/json", spec)
+	}
+}
+
+func swaggerUiHandler() gin.HandlerFunc {
+	fileServer := http.StripPrefix("/docs", http.FileServer(http.FS(swaggerFiles.FS)))
+	return func(context *gin.Context) {
+		if strings.TrimPrefix(context.Param("filepath"), "/") == "swagger-initializer.js" {
+			context.Data(http.StatusOK, "application/javascript", swaggerInitializer)
+			return
+		}
+
+		fileServer.ServeHTTP(context.Writer, context.Request)
+	}
+}
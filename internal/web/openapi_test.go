@@ -0,0 +1,284 @@
+package web
+
+import (
+	"auth-service/api/openapi"
+	"auth-service/internal/audit"
+	"auth-service/internal/health"
+	"auth-service/internal/jwt"
+	"auth-service/internal/model"
+	"auth-service/internal/options"
+	"auth-service/internal/policy"
+	"auth-service/internal/rbac"
+	"auth-service/internal/realm"
+	"auth-service/internal/scim"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// undocumentedRoutes are registered but intentionally left out of the OpenAPI document
+var undocumentedRoutes = map[string]bool{
+	"GET /docs/*filepath": true,
+}
+
+// requestSchemas maps the request schemas of the document to the types the handlers bind, required properties of the
+// schemas must match the fields which are validated as required
+var requestSchemas = map[string]interface{}{
+	"AuthRequest":                authRequest{},
+	"ValidateRequest":            validateRequest{},
+	"CertificateRequest":         certificateRequest{},
+	"EntitlementRequest":         entitlementRequest{},
+	"IdentityBackendRequest":     identityBackendRequest{},
+	"PersonalAccessTokenRequest": personalAccessTokenRequest{},
+	"AuthorizeRequest":           authorizeRequest{},
+	"PermissionRequest":          permissionRequest{},
+	"DecisionRequest":            decisionRequest{},
+	"RealmRequest":               realmRequest{},
+	"RefreshRequest":             refreshRequest{},
+	"ScimUser":                   scim.User{},
+	"ScimGroup":                  scim.Group{},
+	"ScimPatchRequest":           scim.PatchRequest{},
+	"ScimPatchOperation":         scim.PatchOperation{},
+}
+
+// responseSchemas maps the response schemas of the document to the types the handlers render
+var responseSchemas = map[string]interface{}{
+	"HealthReport":                health.Report{},
+	"HealthCheck":                 health.CheckResult{},
+	"Problem":                     problem{},
+	"FieldError":                  fieldError{},
+	"AuthFailResponse":            authFailResponse{},
+	"ValidationErrorResponse":     validationErrorResponse{},
+	"AuthSuccessResponse":         authSuccessResponse{},
+	"Role":                        model.Role{},
+	"ValidateResponse":            validateResponse{},
+	"EntitlementClaim":            jwt.EntitlementClaim{},
+	"CertificateResponse":         certificateResponse{},
+	"CertificateStatusResponse":   certificateStatusResponse{},
+	"Entitlement":                 model.Entitlement{},
+	"PersonalAccessTokenResponse": personalAccessTokenResponse{},
+	"AuthorizeResponse":           authorizeResponse{},
+	"Permission":                  model.Permission{},
+	"RoleDetails":                 rbac.RoleDetails{},
+	"DecisionResponse":            decisionResponse{},
+	"Evaluation":                  policy.Evaluation{},
+	"Realm":                       model.Realm{},
+	"RealmResponse":               realmResponse{},
+	"PasswordPolicy":              realm.PasswordPolicy{},
+	"JsonWebKeySet":               jsonWebKeySet{},
+	"JsonWebKey":                  jsonWebKey{},
+	"LoginHistoryResponse":        loginHistoryResponse{},
+	"AuditEvent":                  audit.Event{},
+	"ScimMeta":                    scim.Meta{},
+	"ScimEmail":                   scim.Email{},
+	"ScimReference":               scim.Reference{},
+	"ScimListResponse":            scim.ListResponse{},
+	"ScimError":                   scim.Error{},
+	"UserResponse":                userResponse{},
+	"TokenPair":                   tokenPair{},
+	"SessionResponse":             sessionResponse{},
+	"TokenInfoResponse":           tokenInfoResponse{},
+	"LogoutResponse":              logoutResponse{},
+}
+
+var pathParam = regexp.MustCompile(`:(\w+)`)
+
+func newDocsRouter(t *testing.T) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	opts = &options.AuthServiceOptions{AdminRole: "ROLE_ADMIN"}
+	if err := initDocs(); err != nil {
+		t.Fatal(err)
+	}
+
+	router := gin.New()
+	registerHandlers(router)
+	return router
+}
+
+func loadDocument(t *testing.T) map[string]interface{} {
+	doc, err := openapi.Document()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return doc
+}
+
+// registeredRoutes returns the routes of the router in the form of the document like GET /auth/tokens/{id}, routes
+// of the realm group are returned separately without the /realms/{realm} prefix
+func registeredRoutes(router *gin.Engine) (root, realmScoped map[string]bool) {
+	root, realmScoped = make(map[string]bool), make(map[string]bool)
+	for _, r := range router.Routes() {
+		route := r.Method + " " + r.Path
+		if undocumentedRoutes[route] {
+			continue
+		}
+
+		if strings.HasPrefix(r.Path, "/realms/:realm/") {
+			realmScoped[r.Method+" "+pathParam.ReplaceAllString(strings.TrimPrefix(r.Path, "/realms/:realm"),
+				"{$1}")] = true
+			continue
+		}
+		root[r.Method+" "+pathParam.ReplaceAllString(r.Path, "{$1}")] = true
+	}
+
+	return root, realmScoped
+}
+
+// documentedRoutes returns the operations of the document, value is true if the path is only served at the root
+func documentedRoutes(doc map[string]interface{}) map[string]bool {
+	routes := make(map[string]bool)
+	for path, item := range doc["paths"].(map[string]interface{}) {
+		operations := item.(map[string]interface{})
+		_, rootOnly := operations["servers"]
+		for method := range operations {
+			switch method {
+			case "get", "put", "post", "delete", "patch", "head", "options":
+				routes[strings.ToUpper(method)+" "+path] = rootOnly
+			}
+		}
+	}
+
+	return routes
+}
+
+func TestOpenApiRoutes(t *testing.T) {
+	root, realmScoped := registeredRoutes(newDocsRouter(t))
+	documented := documentedRoutes(loadDocument(t))
+	for route, rootOnly := range documented {
+		if !root[route] {
+			t.Errorf("%s is documented but not registered", route)
+		}
+
+		if rootOnly && realmScoped[route] {
+			t.Errorf("%s is documented as root only but registered in the realm group", route)
+		} else if !rootOnly && !realmScoped[route] {
+			t.Errorf("%s is documented as realm scoped but not registered in the realm group", route)
+		}
+	}
+
+	for _, routes := range []map[string]bool{root, realmScoped} {
+		for route := range routes {
+			if _, ok := documented[route]; !ok {
+				t.Errorf("%s is registered but not documented", route)
+			}
+		}
+	}
+}
+
+func TestOpenApiSchemas(t *testing.T) {
+	schemas := loadDocument(t)["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	check := func(name string, v interface{}, request bool) {
+		schema, ok := schemas[name].(map[string]interface{})
+		if !ok {
+			t.Errorf("schema %s is not documented", name)
+			return
+		}
+
+		properties, _ := schema["properties"].(map[string]interface{})
+		var documented []string
+		for property := range properties {
+			documented = append(documented, property)
+		}
+
+		fields, required := jsonFields(reflect.TypeOf(v))
+		if !equalSets(documented, fields) {
+			t.Errorf("properties of schema %s are %v, fields of %T are %v", name, sorted(documented), v,
+				sorted(fields))
+		}
+
+		if !request {
+			return
+		}
+
+		var documentedRequired []string
+		list, _ := schema["required"].([]interface{})
+		for _, property := range list {
+			documentedRequired = append(documentedRequired, property.(string))
+		}
+
+		if !equalSets(documentedRequired, required) {
+			t.Errorf("required properties of schema %s are %v, required fields of %T are %v", name,
+				sorted(documentedRequired), v, sorted(required))
+		}
+	}
+
+	for name, v := range requestSchemas {
+		check(name, v, true)
+	}
+
+	for name, v := range responseSchemas {
+		check(name, v, false)
+	}
+}
+
+// jsonFields returns the json names of the fields of the struct and the ones which are validated as required,
+// fields of the embedded structs are flattened like encoding/json does
+func jsonFields(t reflect.Type) (fields, required []string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" || (f.PkgPath != "" && !f.Anonymous) {
+			continue
+		}
+
+		name := strings.Split(tag, ",")[0]
+		if f.Anonymous && name == "" {
+			embeddedFields, embeddedRequired := jsonFields(f.Type)
+			fields = append(fields, embeddedFields...)
+			required = append(required, embeddedRequired...)
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, name)
+
+		// rules after dive apply to the elements
+		for _, rule := range strings.Split(f.Tag.Get("validate"), ",") {
+			if rule == "dive" {
+				break
+			}
+
+			if rule == "required" {
+				required = append(required, name)
+				break
+			}
+		}
+	}
+
+	return fields, required
+}
+
+func equalSets(a, b []string) bool {
+	return reflect.DeepEqual(sorted(a), sorted(b))
+}
+
+func sorted(s []string) []string {
+	c := append([]string{}, s...)
+	sort.Strings(c)
+	return c
+}
+
+func TestOpenApiHandlers(t *testing.T) {
+	router := newDocsRouter(t)
+	rec := serve(router, "/openapi.json", nil)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"openapi":"3.1.0"`) {
+		t.Errorf("expected the openapi document, got %d", rec.Code)
+	}
+
+	rec = serve(router, "/docs/swagger-initializer.js", nil)
+	if !strings.Contains(rec.Body.String(), "../openapi.json") {
+		t.Errorf("expected the initializer to point to the document, got %s", rec.Body.String())
+	}
+
+	rec = serve(router, "/docs/", nil)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "swagger-ui") {
+		t.Errorf("expected the swagger ui, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,86 @@
+package web
+
+import (
+	"auth-service/internal/auth"
+	"auth-service/internal/jwt"
+	"auth-service/internal/policy"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var policyEngine *policy.Engine
+
+// subjectFromClaims builds the subject variable of the policy conditions from the token claims
+func subjectFromClaims(claims *jwt.VpnbeastClaim) map[string]interface{} {
+	entitlements := make(map[string]interface{}, len(claims.Entitlements))
+	for name, e := range claims.Entitlements {
+		entitlements[name] = e.Value
+	}
+
+	roles := claims.Roles
+	if roles == nil {
+		roles = []string{}
+	}
+
+	return map[string]interface{}{
+		"sub":          claims.Subject,
+		"iss":          claims.Issuer,
+		"realm":        claims.Realm,
+		"iat":          claims.IssuedAt,
+		"exp":          claims.ExpiresAt,
+		"roles":        roles,
+		"permissions":  strings.Fields(claims.Scope),
+		"entitlements": entitlements,
+		"pat":          claims.Id == auth.TokenTypePat,
+	}
+}
+
+// requestFromContext builds the request variable of the policy conditions, attributes given by the client are kept
+// under the attributes key so they can not override the ones observed by the server
+func requestFromContext(context *gin.Context, attributes map[string]interface{}) map[string]interface{} {
+	if attributes == nil {
+		attributes = map[string]interface{}{}
+	}
+
+	now := time.Now()
+	return map[string]interface{}{
+		"time":       now,
+		"hour":       now.Hour(),
+		"weekday":    int(now.Weekday()),
+		"ip":         context.ClientIP(),
+		"userAgent":  context.Request.UserAgent(),
+		"attributes": attributes,
+	}
+}
+
+func decideHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		req, _ := context.Get("data")
+		decisionReq := req.(decisionRequest)
+		claims, err, code := authenticateToken(context.Request.Context(), currentRealm(context), decisionReq.Token)
+		if err != nil {
+			tokenErrorResponse(context, code, err)
+			return
+		}
+
+		decision := policyEngine.Evaluate(policy.Input{
+			Subject:  subjectFromClaims(claims),
+			Action:   decisionReq.Action,
+			Resource: decisionReq.Resource,
+			Request:  requestFromContext(context, decisionReq.Context),
+		})
+
+		context.JSON(http.StatusOK, decisionResponse{
+			Allowed:       decision.Allowed,
+			Username:      claims.Subject,
+			Action:        decisionReq.Action,
+			MatchedPolicy: decision.MatchedPolicy,
+			Reason:        decision.Reason,
+			Evaluations:   decision.Evaluations,
+			HttpCode:      http.StatusOK,
+			Timestamp:     time.Now().Format(time.RFC3339),
+		})
+	}
+}
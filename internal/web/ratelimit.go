@@ -0,0 +1,102 @@
+package web
+
+import (
+	"auth-service/internal/jwt"
+	"auth-service/internal/ratelimit"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"math"
+	"strconv"
+	"time"
+)
+
+// clientIdHeader identifies the client application for the client id keyed rate limits
+const clientIdHeader = "X-Client-Id"
+
+var limiter *ratelimit.Limiter
+
+// rateLimitKey returns the key of the request for the policy, an empty key means the policy does not apply to the
+// request. Keys are scoped by the realm
+func rateLimitKey(context *gin.Context, policy ratelimit.Policy) string {
+	var key string
+	switch policy.Key {
+	case ratelimit.KeyIp:
+		key = context.ClientIP()
+	case ratelimit.KeyUsername:
+		if req, ok := context.Get("data"); ok {
+			if authReq, ok := req.(authRequest); ok {
+				key = authReq.Username
+			}
+		}
+	case ratelimit.KeyClientId:
+		key = context.GetHeader(clientIdHeader)
+	case ratelimit.KeySubject:
+		if claims, ok := context.Get("claims"); ok {
+			key = claims.(*jwt.VpnbeastClaim).Subject
+		}
+	}
+
+	if key == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("%d:%s", currentRealm(context).Id, key)
+}
+
+// rateLimit checks the request against the policies of the route and refuses it with 429 if any of them is
+// exceeded. Headers of the most restrictive policy are sent, requests are allowed if the backend fails
+func rateLimit(route string) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		if limiter == nil {
+			context.Next()
+			return
+		}
+
+		var strictest *ratelimit.Result
+		for _, policy := range limiter.Policies(route) {
+			key := rateLimitKey(context, policy)
+			if key == "" {
+				continue
+			}
+
+			result, err := limiter.Allow(context.Request.Context(), policy, key)
+			if err != nil {
+				logger.Warn("an error occurred while checking rate limit", zap.String("route", route),
+					zap.String("error", err.Error()))
+				continue
+			}
+
+			if strictest == nil || !result.Allowed || (strictest.Allowed && result.Remaining < strictest.Remaining) {
+				r := result
+				strictest = &r
+			}
+
+			if !result.Allowed {
+				break
+			}
+		}
+
+		if strictest == nil {
+			context.Next()
+			return
+		}
+
+		context.Header("RateLimit-Limit", strconv.Itoa(strictest.Limit))
+		context.Header("RateLimit-Remaining", strconv.Itoa(strictest.Remaining))
+		context.Header("RateLimit-Reset", ceilSeconds(strictest.Reset))
+		if !strictest.Allowed {
+			context.Header("Retry-After", ceilSeconds(strictest.RetryAfter))
+			errorResponse(context, errTooManyRequests)
+			context.Abort()
+			return
+		}
+
+		context.Next()
+	}
+}
+
+// ceilSeconds formats the duration as whole seconds, rounded up so the clients do not retry too early
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
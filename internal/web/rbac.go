@@ -0,0 +1,168 @@
+package web
+
+import (
+	"auth-service/internal/rbac"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+	"time"
+)
+
+func authorizeHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		req, _ := context.Get("data")
+		authorizeReq := req.(authorizeRequest)
+		r := currentRealm(context)
+		claims, err, code := authenticateToken(context.Request.Context(), r, authorizeReq.Token)
+		if err != nil {
+			tokenErrorResponse(context, code, err)
+			return
+		}
+
+		granted, err := rbac.EffectivePermissions(db, r.Id, claims.Roles)
+		if err != nil {
+			logger.Error("an error occurred while resolving permissions", zap.String("error", err.Error()))
+			errorResponse(context, errUnknown)
+			context.Abort()
+			return
+		}
+
+		missing := rbac.Missing(granted, authorizeReq.Permissions)
+		context.JSON(http.StatusOK, authorizeResponse{
+			Allowed:            len(missing) == 0,
+			Username:           claims.Subject,
+			Permissions:        authorizeReq.Permissions,
+			MissingPermissions: missing,
+			HttpCode:           http.StatusOK,
+			Timestamp:          time.Now().Format(time.RFC3339),
+		})
+	}
+}
+
+// rbacErrorResponse maps the errors of the rbac package to the responses
+func rbacErrorResponse(context *gin.Context, err error) {
+	switch err {
+	case rbac.ErrRoleNotFound:
+		errorResponse(context, errRoleNotFound)
+	case rbac.ErrPermissionNotFound:
+		errorResponse(context, errPermissionNotFound)
+	case rbac.ErrPermissionExists:
+		errorResponse(context, errPermissionExists)
+	case rbac.ErrInvalidPermissionName:
+		validationResponse(context, []fieldError{invalidField("name", errInvalidPermission)})
+	case rbac.ErrCyclicInheritance:
+		errorResponse(context, errCyclicInheritance)
+	default:
+		logger.Error("an error occurred while managing rbac", zap.String("error", err.Error()))
+		errorResponse(context, errUnknown)
+	}
+	context.Abort()
+}
+
+func listPermissionsHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		permissions, err := rbac.ListPermissions(db)
+		if err != nil {
+			rbacErrorResponse(context, err)
+			return
+		}
+
+		context.JSON(http.StatusOK, permissions)
+	}
+}
+
+func createPermissionHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		req, _ := context.Get("data")
+		permissionReq := req.(permissionRequest)
+		permission, err := rbac.CreatePermission(db, permissionReq.Name, permissionReq.Description)
+		if err != nil {
+			rbacErrorResponse(context, err)
+			return
+		}
+
+		logger.Info("permission created", zap.String("permission", permission.Name))
+		context.JSON(http.StatusCreated, permission)
+	}
+}
+
+func deletePermissionHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		name := context.Param("permission")
+		if err := rbac.DeletePermission(db, name); err != nil {
+			rbacErrorResponse(context, err)
+			return
+		}
+
+		logger.Info("permission deleted", zap.String("permission", name))
+		context.Status(http.StatusNoContent)
+	}
+}
+
+func listRolesHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		roles, err := rbac.ListRoles(db, currentRealm(context).Id)
+		if err != nil {
+			rbacErrorResponse(context, err)
+			return
+		}
+
+		context.JSON(http.StatusOK, roles)
+	}
+}
+
+func grantPermissionHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		role, permission := context.Param("role"), context.Param("permission")
+		if err := rbac.GrantPermission(db, currentRealm(context).Id, role, permission); err != nil {
+			rbacErrorResponse(context, err)
+			return
+		}
+
+		logger.Info("permission granted", zap.String("role", role), zap.String("permission", permission))
+		recordRoleChange(context, role, "grant_permission", map[string]string{"permission": permission})
+		context.Status(http.StatusNoContent)
+	}
+}
+
+func revokePermissionHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		role, permission := context.Param("role"), context.Param("permission")
+		if err := rbac.RevokePermission(db, currentRealm(context).Id, role, permission); err != nil {
+			rbacErrorResponse(context, err)
+			return
+		}
+
+		logger.Info("permission revoked", zap.String("role", role), zap.String("permission", permission))
+		recordRoleChange(context, role, "revoke_permission", map[string]string{"permission": permission})
+		context.Status(http.StatusNoContent)
+	}
+}
+
+func addParentRoleHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		role, parent := context.Param("role"), context.Param("parent")
+		if err := rbac.AddParent(db, currentRealm(context).Id, role, parent); err != nil {
+			rbacErrorResponse(context, err)
+			return
+		}
+
+		logger.Info("parent role added", zap.String("role", role), zap.String("parent", parent))
+		recordRoleChange(context, role, "add_parent", map[string]string{"parent": parent})
+		context.Status(http.StatusNoContent)
+	}
+}
+
+func removeParentRoleHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		role, parent := context.Param("role"), context.Param("parent")
+		if err := rbac.RemoveParent(db, currentRealm(context).Id, role, parent); err != nil {
+			rbacErrorResponse(context, err)
+			return
+		}
+
+		logger.Info("parent role removed", zap.String("role", role), zap.String("parent", parent))
+		recordRoleChange(context, role, "remove_parent", map[string]string{"parent": parent})
+		context.Status(http.StatusNoContent)
+	}
+}
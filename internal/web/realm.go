@@ -0,0 +1,188 @@
+package web
+
+import (
+	"auth-service/internal/model"
+	"auth-service/internal/realm"
+	"encoding/base64"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"math/big"
+	"net/http"
+	"time"
+)
+
+var realms *realm.Registry
+
+// realmResolver selects the realm of the request by the realm path parameter, then by the host header and falls
+// back to the default realm, the realm is stored in the context
+func realmResolver() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var r *realm.Realm
+		var err error
+		if name := c.Param("realm"); name != "" {
+			r, err = realms.ByName(name)
+		} else if r, err = realms.ByHost(c.Request.Host); err == realm.ErrRealmNotFound {
+			r, err = realms.Default()
+		}
+
+		switch err {
+		case nil:
+			c.Set("realm", r)
+			c.Next()
+			return
+		case realm.ErrRealmNotFound:
+			errorResponse(c, errRealmNotFound)
+		default:
+			logger.Error("an error occurred while resolving realm", zap.String("error", err.Error()))
+			errorResponse(c, errUnknown)
+		}
+		c.Abort()
+	}
+}
+
+// currentRealm returns the realm of the request, must be used after realmResolver
+func currentRealm(c *gin.Context) *realm.Realm {
+	return c.MustGet("realm").(*realm.Realm)
+}
+
+// requireDefaultRealm aborts the request if it is not made in the default realm, realms can only be managed by the
+// admins of the default realm
+func requireDefaultRealm() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !currentRealm(c).IsDefault() {
+			errorResponse(c, errForbidden)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func realmHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		r := currentRealm(context)
+		context.JSON(http.StatusOK, realmResponse{
+			Name:                       r.Name,
+			Issuer:                     r.Issuer(),
+			AccessTokenValidInMinutes:  r.AccessTokenValidInMinutes(),
+			RefreshTokenValidInMinutes: r.RefreshTokenValidInMinutes(),
+			PasswordPolicy:             r.PasswordPolicy(),
+		})
+	}
+}
+
+func jwksHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		kids, keys := currentRealm(context).Signer().Keys().PublicKeys()
+		res := jsonWebKeySet{Keys: make([]jsonWebKey, 0, len(keys))}
+		for i, key := range keys {
+			res.Keys = append(res.Keys, jsonWebKey{
+				Kty: "RSA",
+				Kid: kids[i],
+				Use: "sig",
+				Alg: "RS256",
+				N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			})
+		}
+
+		context.JSON(http.StatusOK, res)
+	}
+}
+
+// realmErrorResponse maps the errors of the realm package to the responses
+func realmErrorResponse(context *gin.Context, err error) {
+	switch err {
+	case realm.ErrRealmNotFound:
+		errorResponse(context, errRealmNotFound)
+	case realm.ErrRealmExists:
+		errorResponse(context, errRealmExists)
+	case realm.ErrInvalidRealmName:
+		validationResponse(context, []fieldError{invalidField("name", errInvalidRealmName)})
+	case realm.ErrDisableDefaultRealm:
+		errorResponse(context, errDisableDefaultRealm)
+	default:
+		logger.Error("an error occurred while managing realms", zap.String("error", err.Error()))
+		errorResponse(context, errUnknown)
+	}
+	context.Abort()
+}
+
+func toRealmModel(realmReq realmRequest) *model.Realm {
+	return &model.Realm{
+		Name:                       realmReq.Name,
+		IssuerUrl:                  realmReq.IssuerUrl,
+		Hosts:                      realmReq.Hosts,
+		AccessTokenValidInMinutes:  realmReq.AccessTokenValidInMinutes,
+		RefreshTokenValidInMinutes: realmReq.RefreshTokenValidInMinutes,
+		PasswordMinLength:          realmReq.PasswordMinLength,
+		PasswordMaxLength:          realmReq.PasswordMaxLength,
+		PasswordRequireDigit:       realmReq.PasswordRequireDigit,
+		PasswordRequireUpper:       realmReq.PasswordRequireUpper,
+		PasswordRequireLower:       realmReq.PasswordRequireLower,
+		PasswordRequireSymbol:      realmReq.PasswordRequireSymbol,
+		IdentityBackend:            realmReq.IdentityBackend,
+		Enabled:                    realmReq.Enabled,
+	}
+}
+
+func listRealmsHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		list, err := realm.List(db)
+		if err != nil {
+			realmErrorResponse(context, err)
+			return
+		}
+
+		context.JSON(http.StatusOK, list)
+	}
+}
+
+func createRealmHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		req, _ := context.Get("data")
+		r := toRealmModel(req.(realmRequest))
+		if err := realm.Create(db, r); err != nil {
+			realmErrorResponse(context, err)
+			return
+		}
+
+		realms.Invalidate()
+		logger.Info("realm created", zap.String("realm", r.Name))
+		context.JSON(http.StatusCreated, r)
+	}
+}
+
+func updateRealmHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		req, _ := context.Get("data")
+		name := context.Param("name")
+		if err := realm.Update(db, name, toRealmModel(req.(realmRequest))); err != nil {
+			realmErrorResponse(context, err)
+			return
+		}
+
+		realms.Invalidate()
+		logger.Info("realm updated", zap.String("realm", name))
+		context.Status(http.StatusNoContent)
+	}
+}
+
+func rotateRealmKeyHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		name := context.Param("name")
+		key, err := realm.RotateKey(db, name)
+		if err != nil {
+			realmErrorResponse(context, err)
+			return
+		}
+
+		realms.Invalidate()
+		logger.Info("realm signing key rotated", zap.String("realm", name), zap.String("kid", key.Kid))
+		context.JSON(http.StatusCreated, gin.H{
+			"kid":       key.Kid,
+			"createdAt": key.CreatedAt.Format(time.RFC3339),
+		})
+	}
+}
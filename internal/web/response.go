@@ -2,24 +2,124 @@ package web
 
 import (
 	"github.com/gin-gonic/gin"
-	"net/http"
+	"github.com/google/uuid"
+	"strings"
 	"time"
 )
 
-func validationResponse(ctx *gin.Context, errSlice []string) {
-	ctx.JSON(http.StatusBadRequest, validationErrorResponse{
-		ErrorMessage: errSlice,
+const (
+	// problemContentType is the media type of the error responses, see RFC 7807
+	problemContentType = "application/problem+json"
+	// problemTypePrefix is prepended to the codes of the catalog to build the type of the problems
+	problemTypePrefix = "urn:vpnbeast:auth-service:"
+	// maxRequestIdLength bounds the length of the request ids provided by the clients
+	maxRequestIdLength = 128
+)
+
+// problem represents an error response in RFC 7807 format, Code is the code of the catalog entry and Errors lists
+// the invalid fields of the request
+type problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code"`
+	RequestId string       `json:"requestId,omitempty"`
+	Errors    []fieldError `json:"errors,omitempty"`
+}
+
+// fieldError represents an invalid field of the request, Field is empty if the error is not about a single field
+type fieldError struct {
+	Field   string `json:"field,omitempty"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// invalidField builds the fieldError of the field from the catalog entry
+func invalidField(field string, err apiError) fieldError {
+	return fieldError{Field: field, Code: err.Code, Message: err.Title}
+}
+
+// requestId makes sure that every request has an id, the id provided by the client with the X-Request-Id header is
+// kept if it is valid. Id is returned with the same header and included in the audit events and the problems
+func requestId() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(requestIdHeader)
+		if id == "" || len(id) > maxRequestIdLength || strings.ContainsAny(id, "\r\n") {
+			id = uuid.NewString()
+			c.Request.Header.Set(requestIdHeader, id)
+		}
+
+		c.Header(requestIdHeader, id)
+		c.Next()
+	}
+}
+
+// legacyErrors decides if the error responses are rendered in the shapes which were used before the problems.
+// Clients can opt in to the problems with the Accept header while the legacy responses are enabled, /v2 routes never
+// use the legacy shapes
+func legacyErrors(ctx *gin.Context) bool {
+	if opts == nil || !opts.LegacyErrorResponses || isV2(ctx) {
+		return false
+	}
+
+	return !strings.Contains(ctx.GetHeader("Accept"), problemContentType)
+}
+
+// problemResponse writes the problem of the catalog entry, detail is optional. Title and the messages of the field
+// errors which have a catalog code are localized
+func problemResponse(ctx *gin.Context, err apiError, detail string, errs []fieldError) {
+	locale := responseLocale(ctx)
+	for i, e := range errs {
+		if message, ok := catalog.Error(locale, e.Code); ok {
+			errs[i].Message = message
+		}
+	}
+
+	ctx.Header("Content-Type", problemContentType)
+	ctx.Header("Content-Language", locale)
+	ctx.JSON(err.Status, problem{
+		Type:      problemTypePrefix + err.Code,
+		Title:     localizedTitle(locale, err),
+		Status:    err.Status,
+		Detail:    detail,
+		Instance:  ctx.Request.URL.Path,
+		Code:      err.Code,
+		RequestId: ctx.GetHeader(requestIdHeader),
+		Errors:    errs,
+	})
+}
+
+func validationResponse(ctx *gin.Context, errs []fieldError) {
+	if !legacyErrors(ctx) {
+		problemResponse(ctx, errValidationFailed, "", errs)
+		return
+	}
+
+	messages := make([]string, 0, len(errs))
+	for _, e := range errs {
+		messages = append(messages, e.Message)
+	}
+
+	ctx.JSON(errValidationFailed.Status, validationErrorResponse{
+		ErrorMessage: messages,
 		Status:       false,
-		HttpCode:     http.StatusBadRequest,
+		HttpCode:     errValidationFailed.Status,
 		Timestamp:    time.Now(),
 	})
 }
 
-func errorResponse(ctx *gin.Context, code int, err string) {
-	ctx.JSON(code, authFailResponse{
-		ErrorMessage: err,
+func errorResponse(ctx *gin.Context, err apiError) {
+	if !legacyErrors(ctx) {
+		problemResponse(ctx, err, "", nil)
+		return
+	}
+
+	ctx.JSON(err.Status, authFailResponse{
+		ErrorMessage: err.Title,
 		Status:       false,
-		HttpCode:     code,
+		HttpCode:     err.Status,
 		Timestamp:    time.Now(),
 	})
 }
@@ -0,0 +1,157 @@
+package web
+
+import (
+	"auth-service/internal/options"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newErrorRouter(legacy bool) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	opts = &options.AuthServiceOptions{LegacyErrorResponses: legacy}
+	router := gin.New()
+	router.Use(requestId())
+	router.GET("/users", func(c *gin.Context) {
+		errorResponse(c, errUserNotFound)
+	})
+	router.GET("/audit", func(c *gin.Context) {
+		_, _, errs := pagination(c, nil)
+		validationResponse(c, errs)
+	})
+	return router
+}
+
+func serve(router *gin.Engine, path string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestProblemResponse(t *testing.T) {
+	router := newErrorRouter(false)
+	rec := serve(router, "/users", http.Header{requestIdHeader: {"req-1"}})
+	if rec.Code != http.StatusNotFound || rec.Header().Get("Content-Type") != problemContentType {
+		t.Fatalf("expected a 404 problem, got %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	var p problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Code != "user_not_found" || p.Type != problemTypePrefix+"user_not_found" || p.Status != http.StatusNotFound ||
+		p.Instance != "/users" || p.RequestId != "req-1" {
+		t.Errorf("unexpected problem %+v", p)
+	}
+
+	if rec.Header().Get(requestIdHeader) != "req-1" {
+		t.Errorf("expected the request id of the client to be returned, got %q", rec.Header().Get(requestIdHeader))
+	}
+
+	rec = serve(router, "/audit?limit=0&offset=-1", nil)
+	p = problem{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Code != "validation_failed" || len(p.Errors) != 2 || p.Errors[0].Field != "limit" ||
+		p.Errors[0].Code != "invalid_limit" || p.Errors[1].Field != "offset" {
+		t.Errorf("unexpected validation problem %+v", p)
+	}
+
+	if p.RequestId == "" || p.RequestId != rec.Header().Get(requestIdHeader) {
+		t.Errorf("expected a generated request id, got %q", p.RequestId)
+	}
+}
+
+func TestLegacyResponse(t *testing.T) {
+	router := newErrorRouter(true)
+	rec := serve(router, "/users", nil)
+	var res authFailResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec.Code != http.StatusNotFound || res.ErrorMessage != "User not found!" || res.HttpCode != http.StatusNotFound {
+		t.Errorf("expected the legacy response, got %d %+v", rec.Code, res)
+	}
+
+	rec = serve(router, "/audit?limit=0", nil)
+	var validationRes validationErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &validationRes); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(validationRes.ErrorMessage) != 1 || validationRes.ErrorMessage[0] != errInvalidLimit.Title {
+		t.Errorf("expected the legacy validation response, got %+v", validationRes)
+	}
+
+	rec = serve(router, "/users", http.Header{"Accept": {problemContentType}})
+	if rec.Header().Get("Content-Type") != problemContentType {
+		t.Errorf("expected the client to opt in to the problems, got %s", rec.Header().Get("Content-Type"))
+	}
+}
+
+func TestJsonField(t *testing.T) {
+	req := &personalAccessTokenRequest{}
+	if field := jsonField(req, "Scopes[0]"); field != "scopes[0]" {
+		t.Errorf("expected scopes[0], got %s", field)
+	}
+
+	if field := jsonField(req, "Unknown"); field != "Unknown" {
+		t.Errorf("expected the unknown field to be kept, got %s", field)
+	}
+}
+
+func TestLocalizedResponse(t *testing.T) {
+	router := newErrorRouter(false)
+	if err := initValidator(); err != nil {
+		t.Fatal(err)
+	}
+	router.POST("/tokens", personalAccessTokenRequestValidator())
+
+	rec := serve(router, "/users", http.Header{"Accept-Language": {"de-DE,de;q=0.9,en;q=0.8"}})
+	var p problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Title != "Benutzer nicht gefunden!" || p.Code != "user_not_found" ||
+		rec.Header().Get("Content-Language") != "de" {
+		t.Errorf("expected the german problem, got %+v", p)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/tokens", strings.NewReader(`{"scopes": ["ROLE_USER"]}`))
+	req.Header.Set("Accept-Language", "tr")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	p = problem{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(p.Errors) != 1 || p.Errors[0].Field != "name" || p.Errors[0].Code != "required" ||
+		p.Errors[0].Message != "Name zorunlu bir alandır" {
+		t.Errorf("expected the turkish validation error, got %+v", p.Errors)
+	}
+
+	opts.LegacyErrorResponses = true
+	rec = serve(router, "/users", http.Header{"Accept-Language": {"es"}})
+	var res authFailResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+
+	if res.ErrorMessage != "User not found!" {
+		t.Errorf("expected the legacy response in english, got %q", res.ErrorMessage)
+	}
+}
@@ -0,0 +1,331 @@
+package web
+
+import (
+	"auth-service/internal/scim"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const scimContentType = "application/scim+json"
+
+// scimRole returns the role required to use the SCIM api, which is the admin role unless configured
+func scimRole() string {
+	if opts.ScimRole != "" {
+		return opts.ScimRole
+	}
+
+	return opts.AdminRole
+}
+
+func scimResponse(context *gin.Context, code int, body interface{}) {
+	content, err := json.Marshal(body)
+	if err != nil {
+		logger.Error("an error occurred while marshalling scim response", zap.String("error", err.Error()))
+		context.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	context.Data(code, scimContentType, content)
+}
+
+// scimErrorResponse writes the errors of the scim package in the SCIM error format
+func scimErrorResponse(context *gin.Context, err error) {
+	scimErr, ok := err.(*scim.Error)
+	if !ok {
+		logger.Error("an error occurred while handling scim request", zap.String("error", err.Error()))
+		scimErr = scim.NewError(http.StatusInternalServerError, errUnknown.Title)
+	}
+
+	scimResponse(context, scimErr.Code(), scimErr)
+	context.Abort()
+}
+
+// bindScimRequest decodes the body into the target, SCIM clients send application/scim+json so the content type is
+// not checked
+func bindScimRequest(context *gin.Context, target interface{}) bool {
+	if err := json.NewDecoder(context.Request.Body).Decode(target); err != nil {
+		scimErrorResponse(context, scim.NewError(http.StatusBadRequest, "invalid request body: "+err.Error()))
+		return false
+	}
+
+	return true
+}
+
+func scimQuery(context *gin.Context) scim.Query {
+	query := scim.Query{Filter: context.Query("filter"), StartIndex: 1, Count: scim.DefaultCount}
+	if startIndex, err := strconv.Atoi(context.Query("startIndex")); err == nil {
+		query.StartIndex = startIndex
+	}
+
+	if count, err := strconv.Atoi(context.Query("count")); err == nil {
+		query.Count = count
+	}
+
+	return query
+}
+
+// scimLocation builds the location of the resource from the request path, so it keeps the realm prefix
+func scimLocation(context *gin.Context, resourceType, id string) string {
+	path := context.Request.URL.Path
+	if i := strings.Index(path, "/scim/v2/"); i >= 0 {
+		path = path[:i+len("/scim/v2/")]
+	}
+
+	scheme := "http"
+	if context.Request.TLS != nil || context.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+
+	return scheme + "://" + context.Request.Host + path + resourceType + "s/" + id
+}
+
+// scimResourceResponse writes a single resource with its location and version headers
+func scimResourceResponse(context *gin.Context, code int, resourceType, id string, meta *scim.Meta,
+	body interface{}) {
+	meta.Location = scimLocation(context, resourceType, id)
+	context.Header("ETag", meta.Version)
+	if code == http.StatusCreated {
+		context.Header("Location", meta.Location)
+	}
+
+	scimResponse(context, code, body)
+}
+
+func scimUserResponse(context *gin.Context, code int, user *scim.User) {
+	for i := range user.Groups {
+		user.Groups[i].Ref = scimLocation(context, scim.ResourceTypeGroup, user.Groups[i].Value)
+	}
+
+	scimResourceResponse(context, code, scim.ResourceTypeUser, user.Id, user.Meta, user)
+}
+
+func scimGroupResponse(context *gin.Context, code int, group *scim.Group) {
+	for i := range group.Members {
+		group.Members[i].Ref = scimLocation(context, scim.ResourceTypeUser, group.Members[i].Value)
+	}
+
+	scimResourceResponse(context, code, scim.ResourceTypeGroup, group.Id, group.Meta, group)
+}
+
+func scimServiceProviderConfigHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		scimResponse(context, http.StatusOK, gin.H{
+			"schemas":        []string{scim.SchemaServiceProvider},
+			"patch":          gin.H{"supported": true},
+			"bulk":           gin.H{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+			"filter":         gin.H{"supported": true, "maxResults": scim.MaxCount},
+			"changePassword": gin.H{"supported": false},
+			"sort":           gin.H{"supported": false},
+			"etag":           gin.H{"supported": true},
+			"authenticationSchemes": []gin.H{{
+				"type":        "oauthbearertoken",
+				"name":        "Bearer token",
+				"description": "Personal access token of a user with the SCIM role",
+				"primary":     true,
+			}},
+		})
+	}
+}
+
+func scimListUsersHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		list, err := scim.ListUsers(db, currentRealm(context).Id, scimQuery(context))
+		if err != nil {
+			scimErrorResponse(context, err)
+			return
+		}
+
+		for _, resource := range list.Resources {
+			user := resource.(*scim.User)
+			user.Meta.Location = scimLocation(context, scim.ResourceTypeUser, user.Id)
+		}
+		scimResponse(context, http.StatusOK, list)
+	}
+}
+
+func scimGetUserHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		user, err := scim.GetUser(db, currentRealm(context).Id, context.Param("id"))
+		if err != nil {
+			scimErrorResponse(context, err)
+			return
+		}
+
+		scimUserResponse(context, http.StatusOK, user)
+	}
+}
+
+func scimCreateUserHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		var resource scim.User
+		if !bindScimRequest(context, &resource) {
+			return
+		}
+
+		user, err := scim.CreateUser(db, currentRealm(context).Id, &resource)
+		if err != nil {
+			scimErrorResponse(context, err)
+			return
+		}
+
+		logger.Info("user provisioned through scim", zap.String("user", user.UserName))
+		scimUserResponse(context, http.StatusCreated, user)
+	}
+}
+
+func scimReplaceUserHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		var resource scim.User
+		if !bindScimRequest(context, &resource) {
+			return
+		}
+
+		user, err := scim.ReplaceUser(db, currentRealm(context).Id, context.Param("id"),
+			context.GetHeader("If-Match"), &resource, revocationListeners...)
+		if err != nil {
+			scimErrorResponse(context, err)
+			return
+		}
+
+		scimUserResponse(context, http.StatusOK, user)
+	}
+}
+
+func scimPatchUserHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		var patch scim.PatchRequest
+		if !bindScimRequest(context, &patch) {
+			return
+		}
+
+		user, err := scim.PatchUser(db, currentRealm(context).Id, context.Param("id"),
+			context.GetHeader("If-Match"), patch, revocationListeners...)
+		if err != nil {
+			scimErrorResponse(context, err)
+			return
+		}
+
+		scimUserResponse(context, http.StatusOK, user)
+	}
+}
+
+func scimDeleteUserHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		id := context.Param("id")
+		err := scim.DeleteUser(db, currentRealm(context).Id, id, context.GetHeader("If-Match"),
+			revocationListeners...)
+		if err != nil {
+			scimErrorResponse(context, err)
+			return
+		}
+
+		logger.Info("user deprovisioned through scim", zap.String("uuid", id))
+		context.Status(http.StatusNoContent)
+	}
+}
+
+func scimListGroupsHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		excludeMembers := strings.Contains(strings.ToLower(context.Query("excludedAttributes")), "members")
+		list, err := scim.ListGroups(db, currentRealm(context).Id, scimQuery(context),
+			excludeMembers)
+		if err != nil {
+			scimErrorResponse(context, err)
+			return
+		}
+
+		for _, resource := range list.Resources {
+			group := resource.(*scim.Group)
+			group.Meta.Location = scimLocation(context, scim.ResourceTypeGroup, group.Id)
+		}
+		scimResponse(context, http.StatusOK, list)
+	}
+}
+
+func scimGetGroupHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		group, err := scim.GetGroup(db, currentRealm(context).Id, context.Param("id"))
+		if err != nil {
+			scimErrorResponse(context, err)
+			return
+		}
+
+		scimGroupResponse(context, http.StatusOK, group)
+	}
+}
+
+func scimCreateGroupHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		var resource scim.Group
+		if !bindScimRequest(context, &resource) {
+			return
+		}
+
+		group, err := scim.CreateGroup(db, currentRealm(context).Id, &resource)
+		if err != nil {
+			scimErrorResponse(context, err)
+			return
+		}
+
+		logger.Info("group provisioned through scim", zap.String("group", group.DisplayName))
+		recordRoleChange(context, group.DisplayName, "scim_create", nil)
+		scimGroupResponse(context, http.StatusCreated, group)
+	}
+}
+
+func scimReplaceGroupHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		var resource scim.Group
+		if !bindScimRequest(context, &resource) {
+			return
+		}
+
+		group, err := scim.ReplaceGroup(db, currentRealm(context).Id, context.Param("id"),
+			context.GetHeader("If-Match"), &resource)
+		if err != nil {
+			scimErrorResponse(context, err)
+			return
+		}
+
+		recordRoleChange(context, group.DisplayName, "scim_replace", nil)
+		scimGroupResponse(context, http.StatusOK, group)
+	}
+}
+
+func scimPatchGroupHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		var patch scim.PatchRequest
+		if !bindScimRequest(context, &patch) {
+			return
+		}
+
+		group, err := scim.PatchGroup(db, currentRealm(context).Id, context.Param("id"),
+			context.GetHeader("If-Match"), patch)
+		if err != nil {
+			scimErrorResponse(context, err)
+			return
+		}
+
+		recordRoleChange(context, group.DisplayName, "scim_patch", nil)
+		scimGroupResponse(context, http.StatusOK, group)
+	}
+}
+
+func scimDeleteGroupHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		id := context.Param("id")
+		err := scim.DeleteGroup(db, currentRealm(context).Id, id, context.GetHeader("If-Match"))
+		if err != nil {
+			scimErrorResponse(context, err)
+			return
+		}
+
+		logger.Info("group deleted through scim", zap.String("id", id))
+		recordRoleChange(context, id, "scim_delete", nil)
+		context.Status(http.StatusNoContent)
+	}
+}
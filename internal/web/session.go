@@ -0,0 +1,193 @@
+package web
+
+import (
+	"auth-service/internal/auth"
+	"auth-service/internal/jwt"
+	"auth-service/internal/realm"
+	"context"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// bearerToken extracts the token from the Authorization header
+func bearerToken(context *gin.Context) (string, bool) {
+	header := context.Request.Header.Get("Authorization")
+	if len(header) <= 7 || !strings.EqualFold(header[:7], "Bearer ") {
+		return "", false
+	}
+
+	return header[7:], true
+}
+
+// validateSession validates the session access token of the realm, see auth.AuthService.ValidateAccessToken
+func validateSession(ctx context.Context, r *realm.Realm, token string) (*jwt.VpnbeastClaim, error, int) {
+	return tokenResult(authService.ValidateAccessToken(ctx, r, token))
+}
+
+// validateAnySession validates either the access or the refresh token of a session of the realm, see
+// auth.AuthService.ValidateSession
+func validateAnySession(ctx context.Context, r *realm.Realm, token string) (*jwt.VpnbeastClaim, error, int) {
+	return tokenResult(authService.ValidateSession(ctx, r, token))
+}
+
+// authenticateToken validates a session token or a personal access token of the realm, see
+// auth.AuthService.AuthenticateToken
+func authenticateToken(ctx context.Context, r *realm.Realm, token string) (*jwt.VpnbeastClaim, error, int) {
+	return tokenResult(authService.AuthenticateToken(ctx, r, token))
+}
+
+// tokenResult maps the errors of the token validation to the error and the status code of the response
+func tokenResult(claims *jwt.VpnbeastClaim, err error) (*jwt.VpnbeastClaim, error, int) {
+	if err == nil {
+		return claims, nil, http.StatusOK
+	}
+
+	var tokenErr *auth.TokenError
+	if errors.As(err, &tokenErr) {
+		return nil, tokenErr, tokenErr.Code
+	}
+
+	logger.Error("an error occurred while validating token", zap.String("error", err.Error()))
+	return nil, errors.New(errUnknown.Title), http.StatusInternalServerError
+}
+
+// tokenErrorResponse maps the errors of the token validation to the catalog entries, message of the error is kept
+// since the errors of the token parsing are not in the catalog
+func tokenErrorResponse(context *gin.Context, code int, err error) {
+	apiErr := errInvalidToken
+	switch {
+	case err == auth.ErrTokenRevoked:
+		apiErr = errTokenRevoked
+	case err == auth.ErrEntitlementExpired:
+		apiErr = errEntitlementExpired
+	case code >= http.StatusInternalServerError:
+		apiErr = errUnknown
+	default:
+		apiErr.Status = code
+	}
+	tokenFailResponse(context, apiErr, err.Error())
+}
+
+// tokenFailResponse aborts the request with the validateResponse shape in legacy mode which is used for token errors,
+// message is the detail of the problem unless it is the title of the catalog entry
+func tokenFailResponse(context *gin.Context, err apiError, message string) {
+	defer context.Abort()
+	if !legacyErrors(context) {
+		detail := message
+		if detail == err.Title {
+			detail = ""
+		}
+		problemResponse(context, err, detail, nil)
+		return
+	}
+
+	context.JSON(err.Status, validateResponse{
+		Status:       false,
+		ErrorMessage: message,
+		HttpCode:     err.Status,
+		Timestamp:    time.Now().Format(time.RFC3339),
+	})
+}
+
+// accessTokenValidator validates the bearer token of the request, which can be a session token or a personal
+// access token, and stores its claims in the context
+func accessTokenValidator() gin.HandlerFunc {
+	return bearerValidator(authenticateToken)
+}
+
+// sessionValidator is like accessTokenValidator but only accepts session access tokens
+func sessionValidator() gin.HandlerFunc {
+	return bearerValidator(validateSession)
+}
+
+// logoutValidator is like sessionValidator but also accepts the refresh tokens, so a session can be ended with the
+// refresh token of the session cookie
+func logoutValidator() gin.HandlerFunc {
+	return bearerValidator(validateAnySession)
+}
+
+func bearerValidator(validate func(ctx context.Context, r *realm.Realm, token string) (*jwt.VpnbeastClaim, error,
+	int)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token, ok := bearerToken(c)
+		if cookie := c.GetString(sessionCookieKey); cookie != "" {
+			token, ok = cookie, true
+		}
+
+		if !ok {
+			tokenFailResponse(c, errMissingToken, errMissingToken.Title)
+			return
+		}
+
+		claims, err, code := validate(c.Request.Context(), currentRealm(c), token)
+		if err != nil {
+			tokenErrorResponse(c, code, err)
+			return
+		}
+
+		c.Set("claims", claims)
+		c.Next()
+	}
+}
+
+// requireRole aborts the request if the validated token does not carry the role, must be used after
+// accessTokenValidator
+func requireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := c.MustGet("claims").(*jwt.VpnbeastClaim)
+		for _, r := range claims.Roles {
+			if r == role {
+				c.Next()
+				return
+			}
+		}
+
+		logger.Warn("request rejected due to missing role", zap.String("user", claims.Subject),
+			zap.String("role", role))
+		errorResponse(c, errForbidden)
+		c.Abort()
+	}
+}
+
+func logoutHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		claims := context.MustGet("claims").(*jwt.VpnbeastClaim)
+		user, err := authService.Logout(context.Request.Context(), currentRealm(context), claims.Subject)
+		switch err {
+		case auth.ErrUserNotFound:
+			logger.Warn(errNoRowsReturned, zap.String("user", claims.Subject))
+			errorResponse(context, errUserNotFound)
+			context.Abort()
+			return
+		case nil:
+			logger.Info("sessions revoked", zap.String("user", user.UserName))
+			if context.GetString(sessionCookieKey) != "" {
+				clearSessionCookies(context)
+			}
+
+			if isV2(context) {
+				context.JSON(http.StatusOK, logoutResponse{Username: user.UserName})
+				context.Abort()
+				return
+			}
+
+			context.JSON(http.StatusOK, validateResponse{
+				Status:    true,
+				Username:  user.UserName,
+				HttpCode:  http.StatusOK,
+				Timestamp: time.Now().Format(time.RFC3339),
+			})
+			context.Abort()
+			return
+		default:
+			logger.Error("an error occurred while revoking sessions", zap.String("error", err.Error()))
+			errorResponse(context, errUnknown)
+			context.Abort()
+			return
+		}
+	}
+}
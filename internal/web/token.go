@@ -0,0 +1,146 @@
+package web
+
+import (
+	"auth-service/internal/audit"
+	"auth-service/internal/jwt"
+	"auth-service/internal/model"
+	"auth-service/internal/pat"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// currentUser finds the user of the validated token with its roles, writes the error response and returns false if
+// it can not
+func currentUser(context *gin.Context) (*model.User, bool) {
+	claims := context.MustGet("claims").(*jwt.VpnbeastClaim)
+	var user model.User
+	err := db.Preload("Roles").Where(queryUsername, currentRealm(context).Id, claims.Subject).First(&user).Error
+	switch err {
+	case nil:
+		return &user, true
+	case gorm.ErrRecordNotFound:
+		logger.Warn(errNoRowsReturned, zap.String("user", claims.Subject))
+		errorResponse(context, errUserNotFound)
+	default:
+		logger.Error("an error occurred while querying user", zap.String("error", err.Error()))
+		errorResponse(context, errUnknown)
+	}
+
+	context.Abort()
+	return nil, false
+}
+
+func toPersonalAccessTokenResponse(token *model.PersonalAccessToken) personalAccessTokenResponse {
+	return personalAccessTokenResponse{
+		Id:          token.Id,
+		Name:        token.Name,
+		TokenPrefix: token.TokenPrefix,
+		Scopes:      pat.Scopes(token),
+		LastUsedAt:  token.LastUsedAt,
+		ExpiresAt:   token.ExpiresAt,
+		RevokedAt:   token.RevokedAt,
+		CreatedAt:   token.CreatedAt,
+	}
+}
+
+func createTokenHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		req, _ := context.Get("data")
+		tokenReq := req.(personalAccessTokenRequest)
+		user, ok := currentUser(context)
+		if !ok {
+			return
+		}
+
+		var expiresAt *time.Time
+		if tokenReq.ExpiresAt != "" {
+			t, err := time.Parse(time.RFC3339, tokenReq.ExpiresAt)
+			if err != nil {
+				validationResponse(context, []fieldError{invalidField("expiresAt", errInvalidTimestamp)})
+				context.Abort()
+				return
+			}
+			expiresAt = &t
+		}
+
+		token, personalAccessToken, err := pat.Create(db, user, tokenReq.Name, tokenReq.Scopes,
+			expiresAt)
+		switch err {
+		case nil:
+			logger.Info("personal access token created", zap.String("user", user.UserName),
+				zap.Uint("tokenId", personalAccessToken.Id))
+			res := toPersonalAccessTokenResponse(personalAccessToken)
+			res.Token = token
+			context.JSON(http.StatusCreated, res)
+		case pat.ErrInvalidScope:
+			errorResponse(context, errInvalidScope)
+		default:
+			logger.Error("an error occurred while creating personal access token", zap.String("error", err.Error()))
+			errorResponse(context, errUnknown)
+		}
+		context.Abort()
+	}
+}
+
+func listTokensHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		user, ok := currentUser(context)
+		if !ok {
+			return
+		}
+
+		tokens, err := pat.List(db, user.Id)
+		if err != nil {
+			logger.Error("an error occurred while querying personal access tokens", zap.String("error", err.Error()))
+			errorResponse(context, errUnknown)
+			context.Abort()
+			return
+		}
+
+		res := make([]personalAccessTokenResponse, 0, len(tokens))
+		for i := range tokens {
+			res = append(res, toPersonalAccessTokenResponse(&tokens[i]))
+		}
+
+		context.JSON(http.StatusOK, res)
+	}
+}
+
+func revokeTokenHandler() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		id, err := strconv.ParseUint(context.Param("id"), 10, 64)
+		if err != nil {
+			errorResponse(context, errTokenNotFound)
+			context.Abort()
+			return
+		}
+
+		user, ok := currentUser(context)
+		if !ok {
+			return
+		}
+
+		revoked, err := pat.Revoke(db, user.Id, uint(id))
+		if err != nil {
+			logger.Error("an error occurred while revoking personal access token", zap.String("error", err.Error()))
+			errorResponse(context, errUnknown)
+			context.Abort()
+			return
+		}
+
+		if !revoked {
+			errorResponse(context, errTokenNotFound)
+			context.Abort()
+			return
+		}
+
+		logger.Info("personal access token revoked", zap.String("user", user.UserName), zap.Uint64("tokenId", id))
+		recordEvent(context, audit.Event{Type: audit.EventTokenRevoked, Actor: user.UserName, Subject: user.UserName,
+			Details: map[string]string{"tokenId": strconv.FormatUint(id, 10), "scope": "personal_access_token"}})
+		context.Status(http.StatusNoContent)
+	}
+}
@@ -1,12 +1,21 @@
 package web
 
 import (
+	"auth-service/internal/i18n"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/locales"
 	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/es"
+	"github.com/go-playground/locales/tr"
 	ut "github.com/go-playground/universal-translator"
 	"github.com/go-playground/validator/v10"
 	entranslations "github.com/go-playground/validator/v10/translations/en"
+	estranslations "github.com/go-playground/validator/v10/translations/es"
+	trtranslations "github.com/go-playground/validator/v10/translations/tr"
+	"go.uber.org/zap"
+	"reflect"
+	"strings"
 )
 
 var (
@@ -14,11 +23,14 @@ var (
 	trans      ut.Translator
 	uni        *ut.UniversalTranslator
 	v          *validator.Validate
+	catalog    = i18n.NewCatalog()
 )
 
-func init() {
+// initValidator creates the request validator with the english, turkish and spanish translations of the validation
+// errors, and loads the translations of the operator into the message catalog
+func initValidator() error {
 	translator = en.New()
-	uni = ut.New(translator, translator)
+	uni = ut.New(translator, translator, tr.New(), es.New())
 
 	var found bool
 	trans, found = uni.GetTranslator("en")
@@ -31,23 +43,116 @@ func init() {
 	if err != nil {
 		panic(err)
 	}
+
+	trTrans, _ := uni.GetTranslator("tr")
+	if err := trtranslations.RegisterDefaultTranslations(v, trTrans); err != nil {
+		panic(err)
+	}
+
+	esTrans, _ := uni.GetTranslator("es")
+	if err := estranslations.RegisterDefaultTranslations(v, esTrans); err != nil {
+		panic(err)
+	}
+
+	catalog = i18n.NewCatalog()
+	if opts.TranslationsDirectory != "" {
+		if err := catalog.LoadDir(opts.TranslationsDirectory); err != nil {
+			return fmt.Errorf("an error occurred while loading translations: %w", err)
+		}
+	}
+	logger.Info("loaded translations", zap.Strings("locales", catalog.Locales()))
+	return nil
 }
 
-func isValidRequest(context *gin.Context, request interface{}) (bool, []string) {
-	var errSlice []string
+// responseLocale negotiates the locale of the error messages with the Accept-Language header. Legacy responses are
+// always in english, since the clients which rely on them match their messages
+func responseLocale(ctx *gin.Context) string {
+	if legacyErrors(ctx) {
+		return i18n.DefaultLocale
+	}
+
+	return catalog.Negotiate(ctx.GetHeader("Accept-Language"))
+}
+
+// localizedTitle returns the title of the catalog entry in the locale, english title is the fallback
+func localizedTitle(locale string, err apiError) string {
+	if title, ok := catalog.Error(locale, err.Code); ok {
+		return title
+	}
+
+	return err.Title
+}
+
+// translateField returns the message of the validation error in the locale. Messages of the catalog take precedence
+// over the translations of the validator, english translation is the fallback
+func translateField(locale string, e validator.FieldError) string {
+	if message, ok := catalog.Validation(locale, e.Tag(), fieldKind(e.Kind()), e.Field(), e.Param()); ok {
+		return message
+	}
+
+	if t, found := uni.GetTranslator(locale); found {
+		return e.Translate(t)
+	}
+
+	return e.Translate(trans)
+}
+
+// fieldKind groups the kinds of the fields like the validator does for the messages of the length and range tags
+func fieldKind(kind reflect.Kind) string {
+	switch kind {
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return "items"
+	default:
+		return "number"
+	}
+}
+
+// isValidRequest binds the JSON body to the request and validates it. Validation errors carry the JSON names of the
+// fields, the failed validation tags as their codes and the messages in the locale of the response
+func isValidRequest(context *gin.Context, request interface{}) (bool, []fieldError) {
+	var errs []fieldError
 	var err error
+	locale := responseLocale(context)
 	if err = context.ShouldBindJSON(request); err == nil {
 		if err = v.Struct(request); err != nil {
 			for _, e := range err.(validator.ValidationErrors) {
-				errSlice = append(errSlice, e.Translate(trans))
+				errs = append(errs, fieldError{
+					Field:   jsonField(request, e.StructField()),
+					Code:    e.Tag(),
+					Message: translateField(locale, e),
+				})
 			}
-			return false, errSlice
+			return false, errs
+		}
+		return true, errs
+	}
+
+	errs = append(errs, invalidField("", errInvalidJson))
+	return false, errs
+}
+
+// jsonField returns the JSON name of the struct field of the request, index of the dived fields like Scopes[0] is
+// kept
+func jsonField(request interface{}, field string) string {
+	name, index := field, ""
+	if i := strings.IndexByte(field, '['); i >= 0 {
+		name, index = field[:i], field[i:]
+	}
+
+	t := reflect.TypeOf(request)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if f, ok := t.FieldByName(name); ok {
+		if tag := strings.Split(f.Tag.Get("json"), ",")[0]; tag != "" && tag != "-" {
+			name = tag
 		}
-		return true, errSlice
 	}
 
-	errSlice = append(errSlice, "not a valid JSON request!")
-	return false, errSlice
+	return name + index
 }
 
 // https://github.com/go-playground/validator/blob/master/_examples/translations/main.go
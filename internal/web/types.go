@@ -1,19 +1,18 @@
 package web
 
 import (
+	"auth-service/internal/jwt"
 	"auth-service/internal/model"
-	"bytes"
-	"encoding/json"
-	"go.uber.org/zap"
-	"io/ioutil"
-	"net/http"
+	"auth-service/internal/policy"
+	"auth-service/internal/realm"
 	"time"
 )
 
-// authRequest represents the incoming auth request to the auth-service
+// authRequest represents the incoming auth request to the auth-service. Length of the password is not limited here,
+// the password policy of the realm is applied when the password is set
 type authRequest struct {
 	Username string `json:"userName" validate:"required,min=3,max=16"`
-	Password string `json:"password" validate:"required,min=3,max=16"`
+	Password string `json:"password" validate:"required"`
 }
 
 // authRequest represents the response of the auth-service to the auth request
@@ -30,8 +29,8 @@ type authSuccessResponse struct {
 	EmailVerified              bool          `json:"emailVerified"`
 	AccessToken                string        `json:"accessToken"`
 	AccessTokenExpiresAt       string        `json:"accessTokenExpiresAt"`
-	RefreshToken               string        `json:"refreshToken"`
-	RefreshTokenExpiresAt      string        `json:"refreshTokenExpiresAt"`
+	RefreshToken               string        `json:"refreshToken,omitempty"`
+	RefreshTokenExpiresAt      string        `json:"refreshTokenExpiresAt,omitempty"`
 	VerificationCodeCreatedAt  string        `json:"verificationCodeCreatedAt"`
 	VerificationCodeVerifiedAt string        `json:"verificationCodeVerifiedAt"`
 	Roles                      []*model.Role `json:"roles"`
@@ -58,63 +57,206 @@ type validateRequest struct {
 
 // validateResponse represents the request of the encryption-service validate response
 type validateResponse struct {
-	Status       bool     `json:"status"`
-	Username     string   `json:"username,omitempty"`
-	Roles        []string `json:"roles,omitempty"`
-	ErrorMessage string   `json:"errorMessage"`
-	HttpCode     int      `json:"httpCode"`
-	Timestamp    string   `json:"timestamp"`
-}
-
-// encryptRequest represents the request of the encryption-service encrypt request
-type encryptRequest struct {
-	PlainText     string `json:"plainText"`
-	EncryptedText string `json:"encryptedText"`
-}
-
-// encrypt method gets the plainText and makes request to encyrption-service to encrypt plainText
-func (req encryptRequest) encrypt(plainText, encrypted string) (encryptResponse, error) {
-	postBody, err := json.Marshal(encryptRequest{
-		PlainText:     plainText,
-		EncryptedText: encrypted,
-	})
-	if err != nil {
-		logger.Error("an error occurred while marshalling request", zap.String("error", err.Error()))
-		return encryptResponse{}, err
-	}
-
-	responseBody := bytes.NewBuffer(postBody)
-	resp, err := http.Post(opts.EncryptionServiceUrl, "application/json", responseBody)
-	if err != nil {
-		logger.Error("an error occurred while making remote request", zap.String("error", err.Error()))
-		return encryptResponse{}, err
-	}
-
-	defer func() {
-		err := resp.Body.Close()
-		if err != nil {
-			panic(err)
-		}
-	}()
-
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		logger.Error("an error occurred while reading response", zap.String("error", err.Error()))
-		return encryptResponse{}, err
-	}
-
-	var response encryptResponse
-	responseString := string(body)
-	err = json.Unmarshal([]byte(responseString), &response)
-	if err != nil {
-		logger.Error("an error occurred while unmarshaling response", zap.String("error", err.Error()))
-		return encryptResponse{}, err
-	}
-
-	return response, nil
-}
-
-// encryptResponse represents the response of the encryption-service request
-type encryptResponse struct {
-	Status bool `json:"status"`
+	Status       bool                            `json:"status"`
+	Username     string                          `json:"username,omitempty"`
+	Roles        []string                        `json:"roles,omitempty"`
+	Entitlements map[string]jwt.EntitlementClaim `json:"entitlements,omitempty"`
+	ErrorMessage string                          `json:"errorMessage"`
+	HttpCode     int                             `json:"httpCode"`
+	Timestamp    string                          `json:"timestamp"`
+}
+
+// certificateRequest represents the vpn client certificate signing request
+type certificateRequest struct {
+	Csr string `json:"csr" validate:"required"`
+}
+
+// certificateResponse represents the signed vpn client certificate
+type certificateResponse struct {
+	SerialNumber  string `json:"serialNumber"`
+	Certificate   string `json:"certificate"`
+	CaCertificate string `json:"caCertificate"`
+	NotBefore     string `json:"notBefore"`
+	NotAfter      string `json:"notAfter"`
+}
+
+// certificateStatusResponse represents the OCSP like status of a vpn client certificate
+type certificateStatusResponse struct {
+	SerialNumber string `json:"serialNumber"`
+	Status       string `json:"status"`
+	NotAfter     string `json:"notAfter,omitempty"`
+	RevokedAt    string `json:"revokedAt,omitempty"`
+	Timestamp    string `json:"timestamp"`
+}
+
+// entitlementRequest represents the request to assign an entitlement to a user
+type entitlementRequest struct {
+	Value     string `json:"value" validate:"required,max=255"`
+	ExpiresAt string `json:"expiresAt"`
+}
+
+// identityBackendRequest represents the request to assign an identity backend to a user, empty backend means the
+// backend of the realm
+type identityBackendRequest struct {
+	Backend string `json:"backend" validate:"max=64"`
+}
+
+// personalAccessTokenRequest represents the request to create a personal access token
+type personalAccessTokenRequest struct {
+	Name      string   `json:"name" validate:"required,max=255"`
+	Scopes    []string `json:"scopes" validate:"required,min=1,dive,required"`
+	ExpiresAt string   `json:"expiresAt"`
+}
+
+// personalAccessTokenResponse represents a personal access token, plain token is only returned on creation
+type personalAccessTokenResponse struct {
+	Id          uint       `json:"id"`
+	Name        string     `json:"name"`
+	Token       string     `json:"token,omitempty"`
+	TokenPrefix string     `json:"tokenPrefix"`
+	Scopes      []string   `json:"scopes"`
+	LastUsedAt  *time.Time `json:"lastUsedAt"`
+	ExpiresAt   *time.Time `json:"expiresAt"`
+	RevokedAt   *time.Time `json:"revokedAt"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
+
+// authorizeRequest represents the request to check if a token is granted the permissions
+type authorizeRequest struct {
+	Token       string   `json:"token" validate:"required"`
+	Permissions []string `json:"permissions" validate:"required,min=1,dive,required"`
+}
+
+// authorizeResponse represents the authorization decision for the permissions
+type authorizeResponse struct {
+	Allowed            bool     `json:"allowed"`
+	Username           string   `json:"username"`
+	Permissions        []string `json:"permissions"`
+	MissingPermissions []string `json:"missingPermissions,omitempty"`
+	HttpCode           int      `json:"httpCode"`
+	Timestamp          string   `json:"timestamp"`
+}
+
+// permissionRequest represents the request to create a permission
+type permissionRequest struct {
+	Name        string `json:"name" validate:"required,max=128"`
+	Description string `json:"description" validate:"max=255"`
+}
+
+// decisionRequest represents the request to evaluate the policies for an action on a resource
+type decisionRequest struct {
+	Token    string                 `json:"token" validate:"required"`
+	Action   string                 `json:"action" validate:"required"`
+	Resource map[string]interface{} `json:"resource"`
+	Context  map[string]interface{} `json:"context"`
+}
+
+// decisionResponse represents the policy decision along with the explanation
+type decisionResponse struct {
+	Allowed       bool                `json:"allowed"`
+	Username      string              `json:"username"`
+	Action        string              `json:"action"`
+	MatchedPolicy string              `json:"matchedPolicy,omitempty"`
+	Reason        string              `json:"reason"`
+	Evaluations   []policy.Evaluation `json:"evaluations"`
+	HttpCode      int                 `json:"httpCode"`
+	Timestamp     string              `json:"timestamp"`
+}
+
+// realmRequest represents the request to create or update a realm, name is ignored on updates
+type realmRequest struct {
+	Name                       string `json:"name" validate:"max=64"`
+	IssuerUrl                  string `json:"issuerUrl" validate:"max=255"`
+	Hosts                      string `json:"hosts" validate:"max=255"`
+	AccessTokenValidInMinutes  int32  `json:"accessTokenValidInMinutes" validate:"min=0"`
+	RefreshTokenValidInMinutes int32  `json:"refreshTokenValidInMinutes" validate:"min=0"`
+	PasswordMinLength          int    `json:"passwordMinLength" validate:"min=0"`
+	PasswordMaxLength          int    `json:"passwordMaxLength" validate:"min=0"`
+	PasswordRequireDigit       bool   `json:"passwordRequireDigit"`
+	PasswordRequireUpper       bool   `json:"passwordRequireUpper"`
+	PasswordRequireLower       bool   `json:"passwordRequireLower"`
+	PasswordRequireSymbol      bool   `json:"passwordRequireSymbol"`
+	IdentityBackend            string `json:"identityBackend" validate:"max=64"`
+	Enabled                    bool   `json:"enabled"`
+}
+
+// realmResponse represents the public metadata of a realm
+type realmResponse struct {
+	Name                       string               `json:"name"`
+	Issuer                     string               `json:"issuer"`
+	AccessTokenValidInMinutes  int32                `json:"accessTokenValidInMinutes"`
+	RefreshTokenValidInMinutes int32                `json:"refreshTokenValidInMinutes"`
+	PasswordPolicy             realm.PasswordPolicy `json:"passwordPolicy"`
+}
+
+// jsonWebKey represents a RSA public key in RFC 7517 format
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// jsonWebKeySet represents the verification keys of a realm
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+// loginHistoryResponse represents a login attempt of the user, Signals are the reasons the login is flagged as
+// suspicious
+type loginHistoryResponse struct {
+	model.LoginHistory
+	Signals []string `json:"signals"`
+}
+
+// refreshRequest represents the /v2 refresh request, refresh token is sent in the body instead of the Authorization
+// header
+type refreshRequest struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+}
+
+// userResponse represents the user in the /v2 responses, neither the stored tokens nor the verification metadata are
+// exposed and the roles are listed by their names
+type userResponse struct {
+	Id            uint     `json:"id"`
+	Uuid          string   `json:"uuid"`
+	Username      string   `json:"username"`
+	Email         string   `json:"email"`
+	Enabled       bool     `json:"enabled"`
+	EmailVerified bool     `json:"emailVerified"`
+	LastLogin     string   `json:"lastLogin"`
+	Roles         []string `json:"roles"`
+	CreatedAt     string   `json:"createdAt"`
+	UpdatedAt     string   `json:"updatedAt"`
+	Version       uint     `json:"version"`
+}
+
+// tokenPair represents the tokens issued by a login or a refresh
+type tokenPair struct {
+	AccessToken           string `json:"accessToken"`
+	AccessTokenExpiresAt  string `json:"accessTokenExpiresAt"`
+	RefreshToken          string `json:"refreshToken,omitempty"`
+	RefreshTokenExpiresAt string `json:"refreshTokenExpiresAt,omitempty"`
+}
+
+// sessionResponse represents the /v2 response of a login or a refresh
+type sessionResponse struct {
+	User   userResponse `json:"user"`
+	Tokens tokenPair    `json:"tokens"`
+}
+
+// tokenInfoResponse represents the /v2 response of the validate request, scope is the space separated effective
+// permissions of the token
+type tokenInfoResponse struct {
+	Username     string                          `json:"username"`
+	Roles        []string                        `json:"roles"`
+	Entitlements map[string]jwt.EntitlementClaim `json:"entitlements"`
+	Scope        string                          `json:"scope"`
+}
+
+// logoutResponse represents the /v2 response of the logout request
+type logoutResponse struct {
+	Username string `json:"username"`
 }
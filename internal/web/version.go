@@ -0,0 +1,89 @@
+package web
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// apiVersionKey holds the version of the api in the context, routes which are not versioned do not set it
+const apiVersionKey = "apiVersion"
+
+// authV1Sunset is the Sunset header of the /auth routes, it is empty if AUTH_V1_SUNSET_DATE is not configured
+var authV1Sunset string
+
+// initApiVersions parses AUTH_V1_SUNSET_DATE
+func initApiVersions() error {
+	authV1Sunset = ""
+	if opts.AuthV1SunsetDate == "" {
+		return nil
+	}
+
+	date, err := time.Parse("2006-01-02", opts.AuthV1SunsetDate)
+	if err != nil {
+		return fmt.Errorf("an error occurred while parsing AUTH_V1_SUNSET_DATE: %w", err)
+	}
+
+	authV1Sunset = date.UTC().Format(http.TimeFormat)
+	return nil
+}
+
+// apiV2 marks the requests of the /v2 routes, which respond with the lean response model and always with the problems
+// regardless of LEGACY_ERROR_RESPONSES
+func apiV2() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(apiVersionKey, 2)
+		c.Next()
+	}
+}
+
+// isV2 checks if the request is made to a /v2 route
+func isV2(c *gin.Context) bool {
+	return c.GetInt(apiVersionKey) == 2
+}
+
+// deprecatedApi announces the deprecation of the /auth routes with the Deprecation header, the Sunset header if
+// AUTH_V1_SUNSET_DATE is configured, and links the same route of /v2 as the successor
+func deprecatedApi() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Deprecation", "true")
+		if authV1Sunset != "" {
+			c.Header("Sunset", authV1Sunset)
+		}
+
+		if successor := successorPath(c.FullPath(), c.Request.URL.Path); successor != "" {
+			c.Header("Link", "<"+successor+">; rel=\"successor-version\"")
+		}
+		c.Next()
+	}
+}
+
+// successorPath inserts /v2 before the /auth segment of the path
+func successorPath(route, path string) string {
+	base, ok := authPath(route, path)
+	if !ok {
+		return ""
+	}
+
+	i := len(base) - len("/auth")
+	return path[:i] + "/v2" + path[i:]
+}
+
+// authPath returns the path up to and including the /auth segment. Segment is located in the route instead of the
+// path since realm names and the path parameters can be auth as well
+func authPath(route, path string) (string, bool) {
+	i := strings.Index(route, "/auth/")
+	if i < 0 {
+		return "", false
+	}
+
+	segments := strings.Split(path, "/")
+	n := strings.Count(route[:i], "/") + 2
+	if len(segments) < n {
+		return "", false
+	}
+
+	return strings.Join(segments[:n], "/"), true
+}
@@ -0,0 +1,74 @@
+package web
+
+import (
+	"auth-service/internal/options"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSuccessorPath(t *testing.T) {
+	cases := []struct {
+		route, path, expected string
+	}{
+		{"/auth/whoami", "/auth/whoami", "/v2/auth/whoami"},
+		{"/realms/:realm/auth/tokens/:id", "/realms/auth/auth/tokens/3", "/realms/auth/v2/auth/tokens/3"},
+		{"/auth/oidc/:provider/login", "/auth/oidc/auth/login", "/v2/auth/oidc/auth/login"},
+		{"/admin/roles", "/admin/roles", ""},
+	}
+
+	for _, c := range cases {
+		if successor := successorPath(c.route, c.path); successor != c.expected {
+			t.Errorf("expected %q for %s, got %q", c.expected, c.path, successor)
+		}
+	}
+}
+
+func TestDeprecatedApi(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	opts = &options.AuthServiceOptions{AuthV1SunsetDate: "2027-06-30"}
+	if err := initApiVersions(); err != nil {
+		t.Fatal(err)
+	}
+
+	router := gin.New()
+	router.GET("/realms/:realm/auth/whoami", deprecatedApi(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	rec := serve(router, "/realms/acme/auth/whoami", nil)
+	if rec.Header().Get("Deprecation") != "true" || rec.Header().Get("Sunset") != "Wed, 30 Jun 2027 00:00:00 GMT" ||
+		rec.Header().Get("Link") != `</realms/acme/v2/auth/whoami>; rel="successor-version"` {
+		t.Errorf("expected the deprecation headers, got %v", rec.Header())
+	}
+
+	opts.AuthV1SunsetDate = "30.06.2027"
+	if err := initApiVersions(); err == nil {
+		t.Error("expected an error for the malformed sunset date")
+	}
+}
+
+func TestV2Problems(t *testing.T) {
+	router := newErrorRouter(true)
+	if err := initValidator(); err != nil {
+		t.Fatal(err)
+	}
+	router.POST("/v2/auth/refresh", apiV2(), refreshRequestValidator())
+
+	req := httptest.NewRequest(http.MethodPost, "/v2/auth/refresh", strings.NewReader(`{}`))
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	var p problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+
+	if rec.Header().Get("Content-Type") != problemContentType || len(p.Errors) != 1 ||
+		p.Errors[0].Field != "refreshToken" {
+		t.Errorf("expected a problem regardless of the legacy responses, got %s %+v",
+			rec.Header().Get("Content-Type"), p)
+	}
+}
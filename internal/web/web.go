@@ -1,59 +1,241 @@
 package web
 
 import (
+	"auth-service/internal/audit"
+	"auth-service/internal/auth"
+	"auth-service/internal/ca"
+	"auth-service/internal/encryption"
+	"auth-service/internal/health"
 	"auth-service/internal/options"
+	"auth-service/internal/policy"
+	"auth-service/internal/ratelimit"
+	"auth-service/internal/realm"
+	"auth-service/internal/revocation"
+	"context"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	commons "github.com/vpnbeast/golang-commons"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 	"net/http"
 	"time"
 )
 
 var (
-	logger *zap.Logger
-	opts   *options.AuthServiceOptions
+	logger    = commons.GetLogger()
+	opts      *options.AuthServiceOptions
+	db        *gorm.DB
+	authority *ca.CertificateAuthority
+	prober    *health.Prober
+	// revocationListeners are notified in the same transaction whenever the sessions of a user are revoked
+	revocationListeners []revocation.Listener
 )
 
-func init() {
-	logger = commons.GetLogger()
-	opts = options.GetAuthServiceOptions()
+// Dependencies represents the resources which are created in main and shared by the handlers, Authority is nil if
+// the certificate authority is not configured, audit events are dropped if Auditor is nil and the logins are neither
+// recorded nor assessed if Guard is nil. Requests are not limited if Limiter is nil. Revocation listeners are notified
+// in the same transaction whenever the sessions of a user are revoked
+type Dependencies struct {
+	Options    *options.AuthServiceOptions
+	Db         *gorm.DB
+	Authority  *ca.CertificateAuthority
+	Encryption *encryption.Client
+	Prober     *health.Prober
+	Auditor    audit.Recorder
+	Guard      auth.LoginGuard
+	Limiter    *ratelimit.Limiter
+	Revocation []revocation.Listener
 }
 
 func registerHandlers(router *gin.Engine) {
+	router.Use(requestId())
 	// Recovery middleware recovers from any panics and writes a 500 if there was one.
 	router.Use(gin.CustomRecovery(func(c *gin.Context, recovered interface{}) {
-		if err, ok := recovered.(string); ok {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"code":      http.StatusInternalServerError,
-				"error":     err,
-				"timestamp": time.Now(),
-			})
-		}
-		c.AbortWithStatus(http.StatusInternalServerError)
+		logger.Error("recovered from panic", zap.Any("panic", recovered),
+			zap.String("requestId", c.GetHeader(requestIdHeader)))
+		errorResponse(c, errUnknown)
+		c.Abort()
 	}))
+	router.Use(auditRequest())
 	healthRoutes := router.Group("/health")
 	{
 		healthRoutes.GET("/ping", pingHandler())
 	}
-	authRoutes := router.Group("/auth")
+	router.GET("/livez", gin.WrapF(prober.Handler(health.Live)))
+	router.GET("/readyz", gin.WrapF(prober.Handler(health.Ready)))
+	router.GET("/startupz", gin.WrapF(prober.Handler(health.Startup)))
+	registerDocsHandlers(router)
+	registerRealmHandlers(&router.RouterGroup)
+	registerRealmHandlers(router.Group("/realms/:realm"))
+}
+
+// registerRealmHandlers registers the realm scoped handlers, realm is selected by the realm path parameter if the
+// group has one or by the host header
+func registerRealmHandlers(group *gin.RouterGroup) {
+	registerAuthHandlers(group.Group("/auth", deprecatedApi(), realmResolver()), false)
+	registerAuthHandlers(group.Group("/v2/auth", apiV2(), realmResolver()), true)
+	vpnRoutes := group.Group("/vpn", certificateAuthorityEnabled(), realmResolver())
+	{
+		vpnRoutes.POST("/certificates", accessTokenValidator(), certificateRequestValidator(),
+			signCertificateHandler())
+		vpnRoutes.GET("/certificates/:serial/status", certificateStatusHandler())
+		vpnRoutes.GET("/ca", caCertificateHandler())
+		vpnRoutes.GET("/crl", crlHandler())
+	}
+	adminRoutes := group.Group("/admin", realmResolver(), accessTokenValidator(), requireRole(opts.AdminRole))
 	{
-		// TODO: single request validator middleware instead of 2 seperate
-		authRoutes.POST("/authenticate", authRequestValidator(), authenticateHandler())
-		authRoutes.POST("/validate", validateRequestValidator(), validateHandler())
-		// TODO: should below /refresh and /whoami endpoints should be GET or POST?
-		authRoutes.GET("/refresh", refreshHandler())
-		authRoutes.GET("/whoami", whoamiHandler())
+		adminRoutes.GET("/users/:uuid/entitlements", listEntitlementsHandler())
+		adminRoutes.PUT("/users/:uuid/entitlements/:name", entitlementRequestValidator(), assignEntitlementHandler())
+		adminRoutes.DELETE("/users/:uuid/entitlements/:name", removeEntitlementHandler())
+		adminRoutes.PUT("/users/:uuid/identity-backend", identityBackendRequestValidator(),
+			assignIdentityBackendHandler())
+		adminRoutes.GET("/roles", listRolesHandler())
+		adminRoutes.PUT("/roles/:role/permissions/:permission", grantPermissionHandler())
+		adminRoutes.DELETE("/roles/:role/permissions/:permission", revokePermissionHandler())
+		adminRoutes.PUT("/roles/:role/parents/:parent", addParentRoleHandler())
+		adminRoutes.DELETE("/roles/:role/parents/:parent", removeParentRoleHandler())
+		adminRoutes.GET("/audit", listAuditEventsHandler())
+	}
+	scimRoutes := group.Group("/scim/v2", realmResolver(), accessTokenValidator(), requireRole(scimRole()))
+	{
+		scimRoutes.GET("/ServiceProviderConfig", scimServiceProviderConfigHandler())
+		scimRoutes.GET("/Users", scimListUsersHandler())
+		scimRoutes.POST("/Users", scimCreateUserHandler())
+		scimRoutes.GET("/Users/:id", scimGetUserHandler())
+		scimRoutes.PUT("/Users/:id", scimReplaceUserHandler())
+		scimRoutes.PATCH("/Users/:id", scimPatchUserHandler())
+		scimRoutes.DELETE("/Users/:id", scimDeleteUserHandler())
+		scimRoutes.GET("/Groups", scimListGroupsHandler())
+		scimRoutes.POST("/Groups", scimCreateGroupHandler())
+		scimRoutes.GET("/Groups/:id", scimGetGroupHandler())
+		scimRoutes.PUT("/Groups/:id", scimReplaceGroupHandler())
+		scimRoutes.PATCH("/Groups/:id", scimPatchGroupHandler())
+		scimRoutes.DELETE("/Groups/:id", scimDeleteGroupHandler())
+	}
+	// permissions are shared by the realms, so only the admins of the default realm can manage them
+	permissionRoutes := adminRoutes.Group("/permissions", requireDefaultRealm())
+	{
+		permissionRoutes.GET("", listPermissionsHandler())
+		permissionRoutes.POST("", permissionRequestValidator(), createPermissionHandler())
+		permissionRoutes.DELETE("/:permission", deletePermissionHandler())
+	}
+	realmRoutes := adminRoutes.Group("/realms", requireDefaultRealm())
+	{
+		realmRoutes.GET("", listRealmsHandler())
+		realmRoutes.POST("", realmRequestValidator(), createRealmHandler())
+		realmRoutes.PUT("/:name", realmRequestValidator(), updateRealmHandler())
+		realmRoutes.POST("/:name/keys", rotateRealmKeyHandler())
 	}
 }
 
-// InitServer initializes *http.Server with provided parameters
-func InitServer(router *gin.Engine) *http.Server {
+// registerAuthHandlers registers the handlers of /auth or /v2/auth in the group, handlers respond with the model of
+// the api version. Refresh requires POST with the refresh token in the body on /v2 since it rotates the tokens
+func registerAuthHandlers(authRoutes *gin.RouterGroup, v2 bool) {
+	// TODO: single request validator middleware instead of 2 seperate
+	authRoutes.POST("/authenticate", authRequestValidator(), rateLimit("/auth/authenticate"), authenticateHandler())
+	authRoutes.POST("/validate", validateRequestValidator(), rateLimit("/auth/validate"), validateHandler())
+	authRoutes.POST("/authorize", authorizeRequestValidator(), authorizeHandler())
+	authRoutes.POST("/decide", decisionRequestValidator(), decideHandler())
+	if v2 {
+		authRoutes.POST("/refresh", sessionCookieAuth(), refreshRequestValidator(), rateLimit("/auth/refresh"),
+			refreshHandler())
+	} else {
+		authRoutes.GET("/refresh", sessionCookieAuth(), rateLimit("/auth/refresh"), refreshHandler())
+	}
+	authRoutes.GET("/whoami", whoamiHandler())
+	authRoutes.GET("/login-history", sessionValidator(), loginHistoryHandler())
+	authRoutes.POST("/logout", sessionCookieAuth(), logoutValidator(), logoutHandler())
+	// personal access tokens can only be managed with a session token
+	authRoutes.POST("/tokens", sessionValidator(), personalAccessTokenRequestValidator(), createTokenHandler())
+	authRoutes.GET("/tokens", sessionValidator(), listTokensHandler())
+	authRoutes.DELETE("/tokens/:id", sessionValidator(), revokeTokenHandler())
+	authRoutes.GET("/realm", realmHandler())
+	authRoutes.GET("/jwks", jwksHandler())
+	authRoutes.GET("/oidc/:provider/login", oidcLoginHandler())
+	authRoutes.GET("/oidc/:provider/callback", oidcCallbackHandler())
+}
+
+// initPolicyEngine loads the policies from the database and the policy directory if configured, and keeps them up
+// to date in the background until ctx is done
+func initPolicyEngine(ctx context.Context) error {
+	sources := []policy.Source{policy.DbSource{Db: db}}
+	var dirs []string
+	if opts.PolicyDirectory != "" {
+		sources = append(sources, policy.FileSource{Dir: opts.PolicyDirectory})
+		dirs = append(dirs, opts.PolicyDirectory)
+	}
+
+	var err error
+	policyEngine, err = policy.NewEngine(logger, sources...)
+	if err != nil {
+		return fmt.Errorf("an error occurred while initializing policy engine: %w", err)
+	}
+
+	interval := time.Duration(opts.PolicyReloadIntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	go policyEngine.Watch(ctx, interval, dirs...)
+	return nil
+}
+
+// InitServer initializes *http.Server with provided parameters, background work like reloading the policies runs
+// until ctx is done. Errors of the configuration are returned instead of exiting, so the caller can release the
+// resources it created
+func InitServer(ctx context.Context, router *gin.Engine, deps Dependencies) (*http.Server, error) {
+	opts = deps.Options
+	db = deps.Db
+	authority = deps.Authority
+	prober = deps.Prober
+	limiter = deps.Limiter
+	revocationListeners = deps.Revocation
+	if deps.Auditor != nil {
+		auditor = deps.Auditor
+	}
+
+	ttl := time.Duration(opts.RealmCacheTtlSeconds) * time.Second
+	if ttl <= 0 {
+		ttl = 30 * time.Second
+	}
+	realms = realm.NewRegistry(db, ttl, realm.Defaults{
+		Issuer:                     opts.Issuer,
+		PrivateKey:                 opts.PrivateKey,
+		PublicKey:                  opts.PublicKey,
+		AccessTokenValidInMinutes:  int32(opts.AccessTokenValidInMinutes),
+		RefreshTokenValidInMinutes: int32(opts.RefreshTokenValidInMinutes),
+	})
+
+	for _, initialize := range []func() error{initValidator, initApiVersions, initDocs, initFederation} {
+		if err := initialize(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := initIdentityBackends(deps.Encryption); err != nil {
+		return nil, err
+	}
+
+	if err := initPolicyEngine(ctx); err != nil {
+		return nil, err
+	}
+
+	authService = auth.NewAuthService(auth.NewGormStore(db, revocationListeners...), auth.RealmSigner,
+		auth.NewBackendVerifier(db, identityBackends), auditor, deps.Guard, time.Now)
 	registerHandlers(router)
 	return &http.Server{
 		Handler:      router,
 		Addr:         fmt.Sprintf(":%d", opts.ServerPort),
 		WriteTimeout: time.Duration(int32(opts.WriteTimeoutSeconds)) * time.Second,
 		ReadTimeout:  time.Duration(int32(opts.ReadTimeoutSeconds)) * time.Second,
-	}
+	}, nil
+}
+
+// Realms returns the realm registry shared with the gRPC server, it is nil until InitServer is called
+func Realms() *realm.Registry {
+	return realms
+}
+
+// AuthService returns the auth service shared with the gRPC server, it is nil until InitServer is called
+func AuthService() *auth.AuthService {
+	return authService
 }